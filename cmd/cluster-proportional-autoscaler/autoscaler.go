@@ -17,16 +17,21 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"k8s.io/component-base/cli/flag"
 
 	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/cmd/cluster-proportional-autoscaler/options"
 	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
 	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/version"
 
-	"github.com/golang/glog"
 	"github.com/spf13/pflag"
 )
 
@@ -34,24 +39,124 @@ func main() {
 	config := options.NewAutoScalerConfig()
 	config.AddFlags(pflag.CommandLine)
 	flag.InitFlags()
+	if err := options.ApplyEnv(pflag.CommandLine); err != nil {
+		logging.Errorf("%v", err)
+		os.Exit(1)
+	}
 
 	if config.PrintVer {
 		fmt.Printf("%s\n", version.VERSION)
 		os.Exit(0)
 	}
 
+	if err := logging.SetFormat(config.LogFormat); err != nil {
+		logging.Errorf("%v", err)
+		os.Exit(1)
+	}
+
+	if config.ValidateParamsFile != "" {
+		os.Exit(validateParamsFile(config.ValidateParamsFile, config.ControllerType))
+	}
+
+	if config.Simulate {
+		os.Exit(simulate(config))
+	}
+
 	// Perform further validation of flags.
 	if err := config.ValidateFlags(); err != nil {
-		glog.Errorf("%v", err)
+		logging.Errorf("%v", err)
 		os.Exit(1)
 	}
 
-	glog.V(0).Infof("Scaling Namespace: %s, Target: %s", config.Namespace, config.Target)
+	logging.V(0).Infof("Scaling Namespace: %s, Targets: %v", config.Namespace, config.Targets)
+	if config.ConfigMapNamespace != "" && config.ConfigMapNamespace != config.Namespace {
+		logging.V(0).Infof("ConfigMap Namespace: %s", config.ConfigMapNamespace)
+	}
 	scaler, err := autoscaler.NewAutoScaler(config)
 	if err != nil {
-		glog.Errorf("%v", err)
+		logging.Errorf("%v", err)
 		os.Exit(1)
 	}
+	// Stop autoscaling on SIGTERM, giving the poll in progress the grace
+	// period to complete.
+	ctx, cancel := context.WithCancel(context.Background())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		gracePeriod := time.Second * time.Duration(config.ShutdownGraceSeconds)
+		logging.V(0).Infof("Received SIGTERM, shutting down within %v", gracePeriod)
+		cancel()
+		time.Sleep(gracePeriod)
+		logging.Errorf("The poll in progress did not complete within %v, exiting", gracePeriod)
+		os.Exit(1)
+	}()
+	// Re-read the params and poll right away on SIGHUP.
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	go func() {
+		for range hupCh {
+			logging.V(0).Infof("Received SIGHUP, reloading the params")
+			scaler.Reload()
+		}
+	}()
+
 	// Begin autoscaling.
-	scaler.Run()
+	scaler.Run(ctx)
+	logging.V(0).Infof("Autoscaler stopped")
+}
+
+// validateParamsFile validates the params in the file as they would be at
+// runtime with the given --controller-type, and returns the exit code.
+func validateParamsFile(path, mode string) int {
+	params, err := options.LoadParamsFile(path)
+	if err == nil {
+		err = autoscaler.ValidateParams(params, mode)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return 1
+	}
+	fmt.Printf("%s: OK\n", path)
+	return 0
+}
+
+// simulate prints the replicas recommended by the default params for the
+// simulated cluster sizes, and returns the exit code.
+func simulate(config *options.AutoScalerConfig) int {
+	params := map[string]string(config.DefaultParams)
+	if config.DefaultParamsFile != "" {
+		if len(params) > 0 {
+			fmt.Fprintf(os.Stderr, "--default-params and --default-params-file cannot both be set\n")
+			return 1
+		}
+		var err error
+		if params, err = options.LoadParamsFile(config.DefaultParamsFile); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+	}
+	if len(params) == 0 {
+		fmt.Fprintf(os.Stderr, "--simulate needs the params from --default-params or --default-params-file\n")
+		return 1
+	}
+	nodes, cores := config.SimulateNodes.Values(), config.SimulateCores.Values()
+	if len(nodes) == 0 {
+		nodes = []int{0}
+	}
+	if len(cores) == 0 {
+		cores = []int{0}
+	}
+	results, err := autoscaler.Simulate(params, nodes, cores)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NODES\tCORES\tREPLICAS\tBINDING TERM")
+	for _, r := range results {
+		fmt.Fprintf(w, "%d\t%d\t%d\t%s\n", r.Nodes, r.Cores, r.Expected.Replicas, r.Expected.BindingTerm)
+	}
+	w.Flush()
+	return 0
 }
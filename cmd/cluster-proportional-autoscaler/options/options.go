@@ -20,52 +20,480 @@ package options
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller/plugin"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
 
-	"github.com/golang/glog"
 	"github.com/spf13/pflag"
 )
 
+// Behaviors when the ConfigMap is deleted at runtime.
+const (
+	// OnConfigMapMissingRecreateDefault recreates the ConfigMap with the
+	// default params, or fails the poll if there are none.
+	OnConfigMapMissingRecreateDefault = "recreate-default"
+	// OnConfigMapMissingHold keeps scaling with the last known params.
+	OnConfigMapMissingHold = "hold"
+	// OnConfigMapMissingFail fails the poll.
+	OnConfigMapMissingFail = "fail"
+)
+
+// Behaviors when the desired replicas of a target match no pods.
+const (
+	// RequireRunningPodsOff doesn't check the pods of the targets.
+	RequireRunningPodsOff = "off"
+	// RequireRunningPodsWarn logs a warning and records an event.
+	RequireRunningPodsWarn = "warn"
+	// RequireRunningPodsRefuse refuses to scale the target.
+	RequireRunningPodsRefuse = "refuse"
+)
+
+// Formats of the params in the ConfigMap.
+const (
+	// ParamsFormatJSON only accepts JSON params.
+	ParamsFormatJSON = "json"
+	// ParamsFormatYAML accepts YAML params, as well as JSON ones.
+	ParamsFormatYAML = "yaml"
+)
+
 // AutoScalerConfig configures and runs an autoscaler server
 type AutoScalerConfig struct {
-	Target            string
-	ConfigMap         string
-	Namespace         string
-	DefaultParams     configMapData
-	PollPeriodSeconds int
-	PrintVer          bool
-	NodeLabels        string
-	MaxSyncFailures   int
+	Targets                       []string
+	TargetNamespaceSelector       string
+	PinTargetUID                  bool
+	TargetWait                    bool
+	TargetBounds                  map[string]TargetBounds
+	ConfigMap                     string
+	ConfigMapNamespace            string
+	ConfigMapKey                  string
+	Secret                        string
+	ActiveProfile                 string
+	Namespace                     string
+	DefaultParams                 configMapData
+	DefaultParamsFile             string
+	OnConfigMapMissing            string
+	ParamsFormat                  string
+	ControllerType                string
+	PollPeriodSeconds             int
+	PollJitterFactor              float64
+	ReactiveNodeDelta             int
+	ConfigMapResyncSeconds        int
+	NodeCountSmoothingWindow      int
+	MaxNodeDropFraction           float64
+	MaxNodeDropHoldSeconds        int
+	DrainBoostReplicas            int
+	DrainBoostSeconds             int
+	PrintVer                      bool
+	NodeLabels                    string
+	NodeFieldSelector             string
+	IgnoreTaintedNodes            bool
+	ToleratedTaintKeys            []string
+	CountReadyNodesOnly           bool
+	ExcludeControlPlaneNodes      bool
+	MaxCoresPerNode               int
+	NodeWeightLabel               string
+	NodeWeights                   nodeWeights
+	UnschedulableNodeWeight       float64
+	BackendSelector               string
+	CountPendingPods              bool
+	CountNamespaces               bool
+	NamespaceSelector             string
+	CoresNodeSelector             string
+	MemoryNodeSelector            string
+	PodsNodeSelector              string
+	GPUsNodeSelector              string
+	CoresSource                   string
+	DiscoveryRefreshSeconds       int
+	KubeAPIQPS                    float32
+	KubeAPIBurst                  int
+	KubeAPITimeout                time.Duration
+	MaxSyncFailures               int
+	GPUResourceName               string
+	ScaleResources                scaleResources
+	ScaleDownStabilizationSeconds int
+	MaxReplicaChangePerPoll       int
+	AbsoluteMaxReplicas           int
+	MaxScaleDownPercent           int
+	MaxScaleUpPercent             int
+	MaxConsecutiveScaleUps        int
+	MinReplicasPerZone            int
+	MaxReplicasFromMemoryRequests bool
+	MinSecondsBetweenScales       int
+	DecayGuardSeconds             int
+	StartupDelaySeconds           int
+	RespectPDB                    bool
+	RequireRunningPods            string
+	NoPodsGraceSeconds            int
+	AnnotateTarget                bool
+	ScaleWebhookURL               string
+	ScaleWebhookAuthHeader        string
+	RecommendationSinkURL         string
+	OTLPEndpoint                  string
+	Timezone                      string
+	MetricsBindAddress            string
+	HealthBindAddress             string
+	GRPCHealthBindAddress         string
+	LeaderElect                   bool
+	LeaderElectLeaseName          string
+	LeaderElectNamespace          string
+	DryRun                        bool
+	ValidateParamsFile            string
+	Simulate                      bool
+	SimulateNodes                 intRange
+	SimulateCores                 intRange
+	LogFormat                     string
+	LogNoopCycles                 bool
+	ShutdownGraceSeconds          int
 }
 
 // NewAutoScalerConfig returns a Autoscaler config
 func NewAutoScalerConfig() *AutoScalerConfig {
 	return &AutoScalerConfig{
-		Namespace:         os.Getenv("MY_POD_NAMESPACE"),
-		PollPeriodSeconds: 10,
-		PrintVer:          false,
+		Namespace:            os.Getenv("MY_POD_NAMESPACE"),
+		PollPeriodSeconds:    10,
+		PrintVer:             false,
+		GPUResourceName:      "nvidia.com/gpu",
+		Timezone:             "UTC",
+		CoresSource:          k8sclient.ResourceSourceAllocatable,
+		KubeAPIQPS:           rest.DefaultQPS,
+		KubeAPIBurst:         rest.DefaultBurst,
+		LeaderElectLeaseName: "cluster-proportional-autoscaler",
+		LogFormat:            logging.FormatText,
+		HealthBindAddress:    ":8080",
+		ShutdownGraceSeconds: 10,
+		OnConfigMapMissing:   OnConfigMapMissingRecreateDefault,
+		ParamsFormat:         ParamsFormatJSON,
+		RequireRunningPods:   RequireRunningPodsOff,
+		NoPodsGraceSeconds:   300,
 	}
 }
 
 // ValidateFlags validates whether flags are set up correctly
 func (c *AutoScalerConfig) ValidateFlags() error {
 	var errorsFound bool
-	c.Target = strings.ToLower(c.Target)
-	if !isTargetFormatValid(c.Target) {
+	if len(c.Targets) == 0 {
+		errorsFound = true
+		logging.Errorf("--target parameter cannot be empty")
+	}
+	seenTargets := make(map[string]bool)
+	for i := range c.Targets {
+		target, bounds, err := parseTargetBounds(strings.ToLower(c.Targets[i]))
+		if err != nil {
+			errorsFound = true
+			logging.Errorf("--target %s: %v", c.Targets[i], err)
+			continue
+		}
+		c.Targets[i] = target
+		if bounds != nil {
+			if c.TargetBounds == nil {
+				c.TargetBounds = make(map[string]TargetBounds)
+			}
+			c.TargetBounds[target] = *bounds
+		}
+		if !isTargetFormatValid(c.Targets[i]) {
+			errorsFound = true
+		}
+		if seenTargets[c.Targets[i]] {
+			errorsFound = true
+			logging.Errorf("--target %s specified more than once", c.Targets[i])
+		}
+		seenTargets[c.Targets[i]] = true
+		if c.TargetNamespaceSelector != "" {
+			if err := k8sclient.ValidateDiscoverableTarget(c.Targets[i]); err != nil {
+				errorsFound = true
+				logging.Errorf("--target-namespace-selector: %v", err)
+			}
+		}
+	}
+	if c.TargetNamespaceSelector != "" {
+		if _, err := labels.Parse(c.TargetNamespaceSelector); err != nil {
+			errorsFound = true
+			logging.Errorf("--target-namespace-selector is malformed: %v", err)
+		}
+	}
+	if c.NodeFieldSelector != "" {
+		if _, err := fields.ParseSelector(c.NodeFieldSelector); err != nil {
+			errorsFound = true
+			logging.Errorf("--node-field-selector is malformed: %v", err)
+		}
+	}
+	if c.DefaultParamsFile != "" {
+		if len(c.DefaultParams) > 0 {
+			errorsFound = true
+			logging.Errorf("--default-params and --default-params-file cannot both be set")
+		} else if params, err := LoadParamsFile(c.DefaultParamsFile); err != nil {
+			errorsFound = true
+			logging.Errorf("--default-params-file cannot be loaded: %v", err)
+		} else {
+			c.DefaultParams = params
+		}
+	}
+	if c.ControllerType != "" {
+		if err := plugin.ValidateMode(c.ControllerType); err != nil {
+			errorsFound = true
+			logging.Errorf("--controller-type: %v", err)
+		} else if _, ok := c.DefaultParams[c.ControllerType]; len(c.DefaultParams) > 0 && !ok {
+			errorsFound = true
+			logging.Errorf("--controller-type is %s but the default params have no %s key", c.ControllerType, c.ControllerType)
+		}
+	}
+	if c.MetricsBindAddress != "" {
+		if err := validateBindAddress(c.MetricsBindAddress); err != nil {
+			errorsFound = true
+			logging.Errorf("--metrics-bind-address is malformed: %v", err)
+		}
+	}
+	if c.HealthBindAddress != "" {
+		if err := validateBindAddress(c.HealthBindAddress); err != nil {
+			errorsFound = true
+			logging.Errorf("--health-bind-address is malformed: %v", err)
+		}
+	}
+	if c.GRPCHealthBindAddress != "" {
+		if err := validateBindAddress(c.GRPCHealthBindAddress); err != nil {
+			errorsFound = true
+			logging.Errorf("--grpc-health-bind-address is malformed: %v", err)
+		}
+	}
+	if c.BackendSelector != "" {
+		if _, err := labels.Parse(c.BackendSelector); err != nil {
+			errorsFound = true
+			logging.Errorf("--backend-selector is malformed: %v", err)
+		}
+	}
+	if c.NamespaceSelector != "" {
+		if !c.CountNamespaces {
+			errorsFound = true
+			logging.Errorf("--namespace-selector requires --count-namespaces")
+		} else if _, err := labels.Parse(c.NamespaceSelector); err != nil {
+			errorsFound = true
+			logging.Errorf("--namespace-selector is malformed: %v", err)
+		}
+	}
+	for _, s := range []struct{ flag, selector string }{
+		{"cores-node-selector", c.CoresNodeSelector},
+		{"memory-node-selector", c.MemoryNodeSelector},
+		{"pods-node-selector", c.PodsNodeSelector},
+		{"gpus-node-selector", c.GPUsNodeSelector},
+	} {
+		if _, err := labels.Parse(s.selector); err != nil {
+			errorsFound = true
+			logging.Errorf("--%s is malformed: %v", s.flag, err)
+		}
+	}
+	if c.ScaleWebhookURL != "" {
+		if u, err := url.ParseRequestURI(c.ScaleWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			errorsFound = true
+			logging.Errorf("--scale-webhook-url must be an http or https URL")
+		}
+	}
+	if c.RecommendationSinkURL != "" {
+		if u, err := url.ParseRequestURI(c.RecommendationSinkURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			errorsFound = true
+			logging.Errorf("--recommendation-sink-url must be an http or https URL")
+		}
+	}
+	if c.OTLPEndpoint != "" {
+		if u, err := url.ParseRequestURI(c.OTLPEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			errorsFound = true
+			logging.Errorf("--otlp-endpoint must be an http or https URL")
+		}
+	}
+	if c.ScaleWebhookAuthHeader != "" {
+		if c.ScaleWebhookURL == "" {
+			errorsFound = true
+			logging.Errorf("--scale-webhook-auth-header requires --scale-webhook-url")
+		}
+		if parts := strings.SplitN(c.ScaleWebhookAuthHeader, ":", 2); len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			errorsFound = true
+			logging.Errorf("--scale-webhook-auth-header must be in the format <name>: <value>")
+		}
+	}
+	if c.DiscoveryRefreshSeconds < 0 {
+		errorsFound = true
+		logging.Errorf("--discovery-refresh-seconds cannot be negative")
+	}
+	if c.KubeAPITimeout < 0 {
+		errorsFound = true
+		logging.Errorf("--kube-api-timeout cannot be negative")
+	}
+	if c.KubeAPIQPS <= 0 {
+		errorsFound = true
+		logging.Errorf("--kube-api-qps must be greater than 0")
+	}
+	if c.KubeAPIBurst <= 0 {
 		errorsFound = true
+		logging.Errorf("--kube-api-burst must be greater than 0")
 	}
-	if c.ConfigMap == "" {
+	if !logging.IsValidFormat(c.LogFormat) {
 		errorsFound = true
-		glog.Errorf("--configmap parameter cannot be empty")
+		logging.Errorf("--log-format must be %s or %s", logging.FormatText, logging.FormatJSON)
+	}
+	if c.ConfigMap == "" && c.Secret == "" {
+		errorsFound = true
+		logging.Errorf("--configmap parameter cannot be empty")
+	}
+	if c.ConfigMap != "" && c.Secret != "" {
+		errorsFound = true
+		logging.Errorf("--configmap and --secret are mutually exclusive")
 	}
 	if c.Namespace == "" {
 		errorsFound = true
-		glog.Errorf("--namespace parameter not set and failed to fallback")
+		logging.Errorf("--namespace parameter not set and failed to fallback")
 	}
 	if c.PollPeriodSeconds < 1 {
 		errorsFound = true
-		glog.Errorf("--poll-period-seconds cannot be less than 1")
+		logging.Errorf("--poll-period-seconds cannot be less than 1")
+	}
+	if c.PollJitterFactor < 0 || c.PollJitterFactor > 1 {
+		errorsFound = true
+		logging.Errorf("--poll-jitter-factor must be between 0 and 1")
+	}
+	if c.ReactiveNodeDelta < 0 {
+		errorsFound = true
+		logging.Errorf("--reactive-node-delta cannot be negative")
+	}
+	if c.ConfigMapResyncSeconds < 0 {
+		errorsFound = true
+		logging.Errorf("--configmap-resync-seconds cannot be negative")
+	}
+	if c.NodeCountSmoothingWindow < 0 {
+		errorsFound = true
+		logging.Errorf("--node-count-smoothing-window cannot be negative")
+	}
+	if c.MaxNodeDropFraction < 0 || c.MaxNodeDropFraction >= 1 {
+		errorsFound = true
+		logging.Errorf("--max-node-drop-fraction must be at least 0 and lower than 1")
+	}
+	if c.MaxNodeDropHoldSeconds < 0 {
+		errorsFound = true
+		logging.Errorf("--max-node-drop-hold-seconds cannot be negative")
+	}
+	if c.DrainBoostReplicas < 0 {
+		errorsFound = true
+		logging.Errorf("--drain-boost-replicas cannot be negative")
+	}
+	if c.DrainBoostSeconds < 0 {
+		errorsFound = true
+		logging.Errorf("--drain-boost-seconds cannot be negative")
+	}
+	if (c.DrainBoostReplicas > 0) != (c.DrainBoostSeconds > 0) {
+		errorsFound = true
+		logging.Errorf("--drain-boost-replicas and --drain-boost-seconds must be set together")
+	}
+	if c.MaxConsecutiveScaleUps < 0 {
+		errorsFound = true
+		logging.Errorf("--max-consecutive-scale-ups cannot be negative")
+	}
+	if c.ScaleDownStabilizationSeconds < 0 {
+		errorsFound = true
+		logging.Errorf("--scale-down-stabilization-seconds cannot be negative")
+	}
+	if c.MaxReplicaChangePerPoll < 0 {
+		errorsFound = true
+		logging.Errorf("--max-replica-change-per-poll cannot be negative")
+	}
+	if c.AbsoluteMaxReplicas < 0 {
+		errorsFound = true
+		logging.Errorf("--absolute-max-replicas cannot be negative")
+	}
+	if c.MaxScaleDownPercent < 0 || c.MaxScaleDownPercent > 100 {
+		errorsFound = true
+		logging.Errorf("--max-scale-down-percent must be between 0 and 100")
+	}
+	if c.MaxScaleUpPercent < 0 {
+		errorsFound = true
+		logging.Errorf("--max-scale-up-percent cannot be negative")
+	}
+	if c.StartupDelaySeconds < 0 {
+		errorsFound = true
+		logging.Errorf("--startup-delay-seconds cannot be negative")
+	}
+	if c.MinSecondsBetweenScales < 0 {
+		errorsFound = true
+		logging.Errorf("--min-seconds-between-scales cannot be negative")
+	}
+	if c.DecayGuardSeconds < 0 {
+		errorsFound = true
+		logging.Errorf("--decay-guard-seconds cannot be negative")
+	}
+	if c.MinReplicasPerZone < 0 {
+		errorsFound = true
+		logging.Errorf("--min-replicas-per-zone cannot be negative")
+	}
+	if c.ShutdownGraceSeconds < 0 {
+		errorsFound = true
+		logging.Errorf("--shutdown-grace-seconds cannot be negative")
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		errorsFound = true
+		logging.Errorf("--timezone is invalid: %v", err)
+	}
+	if c.LeaderElect && c.LeaderElectLeaseName == "" {
+		errorsFound = true
+		logging.Errorf("--leader-elect-lease-name cannot be empty when --leader-elect is set")
+	}
+	if c.CoresSource != k8sclient.ResourceSourceAllocatable && c.CoresSource != k8sclient.ResourceSourceCapacity {
+		errorsFound = true
+		logging.Errorf("--cores-source must be one of %s or %s", k8sclient.ResourceSourceAllocatable, k8sclient.ResourceSourceCapacity)
+	}
+	switch c.OnConfigMapMissing {
+	case OnConfigMapMissingRecreateDefault, OnConfigMapMissingHold, OnConfigMapMissingFail:
+	default:
+		errorsFound = true
+		logging.Errorf("--on-configmap-missing must be one of %s, %s or %s", OnConfigMapMissingRecreateDefault, OnConfigMapMissingHold, OnConfigMapMissingFail)
+	}
+	switch c.RequireRunningPods {
+	case RequireRunningPodsOff, RequireRunningPodsWarn, RequireRunningPodsRefuse:
+	default:
+		errorsFound = true
+		logging.Errorf("--require-running-pods must be one of %s, %s or %s", RequireRunningPodsOff, RequireRunningPodsWarn, RequireRunningPodsRefuse)
+	}
+	if c.NoPodsGraceSeconds < 0 {
+		errorsFound = true
+		logging.Errorf("--no-pods-grace-seconds cannot be negative")
+	}
+	if c.ParamsFormat != ParamsFormatJSON && c.ParamsFormat != ParamsFormatYAML {
+		errorsFound = true
+		logging.Errorf("--params-format must be one of %s or %s", ParamsFormatJSON, ParamsFormatYAML)
+	}
+	if c.MaxCoresPerNode < 0 {
+		errorsFound = true
+		logging.Errorf("--max-cores-per-node cannot be negative")
+	}
+	if c.NodeWeightLabel != "" {
+		if errs := validation.IsQualifiedName(c.NodeWeightLabel); len(errs) > 0 {
+			errorsFound = true
+			logging.Errorf("--node-weight-label is not a valid label key: %s", strings.Join(errs, "; "))
+		}
+	} else if len(c.NodeWeights) > 0 {
+		errorsFound = true
+		logging.Errorf("--node-weights requires --node-weight-label")
+	}
+	if c.UnschedulableNodeWeight < 0 || c.UnschedulableNodeWeight > 1 {
+		errorsFound = true
+		logging.Errorf("--unschedulable-node-weight must be between 0 and 1")
+	}
+	if c.GPUResourceName == "" {
+		errorsFound = true
+		logging.Errorf("--gpu-resource-name cannot be empty")
 	}
 
 	// Log all sanity check errors before returning a single error string
@@ -75,29 +503,92 @@ func (c *AutoScalerConfig) ValidateFlags() error {
 	return nil
 }
 
+// TargetBounds are the min and max replicas given along with a target, e.g.
+// deployment/foo:min=2,max=20, a Max of 0 meaning unbounded.
+type TargetBounds struct {
+	Min int32
+	Max int32
+}
+
+// parseTargetBounds splits the bounds, if any, off the --target value.
+func parseTargetBounds(raw string) (target string, bounds *TargetBounds, err error) {
+	parts := strings.SplitN(raw, ":", 2)
+	if len(parts) == 1 {
+		return raw, nil, nil
+	}
+	bounds = &TargetBounds{}
+	for _, bound := range strings.Split(parts[1], ",") {
+		kv := strings.SplitN(bound, "=", 2)
+		if len(kv) != 2 {
+			return "", nil, fmt.Errorf("invalid bound %q, should be min=<replicas> or max=<replicas>", bound)
+		}
+		replicas, err := strconv.ParseInt(kv[1], 10, 32)
+		if err != nil || replicas < 0 {
+			return "", nil, fmt.Errorf("invalid replicas %q for %s, should be a non-negative integer", kv[1], kv[0])
+		}
+		switch kv[0] {
+		case "min":
+			bounds.Min = int32(replicas)
+		case "max":
+			bounds.Max = int32(replicas)
+		default:
+			return "", nil, fmt.Errorf("unknown bound %q, should be min or max", kv[0])
+		}
+	}
+	if bounds.Max != 0 && bounds.Max < bounds.Min {
+		return "", nil, fmt.Errorf("max %d should be greater than / equal to min %d", bounds.Max, bounds.Min)
+	}
+	return parts[0], bounds, nil
+}
+
 func isTargetFormatValid(target string) bool {
 	if target == "" {
-		glog.Errorf("--target parameter cannot be empty")
+		logging.Errorf("--target parameter cannot be empty")
 		return false
 	}
-	if !strings.HasPrefix(target, "deployment/") &&
-		!strings.HasPrefix(target, "replicationcontroller/") &&
-		!strings.HasPrefix(target, "replicaset/") {
-		glog.Errorf("Target format error. Please use deployment/*, replicationcontroller/* or replicaset/* (not case sensitive).")
+	// Whether the resource exists and can be scaled is checked against API
+	// discovery at startup.
+	splits := strings.Split(target, "/")
+	if len(splits) != 2 || splits[0] == "" || splits[1] == "" {
+		logging.Errorf("Target format error. Please use <resource>[.<group>]/<name>, e.g. deployment/*, replicationcontroller/*, replicaset/* or foosets.example.com/* (not case sensitive).")
 		return false
 	}
 	return true
 }
 
+// validateBindAddress checks the address is a host:port pair, where the host
+// may be empty, a name or an IP, IPv6 ones in brackets, e.g. [::]:8080.
+func validateBindAddress(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%v, expected host:port, e.g. :8080, 0.0.0.0:8080 or [::]:8080", err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > math.MaxUint16 {
+		return fmt.Errorf("invalid port %q in address %s", port, addr)
+	}
+	if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		return fmt.Errorf("invalid IPv6 host %q in address %s", host, addr)
+	}
+	return nil
+}
+
 type configMapData map[string]string
 
 func (c *configMapData) Set(raw string) error {
 	var rawData map[string]interface{}
 	if err := json.Unmarshal([]byte(raw), &rawData); err != nil {
-		return err
+		// Default params may also be given in YAML.
+		if yamlErr := yaml.Unmarshal([]byte(raw), &rawData); yamlErr != nil {
+			return fmt.Errorf("could not parse as JSON (%v) nor as YAML (%v)", err, yamlErr)
+		}
 	}
 	*c = make(map[string]string)
 	for key, param := range rawData {
+		// Params may also be given as JSON strings, as in the ConfigMap.
+		if str, ok := param.(string); ok {
+			(*c)[key] = str
+			continue
+		}
 		marshaled, err := json.Marshal(param)
 		if err != nil {
 			return err
@@ -107,6 +598,19 @@ func (c *configMapData) Set(raw string) error {
 	return nil
 }
 
+// LoadParamsFile reads params in the same format as --default-params from a file.
+func LoadParamsFile(path string) (map[string]string, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var params configMapData
+	if err := params.Set(string(raw)); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", path, err)
+	}
+	return params, nil
+}
+
 func (c *configMapData) String() string {
 	return fmt.Sprintf("%v", *c)
 }
@@ -115,14 +619,290 @@ func (c *configMapData) Type() string {
 	return "configMapData"
 }
 
+// scaleResources holds the --scale-resource flags, in the order they were given.
+type scaleResources []k8sclient.ScaleResource
+
+func (r *scaleResources) Set(raw string) error {
+	parts := strings.SplitN(raw, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("invalid format %q, should be <resource name>=<per replica>", raw)
+	}
+	perReplica, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return fmt.Errorf("invalid per replica amount of %s: %v", parts[0], err)
+	}
+	if perReplica <= 0 || math.IsInf(perReplica, 0) || math.IsNaN(perReplica) {
+		return fmt.Errorf("per replica amount of %s should be positive, got %v", parts[0], parts[1])
+	}
+	for _, scaleResource := range *r {
+		if string(scaleResource.Name) == parts[0] {
+			return fmt.Errorf("resource %s specified more than once", parts[0])
+		}
+	}
+	*r = append(*r, k8sclient.ScaleResource{Name: v1.ResourceName(parts[0]), PerReplica: perReplica})
+	return nil
+}
+
+func (r *scaleResources) String() string {
+	flags := make([]string, len(*r))
+	for i, scaleResource := range *r {
+		flags[i] = fmt.Sprintf("%s=%v", scaleResource.Name, scaleResource.PerReplica)
+	}
+	return "[" + strings.Join(flags, ",") + "]"
+}
+
+func (r *scaleResources) Type() string {
+	return "scaleResources"
+}
+
+// nodeWeights holds the --node-weights flags, the weights of the cores of nodes
+// keyed on the value of their --node-weight-label label.
+type nodeWeights map[string]float64
+
+func (w *nodeWeights) Set(raw string) error {
+	if *w == nil {
+		*w = make(map[string]float64)
+	}
+	for _, entry := range strings.Split(raw, ",") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("invalid format %q, should be <label value>=<weight>[,<label value>=<weight>...]", raw)
+		}
+		weight, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid weight of %s: %v", parts[0], err)
+		}
+		if weight < 0 || math.IsInf(weight, 0) || math.IsNaN(weight) {
+			return fmt.Errorf("weight of %s should not be negative, got %v", parts[0], parts[1])
+		}
+		if _, ok := (*w)[parts[0]]; ok {
+			return fmt.Errorf("label value %s specified more than once", parts[0])
+		}
+		(*w)[parts[0]] = weight
+	}
+	return nil
+}
+
+func (w *nodeWeights) String() string {
+	values := make([]string, 0, len(*w))
+	for value := range *w {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	entries := make([]string, len(values))
+	for i, value := range values {
+		entries[i] = fmt.Sprintf("%s=%v", value, (*w)[value])
+	}
+	return strings.Join(entries, ",")
+}
+
+func (w *nodeWeights) Type() string {
+	return "nodeWeights"
+}
+
+// intRange holds the values of a <start>:<end>:<step> range, end included, or
+// of a single value.
+type intRange struct {
+	spec   string
+	values []int
+}
+
+// maxRangeValues bounds the values of a range, e.g. against a misplaced step.
+const maxRangeValues = 100000
+
+func (r *intRange) Set(raw string) error {
+	parts := strings.Split(raw, ":")
+	if len(parts) != 1 && len(parts) != 3 {
+		return fmt.Errorf("invalid format %q, should be <start>:<end>:<step> or a single value", raw)
+	}
+	bounds := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid value %q in %q, should be a non-negative integer", part, raw)
+		}
+		bounds[i] = n
+	}
+	if len(bounds) == 1 {
+		bounds = []int{bounds[0], bounds[0], 1}
+	}
+	start, end, step := bounds[0], bounds[1], bounds[2]
+	if step == 0 || end < start {
+		return fmt.Errorf("invalid range %q, end should not be lower than start and step should be positive", raw)
+	}
+	if (end-start)/step >= maxRangeValues {
+		return fmt.Errorf("range %q has more than %d values", raw, maxRangeValues)
+	}
+	r.spec = raw
+	r.values = nil
+	for n := start; n <= end; n += step {
+		r.values = append(r.values, n)
+	}
+	return nil
+}
+
+// Values returns the values of the range, in increasing order.
+func (r *intRange) Values() []int {
+	return r.values
+}
+
+func (r *intRange) String() string {
+	return r.spec
+}
+
+func (r *intRange) Type() string {
+	return "intRange"
+}
+
+// envPrefix prefixes the environment variables flags fall back to.
+const envPrefix = "CPA_"
+
+// repeatedFlagTypes are the types of the flags which may be given multiple
+// times, whose environment variables hold comma-separated values.
+var repeatedFlagTypes = map[string]bool{
+	"stringArray":    true,
+	"scaleResources": true,
+}
+
+// splitEnvValues splits the comma-separated values of a repeated flag. The
+// bounds of a target are separated by commas as well, e.g.
+// deployment/foo:min=2,max=20, so the values of --target are only split on
+// the commas starting a new <resource>/<name> target.
+func splitEnvValues(flagName, value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if flagName == "target" && len(values) > 0 && !strings.Contains(v, "/") {
+			values[len(values)-1] += "," + v
+			continue
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// EnvVarName returns the environment variable the flag falls back to, e.g.
+// CPA_POLL_PERIOD_SECONDS for --poll-period-seconds.
+func EnvVarName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// ApplyEnv sets the flags of fs which were not given on the command line from
+// their environment variables, if set. It must be called once fs is parsed, so
+// that flags take precedence over environment variables.
+func ApplyEnv(fs *pflag.FlagSet) error {
+	var errs []string
+	fs.VisitAll(func(f *pflag.Flag) {
+		if f.Changed {
+			return
+		}
+		name := EnvVarName(f.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		values := []string{value}
+		if repeatedFlagTypes[f.Value.Type()] {
+			values = splitEnvValues(f.Name, value)
+		}
+		for _, v := range values {
+			if err := fs.Set(f.Name, v); err != nil {
+				errs = append(errs, fmt.Sprintf("invalid value %q in %s for --%s: %v", v, name, f.Name, err))
+				return
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
 // AddFlags adds flags for a specific AutoScaler to the specified FlagSet
 func (c *AutoScalerConfig) AddFlags(fs *pflag.FlagSet) {
-	fs.StringVar(&c.Target, "target", c.Target, "Target to scale. In format: deployment/*, replicationcontroller/* or replicaset/* (not case sensitive).")
+	fs.StringArrayVar(&c.Targets, "target", c.Targets, "Target to scale. In format: <resource>[.<group>]/<name>, e.g. deployment/*, replicationcontroller/*, replicaset/* or any resource with a scale subresource such as foosets.example.com/* (not case sensitive). May be specified multiple times to scale several targets with the same params. Each target may carry its own bounds on the replicas, e.g. deployment/foo:min=2,max=20.")
+	fs.StringVar(&c.TargetNamespaceSelector, "target-namespace-selector", c.TargetNamespaceSelector, "Label selector of the namespaces in which to discover and scale the targets, instead of --namespace. Only deployments, replicasets and statefulsets are discovered. Usage example: --target-namespace-selector=tenant.")
+	fs.BoolVar(&c.PinTargetUID, "pin-target-uid", c.PinTargetUID, "Pin the targets by UID in addition to their name, so that a target deleted and recreated under the same name is resolved again instead of being scaled from the state of the object it replaced.")
+	fs.BoolVar(&c.TargetWait, "target-wait", c.TargetWait, "Keep polling for the targets whose resource has no scale subresource, instead of exiting at startup.")
 	fs.StringVar(&c.ConfigMap, "configmap", c.ConfigMap, "ConfigMap containing our scaling parameters.")
 	fs.StringVar(&c.Namespace, "namespace", c.Namespace, "Namespace for all operations, fallback to the namespace of this autoscaler(through MY_POD_NAMESPACE env) if not specified.")
+	fs.StringVar(&c.ConfigMapNamespace, "configmap-namespace", c.ConfigMapNamespace, "Namespace of the ConfigMap or Secret holding the params, fallback to --namespace if not specified.")
+	fs.StringVar(&c.ConfigMapKey, "configmap-key", c.ConfigMapKey, "Key of the ConfigMap or Secret holding the params as a JSON object, in the same format as --default-params, all keys are read as the params if not specified.")
+	fs.StringVar(&c.Secret, "secret", c.Secret, "Secret containing our scaling parameters, instead of --configmap.")
+	fs.StringVar(&c.ActiveProfile, "active-profile", c.ActiveProfile, "Key of the ConfigMap or Secret holding the params profile to scale with, all keys are read as a single profile if not specified.")
 	fs.IntVar(&c.PollPeriodSeconds, "poll-period-seconds", c.PollPeriodSeconds, "The time, in seconds, to check cluster status and perform autoscale.")
+	fs.Float64Var(&c.PollJitterFactor, "poll-jitter-factor", c.PollJitterFactor, "Maximum fraction, between 0 and 1, of --poll-period-seconds randomly added to each interval between polls. Default value of 0 polls at exact intervals.")
+	fs.IntVar(&c.ReactiveNodeDelta, "reactive-node-delta", c.ReactiveNodeDelta, "Number of nodes added or deleted since the last poll which triggers a poll right away instead of waiting for the next one, at most every 5 seconds. Default value of 0 only polls every --poll-period-seconds.")
+	fs.IntVar(&c.ConfigMapResyncSeconds, "configmap-resync-seconds", c.ConfigMapResyncSeconds, "The time, in seconds, between checks of the ConfigMap or Secret for new params, which trigger a poll right away instead of waiting for the next one. Default value of 0 only reads the params every --poll-period-seconds.")
 	fs.BoolVar(&c.PrintVer, "version", c.PrintVer, "Print the version and exit.")
 	fs.Var(&c.DefaultParams, "default-params", "Default parameters(JSON format) for auto-scaling. Will create/re-create a ConfigMap with this default params if ConfigMap is not present.")
+	fs.StringVar(&c.DefaultParamsFile, "default-params-file", c.DefaultParamsFile, "Path of a file holding the default parameters, in the same format as --default-params, e.g. mounted from a volume. Cannot be set along with --default-params.")
+	fs.StringVar(&c.OnConfigMapMissing, "on-configmap-missing", c.OnConfigMapMissing, "What to do when the ConfigMap is deleted at runtime: recreate-default recreates it with --default-params, hold keeps scaling with the last known params, fail fails every poll until it is back.")
+	fs.StringVar(&c.ParamsFormat, "params-format", c.ParamsFormat, "Format of the params in the ConfigMap or Secret: json, or yaml which also accepts JSON.")
+	fs.StringVar(&c.ControllerType, "controller-type", c.ControllerType, "Control mode the params must be for, e.g. linear or ladder, failing the polls whose ConfigMap holds the params of another mode. The mode is detected from the key of the params by default.")
 	fs.StringVar(&c.NodeLabels, "nodelabels", c.NodeLabels, "NodeLabels for filtering search of nodes and its cpus by LabelSelectors. Input format is a comma separated list of keyN=valueN LabelSelectors. Usage example: --nodelabels=label1=value1,label2=value2.")
+	fs.StringVar(&c.CoresNodeSelector, "cores-node-selector", c.CoresNodeSelector, "Label selector of the nodes, among those matching --nodelabels, whose cores are counted. All of them are counted if not specified. Usage example: --cores-node-selector=pool=gpu.")
+	fs.StringVar(&c.MemoryNodeSelector, "memory-node-selector", c.MemoryNodeSelector, "Label selector of the nodes, among those matching --nodelabels, whose memory is counted. All of them are counted if not specified.")
+	fs.StringVar(&c.PodsNodeSelector, "pods-node-selector", c.PodsNodeSelector, "Label selector of the nodes, among those matching --nodelabels, whose pods capacity is counted. All of them are counted if not specified.")
+	fs.StringVar(&c.GPUsNodeSelector, "gpus-node-selector", c.GPUsNodeSelector, "Label selector of the nodes, among those matching --nodelabels, whose GPUs are counted. All of them are counted if not specified.")
+	fs.StringVar(&c.NodeFieldSelector, "node-field-selector", c.NodeFieldSelector, "Field selector for filtering search of nodes, along with --nodelabels. Usage example: --node-field-selector=spec.unschedulable=false.")
+	fs.BoolVar(&c.IgnoreTaintedNodes, "ignore-tainted-nodes", c.IgnoreTaintedNodes, "Do not count nodes with a NoSchedule or NoExecute taint as schedulable.")
+	fs.StringSliceVar(&c.ToleratedTaintKeys, "tolerated-taint-keys", c.ToleratedTaintKeys, "Comma separated list of taint keys that don't disqualify a node when --ignore-tainted-nodes is set.")
+	fs.BoolVar(&c.CountReadyNodesOnly, "count-ready-nodes-only", c.CountReadyNodesOnly, "Only count nodes whose Ready condition is True, in both the total and schedulable resources.")
+	fs.BoolVar(&c.ExcludeControlPlaneNodes, "exclude-control-plane-nodes", c.ExcludeControlPlaneNodes, "Do not count nodes labeled node-role.kubernetes.io/control-plane or node-role.kubernetes.io/master, in both the total and schedulable resources.")
+	fs.IntVar(&c.MaxCoresPerNode, "max-cores-per-node", c.MaxCoresPerNode, "Maximum number of cores counted for a single node, nodes with more cores count as that many. Default value of 0 counts all the cores of each node.")
+	fs.StringVar(&c.NodeWeightLabel, "node-weight-label", c.NodeWeightLabel, "Label of nodes, e.g. node.kubernetes.io/instance-type, whose value picks the weight of the cores of each node from --node-weights.")
+	fs.Var(&c.NodeWeights, "node-weights", "Weights of the cores of nodes keyed on the value of their --node-weight-label label, in format <label value>=<weight>[,<label value>=<weight>...], e.g. 4xlarge=2,small=0.5. Unlisted values weigh 1. May be specified multiple times.")
+	fs.Float64Var(&c.UnschedulableNodeWeight, "unschedulable-node-weight", c.UnschedulableNodeWeight, "Fraction, between 0 and 1, of the cordoned nodes, and of their resources, counted as schedulable, e.g. 0.5 for cordoned nodes which keep running long-lived pods. Default value of 0 doesn't count them.")
+	fs.StringVar(&c.BackendSelector, "backend-selector", c.BackendSelector, "Label selector of pods in the namespace to count for the podsMatchingSelectorPerReplica param of the linear controller. Usage example: --backend-selector=app=backend.")
+	fs.BoolVar(&c.CountPendingPods, "count-pending-pods", c.CountPendingPods, "Count the pods pending because they are unschedulable, cluster-wide, for the pendingPodsPerReplica param of the linear controller. This watches all pending pods of the cluster.")
+	fs.BoolVar(&c.CountNamespaces, "count-namespaces", c.CountNamespaces, "Count the namespaces of the cluster, or those matching --namespace-selector, for the namespacesPerReplica param of the linear controller.")
+	fs.StringVar(&c.NamespaceSelector, "namespace-selector", c.NamespaceSelector, "Label selector of the namespaces counted with --count-namespaces. Usage example: --namespace-selector=tenant.")
+	fs.StringVar(&c.CoresSource, "cores-source", c.CoresSource, "Which resources of nodes are counted as cores, memory, pods and GPUs: allocatable or capacity.")
+	fs.IntVar(&c.DiscoveryRefreshSeconds, "discovery-refresh-seconds", c.DiscoveryRefreshSeconds, "Period, in seconds, of the refresh of the API discovery used to resolve the targets, e.g. to pick up new versions of custom resources. Default value of 0 only refreshes it when a target can't be resolved.")
+	fs.Float32Var(&c.KubeAPIQPS, "kube-api-qps", c.KubeAPIQPS, "Maximum queries per second to the apiserver from this client.")
+	fs.IntVar(&c.KubeAPIBurst, "kube-api-burst", c.KubeAPIBurst, "Maximum burst of queries to the apiserver from this client, above --kube-api-qps.")
+	fs.DurationVar(&c.KubeAPITimeout, "kube-api-timeout", c.KubeAPITimeout, "Timeout of the requests to the apiserver from this client, e.g. 30s, watches excepted. Default value of 0 uses the poll period.")
 	fs.IntVar(&c.MaxSyncFailures, "max-sync-failures", c.MaxSyncFailures, "Number of consecutive polling failures before exiting. Default value of 0 will allow for unlimited retries.")
+	fs.IntVar(&c.NodeCountSmoothingWindow, "node-count-smoothing-window", c.NodeCountSmoothingWindow, "Number of polls over which the schedulable nodes and cores are averaged before computing the replicas. Default value of 0 disables smoothing.")
+	fs.Float64Var(&c.MaxNodeDropFraction, "max-node-drop-fraction", c.MaxNodeDropFraction, "Fraction of the nodes, e.g. 0.5, by which the node count may drop from one poll to the next before scaling is frozen until it recovers. Default value of 0 disables it.")
+	fs.IntVar(&c.MaxNodeDropHoldSeconds, "max-node-drop-hold-seconds", c.MaxNodeDropHoldSeconds, "Time, in seconds, after which a node count drop beyond --max-node-drop-fraction is accepted and scaling resumes. Default value of 0 holds until the node count recovers.")
+	fs.IntVar(&c.DrainBoostReplicas, "drain-boost-replicas", c.DrainBoostReplicas, "Number of replicas the min of the params is raised by for --drain-boost-seconds after nodes start being drained or deleted. Default value of 0 disables it.")
+	fs.IntVar(&c.DrainBoostSeconds, "drain-boost-seconds", c.DrainBoostSeconds, "Time, in seconds, the min of the params stays raised by --drain-boost-replicas after nodes start being drained or deleted.")
+	fs.IntVar(&c.ScaleDownStabilizationSeconds, "scale-down-stabilization-seconds", c.ScaleDownStabilizationSeconds, "The time, in seconds, a lower replicas count must be recommended for before scaling down. Scale ups are applied immediately. Default value of 0 disables stabilization.")
+	fs.IntVar(&c.MaxReplicaChangePerPoll, "max-replica-change-per-poll", c.MaxReplicaChangePerPoll, "Maximum number of replicas added or removed in a single poll. Default value of 0 will allow for unlimited changes.")
+	fs.IntVar(&c.AbsoluteMaxReplicas, "absolute-max-replicas", c.AbsoluteMaxReplicas, "Ceiling on the replicas of each target, whatever the params. Default value of 0 disables it.")
+	fs.IntVar(&c.MaxScaleDownPercent, "max-scale-down-percent", c.MaxScaleDownPercent, "Maximum percentage of the current replicas removed in a single poll, at least 1 replica. Default value of 0 will allow for unlimited scale downs.")
+	fs.IntVar(&c.MaxScaleUpPercent, "max-scale-up-percent", c.MaxScaleUpPercent, "Maximum percentage of the current replicas added in a single poll, at least 1 replica. Default value of 0 will allow for unlimited scale ups.")
+	fs.IntVar(&c.MaxConsecutiveScaleUps, "max-consecutive-scale-ups", c.MaxConsecutiveScaleUps, "Number of scale ups of a target in a row, without any scale down, after which its scale ups are blocked until the params change or a scale down is recommended. Default value of 0 will allow for unlimited scale ups.")
+	fs.IntVar(&c.MinSecondsBetweenScales, "min-seconds-between-scales", c.MinSecondsBetweenScales, "Minimum time, in seconds, between two changes of the replicas of a target, in either direction. Default value of 0 disables it.")
+	fs.IntVar(&c.DecayGuardSeconds, "decay-guard-seconds", c.DecayGuardSeconds, "Time, in seconds, after a target is scaled during which its scale downs are suppressed at first, then allowed in proportion to the time elapsed. Default value of 0 disables it.")
+	fs.IntVar(&c.StartupDelaySeconds, "startup-delay-seconds", c.StartupDelaySeconds, "The time, in seconds, after start up during which the recommendations are computed and logged but the replicas are not updated. Default value of 0 scales from the first poll.")
+	fs.BoolVar(&c.RespectPDB, "respect-pdb", c.RespectPDB, "Limit scale downs so that the PodDisruptionBudget matching the pods of the target is not violated.")
+	fs.StringVar(&c.RequireRunningPods, "require-running-pods", c.RequireRunningPods, "What to do when the selector of a target with desired replicas has matched no pods for --no-pods-grace-seconds, e.g. because of a typo in --target: off doesn't check the pods, warn logs a warning and records an event, refuse refuses to scale the target.")
+	fs.IntVar(&c.NoPodsGraceSeconds, "no-pods-grace-seconds", c.NoPodsGraceSeconds, "Time, in seconds, the selector of a target with desired replicas may match no pods before --require-running-pods applies.")
+	fs.BoolVar(&c.AnnotateTarget, "annotate-target", c.AnnotateTarget, "Annotate each target with the recommended replicas and the schedulable nodes and cores it was computed from whenever its replicas are changed.")
+	fs.StringVar(&c.ScaleWebhookURL, "scale-webhook-url", c.ScaleWebhookURL, "URL to POST a JSON notification to whenever the replicas of a target are changed. Delivery is best-effort.")
+	fs.StringVar(&c.ScaleWebhookAuthHeader, "scale-webhook-auth-header", c.ScaleWebhookAuthHeader, "Header, in the format <name>: <value>, sent along with the scale webhook notifications, e.g. to pass a shared secret.")
+	fs.StringVar(&c.RecommendationSinkURL, "recommendation-sink-url", c.RecommendationSinkURL, "URL to POST the recommendations and cluster status computed at each poll to, in JSON. Delivery is best-effort.")
+	fs.StringVar(&c.OTLPEndpoint, "otlp-endpoint", c.OTLPEndpoint, "OTLP/HTTP endpoint to export a trace of each poll to, e.g. http://otel-collector:4318. Tracing is disabled by default.")
+	fs.IntVar(&c.MinReplicasPerZone, "min-replicas-per-zone", c.MinReplicasPerZone, "Minimum number of replicas per zone of schedulable nodes, from the topology.kubernetes.io/zone label. Raises the computed replicas to this times the number of zones. Default value of 0 disables it.")
+	fs.BoolVar(&c.MaxReplicasFromMemoryRequests, "max-replicas-from-memory-requests", c.MaxReplicasFromMemoryRequests, "Cap the replicas of each target to the schedulable memory divided by the memory request of its pod template, when it sets one.")
+	fs.StringVar(&c.Timezone, "timezone", c.Timezone, "Timezone, e.g. Europe/Paris, the cron expressions of the ConfigMap schedule are evaluated in.")
+	fs.StringVar(&c.MetricsBindAddress, "metrics-bind-address", c.MetricsBindAddress, "The address, e.g. :9090 or [::]:9090, to serve Prometheus metrics on at /metrics. Metrics are disabled when empty.")
+	fs.StringVar(&c.HealthBindAddress, "health-bind-address", c.HealthBindAddress, "The address, e.g. :8080 or [::]:8080, to serve the /healthz, /readyz and /last-poll health checks and /debug/status and /debug/errors on. Health checks are disabled when empty.")
+	fs.StringVar(&c.GRPCHealthBindAddress, "grpc-health-bind-address", c.GRPCHealthBindAddress, "The address, e.g. :8081 or [::]:8081, to serve the standard gRPC health service, grpc.health.v1.Health, on in cleartext. It reports SERVING while /healthz succeeds. Disabled when empty.")
+	fs.BoolVar(&c.LeaderElect, "leader-elect", c.LeaderElect, "Elect a leader among autoscaler replicas through a Lease before autoscaling. Only the leader scales the target.")
+	fs.StringVar(&c.LeaderElectLeaseName, "leader-elect-lease-name", c.LeaderElectLeaseName, "Name of the Lease used for leader election.")
+	fs.StringVar(&c.LeaderElectNamespace, "leader-elect-namespace", c.LeaderElectNamespace, "Namespace of the Lease used for leader election, fallback to --namespace if not specified.")
+	fs.BoolVar(&c.DryRun, "dry-run", c.DryRun, "Compute and log the expected replicas without updating the target.")
+	fs.StringVar(&c.ValidateParamsFile, "validate-params", c.ValidateParamsFile, "Path of a JSON params file, in the same format as --default-params, to validate offline. Prints OK or the validation errors and exits.")
+	fs.BoolVar(&c.Simulate, "simulate", c.Simulate, "Print the replicas recommended by the params of --default-params or --default-params-file for the cluster sizes of --simulate-nodes and --simulate-cores, and exit.")
+	fs.Var(&c.SimulateNodes, "simulate-nodes", "Range of schedulable nodes to simulate, in format <start>:<end>:<step>, e.g. 0:1000:50, or a single value. Defaults to 0.")
+	fs.Var(&c.SimulateCores, "simulate-cores", "Range of schedulable cores to simulate, in format <start>:<end>:<step>, e.g. 0:4000:500, or a single value. Defaults to 0.")
+	fs.StringVar(&c.LogFormat, "log-format", c.LogFormat, "Format of log lines: text or json.")
+	fs.BoolVar(&c.LogNoopCycles, "log-noop-cycles", c.LogNoopCycles, "Log the polls which leave the replicas unchanged at the default verbosity, instead of --v=4, e.g. for debugging.")
+	fs.IntVar(&c.ShutdownGraceSeconds, "shutdown-grace-seconds", c.ShutdownGraceSeconds, "The time, in seconds, the poll in progress is given to complete on SIGTERM before exiting.")
+	fs.StringVar(&c.GPUResourceName, "gpu-resource-name", c.GPUResourceName, "Name of the extended resource counted as GPUs on nodes, e.g. nvidia.com/gpu or amd.com/gpu.")
+	fs.Var(&c.ScaleResources, "scale-resource", "Resource of nodes to add a term for in linear mode, in format <resource name>=<per replica>, e.g. example.com/fpga=4. May be specified multiple times.")
 }
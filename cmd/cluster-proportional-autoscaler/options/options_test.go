@@ -17,8 +17,14 @@ limitations under the License.
 package options
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/spf13/pflag"
 )
 
 func TestIsTargetFormatValid(t *testing.T) {
@@ -44,16 +50,32 @@ func TestIsTargetFormatValid(t *testing.T) {
 		},
 		{
 			"deployments/anything",
-			false,
+			true,
+		},
+		{
+			"foosets.example.com/anything",
+			true,
 		},
 		{
 			"noexist/anything",
-			false,
+			true,
 		},
 		{
 			"deployment",
 			false,
 		},
+		{
+			"deployment/",
+			false,
+		},
+		{
+			"/anything",
+			false,
+		},
+		{
+			"deployment/anything/else",
+			false,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -64,3 +86,520 @@ func TestIsTargetFormatValid(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadParamsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "params")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		content   string
+		expParams map[string]string
+		expError  bool
+	}{
+		{
+			`{"linear": {"coresPerReplica": 2}}`,
+			map[string]string{"linear": `{"coresPerReplica":2}`},
+			false,
+		},
+		{
+			// Params given as a JSON string, as in the ConfigMap.
+			`{"linear": "{\"coresPerReplica\": 2}"}`,
+			map[string]string{"linear": `{"coresPerReplica": 2}`},
+			false,
+		},
+		{
+			"linear:\n  coresPerReplica: 2\n  nodesPerReplica: 1\n",
+			map[string]string{"linear": `{"coresPerReplica":2,"nodesPerReplica":1}`},
+			false,
+		},
+		{
+			`{"linear": `,
+			nil,
+			true,
+		},
+		{
+			"linear:\n\tcoresPerReplica: 2\n",
+			nil,
+			true,
+		},
+	}
+
+	for i, tc := range testCases {
+		path := filepath.Join(dir, "params.json")
+		if err := ioutil.WriteFile(path, []byte(tc.content), 0644); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		params, err := LoadParamsFile(path)
+		if tc.expError {
+			if err == nil {
+				t.Errorf("Case %d: expected an error, got params %v", i, params)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(params, tc.expParams) {
+			t.Errorf("Case %d: expected params %v, got %v", i, tc.expParams, params)
+		}
+	}
+
+	if _, err := LoadParamsFile(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
+
+func TestValidateFlagsDefaultParamsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "params")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "params.json")
+	if err := ioutil.WriteFile(path, []byte(`{"linear": {"coresPerReplica": 2}}`), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		defaultParams     map[string]string
+		defaultParamsFile string
+		expParams         map[string]string
+		expError          bool
+	}{
+		{nil, "", nil, false},
+		{map[string]string{"linear": `{"nodesPerReplica":1}`}, "", map[string]string{"linear": `{"nodesPerReplica":1}`}, false},
+		{nil, path, map[string]string{"linear": `{"coresPerReplica":2}`}, false},
+		{map[string]string{"linear": `{"nodesPerReplica":1}`}, path, nil, true},
+		{nil, filepath.Join(dir, "missing.json"), nil, true},
+	}
+
+	for i, tc := range testCases {
+		c := NewAutoScalerConfig()
+		c.Targets = []string{"deployment/dns"}
+		c.Namespace = "kube-system"
+		c.ConfigMap = "dns-autoscaler"
+		c.DefaultParams = tc.defaultParams
+		c.DefaultParamsFile = tc.defaultParamsFile
+		err := c.ValidateFlags()
+		if (err != nil) != tc.expError {
+			t.Errorf("Case %d: expected error %v, got %v", i, tc.expError, err)
+			continue
+		}
+		if !tc.expError && !reflect.DeepEqual(map[string]string(c.DefaultParams), tc.expParams) {
+			t.Errorf("Case %d: expected default params %v, got %v", i, tc.expParams, c.DefaultParams)
+		}
+	}
+}
+
+func TestValidateFlagsNodeFieldSelector(t *testing.T) {
+	testCases := []struct {
+		selector string
+		expError bool
+	}{
+		{"", false},
+		{"spec.unschedulable=false", false},
+		{"spec.unschedulable!=true,metadata.name!=master", false},
+		{"spec.unschedulable", true},
+		{"spec.unschedulable==false=true", true},
+	}
+
+	for _, tc := range testCases {
+		c := NewAutoScalerConfig()
+		c.Targets = []string{"deployment/dns"}
+		c.Namespace = "kube-system"
+		c.ConfigMap = "dns-autoscaler"
+		c.NodeFieldSelector = tc.selector
+		if err := c.ValidateFlags(); (err != nil) != tc.expError {
+			t.Errorf("For --node-field-selector=%q expected error %v, got %v", tc.selector, tc.expError, err)
+		}
+	}
+}
+
+func TestValidateFlagsUnschedulableNodeWeight(t *testing.T) {
+	testCases := []struct {
+		weight   float64
+		expError bool
+	}{
+		{0, false},
+		{0.5, false},
+		{1, false},
+		{-0.5, true},
+		{1.5, true},
+	}
+
+	for _, tc := range testCases {
+		c := NewAutoScalerConfig()
+		c.Targets = []string{"deployment/dns"}
+		c.Namespace = "kube-system"
+		c.ConfigMap = "dns-autoscaler"
+		c.UnschedulableNodeWeight = tc.weight
+		if err := c.ValidateFlags(); (err != nil) != tc.expError {
+			t.Errorf("For --unschedulable-node-weight=%v expected error %v, got %v", tc.weight, tc.expError, err)
+		}
+	}
+}
+
+func TestValidateFlagsNodeWeights(t *testing.T) {
+	testCases := []struct {
+		label    string
+		weights  string
+		expError bool
+	}{
+		{"", "", false},
+		{"node.kubernetes.io/instance-type", "", false},
+		{"node.kubernetes.io/instance-type", "4xlarge=2,small=0.5", false},
+		{"", "4xlarge=2", true},
+		{"not a label", "", true},
+	}
+
+	for _, tc := range testCases {
+		c := NewAutoScalerConfig()
+		c.Targets = []string{"deployment/dns"}
+		c.Namespace = "kube-system"
+		c.ConfigMap = "dns-autoscaler"
+		c.NodeWeightLabel = tc.label
+		if tc.weights != "" {
+			if err := c.NodeWeights.Set(tc.weights); err != nil {
+				t.Fatalf("Unexpected error for --node-weights=%s: %v", tc.weights, err)
+			}
+		}
+		if err := c.ValidateFlags(); (err != nil) != tc.expError {
+			t.Errorf("For --node-weight-label=%q --node-weights=%q expected error %v, got %v", tc.label, tc.weights, tc.expError, err)
+		}
+	}
+}
+
+func TestValidateFlagsDrainBoost(t *testing.T) {
+	testCases := []struct {
+		replicas int
+		seconds  int
+		expError bool
+	}{
+		{0, 0, false},
+		{2, 300, false},
+		{2, 0, true},
+		{0, 300, true},
+		{-1, 300, true},
+	}
+
+	for _, tc := range testCases {
+		c := NewAutoScalerConfig()
+		c.Targets = []string{"deployment/dns"}
+		c.Namespace = "kube-system"
+		c.ConfigMap = "dns-autoscaler"
+		c.DrainBoostReplicas = tc.replicas
+		c.DrainBoostSeconds = tc.seconds
+		if err := c.ValidateFlags(); (err != nil) != tc.expError {
+			t.Errorf("For --drain-boost-replicas=%d --drain-boost-seconds=%d expected error %v, got %v", tc.replicas, tc.seconds, tc.expError, err)
+		}
+	}
+}
+
+func TestValidateFlagsTargetBounds(t *testing.T) {
+	testCases := []struct {
+		targets    []string
+		expTargets []string
+		expBounds  map[string]TargetBounds
+		expError   bool
+	}{
+		{
+			[]string{"deployment/foo", "Deployment/Bar:min=2,max=20", "replicaset/baz:max=3"},
+			[]string{"deployment/foo", "deployment/bar", "replicaset/baz"},
+			map[string]TargetBounds{"deployment/bar": {Min: 2, Max: 20}, "replicaset/baz": {Max: 3}},
+			false,
+		},
+		{[]string{"deployment/foo:min=2"}, []string{"deployment/foo"}, map[string]TargetBounds{"deployment/foo": {Min: 2}}, false},
+		{[]string{"deployment/foo:min=20,max=2"}, nil, nil, true},
+		{[]string{"deployment/foo:min=-1"}, nil, nil, true},
+		{[]string{"deployment/foo:min"}, nil, nil, true},
+		{[]string{"deployment/foo:replicas=2"}, nil, nil, true},
+		{[]string{"deployment/foo:min=2", "deployment/foo:max=3"}, nil, nil, true},
+	}
+
+	for _, tc := range testCases {
+		c := NewAutoScalerConfig()
+		c.Targets = append([]string(nil), tc.targets...)
+		c.Namespace = "kube-system"
+		c.ConfigMap = "dns-autoscaler"
+		err := c.ValidateFlags()
+		if (err != nil) != tc.expError {
+			t.Errorf("For targets %v expected error %v, got %v", tc.targets, tc.expError, err)
+			continue
+		}
+		if tc.expError {
+			continue
+		}
+		if !reflect.DeepEqual(c.Targets, tc.expTargets) || !reflect.DeepEqual(c.TargetBounds, tc.expBounds) {
+			t.Errorf("For targets %v expected %v with bounds %v, got %v with bounds %v", tc.targets, tc.expTargets, tc.expBounds, c.Targets, c.TargetBounds)
+		}
+	}
+}
+
+func TestValidateFlagsTargetNamespaceSelector(t *testing.T) {
+	testCases := []struct {
+		targets  []string
+		selector string
+		expError bool
+	}{
+		{[]string{"deployment/coredns", "statefulset/etcd"}, "tenant", false},
+		{[]string{"replicasets.apps/foo"}, "tenant in (a, b)", false},
+		{[]string{"replicationcontroller/foo"}, "tenant", true},
+		{[]string{"foosets.example.com/foo"}, "tenant", true},
+		{[]string{"deployment/coredns"}, "tenant in (", true},
+	}
+
+	for _, tc := range testCases {
+		c := NewAutoScalerConfig()
+		c.Targets = append([]string(nil), tc.targets...)
+		c.TargetNamespaceSelector = tc.selector
+		c.Namespace = "kube-system"
+		c.ConfigMap = "dns-autoscaler"
+		if err := c.ValidateFlags(); (err != nil) != tc.expError {
+			t.Errorf("For targets %v and selector %q expected error %v, got %v", tc.targets, tc.selector, tc.expError, err)
+		}
+	}
+}
+
+func TestValidateFlagsControllerType(t *testing.T) {
+	testCases := []struct {
+		controllerType string
+		defaultParams  configMapData
+		expError       bool
+	}{
+		{"", nil, false},
+		{"linear", nil, false},
+		{"linear", configMapData{"linear": `{"nodesPerReplica": 1}`}, false},
+		{"ladder", configMapData{"linear": `{"nodesPerReplica": 1}`}, true},
+		{"unknown", nil, true},
+	}
+
+	for _, tc := range testCases {
+		c := NewAutoScalerConfig()
+		c.Targets = []string{"deployment/coredns"}
+		c.Namespace = "kube-system"
+		c.ConfigMap = "dns-autoscaler"
+		c.ControllerType = tc.controllerType
+		c.DefaultParams = tc.defaultParams
+		if err := c.ValidateFlags(); (err != nil) != tc.expError {
+			t.Errorf("For --controller-type=%q and default params %v expected error %v, got %v", tc.controllerType, tc.defaultParams, tc.expError, err)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"CPA_TARGET":              "deployment/dns, deployment/metrics:min=2,max=20, deployment/foo:max=5",
+		"CPA_CONFIGMAP":           "dns-autoscaler",
+		"CPA_POLL_PERIOD_SECONDS": "30",
+		"CPA_DRY_RUN":             "true",
+		"CPA_SCALE_RESOURCE":      "example.com/fpga=4,example.com/foo=2",
+	}
+	for name, value := range env {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	c := NewAutoScalerConfig()
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	c.AddFlags(fs)
+	if err := fs.Parse([]string{"--configmap=from-flag"}); err != nil {
+		t.Fatalf("Unexpected error parsing flags: %v", err)
+	}
+	if err := ApplyEnv(fs); err != nil {
+		t.Fatalf("Unexpected error applying env: %v", err)
+	}
+	if !reflect.DeepEqual(c.Targets, []string{"deployment/dns", "deployment/metrics:min=2,max=20", "deployment/foo:max=5"}) {
+		t.Errorf("Expected targets from CPA_TARGET, got %v", c.Targets)
+	} else if _, bounds, err := parseTargetBounds(c.Targets[1]); err != nil || bounds == nil || *bounds != (TargetBounds{Min: 2, Max: 20}) {
+		t.Errorf("Expected both bounds of %s from CPA_TARGET, got %v (%v)", c.Targets[1], bounds, err)
+	}
+	if c.ConfigMap != "from-flag" {
+		t.Errorf("Expected --configmap to take precedence over CPA_CONFIGMAP, got %q", c.ConfigMap)
+	}
+	if c.PollPeriodSeconds != 30 {
+		t.Errorf("Expected a poll period of 30 from CPA_POLL_PERIOD_SECONDS, got %d", c.PollPeriodSeconds)
+	}
+	if !c.DryRun {
+		t.Errorf("Expected dry run from CPA_DRY_RUN")
+	}
+	if len(c.ScaleResources) != 2 {
+		t.Errorf("Expected 2 scale resources from CPA_SCALE_RESOURCE, got %v", c.ScaleResources)
+	}
+
+	os.Setenv("CPA_MAX_CORES_PER_NODE", "many")
+	defer os.Unsetenv("CPA_MAX_CORES_PER_NODE")
+	if err := ApplyEnv(fs); err == nil {
+		t.Errorf("Expected an error for an invalid CPA_MAX_CORES_PER_NODE")
+	}
+
+	// The error of a comma-separated value quotes the invalid one.
+	os.Setenv("CPA_SCALE_RESOURCE", "example.com/fpga=4,example.com/foo=none")
+	fs = pflag.NewFlagSet("test", pflag.ContinueOnError)
+	NewAutoScalerConfig().AddFlags(fs)
+	err := ApplyEnv(fs)
+	if err == nil || !strings.Contains(err.Error(), `invalid value "example.com/foo=none" in CPA_SCALE_RESOURCE for --scale-resource`) {
+		t.Errorf("Expected an error quoting the invalid value of CPA_SCALE_RESOURCE, got %v", err)
+	}
+}
+
+func TestValidateFlagsBindAddresses(t *testing.T) {
+	testCases := []struct {
+		addr     string
+		expError bool
+	}{
+		{"", false},
+		{":8080", false},
+		{"0.0.0.0:8080", false},
+		{"localhost:8080", false},
+		{"[::]:8080", false},
+		{"[::1]:0", false},
+		{"8080", true},
+		{"::8080", true},
+		{"[::]", true},
+		{":http", true},
+		{":65536", true},
+		{"[::zz]:8080", true},
+	}
+
+	for _, tc := range testCases {
+		for _, flag := range []string{"--metrics-bind-address", "--health-bind-address"} {
+			c := NewAutoScalerConfig()
+			c.Targets = []string{"deployment/dns"}
+			c.Namespace = "kube-system"
+			c.ConfigMap = "dns-autoscaler"
+			if flag == "--metrics-bind-address" {
+				c.MetricsBindAddress = tc.addr
+			} else {
+				c.HealthBindAddress = tc.addr
+			}
+			if err := c.ValidateFlags(); (err != nil) != tc.expError {
+				t.Errorf("For %s=%q expected error %v, got %v", flag, tc.addr, tc.expError, err)
+			}
+		}
+	}
+}
+
+func TestScaleResourcesSet(t *testing.T) {
+	testCases := []struct {
+		flags    []string
+		expError bool
+		expValue string
+	}{
+		{[]string{"example.com/fpga=4"}, false, "[example.com/fpga=4]"},
+		{[]string{"example.com/fpga=4", "example.com/asic=0.5"}, false, "[example.com/fpga=4,example.com/asic=0.5]"},
+		{[]string{"example.com/fpga"}, true, ""},
+		{[]string{"=4"}, true, ""},
+		{[]string{"example.com/fpga=four"}, true, ""},
+		{[]string{"example.com/fpga=0"}, true, ""},
+		{[]string{"example.com/fpga=-1"}, true, ""},
+		{[]string{"example.com/fpga=NaN"}, true, ""},
+		{[]string{"example.com/fpga=4", "example.com/fpga=2"}, true, ""},
+	}
+
+	for _, tc := range testCases {
+		var r scaleResources
+		var err error
+		for _, flag := range tc.flags {
+			if err = r.Set(flag); err != nil {
+				break
+			}
+		}
+		if tc.expError {
+			if err == nil {
+				t.Errorf("Expected an error for --scale-resource %v", tc.flags)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for --scale-resource %v: %v", tc.flags, err)
+			continue
+		}
+		if r.String() != tc.expValue {
+			t.Errorf("Expected %s for --scale-resource %v, got %s", tc.expValue, tc.flags, r.String())
+		}
+	}
+}
+
+func TestIntRangeSet(t *testing.T) {
+	testCases := []struct {
+		flag      string
+		expError  bool
+		expValues []int
+	}{
+		{"0:100:50", false, []int{0, 50, 100}},
+		{"10:25:10", false, []int{10, 20}},
+		{"7", false, []int{7}},
+		{"5:5:1", false, []int{5}},
+		{"0:100", true, nil},
+		{"0:100:0", true, nil},
+		{"100:0:10", true, nil},
+		{"-1:10:1", true, nil},
+		{"a:b:c", true, nil},
+		{"0:1000000:1", true, nil},
+	}
+
+	for _, tc := range testCases {
+		var r intRange
+		err := r.Set(tc.flag)
+		if tc.expError {
+			if err == nil {
+				t.Errorf("Expected an error for range %q", tc.flag)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for range %q: %v", tc.flag, err)
+			continue
+		}
+		if !reflect.DeepEqual(r.Values(), tc.expValues) {
+			t.Errorf("Expected %v for range %q, got %v", tc.expValues, tc.flag, r.Values())
+		}
+	}
+}
+
+func TestNodeWeightsSet(t *testing.T) {
+	testCases := []struct {
+		flags    []string
+		expError bool
+		expValue string
+	}{
+		{[]string{"4xlarge=2"}, false, "4xlarge=2"},
+		{[]string{"small=0.5,4xlarge=2"}, false, "4xlarge=2,small=0.5"},
+		{[]string{"4xlarge=2", "small=0.5"}, false, "4xlarge=2,small=0.5"},
+		{[]string{"spot=0"}, false, "spot=0"},
+		{[]string{"4xlarge"}, true, ""},
+		{[]string{"=2"}, true, ""},
+		{[]string{"4xlarge=two"}, true, ""},
+		{[]string{"4xlarge=-1"}, true, ""},
+		{[]string{"4xlarge=Inf"}, true, ""},
+		{[]string{"4xlarge=2,"}, true, ""},
+		{[]string{"4xlarge=2", "4xlarge=3"}, true, ""},
+	}
+
+	for _, tc := range testCases {
+		var w nodeWeights
+		var err error
+		for _, flag := range tc.flags {
+			if err = w.Set(flag); err != nil {
+				break
+			}
+		}
+		if tc.expError {
+			if err == nil {
+				t.Errorf("Expected an error for --node-weights %v", tc.flags)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for --node-weights %v: %v", tc.flags, err)
+			continue
+		}
+		if w.String() != tc.expValue {
+			t.Errorf("Expected %s for --node-weights %v, got %s", tc.expValue, tc.flags, w.String())
+		}
+	}
+}
@@ -17,18 +17,31 @@ limitations under the License.
 package autoscaler
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/util/clock"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"sigs.k8s.io/yaml"
 
 	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/cmd/cluster-proportional-autoscaler/options"
 	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller"
 	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller/plugin"
 	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
-
-	"github.com/golang/glog"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
 )
 
 // AutoScaler determines the number of replicas to run
@@ -36,128 +49,1439 @@ type AutoScaler struct {
 	k8sClient           k8sclient.K8sClient
 	controller          controller.Controller
 	configMapName       string
+	configMapNamespace  string
+	configMapKey        string
+	secretName          string
+	activeProfile       string
 	defaultParams       map[string]string
+	onConfigMapMissing  string
+	paramsFormat        string
+	controllerType      string
 	pollPeriod          time.Duration
+	pollJitterFactor    float64
+	reactiveNodeDelta   int
+	reactiveCh          chan struct{}
+	reloadCh            chan struct{}
+	configMapResync     time.Duration
+	paramsVersion       string
+	parsedConfigMap     *v1.ConfigMap // The params of parsedConfigMapKey, once parsed.
+	parsedConfigMapKey  string
 	clock               clock.Clock
-	stopCh              chan struct{}
 	readyCh             chan<- struct{} // For testing.
 	healthServer        HealthServer
+	grpcHealthServer    *grpcHealthServer
 	lastPollCycleHealth *healthInfo
 	maxSyncFailures     int
 	exitFn              func()
+	stabilizers         map[string]*scaleDownStabilizer
+	scaleDownWindow     time.Duration
+	paramsStabilizers   map[string]*scaleDownStabilizer
+	smoother            *nodeCountSmoother
+	nodeDropBreaker     *nodeDropBreaker
+	drainBoost          *drainBoost
+	targetBounds        map[string]options.TargetBounds
+	maxReplicaChange    int32
+	absoluteMaxReplicas int32
+	maxScaleDownPercent int32
+	maxScaleUpPercent   int32
+	maxConsecutiveUps   int
+	metricsServer       *http.Server
+	metricsListener     net.Listener
+	healthAddr          string
+	leaseLock           resourcelock.Interface
+	dryRun              bool
+	logNoopCycles       bool
+	minReplicasPerZone  int32
+	capByMemoryRequests bool
+	location            *time.Location
+	schedule            *replicaSchedule
+	scheduleVersion     string
+	minScaleInterval    time.Duration
+	decayGuard          time.Duration
+	startupDelay        time.Duration
+	startTime           time.Time
+	respectPDB          bool
+	annotateTarget      bool
+	requireRunningPods  string
+	noPodsGracePeriod   time.Duration
+	// noPodsSince holds since when the desired replicas of each target have
+	// matched no pods.
+	noPodsSince        map[string]time.Time
+	webhook            *scaleWebhook
+	recommendationSink *recommendationSink
+	tracer             *otlpTracer
+	pollSpan           *traceSpan // The span of the poll in progress, if traced.
+	// recommendations holds the last result of the controller for each target.
+	recommendations map[string]controller.ExpectedReplicas
+	lastScaleTimes  map[string]time.Time
+	// decayGuardSince holds since when the scale downs of each target have
+	// been guarded, i.e. its last scale other than a guarded scale down.
+	decayGuardSince map[string]time.Time
+	// consecutiveUps counts the scale ups of each target since its last
+	// scale down or params change.
+	consecutiveUps map[string]int
+	// pauseCheckForbidden holds the targets whose annotations can't be read.
+	pauseCheckForbidden map[string]bool
+	unavailableBackoff  int
+	skippedPolls        int
+	// configurationLogged is set once the effective configuration is logged.
+	configurationLogged bool
+	// observedNodes and polledNodes are the number of nodes last reported by
+	// the node informer and at the last poll, accessed atomically.
+	observedNodes int32
+	polledNodes   int32
+	// lastConfigMap holds the last ConfigMap fetched from the apiserver.
+	lastConfigMap *v1.ConfigMap
 }
 
+// metricNodeSelectors returns the node selectors of the metrics which are
+// counted on their own subset of the nodes.
+func metricNodeSelectors(c *options.AutoScalerConfig) map[string]string {
+	return map[string]string{
+		k8sclient.MetricCores:  c.CoresNodeSelector,
+		k8sclient.MetricMemory: c.MemoryNodeSelector,
+		k8sclient.MetricPods:   c.PodsNodeSelector,
+		k8sclient.MetricGPUs:   c.GPUsNodeSelector,
+	}
+}
+
+// maxUnavailableBackoff caps the backoff between polls while all targets are
+// unavailable.
+const maxUnavailableBackoff = 5 * time.Minute
+
 // NewAutoScaler returns a new AutoScaler
 func NewAutoScaler(c *options.AutoScalerConfig) (*AutoScaler, error) {
-	newK8sClient, err := k8sclient.NewK8sClient(c.Namespace, c.Target, c.NodeLabels)
+	pollPeriod := time.Second * time.Duration(c.PollPeriodSeconds)
+	apiTimeout := c.KubeAPITimeout
+	if apiTimeout == 0 {
+		apiTimeout = pollPeriod
+	}
+	newK8sClient, err := k8sclient.NewK8sClient(k8sclient.Config{
+		Namespace:                c.Namespace,
+		Targets:                  c.Targets,
+		TargetNamespaceSelector:  c.TargetNamespaceSelector,
+		PinTargetUID:             c.PinTargetUID,
+		TargetWait:               c.TargetWait,
+		NodeLabels:               c.NodeLabels,
+		NodeFieldSelector:        c.NodeFieldSelector,
+		GPUResourceName:          c.GPUResourceName,
+		IgnoreTaintedNodes:       c.IgnoreTaintedNodes,
+		ToleratedTaintKeys:       c.ToleratedTaintKeys,
+		ResourceSource:           c.CoresSource,
+		ReadyNodesOnly:           c.CountReadyNodesOnly,
+		ExcludeControlPlaneNodes: c.ExcludeControlPlaneNodes,
+		MaxCoresPerNode:          int64(c.MaxCoresPerNode),
+		NodeWeightLabel:          c.NodeWeightLabel,
+		NodeWeights:              c.NodeWeights,
+		UnschedulableNodeWeight:  c.UnschedulableNodeWeight,
+		BackendSelector:          c.BackendSelector,
+		CountPendingPods:         c.CountPendingPods,
+		CountNamespaces:          c.CountNamespaces,
+		NamespaceSelector:        c.NamespaceSelector,
+		ScaleResources:           c.ScaleResources,
+		MetricNodeSelectors:      metricNodeSelectors(c),
+		DiscoveryRefresh:         time.Second * time.Duration(c.DiscoveryRefreshSeconds),
+		QPS:                      c.KubeAPIQPS,
+		Burst:                    c.KubeAPIBurst,
+		Timeout:                  apiTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	location, err := time.LoadLocation(c.Timezone)
 	if err != nil {
 		return nil, err
 	}
 	healthInfo := newHealthInfo()
-	healthServer := httpHealthServer{lastPollCycleHealth: healthInfo}
-	return &AutoScaler{
+	realClock := clock.RealClock{}
+	// Jittered intervals are up to (1 + jitter) poll periods long.
+	maxPollInterval := time.Duration(float64(pollPeriod) * (1 + c.PollJitterFactor))
+	healthServer := newHTTPHealthServer(c.HealthBindAddress, healthInfo, realClock, maxPollInterval, c.MaxSyncFailures)
+	if err := healthServer.listen(); err != nil {
+		return nil, err
+	}
+	var healthAddr string
+	if healthServer.listener != nil {
+		healthAddr = healthServer.listener.Addr().String()
+	}
+	var grpcHealthServer *grpcHealthServer
+	if c.GRPCHealthBindAddress != "" {
+		grpcHealthServer = newGRPCHealthServer(healthInfo, realClock, maxPollInterval)
+		if err := grpcHealthServer.listen(c.GRPCHealthBindAddress); err != nil {
+			return nil, err
+		}
+	}
+	var metricsServer *http.Server
+	var metricsListener net.Listener
+	if c.MetricsBindAddress != "" {
+		metricsServer = newMetricsServer(c.MetricsBindAddress)
+		if metricsListener, err = listen("--metrics-bind-address", c.MetricsBindAddress); err != nil {
+			return nil, err
+		}
+	}
+	var leaseLock resourcelock.Interface
+	if c.LeaderElect {
+		namespace := c.LeaderElectNamespace
+		if namespace == "" {
+			namespace = c.Namespace
+		}
+		if leaseLock, err = newLeaseLock(namespace, c.LeaderElectLeaseName); err != nil {
+			return nil, err
+		}
+	}
+	configMapNamespace := c.ConfigMapNamespace
+	if configMapNamespace == "" {
+		configMapNamespace = c.Namespace
+	}
+	defaultParams := map[string]string(c.DefaultParams)
+	if c.ConfigMapKey != "" && defaultParams != nil {
+		if defaultParams, err = nestDefaultParams(c.ConfigMapKey, defaultParams); err != nil {
+			return nil, err
+		}
+	}
+	var webhook *scaleWebhook
+	if c.ScaleWebhookURL != "" {
+		webhook = newScaleWebhook(c.ScaleWebhookURL, c.ScaleWebhookAuthHeader)
+	}
+	var sink *recommendationSink
+	if c.RecommendationSinkURL != "" {
+		sink = newRecommendationSink(c.RecommendationSinkURL)
+	}
+	var tracer *otlpTracer
+	if c.OTLPEndpoint != "" {
+		tracer = newOTLPTracer(c.OTLPEndpoint)
+	}
+	var smoother *nodeCountSmoother
+	if c.NodeCountSmoothingWindow > 1 {
+		smoother = newNodeCountSmoother(c.NodeCountSmoothingWindow)
+	}
+	var breaker *nodeDropBreaker
+	if c.MaxNodeDropFraction > 0 {
+		breaker = newNodeDropBreaker(c.MaxNodeDropFraction, time.Second*time.Duration(c.MaxNodeDropHoldSeconds), realClock)
+	}
+	var boost *drainBoost
+	if c.DrainBoostReplicas > 0 {
+		boost = newDrainBoost(int32(c.DrainBoostReplicas), time.Second*time.Duration(c.DrainBoostSeconds), realClock)
+	}
+	autoScaler := &AutoScaler{
 		k8sClient:           newK8sClient,
 		configMapName:       c.ConfigMap,
-		defaultParams:       c.DefaultParams,
-		pollPeriod:          time.Second * time.Duration(c.PollPeriodSeconds),
-		clock:               clock.RealClock{},
-		stopCh:              make(chan struct{}),
+		configMapNamespace:  configMapNamespace,
+		configMapKey:        c.ConfigMapKey,
+		secretName:          c.Secret,
+		activeProfile:       c.ActiveProfile,
+		defaultParams:       defaultParams,
+		onConfigMapMissing:  c.OnConfigMapMissing,
+		paramsFormat:        c.ParamsFormat,
+		controllerType:      c.ControllerType,
+		pollPeriod:          pollPeriod,
+		pollJitterFactor:    c.PollJitterFactor,
+		reactiveNodeDelta:   c.ReactiveNodeDelta,
+		configMapResync:     time.Second * time.Duration(c.ConfigMapResyncSeconds),
+		clock:               realClock,
 		readyCh:             make(chan struct{}, 1),
+		reloadCh:            make(chan struct{}, 1),
 		lastPollCycleHealth: healthInfo,
-		healthServer:        &healthServer,
+		healthServer:        healthServer,
+		grpcHealthServer:    grpcHealthServer,
 		maxSyncFailures:     c.MaxSyncFailures,
 		exitFn:              func() { os.Exit(1) },
-	}, nil
+		stabilizers:         make(map[string]*scaleDownStabilizer),
+		scaleDownWindow:     time.Second * time.Duration(c.ScaleDownStabilizationSeconds),
+		smoother:            smoother,
+		nodeDropBreaker:     breaker,
+		drainBoost:          boost,
+		targetBounds:        c.TargetBounds,
+		maxReplicaChange:    int32(c.MaxReplicaChangePerPoll),
+		absoluteMaxReplicas: int32(c.AbsoluteMaxReplicas),
+		maxScaleDownPercent: int32(c.MaxScaleDownPercent),
+		maxScaleUpPercent:   int32(c.MaxScaleUpPercent),
+		maxConsecutiveUps:   c.MaxConsecutiveScaleUps,
+		metricsServer:       metricsServer,
+		metricsListener:     metricsListener,
+		healthAddr:          healthAddr,
+		leaseLock:           leaseLock,
+		dryRun:              c.DryRun,
+		logNoopCycles:       c.LogNoopCycles,
+		minReplicasPerZone:  int32(c.MinReplicasPerZone),
+		capByMemoryRequests: c.MaxReplicasFromMemoryRequests,
+		location:            location,
+		minScaleInterval:    time.Second * time.Duration(c.MinSecondsBetweenScales),
+		decayGuard:          time.Second * time.Duration(c.DecayGuardSeconds),
+		startupDelay:        time.Second * time.Duration(c.StartupDelaySeconds),
+		respectPDB:          c.RespectPDB,
+		annotateTarget:      c.AnnotateTarget,
+		requireRunningPods:  c.RequireRunningPods,
+		noPodsGracePeriod:   time.Second * time.Duration(c.NoPodsGraceSeconds),
+		webhook:             webhook,
+		recommendationSink:  sink,
+		tracer:              tracer,
+	}
+	if c.ReactiveNodeDelta > 0 {
+		autoScaler.reactiveCh = make(chan struct{}, 1)
+		newK8sClient.SetNodeCountHandler(autoScaler.onNodeCountChange)
+	}
+	return autoScaler, nil
 }
 
 // Run periodically counts the number of nodes and cores, estimates the expected
 // number of replicas, compares them to the actual replicas, and
 // updates the target resource with the expected replicas if necessary.
-func (s *AutoScaler) Run() {
-	ticker := s.clock.NewTicker(s.pollPeriod)
+// With leader election enabled, Run blocks until this autoscaler becomes the
+// leader before it starts autoscaling. Run returns once ctx is done, after
+// the poll in progress, if any, has completed.
+func (s *AutoScaler) Run(ctx context.Context) {
+	s.startTime = s.clock.Now()
+	go s.healthServer.Start()
+	defer s.healthServer.Shutdown()
+	if s.grpcHealthServer != nil {
+		go s.grpcHealthServer.Start()
+		defer s.grpcHealthServer.Shutdown()
+	}
+	if s.metricsServer != nil {
+		go s.serveMetrics()
+	}
+	defer s.shutdownMetrics()
+
+	if s.leaseLock != nil {
+		s.runWithLeaderElection(ctx)
+		return
+	}
+	leaderGauge.Set(1)
+	s.runLoop(ctx.Done())
+}
+
+func (s *AutoScaler) runLoop(stopCh <-chan struct{}) {
+	// A ticker keeps exact intervals, jittered intervals are drawn after each
+	// poll instead.
+	var tick <-chan time.Time
+	if s.pollJitterFactor > 0 {
+		tick = s.clock.After(s.jitteredPollPeriod())
+	} else {
+		ticker := s.clock.NewTicker(s.pollPeriod)
+		defer ticker.Stop()
+		tick = ticker.C()
+	}
+	var resync <-chan time.Time
+	if s.configMapResync > 0 {
+		ticker := s.clock.NewTicker(s.configMapResync)
+		defer ticker.Stop()
+		resync = ticker.C()
+	}
+	s.lastPollCycleHealth.setPolling(s.clock.Now(), true)
+	defer s.lastPollCycleHealth.setPolling(s.clock.Now(), false)
 	s.readyCh <- struct{}{} // For testing.
 
-	go s.healthServer.Start()
 	// Don't wait for ticker and execute pollAPIServer() for the first time.
+	lastPoll := s.clock.Now()
 	s.tryPollAPIServer()
 
 	for {
 		select {
-		case <-ticker.C():
+		case <-tick:
+			// Don't start a new poll if both are ready.
+			select {
+			case <-stopCh:
+				return
+			default:
+			}
+			lastPoll = s.clock.Now()
 			s.tryPollAPIServer()
-		case <-s.stopCh:
+			if s.pollJitterFactor > 0 {
+				tick = s.clock.After(s.jitteredPollPeriod())
+			}
+		case <-s.reactiveCh:
+			// Left to the next poll if the last one was too recent.
+			if since := s.clock.Since(lastPoll); since < minReactivePollInterval {
+				logging.V(2).Infof("Not polling on node events, last poll was %v ago", since)
+				continue
+			}
+			lastPoll = s.clock.Now()
+			s.tryPollAPIServer()
+		case <-s.reloadCh:
+			lastPoll = s.clock.Now()
+			s.reload()
+		case <-resync:
+			if !s.paramsChanged() {
+				continue
+			}
+			logging.V(0).Infof("Params changed since the last poll, polling right away")
+			lastPoll = s.clock.Now()
+			s.tryPollAPIServer()
+		case <-stopCh:
 			return
 		}
 	}
 }
 
+// jitteredPollPeriod returns the poll period plus up to pollJitterFactor of
+// it, so that autoscalers started together don't poll the apiserver in sync.
+func (s *AutoScaler) jitteredPollPeriod() time.Duration {
+	return wait.Jitter(s.pollPeriod, s.pollJitterFactor)
+}
+
+func (s *AutoScaler) serveMetrics() {
+	logging.V(0).Infof("Serving metrics on %s", s.metricsListener.Addr())
+	if err := s.metricsServer.Serve(s.metricsListener); err != nil && err != http.ErrServerClosed {
+		logging.Errorf("Metrics server failed: %v", err)
+	}
+}
+
+func (s *AutoScaler) shutdownMetrics() {
+	if s.metricsServer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.metricsServer.Shutdown(ctx); err != nil {
+		logging.Errorf("Error shutting down metrics server: %v", err)
+	}
+}
+
 func (s *AutoScaler) tryPollAPIServer() {
+	if s.skippedPolls < s.unavailableBackoff {
+		s.skippedPolls++
+		logging.V(4).Infof("Backing off polling while targets are unavailable (%d/%d)", s.skippedPolls, s.unavailableBackoff)
+		return
+	}
 	err := s.pollAPIServer()
-	attempts := s.lastPollCycleHealth.setLastPollError(err)
+	s.markPolled()
+	s.backOffIfTargetsUnavailable(err)
+	attempts := s.lastPollCycleHealth.setLastPollError(err, s.clock.Now())
+	if err == nil {
+		s.lastPollCycleHealth.setLastSuccessfulPoll(s.clock.Now())
+	}
+	syncFailuresGauge.Set(float64(attempts))
 	// if we've tried polling the apiserver more times than allowed
 	if s.maxSyncFailures > 0 && attempts == s.maxSyncFailures {
-		glog.Errorf("Maximum number of api server polling attempts (%d) have been reached. Exiting application.", s.maxSyncFailures)
+		logging.Errorf("Maximum number of api server polling attempts (%d) have been reached. Exiting application.", s.maxSyncFailures)
 		s.exitFn()
 	}
 }
 
-func (s *AutoScaler) pollAPIServer() error {
+func (s *AutoScaler) pollAPIServer() (err error) {
+	s.pollSpan = s.tracer.startSpan("poll", s.clock.Now())
+	defer func() {
+		s.pollSpan.finish(s.clock.Now(), err)
+		s.pollSpan = nil
+	}()
+
 	// Query the apiserver for the cluster status --- number of nodes and cores
+	span := s.pollSpan.child("GetClusterStatus", s.clock.Now())
 	clusterStatus, err := s.k8sClient.GetClusterStatus()
+	span.finish(s.clock.Now(), err)
 	if err != nil {
-		glog.Errorf("Error while getting cluster status: %v", err)
+		logging.Errorf("Error while getting cluster status: %v", err)
 		return err
 	}
-	glog.V(4).Infof("Total nodes %5d, schedulable nodes: %5d", clusterStatus.TotalNodes, clusterStatus.SchedulableNodes)
-	glog.V(4).Infof("Total cores %5d, schedulable cores: %5d", clusterStatus.TotalCores, clusterStatus.SchedulableCores)
+	logging.V(4).Infof("Total nodes %5d, schedulable nodes: %5d", clusterStatus.TotalNodes, clusterStatus.SchedulableNodes)
+	logging.V(4).Infof("Total cores %5d, schedulable cores: %5d", clusterStatus.TotalCores, clusterStatus.SchedulableCores)
+	logging.V(4).Infof("Total memory %d, schedulable memory: %d", clusterStatus.TotalMemory, clusterStatus.SchedulableMemory)
+	logging.V(4).Infof("Total ephemeral storage %d, schedulable ephemeral storage: %d", clusterStatus.TotalEphemeralStorage, clusterStatus.SchedulableEphemeralStorage)
+	logging.V(4).Infof("Total pods %5d, schedulable pods: %5d", clusterStatus.TotalPods, clusterStatus.SchedulablePods)
+	logging.V(4).Infof("Total GPUs %5d, schedulable GPUs: %5d", clusterStatus.TotalGPUs, clusterStatus.SchedulableGPUs)
+	logging.V(4).Infof("Total zones %5d, schedulable zones: %5d", clusterStatus.TotalZones, clusterStatus.SchedulableZones)
+	logging.V(4).Infof("Pods matching the backend selector: %5d", clusterStatus.MatchingPods)
+	logging.V(4).Infof("Unschedulable pending pods: %5d", clusterStatus.PendingPods)
+	logging.V(4).Infof("Namespaces matching the namespace selector: %5d", clusterStatus.MatchingNamespaces)
+	logging.V(4).Infof("Draining nodes: %5d", clusterStatus.DrainingNodes)
+	schedulableNodesGauge.Set(float64(clusterStatus.SchedulableNodes))
+	schedulableCoresGauge.Set(float64(clusterStatus.SchedulableCores))
+	matchingPodsGauge.Set(float64(clusterStatus.MatchingPods))
+	pendingPodsGauge.Set(float64(clusterStatus.PendingPods))
+	namespacesGauge.Set(float64(clusterStatus.MatchingNamespaces))
+	// A frozen poll still syncs the params, only the replicas are held.
+	frozen := false
+	if s.nodeDropBreaker != nil {
+		frozen = s.nodeDropBreaker.tripped(clusterStatus.TotalNodes)
+		if frozen {
+			scalingFrozenGauge.Set(1)
+		} else {
+			scalingFrozenGauge.Set(0)
+		}
+	}
+	if s.drainBoost != nil && !frozen {
+		s.drainBoost.observe(clusterStatus.DrainingNodes)
+	}
+	if s.smoother != nil && !frozen {
+		smoothed := *clusterStatus
+		smoothed.SchedulableNodes, smoothed.SchedulableCores = s.smoother.smooth(clusterStatus.SchedulableNodes, clusterStatus.SchedulableCores)
+		logging.V(4).Infof("Smoothed schedulable nodes: %5d, smoothed schedulable cores: %5d", smoothed.SchedulableNodes, smoothed.SchedulableCores)
+		clusterStatus = &smoothed
+	}
 
 	// Sync autoscaler ConfigMap with apiserver
 	configMap, err := s.syncConfigWithServer()
 	if err != nil || configMap == nil {
-		glog.Errorf("Error syncing configMap with apiserver: %v", err)
+		logging.Errorf("Error syncing configMap with apiserver: %v", err)
+		return err
+	}
+	version := configMap.ObjectMeta.ResourceVersion
+	// Without a resource version there is no telling whether the params
+	// changed, so they are parsed on every poll.
+	unversioned := version == ""
+	if unversioned {
+		logging.V(2).Infof("ConfigMap has no resource version, parsing the params")
+	}
+	if configMap, err = s.parseConfigMap(configMap); err != nil {
 		return err
 	}
+	s.paramsVersion = version
+	s.lastPollCycleHealth.setReady()
 
 	// Only sync updated ConfigMap or before controller is set.
-	if s.controller == nil || configMap.ObjectMeta.ResourceVersion != s.controller.GetParamsVersion() {
+	if s.controller == nil || unversioned || version != s.controller.GetParamsVersion() {
+		if s.controller != nil && !unversioned {
+			logging.V(0).Infof("Params changed from version %s to %s, re-evaluating the replicas", s.controller.GetParamsVersion(), version)
+		}
 		// Ensure corresponding controller type and scaling params.
-		s.controller, err = plugin.EnsureController(s.controller, configMap)
+		s.controller, err = plugin.EnsureControllerOfMode(s.controller, configMap, s.controllerType)
 		if err != nil || s.controller == nil {
-			glog.Errorf("Error ensuring controller: %v", err)
+			logging.Errorf("Error ensuring controller: %v", err)
+			// Parse the params again on the next poll.
+			s.parsedConfigMap = nil
 			return err
 		}
+		// New params are an operator intervention lifting blocked scale ups.
+		if !unversioned {
+			for target := range s.consecutiveUps {
+				s.setConsecutiveUps(target, 0)
+			}
+		}
+	} else {
+		logging.V(4).Infof("Params unchanged at version %s, skipping parsing", version)
 	}
 
-	// Query the controller for the expected replicas number
-	expReplicas, err := s.controller.GetExpectedReplicas(clusterStatus)
+	// Only sync updated schedule.
+	if unversioned || version != s.scheduleVersion {
+		if err := s.syncSchedule(configMap); err != nil {
+			logging.Errorf("Error syncing schedule: %v", err)
+			return err
+		}
+	}
+	if !s.configurationLogged {
+		s.logConfiguration()
+		s.configurationLogged = true
+	}
+
+	// Scale each target independently so that a failure on one of them does
+	// not prevent the others from being updated.
+	var errs []error
+	status := &pollStatus{
+		Time:           s.clock.Now(),
+		ClusterStatus:  clusterStatus,
+		ControllerType: s.controller.GetControllerType(),
+		ParamsVersion:  s.controller.GetParamsVersion(),
+		Params:         s.controller.GetParams(),
+		Targets:        make(map[string]*targetStatus),
+		ScalingFrozen:  frozen,
+	}
+	targets := s.k8sClient.GetTargets()
+	if frozen {
+		logging.V(0).Infof("Scaling is frozen by the node count drop, holding the replicas of %d targets", len(targets))
+		targets = nil
+	}
+	for _, target := range targets {
+		ts := &targetStatus{}
+		status.Targets[target] = ts
+		if err := s.scaleTarget(target, clusterStatus, ts); err != nil {
+			if k8sclient.IsTargetNotFound(err) {
+				logging.Warningf("Target %s is unavailable, will retry: %v", target, err)
+				targetUnavailableCounter.WithLabelValues(target).Inc()
+			} else {
+				logging.Errorf("Error scaling target %s: %v", target, err)
+			}
+			ts.Error = err.Error()
+			errs = append(errs, err)
+		}
+		if lastScaleTime, ok := s.lastScaleTimes[target]; ok {
+			ts.LastScaleTime = &lastScaleTime
+		}
+	}
+	s.lastPollCycleHealth.setLastPollStatus(status)
+	if s.pollSpan != nil {
+		s.setPollSpanAttributes(status)
+	}
+	if s.recommendationSink != nil {
+		report := *status
+		if s.secretName != "" {
+			// Params from a Secret are not sent out.
+			report.Params = nil
+		}
+		s.recommendationSink.publish(&report)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// setPollSpanAttributes sets the cluster status and the replicas of the poll
+// on its span. The replicas are summed up over the targets.
+func (s *AutoScaler) setPollSpanAttributes(status *pollStatus) {
+	var expected, replicas int32
+	scaled := false
+	for _, ts := range status.Targets {
+		expected += ts.ExpectedReplicas
+		replicas += ts.Replicas
+		if ts.Error == "" && ts.Replicas != ts.CurrentReplicas {
+			scaled = true
+		}
+	}
+	s.pollSpan.setAttribute("cpa.nodes", status.ClusterStatus.SchedulableNodes)
+	s.pollSpan.setAttribute("cpa.cores", status.ClusterStatus.SchedulableCores)
+	s.pollSpan.setAttribute("cpa.targets", len(status.Targets))
+	s.pollSpan.setAttribute("cpa.replicas.expected", expected)
+	s.pollSpan.setAttribute("cpa.replicas", replicas)
+	s.pollSpan.setAttribute("cpa.scaled", scaled)
+}
+
+// logConfiguration logs the effective configuration on a single line, once the
+// params are loaded for the first time. The params themselves are left out as
+// they may come from a Secret.
+func (s *AutoScaler) logConfiguration() {
+	namespace := s.paramsNamespace()
+	keysAndValues := []interface{}{
+		"namespace", s.k8sClient.GetNamespace(),
+		"targets", s.k8sClient.GetTargets(),
+	}
+	if s.secretName != "" {
+		keysAndValues = append(keysAndValues, "secret", namespace+"/"+s.secretName)
+	} else {
+		keysAndValues = append(keysAndValues, "configMap", namespace+"/"+s.configMapName)
+	}
+	if s.configMapKey != "" {
+		keysAndValues = append(keysAndValues, "configMapKey", s.configMapKey)
+	}
+	if s.activeProfile != "" {
+		keysAndValues = append(keysAndValues, "profile", s.activeProfile)
+	}
+	keysAndValues = append(keysAndValues, "pollPeriod", s.pollPeriod.String(), "controller", s.controller.GetControllerType())
+	if bounded, ok := s.controller.(controller.BoundedController); ok {
+		min, max := bounded.GetBounds()
+		keysAndValues = append(keysAndValues, "min", min, "max", max)
+	}
+	keysAndValues = append(keysAndValues, "dryRun", s.dryRun)
+	if s.metricsListener != nil {
+		keysAndValues = append(keysAndValues, "metricsAddress", s.metricsListener.Addr().String())
+	}
+	if s.healthAddr != "" {
+		keysAndValues = append(keysAndValues, "healthAddress", s.healthAddr)
+	}
+	if s.grpcHealthServer != nil {
+		keysAndValues = append(keysAndValues, "grpcHealthAddress", s.grpcHealthServer.listener.Addr().String())
+	}
+	logging.InfoS("Effective configuration", keysAndValues...)
+}
+
+// paramsStabilizer returns the scale down stabilizer of the target for the
+// stabilization window of the params, starting a new one seeded with the
+// current replicas whenever the window changes.
+func (s *AutoScaler) paramsStabilizer(target string, window time.Duration, currentReplicas int32) *scaleDownStabilizer {
+	stabilizer := s.paramsStabilizers[target]
+	if stabilizer == nil || stabilizer.window != window {
+		if s.paramsStabilizers == nil {
+			s.paramsStabilizers = make(map[string]*scaleDownStabilizer)
+		}
+		stabilizer = newScaleDownStabilizer(window, s.clock)
+		stabilizer.seed(currentReplicas)
+		s.paramsStabilizers[target] = stabilizer
+	}
+	return stabilizer
+}
+
+// scaleTarget scales the target for the cluster status, reporting what it
+// computed in ts.
+func (s *AutoScaler) scaleTarget(target string, clusterStatus *k8sclient.ClusterStatus, ts *targetStatus) error {
+	span := s.pollSpan.child("GetReplicas", s.clock.Now())
+	span.setAttribute("cpa.target", target)
+	currentReplicas, err := s.k8sClient.GetReplicas(target)
+	span.finish(s.clock.Now(), err)
 	if err != nil {
-		glog.Errorf("Error calculating expected replicas number: %v", err)
 		return err
 	}
-	glog.V(4).Infof("Expected replica count: %3d", expReplicas)
+	ts.CurrentReplicas = currentReplicas
+	ts.Replicas = currentReplicas
+	if err := s.checkRunningPods(target, currentReplicas); err != nil {
+		return err
+	}
+
+	// Query the controller for the expected replicas number
+	expected, err := s.controller.GetExpectedReplicas(clusterStatus, currentReplicas)
+	if err != nil {
+		return fmt.Errorf("error calculating expected replicas number: %v", err)
+	}
+	s.recordRecommendation(target, expected)
+	ts.ExpectedReplicas = expected.Replicas
+	ts.BindingTerm = expected.BindingTerm
+	ts.RawValue = expected.RawValue
+	expReplicas := expected.Replicas
+	if stabilizedController, ok := s.controller.(controller.StabilizedController); ok && stabilizedController.GetStabilizationWindow() > 0 {
+		window := stabilizedController.GetStabilizationWindow()
+		stabilizedReplicas := s.paramsStabilizer(target, window, currentReplicas).stabilize(expReplicas)
+		if stabilizedReplicas != expReplicas {
+			logging.V(2).Infof("Holding replicas of %s at %d instead of %d until the lower replicas have lasted %v", target, stabilizedReplicas, expReplicas, window)
+			expReplicas = stabilizedReplicas
+		}
+	} else {
+		delete(s.paramsStabilizers, target)
+	}
+	if s.drainBoost != nil {
+		if boost := s.drainBoost.boost(); boost > 0 {
+			min, max := s.boundsAt(clusterStatus.SchedulableNodes)
+			boostedMin := min + boost
+			if max > 0 && boostedMin > max {
+				boostedMin = max
+			}
+			if expReplicas < boostedMin {
+				logging.V(2).Infof("Raising replicas of %s from %d to %d, the min boosted by %d while nodes are draining", target, expReplicas, boostedMin, boost)
+				expReplicas = boostedMin
+			}
+		}
+	}
+	// Discovered targets have the bounds of the target they were discovered from.
+	_, baseTarget := k8sclient.SplitNamespacedTarget(target)
+	if bounds, ok := s.targetBounds[baseTarget]; ok {
+		boundedReplicas := expReplicas
+		if boundedReplicas < bounds.Min {
+			boundedReplicas = bounds.Min
+		}
+		if bounds.Max > 0 && boundedReplicas > bounds.Max {
+			boundedReplicas = bounds.Max
+		}
+		if boundedReplicas != expReplicas {
+			logging.V(2).Infof("Bounding replicas of %s from %d to %d by the bounds of the target", target, expReplicas, boundedReplicas)
+			expReplicas = boundedReplicas
+		}
+	}
+	if s.capByMemoryRequests {
+		memoryReplicas, found, err := s.maxReplicasFromMemory(target, clusterStatus)
+		if err != nil {
+			return err
+		}
+		if found && expReplicas > memoryReplicas {
+			logging.V(2).Infof("Capping replicas of %s from %d to %d, as many as the schedulable memory can host", target, expReplicas, memoryReplicas)
+			expReplicas = memoryReplicas
+		}
+	}
+	if s.schedule != nil {
+		scheduledReplicas := s.schedule.apply(expReplicas, s.clock.Now())
+		if scheduledReplicas != expReplicas {
+			logging.V(2).Infof("Bounding replicas of %s from %d to %d by the active schedule window", target, expReplicas, scheduledReplicas)
+			expReplicas = scheduledReplicas
+		}
+	}
+	if s.minReplicasPerZone > 0 {
+		zoneReplicas := s.minReplicasPerZone * clusterStatus.SchedulableZones
+		if expReplicas < zoneReplicas {
+			logging.V(2).Infof("Raising replicas of %s from %d to %d to run at least %d per zone in %d zones", target, expReplicas, zoneReplicas, s.minReplicasPerZone, clusterStatus.SchedulableZones)
+			expReplicas = zoneReplicas
+		}
+	}
+	if floorController, ok := s.controller.(controller.TargetFloorController); ok && floorController.GetMinReplicasFromTarget() != "" {
+		floorReplicas, err := s.minReplicasFromTarget(floorController.GetMinReplicasFromTarget())
+		if err != nil {
+			return err
+		}
+		if expReplicas < floorReplicas {
+			logging.V(2).Infof("Raising replicas of %s from %d to %d, the replicas of %s", target, expReplicas, floorReplicas, floorController.GetMinReplicasFromTarget())
+			expReplicas = floorReplicas
+		}
+	}
+	if floorController, ok := s.controller.(controller.DaemonSetFloorController); ok && floorController.GetMinReplicasFromDaemonSet() != "" {
+		floorReplicas, err := s.minReplicasFromDaemonSet(floorController.GetMinReplicasFromDaemonSet())
+		if err != nil {
+			return err
+		}
+		if expReplicas < floorReplicas {
+			logging.V(2).Infof("Raising replicas of %s from %d to %d, the desired pods of DaemonSet %s", target, expReplicas, floorReplicas, floorController.GetMinReplicasFromDaemonSet())
+			expReplicas = floorReplicas
+		}
+	}
+	// The absolute ceiling is a last line of defense against bad params, so
+	// that not even the floors above exceed it.
+	if s.absoluteMaxReplicas > 0 && expReplicas > s.absoluteMaxReplicas {
+		logging.Warningf("Capping replicas of %s from %d to %d, the absolute max replicas", target, expReplicas, s.absoluteMaxReplicas)
+		expReplicas = s.absoluteMaxReplicas
+	}
+	logging.V(4).InfoS("Computed desired replicas", "target", target, "currentReplicas", currentReplicas, "desiredReplicas", expReplicas, "schedulableNodes", clusterStatus.SchedulableNodes, "schedulableCores", clusterStatus.SchedulableCores)
+	desiredReplicasGauge.WithLabelValues(target).Set(float64(expReplicas))
+	desiredReplicas := expReplicas
+	ts.DesiredReplicas = desiredReplicas
+
+	stabilizer := s.stabilizers[target]
+	if stabilizer == nil && s.scaleDownWindow > 0 {
+		// Targets may be discovered while running, each gets its stabilizer on
+		// its first poll.
+		stabilizer = newScaleDownStabilizer(s.scaleDownWindow, s.clock)
+		s.stabilizers[target] = stabilizer
+	}
+	if stabilizer != nil {
+		if !stabilizer.seeded {
+			stabilizer.seed(currentReplicas)
+		}
+		stabilizedReplicas := stabilizer.stabilize(expReplicas)
+		if stabilizedReplicas != expReplicas {
+			logging.V(2).Infof("Holding replicas of %s at %d instead of %d within the scale down stabilization window", target, stabilizedReplicas, expReplicas)
+			expReplicas = stabilizedReplicas
+		}
+	}
+
+	guarded := false
+	if since, ok := s.decayGuardSince[target]; ok && s.decayGuard > 0 && expReplicas < currentReplicas {
+		guardedReplicas := decayGuardReplicas(currentReplicas, expReplicas, s.clock.Since(since), s.decayGuard)
+		if guardedReplicas != expReplicas {
+			logging.V(2).Infof("Guarding scale down of %s: moving from %d to %d on the way to %d, %v after it was scaled", target, currentReplicas, guardedReplicas, expReplicas, s.clock.Since(since))
+			expReplicas = guardedReplicas
+			guarded = true
+		}
+	}
+
+	if s.maxReplicaChange > 0 {
+		limitedReplicas := limitReplicaChange(currentReplicas, expReplicas, s.maxReplicaChange)
+		if limitedReplicas != expReplicas {
+			logging.V(2).Infof("Throttling replicas change of %s: moving from %d to %d on the way to %d", target, currentReplicas, limitedReplicas, expReplicas)
+			expReplicas = limitedReplicas
+		}
+	}
+
+	if s.maxScaleDownPercent > 0 || s.maxScaleUpPercent > 0 {
+		limitedReplicas := limitReplicaChangePercent(currentReplicas, expReplicas, s.maxScaleDownPercent, s.maxScaleUpPercent)
+		if limitedReplicas != expReplicas {
+			logging.V(2).Infof("Throttling replicas change of %s by percentage: moving from %d to %d on the way to %d", target, currentReplicas, limitedReplicas, expReplicas)
+			expReplicas = limitedReplicas
+		}
+	}
+
+	if s.respectPDB && expReplicas < currentReplicas {
+		disruptionsAllowed, found, err := s.k8sClient.GetDisruptionsAllowed(target)
+		if err != nil {
+			return fmt.Errorf("error checking the PodDisruptionBudget before scaling down: %v", err)
+		}
+		if found && currentReplicas-expReplicas > disruptionsAllowed {
+			logging.V(2).Infof("Limiting scale down of %s to %d replicas instead of %d: its PodDisruptionBudget allows %d disruptions", target, currentReplicas-disruptionsAllowed, expReplicas, disruptionsAllowed)
+			expReplicas = currentReplicas - disruptionsAllowed
+		}
+	}
+
+	if s.maxConsecutiveUps > 0 {
+		blocked := false
+		if expReplicas < currentReplicas {
+			s.setConsecutiveUps(target, 0)
+		} else if expReplicas > currentReplicas && s.consecutiveUps[target] >= s.maxConsecutiveUps {
+			// The replicas are held rather than failing the poll, failures
+			// counting towards --max-sync-failures and a restart lifting the
+			// block.
+			logging.Errorf("Not scaling up %s from %d to %d replicas: it was scaled up %d times in a row, which may be a runaway loop, update the params or scale it down to resume scaling up", target, currentReplicas, expReplicas, s.consecutiveUps[target])
+			expReplicas = currentReplicas
+			blocked = true
+		}
+		s.setScaleUpsBlocked(target, blocked)
+		ts.ScaleUpsBlocked = blocked
+	}
+
+	if s.dryRun {
+		if currentReplicas != expReplicas {
+			logging.V(0).Infof("Dry run: would update replicas of %s from %d to %d", target, currentReplicas, expReplicas)
+			s.k8sClient.RecordEvent(target, v1.EventTypeNormal, "DryRunScale", fmt.Sprintf("Dry run: would scale replicas from %d to %d, %s", currentReplicas, expReplicas, scaleReason(clusterStatus, desiredReplicas)))
+		} else {
+			s.noopLog().Infof("Dry run: replicas of %s are as expected: %d", target, expReplicas)
+		}
+		currentReplicasGauge.WithLabelValues(target).Set(float64(currentReplicas))
+		return nil
+	}
+
+	if sinceStart := s.clock.Since(s.startTime); sinceStart < s.startupDelay {
+		if currentReplicas != expReplicas {
+			logging.V(0).Infof("Warming up for %v: would update replicas of %s from %d to %d", s.startupDelay-sinceStart, target, currentReplicas, expReplicas)
+		}
+		currentReplicasGauge.WithLabelValues(target).Set(float64(currentReplicas))
+		return nil
+	}
+
+	paused, err := s.isPaused(target)
+	if err != nil {
+		return fmt.Errorf("error checking whether scaling is paused: %v", err)
+	}
+	if paused {
+		log := logging.V(0)
+		if currentReplicas == expReplicas {
+			log = s.noopLog()
+		}
+		log.InfoS("Scaling paused by annotation, not updating replicas", "target", target, "annotation", pausedAnnotation, "currentReplicas", currentReplicas, "desiredReplicas", expReplicas)
+		currentReplicasGauge.WithLabelValues(target).Set(float64(currentReplicas))
+		return nil
+	}
+
+	if lastScaleTime, ok := s.lastScaleTimes[target]; ok && s.minScaleInterval > 0 {
+		if sinceLastScale := s.clock.Since(lastScaleTime); sinceLastScale < s.minScaleInterval {
+			logging.V(3).Infof("Deferring scaling of %s to %d replicas: last scaled %v ago, less than %v", target, expReplicas, sinceLastScale, s.minScaleInterval)
+			currentReplicasGauge.WithLabelValues(target).Set(float64(currentReplicas))
+			return nil
+		}
+	}
 
 	// Update resource target with expected replicas.
-	_, err = s.k8sClient.UpdateReplicas(expReplicas)
+	span = s.pollSpan.child("UpdateReplicas", s.clock.Now())
+	span.setAttribute("cpa.target", target)
+	span.setAttribute("cpa.replicas", expReplicas)
+	prevReplicas, err := s.k8sClient.UpdateReplicas(target, expReplicas)
+	span.finish(s.clock.Now(), err)
+	if err != nil {
+		return err
+	}
+	ts.Replicas = expReplicas
+	if prevReplicas != expReplicas {
+		if s.lastScaleTimes == nil {
+			s.lastScaleTimes = make(map[string]time.Time)
+		}
+		s.lastScaleTimes[target] = s.clock.Now()
+		if !guarded {
+			// Guarded scale downs don't restart the guard, so that it keeps
+			// relaxing until the whole scale down is allowed.
+			if s.decayGuardSince == nil {
+				s.decayGuardSince = make(map[string]time.Time)
+			}
+			s.decayGuardSince[target] = s.clock.Now()
+		}
+		scaleOperationsCounter.Inc()
+		if s.maxConsecutiveUps > 0 {
+			if prevReplicas < expReplicas {
+				s.setConsecutiveUps(target, s.consecutiveUps[target]+1)
+			} else {
+				s.setConsecutiveUps(target, 0)
+			}
+		}
+		logging.InfoS("Scaled target", "target", target, "previousReplicas", prevReplicas, "replicas", expReplicas, "desiredReplicas", desiredReplicas, "schedulableNodes", clusterStatus.SchedulableNodes, "schedulableCores", clusterStatus.SchedulableCores)
+		s.k8sClient.RecordEvent(target, v1.EventTypeNormal, "ScaledReplicas", fmt.Sprintf("Scaled replicas from %d to %d, %s", prevReplicas, expReplicas, scaleReason(clusterStatus, desiredReplicas)))
+		if s.annotateTarget {
+			s.annotateScaledTarget(target, desiredReplicas, clusterStatus)
+		}
+		if s.webhook != nil {
+			s.webhook.notify(scaleNotification{
+				Target:      target,
+				OldReplicas: prevReplicas,
+				NewReplicas: expReplicas,
+				Nodes:       clusterStatus.SchedulableNodes,
+				Cores:       clusterStatus.SchedulableCores,
+				Timestamp:   s.clock.Now(),
+			})
+		}
+	} else {
+		s.noopLog().Infof("Replicas of %s are as expected: %d", target, expReplicas)
+	}
+	currentReplicasGauge.WithLabelValues(target).Set(float64(expReplicas))
+	return nil
+}
+
+// checkRunningPods warns once the desired replicas of the target have matched
+// no pods for the grace period, which hints at a wrong target or a broken
+// selector, and fails with --require-running-pods=refuse.
+func (s *AutoScaler) checkRunningPods(target string, currentReplicas int32) error {
+	if s.requireRunningPods == "" || s.requireRunningPods == options.RequireRunningPodsOff {
+		return nil
+	}
+	pods := int32(0)
+	if currentReplicas > 0 {
+		var err error
+		if pods, err = s.k8sClient.CountPods(target); err != nil {
+			logging.Warningf("Not checking the pods of %s: %v", target, err)
+			return nil
+		}
+	}
+	if currentReplicas == 0 || pods > 0 {
+		delete(s.noPodsSince, target)
+		return nil
+	}
+	now := s.clock.Now()
+	since, ok := s.noPodsSince[target]
+	if !ok {
+		if s.noPodsSince == nil {
+			s.noPodsSince = make(map[string]time.Time)
+		}
+		s.noPodsSince[target] = now
+		since = now
+	}
+	elapsed := now.Sub(since)
+	if elapsed < s.noPodsGracePeriod {
+		return nil
+	}
+	message := fmt.Sprintf("%d desired replicas have matched no pods for %v, check the target and the selector of its pods", currentReplicas, elapsed)
+	if s.requireRunningPods == options.RequireRunningPodsRefuse {
+		return fmt.Errorf("refusing to scale %s: %s", target, message)
+	}
+	logging.Warningf("Target %s: %s", target, message)
+	s.k8sClient.RecordEvent(target, v1.EventTypeWarning, "NoMatchingPods", message)
+	return nil
+}
+
+// boundsAt returns the min and max of the params for the schedulable nodes, a
+// max of 0 meaning unbounded. Controllers without bounds have a min of 0.
+func (s *AutoScaler) boundsAt(schedulableNodes int32) (min, max int32) {
+	bounded, ok := s.controller.(controller.BoundedController)
+	if !ok {
+		return 0, 0
+	}
+	minBound, maxBound := bounded.GetBounds()
+	min = boundAt(minBound, schedulableNodes, true)
+	max = boundAt(maxBound, schedulableNodes, false)
+	return min, max
+}
+
+// boundAt evaluates a bound given as replicas or as a percentage of the
+// schedulable nodes, percentages being at least 1.
+func boundAt(bound string, schedulableNodes int32, roundUp bool) int32 {
+	value := intstr.Parse(bound)
+	replicas, err := intstr.GetValueFromIntOrPercent(&value, int(schedulableNodes), roundUp)
+	if err != nil {
+		return 0
+	}
+	if value.Type == intstr.String && replicas < 1 {
+		replicas = 1
+	}
+	return int32(replicas)
+}
+
+// setScaleUpsBlocked records whether the scale ups of the target are blocked
+// by --max-consecutive-scale-ups.
+func (s *AutoScaler) setScaleUpsBlocked(target string, blocked bool) {
+	s.lastPollCycleHealth.setScaleUpsBlocked(target, blocked)
+	if blocked {
+		scaleUpsBlockedGauge.WithLabelValues(target).Set(1)
+	} else {
+		scaleUpsBlockedGauge.WithLabelValues(target).Set(0)
+	}
+}
+
+// setConsecutiveUps records the number of scale ups of the target in a row.
+func (s *AutoScaler) setConsecutiveUps(target string, count int) {
+	if s.consecutiveUps == nil {
+		s.consecutiveUps = make(map[string]int)
+	}
+	s.consecutiveUps[target] = count
+	consecutiveScaleUpsGauge.WithLabelValues(target).Set(float64(count))
+}
+
+// maxReplicasFromMemory returns how many pods of the target the schedulable
+// memory can host, at least 1, and whether the pods request any memory.
+func (s *AutoScaler) maxReplicasFromMemory(target string, clusterStatus *k8sclient.ClusterStatus) (int32, bool, error) {
+	request, found, err := s.k8sClient.GetPodMemoryRequest(target)
 	if err != nil {
-		glog.Errorf("Update failure: %s", err)
+		return 0, false, fmt.Errorf("error getting the memory request of the pods: %v", err)
 	}
-	return err
+	if !found {
+		logging.V(3).Infof("Not capping replicas of %s by memory: its pod template sets no memory request", target)
+		return 0, false, nil
+	}
+	replicas := clusterStatus.SchedulableMemory / request
+	if replicas < 1 {
+		replicas = 1
+	} else if replicas > math.MaxInt32 {
+		replicas = math.MaxInt32
+	}
+	return int32(replicas), true, nil
+}
+
+// minReplicasFromTarget returns the replicas of the resource flooring the
+// expected replicas. A missing resource doesn't floor them, leaving the static
+// min of the params.
+func (s *AutoScaler) minReplicasFromTarget(resource string) (int32, error) {
+	replicas, err := s.k8sClient.GetReferencedReplicas(resource)
+	if k8sclient.IsTargetNotFound(err) {
+		logging.Warningf("Not flooring replicas on %s, falling back to the min of the params: %v", resource, err)
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error getting the replicas of %s from minReplicasFromTarget: %v", resource, err)
+	}
+	return replicas, nil
+}
+
+// minReplicasFromDaemonSet returns the desired pods of the DaemonSet flooring
+// the expected replicas. A missing DaemonSet doesn't floor them, leaving the
+// static min of the params.
+func (s *AutoScaler) minReplicasFromDaemonSet(daemonSet string) (int32, error) {
+	desired, found, err := s.k8sClient.GetDaemonSetDesiredPods(daemonSet)
+	if err != nil {
+		return 0, fmt.Errorf("error getting the desired pods of DaemonSet %s from minReplicasFromDaemonSet: %v", daemonSet, err)
+	}
+	if !found {
+		logging.Warningf("Not flooring replicas on DaemonSet %s, falling back to the min of the params: not found", daemonSet)
+		return 0, nil
+	}
+	return desired, nil
+}
+
+// Annotations set on the scaled targets with --annotate-target.
+const (
+	recommendedReplicasAnnotation = "cluster-proportional-autoscaler.kubernetes.io/recommended-replicas"
+	schedulableNodesAnnotation    = "cluster-proportional-autoscaler.kubernetes.io/schedulable-nodes"
+	schedulableCoresAnnotation    = "cluster-proportional-autoscaler.kubernetes.io/schedulable-cores"
+	lastScaledAtAnnotation        = "cluster-proportional-autoscaler.kubernetes.io/last-scaled-at"
+)
+
+// annotateScaledTarget annotates the target with the recommended replicas and
+// the cluster status they were computed from. Failures are only logged, the
+// replicas being already updated.
+func (s *AutoScaler) annotateScaledTarget(target string, desiredReplicas int32, clusterStatus *k8sclient.ClusterStatus) {
+	annotations := map[string]string{
+		recommendedReplicasAnnotation: strconv.Itoa(int(desiredReplicas)),
+		schedulableNodesAnnotation:    strconv.Itoa(int(clusterStatus.SchedulableNodes)),
+		schedulableCoresAnnotation:    strconv.Itoa(int(clusterStatus.SchedulableCores)),
+		lastScaledAtAnnotation:        s.clock.Now().UTC().Format(time.RFC3339),
+	}
+	if err := s.k8sClient.AnnotateTarget(target, annotations); err != nil {
+		logging.Warningf("Error annotating %s with its recommendation: %v", target, err)
+	}
+}
+
+// pausedAnnotation disables scaling of the target it is set to "true" on, e.g.
+// while replicas are set manually during maintenance.
+const pausedAnnotation = "cluster-proportional-autoscaler.kubernetes.io/paused"
+
+// isPaused returns whether scaling of the target is paused by annotation. The
+// target is not paused if the autoscaler is not allowed to get it, so that
+// RBAC rules granting access to the scale subresource only keep working.
+func (s *AutoScaler) isPaused(target string) (bool, error) {
+	annotations, err := s.k8sClient.GetAnnotations(target)
+	if apierrors.IsForbidden(err) {
+		if !s.pauseCheckForbidden[target] {
+			logging.Warningf("Not checking the %s annotation of %s: %v", pausedAnnotation, target, err)
+			if s.pauseCheckForbidden == nil {
+				s.pauseCheckForbidden = make(map[string]bool)
+			}
+			s.pauseCheckForbidden[target] = true
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return annotations[pausedAnnotation] == "true", nil
+}
+
+// recordRecommendation logs the result of the controller for the target and
+// exposes the term which drove it.
+func (s *AutoScaler) recordRecommendation(target string, expected controller.ExpectedReplicas) {
+	logging.V(2).InfoS("Controller recommendation", "target", target, "replicas", expected.Replicas, "bindingTerm", expected.BindingTerm, "rawValue", expected.RawValue)
+	if s.recommendations == nil {
+		s.recommendations = make(map[string]controller.ExpectedReplicas)
+	}
+	if last, ok := s.recommendations[target]; ok && last.BindingTerm != expected.BindingTerm {
+		bindingTermGauge.DeleteLabelValues(target, last.BindingTerm)
+	}
+	s.recommendations[target] = expected
+	bindingTermGauge.WithLabelValues(target, expected.BindingTerm).Set(expected.RawValue)
+}
+
+// backOffIfTargetsUnavailable exponentially backs off polling, in numbers of
+// poll periods, while the poll failed because all targets are unavailable.
+func (s *AutoScaler) backOffIfTargetsUnavailable(err error) {
+	s.skippedPolls = 0
+	if !s.allTargetsNotFound(err) {
+		s.unavailableBackoff = 0
+		return
+	}
+	if s.unavailableBackoff == 0 {
+		s.unavailableBackoff = 1
+	} else if time.Duration(s.unavailableBackoff*2)*s.pollPeriod <= maxUnavailableBackoff {
+		s.unavailableBackoff *= 2
+	}
+	logging.Warningf("All targets are unavailable, skipping the next %d polls", s.unavailableBackoff)
+}
+
+func (s *AutoScaler) allTargetsNotFound(err error) bool {
+	agg, ok := err.(utilerrors.Aggregate)
+	if !ok || len(agg.Errors()) != len(s.k8sClient.GetTargets()) {
+		return false
+	}
+	for _, e := range agg.Errors() {
+		if !k8sclient.IsTargetNotFound(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateParams runs the params through the same parsing and validation as
+// the ConfigMap at runtime, without any API access. A non empty mode requires
+// the params of that control mode, as --controller-type does.
+func ValidateParams(params map[string]string, mode string) error {
+	configMap := &v1.ConfigMap{Data: params}
+	if _, err := plugin.EnsureControllerOfMode(nil, configMap, mode); err != nil {
+		return err
+	}
+	if data, ok := params[plugin.ScheduleKey]; ok {
+		if _, err := parseSchedule([]byte(data), time.UTC); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseConfigMap converts the params from YAML and extracts them from their
+// key and profile, as configured. The result is cached by resource version so
+// that unchanged params are not parsed again on every poll.
+func (s *AutoScaler) parseConfigMap(configMap *v1.ConfigMap) (*v1.ConfigMap, error) {
+	version := configMap.ObjectMeta.ResourceVersion
+	cacheKey := fmt.Sprintf("%s/%s/%s/%s", version, s.paramsFormat, s.configMapKey, s.activeProfile)
+	if version != "" && s.parsedConfigMap != nil && cacheKey == s.parsedConfigMapKey {
+		return s.parsedConfigMap, nil
+	}
+	s.parsedConfigMap = nil
+	var err error
+	if s.paramsFormat == options.ParamsFormatYAML {
+		if configMap, err = yamlParamsToJSON(configMap); err != nil {
+			logging.Errorf("Error reading the params: %v", err)
+			return nil, err
+		}
+	}
+	if s.configMapKey != "" {
+		if configMap, err = nestedConfigMap(configMap, s.configMapKey); err != nil {
+			logging.Errorf("Error reading the params from the ConfigMap key: %v", err)
+			return nil, err
+		}
+	}
+	if s.activeProfile != "" {
+		if configMap, err = nestedConfigMap(configMap, s.activeProfile); err != nil {
+			logging.Errorf("Error selecting the active profile: %v", err)
+			return nil, err
+		}
+	}
+	s.parsedConfigMap, s.parsedConfigMapKey = configMap, cacheKey
+	return configMap, nil
+}
+
+func (s *AutoScaler) syncSchedule(configMap *v1.ConfigMap) error {
+	s.schedule = nil
+	if data, ok := configMap.Data[plugin.ScheduleKey]; ok {
+		schedule, err := parseSchedule([]byte(data), s.location)
+		if err != nil {
+			return err
+		}
+		s.schedule = schedule
+	}
+	s.scheduleVersion = configMap.ObjectMeta.ResourceVersion
+	return nil
+}
+
+// noopLog logs the polls which leave the replicas of a target unchanged, at
+// V(4) unless --log-noop-cycles is set.
+func (s *AutoScaler) noopLog() logging.Verbose {
+	if s.logNoopCycles {
+		return logging.V(0)
+	}
+	return logging.V(4)
+}
+
+// scaleReason describes the cluster status a replicas change was computed from.
+func scaleReason(clusterStatus *k8sclient.ClusterStatus, desiredReplicas int32) string {
+	return fmt.Sprintf("computed %d replicas from %d schedulable nodes and %d schedulable cores", desiredReplicas, clusterStatus.SchedulableNodes, clusterStatus.SchedulableCores)
+}
+
+// limitReplicaChange moves from current towards expected replicas by at most
+// maxChange replicas.
+func limitReplicaChange(current, expected, maxChange int32) int32 {
+	if expected > current+maxChange {
+		return current + maxChange
+	}
+	if expected < current-maxChange {
+		return current - maxChange
+	}
+	return expected
+}
+
+// limitReplicaChangePercent bounds a scale down to maxDownPercent, and a scale
+// up to maxUpPercent, of the current replicas, 0 meaning unbounded. The change
+// is rounded down but is always at least one replica so that it makes progress.
+func limitReplicaChangePercent(current, expected, maxDownPercent, maxUpPercent int32) int32 {
+	maxChange := func(percent int32) int32 {
+		change := int32(int64(current) * int64(percent) / 100)
+		if change < 1 {
+			change = 1
+		}
+		return change
+	}
+	if expected < current && maxDownPercent > 0 {
+		if change := maxChange(maxDownPercent); expected < current-change {
+			return current - change
+		}
+	}
+	if expected > current && maxUpPercent > 0 {
+		if change := maxChange(maxUpPercent); expected > current+change {
+			return current + change
+		}
+	}
+	return expected
+}
+
+// decayGuardReplicas allows a scale down from current to expected replicas in
+// proportion to the time elapsed since the target was scaled over the guard
+// period: none right after the scale, all of it once the period is over. The
+// allowed change is rounded down.
+func decayGuardReplicas(current, expected int32, elapsed, guard time.Duration) int32 {
+	if expected >= current || elapsed >= guard {
+		return expected
+	}
+	allowed := int32(int64(current-expected) * int64(elapsed) / int64(guard))
+	return current - allowed
 }
 
 func (s *AutoScaler) syncConfigWithServer() (*v1.ConfigMap, error) {
+	namespace := s.paramsNamespace()
+	if s.secretName != "" {
+		return s.syncSecretWithServer(namespace)
+	}
 	// Fetch autoscaler ConfigMap data from apiserver
-	configMap, err := s.k8sClient.FetchConfigMap(s.k8sClient.GetNamespace(), s.configMapName)
+	configMap, err := s.k8sClient.FetchConfigMap(namespace, s.configMapName)
 	if err == nil {
+		s.lastConfigMap = configMap
 		return configMap, nil
 	}
 
+	// Once the params were loaded, a deleted ConfigMap is handled per
+	// --on-configmap-missing.
+	if s.lastConfigMap != nil && apierrors.IsNotFound(err) {
+		switch s.onConfigMapMissing {
+		case options.OnConfigMapMissingHold:
+			logging.Warningf("ConfigMap not found: %v, holding the last known params (version %s)", err, s.lastConfigMap.ObjectMeta.ResourceVersion)
+			return s.lastConfigMap, nil
+		case options.OnConfigMapMissingFail:
+			return nil, err
+		}
+	}
+
 	if s.defaultParams == nil {
 		return nil, err
 	}
-	glog.V(0).Infof("ConfigMap not found: %v, will create one with default params", err)
-	configMap, err = s.k8sClient.CreateConfigMap(s.k8sClient.GetNamespace(), s.configMapName, s.defaultParams)
+	logging.V(0).Infof("ConfigMap not found: %v, will create one with default params", err)
+	configMap, err = s.k8sClient.CreateConfigMap(namespace, s.configMapName, s.defaultParams)
 	if err != nil {
 		return nil, err
 	}
+	s.lastConfigMap = configMap
 	return configMap, nil
 }
+
+// paramsNamespace returns the namespace of the ConfigMap or Secret holding
+// the params.
+func (s *AutoScaler) paramsNamespace() string {
+	if s.configMapNamespace != "" {
+		return s.configMapNamespace
+	}
+	return s.k8sClient.GetNamespace()
+}
+
+// paramsChanged returns whether the ConfigMap or Secret holding the params has
+// a new resource version since the last poll. It never creates them: a
+// missing ConfigMap or Secret is left to the next poll.
+func (s *AutoScaler) paramsChanged() bool {
+	namespace := s.paramsNamespace()
+	var version string
+	if s.secretName != "" {
+		secret, err := s.k8sClient.FetchSecret(namespace, s.secretName)
+		if err != nil {
+			logging.V(2).Infof("Error checking the Secret for new params: %v", err)
+			return false
+		}
+		version = secret.ObjectMeta.ResourceVersion
+	} else {
+		configMap, err := s.k8sClient.FetchConfigMap(namespace, s.configMapName)
+		if err != nil {
+			logging.V(2).Infof("Error checking the ConfigMap for new params: %v", err)
+			return false
+		}
+		version = configMap.ObjectMeta.ResourceVersion
+	}
+	return version != s.paramsVersion
+}
+
+// syncSecretWithServer fetches the params from a Secret, creating it with the
+// default params if needed, and returns them as a ConfigMap so that they go
+// through the same parsing path.
+func (s *AutoScaler) syncSecretWithServer(namespace string) (*v1.ConfigMap, error) {
+	secret, err := s.k8sClient.FetchSecret(namespace, s.secretName)
+	if err != nil {
+		if s.defaultParams == nil {
+			return nil, err
+		}
+		logging.V(0).Infof("Secret not found: %v, will create one with default params", err)
+		if secret, err = s.k8sClient.CreateSecret(namespace, s.secretName, s.defaultParams); err != nil {
+			return nil, err
+		}
+	}
+	return configMapFromSecret(secret), nil
+}
+
+// configMapFromSecret converts the data of a Secret, already base64-decoded by
+// the client, to a ConfigMap keeping its metadata and resource version.
+func configMapFromSecret(secret *v1.Secret) *v1.ConfigMap {
+	configMap := &v1.ConfigMap{ObjectMeta: secret.ObjectMeta}
+	configMap.Data = make(map[string]string, len(secret.Data))
+	for key, value := range secret.Data {
+		configMap.Data[key] = string(value)
+	}
+	return configMap
+}
+
+// nestDefaultParams returns the data of a ConfigMap holding the params under
+// the key, as read back by nestedConfigMap.
+func nestDefaultParams(key string, params map[string]string) (map[string]string, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{key: string(data)}, nil
+}
+
+// yamlParamsToJSON returns a copy of the ConfigMap with the params of each key
+// converted from YAML to the JSON the controllers expect. JSON params are kept
+// as is.
+func yamlParamsToJSON(configMap *v1.ConfigMap) (*v1.ConfigMap, error) {
+	converted := &v1.ConfigMap{ObjectMeta: configMap.ObjectMeta}
+	converted.Data = make(map[string]string, len(configMap.Data))
+	for key, data := range configMap.Data {
+		if json.Valid([]byte(data)) {
+			converted.Data[key] = data
+			continue
+		}
+		raw, err := yaml.YAMLToJSON([]byte(data))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse key %q as YAML: %v", key, err)
+		}
+		converted.Data[key] = string(raw)
+	}
+	return converted, nil
+}
+
+// nestedConfigMap returns a ConfigMap holding only the params under the key,
+// e.g. that of the active profile, keeping the metadata and resource version
+// of configMap. The key holds a JSON object of params in the same format as
+// --default-params.
+func nestedConfigMap(configMap *v1.ConfigMap, key string) (*v1.ConfigMap, error) {
+	data, ok := configMap.Data[key]
+	if !ok {
+		keys := make([]string, 0, len(configMap.Data))
+		for dataKey := range configMap.Data {
+			keys = append(keys, dataKey)
+		}
+		sort.Strings(keys)
+		return nil, fmt.Errorf("key %q not found in %s, available keys: %v", key, configMap.ObjectMeta.Name, keys)
+	}
+	var rawParams map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &rawParams); err != nil {
+		return nil, fmt.Errorf("could not parse key %q: %v", key, err)
+	}
+	nestedMap := &v1.ConfigMap{ObjectMeta: configMap.ObjectMeta}
+	nestedMap.Data = make(map[string]string, len(rawParams))
+	for name, param := range rawParams {
+		// Params may also be given as JSON strings, as in the ConfigMap.
+		if str, ok := param.(string); ok {
+			nestedMap.Data[name] = str
+			continue
+		}
+		marshaled, err := json.Marshal(param)
+		if err != nil {
+			return nil, err
+		}
+		nestedMap.Data[name] = string(marshaled)
+	}
+	return nestedMap, nil
+}
@@ -17,17 +17,28 @@ limitations under the License.
 package autoscaler
 
 import (
+	"context"
 	"errors"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/apimachinery/pkg/util/wait"
 
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/cmd/cluster-proportional-autoscaler/options"
 	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller/laddercontroller"
 	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller/linearcontroller"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller/plugin"
 	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestRun(t *testing.T) {
@@ -77,14 +88,14 @@ func TestRun(t *testing.T) {
 		clock:               fakeClock,
 		pollPeriod:          fakePollPeriod,
 		configMapName:       fakeConfigMapName,
-		stopCh:              make(chan struct{}),
 		readyCh:             make(chan<- struct{}, 1),
 		lastPollCycleHealth: newHealthInfo(),
 		healthServer:        mockHealthServer{},
 	}
 
-	go autoScaler.Run()
-	defer close(autoScaler.stopCh)
+	ctx, cancel := context.WithCancel(context.Background())
+	go autoScaler.Run(ctx)
+	defer cancel()
 
 	t.Logf("Scenario: cluster size changing\n")
 	t.Logf("Wait for the number of replicas be scaled to 1 even no node and no core)\n")
@@ -221,25 +232,24 @@ func TestRun_MaxRetries(t *testing.T) {
 	fakePollPeriod := 5 * time.Second
 	fakeConfigMapName := "fake-cluster-proportional-autoscaler-params"
 	readyCh := make(chan struct{})
-	stopCh := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
 	autoScaler := &AutoScaler{
 		k8sClient:           &mockK8s,
 		controller:          laddercontroller.NewLadderController(),
 		clock:               fakeClock,
 		pollPeriod:          fakePollPeriod,
 		configMapName:       fakeConfigMapName,
-		stopCh:              stopCh,
 		readyCh:             readyCh,
 		lastPollCycleHealth: newHealthInfo(),
 		maxSyncFailures:     maxRetries,
 		exitFn: func() {
 			exitFnCalled = true
-			// shutdown autoScaler via stopCh to stop
-			close(stopCh)
+			// shutdown autoScaler via ctx to stop
+			cancel()
 		},
 		healthServer: mockHealthServer{},
 	}
-	go autoScaler.Run()
+	go autoScaler.Run(ctx)
 	<-readyCh
 	for i := 0; i < maxRetries; i++ {
 		fakeClock.Step(fakePollPeriod)
@@ -256,18 +266,2022 @@ func TestRun_MaxRetries(t *testing.T) {
 	}
 }
 
-func waitForReplicasNumberSatisfy(t *testing.T, mockK8s *k8sclient.MockK8sClient, replicas int) error {
-	return wait.Poll(50*time.Millisecond, 3*time.Second, func() (done bool, err error) {
-		if mockK8s.NumOfReplicas != replicas {
-			t.Logf("Error number of replicas, expected: %d, got %d\n", replicas, mockK8s.NumOfReplicas)
-			return false, nil
+func TestSyncConfigWithServer_ConfigMapNamespace(t *testing.T) {
+	var fetchedNamespace, createdNamespace string
+	mockK8s := k8sclient.MockK8sClient{
+		FetchConfigMapFn: func(namespace, configmap string) (*v1.ConfigMap, error) {
+			fetchedNamespace = namespace
+			return nil, errors.New("mocked error")
+		},
+		CreateConfigMapFn: func(namespace, configmap string, params map[string]string) (*v1.ConfigMap, error) {
+			createdNamespace = namespace
+			return &v1.ConfigMap{Data: params}, nil
+		},
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:          &mockK8s,
+		configMapName:      "fake-cluster-proportional-autoscaler-params",
+		configMapNamespace: "platform-config",
+		defaultParams:      map[string]string{"linear": `{"nodesPerReplica": 1}`},
+	}
+	if _, err := autoScaler.syncConfigWithServer(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if fetchedNamespace != "platform-config" || createdNamespace != "platform-config" {
+		t.Errorf("Expected the ConfigMap to be fetched and created in platform-config, got %q and %q", fetchedNamespace, createdNamespace)
+	}
+}
+
+func TestSyncConfigWithServer_OnConfigMapMissing(t *testing.T) {
+	testCases := []struct {
+		onConfigMapMissing string
+		expErr             bool
+		expCreated         bool
+		expVersion         string
+	}{
+		{options.OnConfigMapMissingRecreateDefault, false, true, "2"},
+		{options.OnConfigMapMissingHold, false, false, "1"},
+		{options.OnConfigMapMissingFail, true, false, ""},
+	}
+
+	for _, tc := range testCases {
+		deleted := false
+		created := false
+		mockK8s := k8sclient.MockK8sClient{
+			FetchConfigMapFn: func(namespace, configmap string) (*v1.ConfigMap, error) {
+				if deleted {
+					return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, configmap)
+				}
+				return &v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"},
+					Data:       map[string]string{"linear": `{"nodesPerReplica": 2}`},
+				}, nil
+			},
+			CreateConfigMapFn: func(namespace, configmap string, params map[string]string) (*v1.ConfigMap, error) {
+				created = true
+				return &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "2"}, Data: params}, nil
+			},
 		}
-		return true, nil
-	})
+		autoScaler := &AutoScaler{
+			k8sClient:          &mockK8s,
+			configMapName:      "fake-cluster-proportional-autoscaler-params",
+			defaultParams:      map[string]string{"linear": `{"nodesPerReplica": 1}`},
+			onConfigMapMissing: tc.onConfigMapMissing,
+		}
+		if _, err := autoScaler.syncConfigWithServer(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.onConfigMapMissing, err)
+		}
+
+		deleted = true
+		configMap, err := autoScaler.syncConfigWithServer()
+		if tc.expErr != (err != nil) {
+			t.Errorf("%s: expected error %v, got %v", tc.onConfigMapMissing, tc.expErr, err)
+		}
+		if created != tc.expCreated {
+			t.Errorf("%s: expected the ConfigMap to be created %v, got %v", tc.onConfigMapMissing, tc.expCreated, created)
+		}
+		if configMap != nil && configMap.ObjectMeta.ResourceVersion != tc.expVersion {
+			t.Errorf("%s: expected params version %q, got %q", tc.onConfigMapMissing, tc.expVersion, configMap.ObjectMeta.ResourceVersion)
+		}
+	}
 }
 
-type mockHealthServer struct {
+func TestRun_CompletesPollInProgress(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"},
+		Data:       map[string]string{"linear": `{"nodesPerReplica": 1}`},
+	}
+	updating := make(chan struct{})
+	unblock := make(chan struct{})
+	var updates int
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    3,
+		NumOfReplicas: 1,
+		ConfigMap:     &testConfigMap,
+	}
+	mockK8s.UpdateReplicasFn = func(target string, expReplicas int32) (int32, error) {
+		updates++
+		close(updating)
+		<-unblock
+		mockK8s.NumOfReplicas = int(expReplicas)
+		return 1, nil
+	}
+	fakeClock := clock.NewFakeClock(time.Now())
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               fakeClock,
+		pollPeriod:          5 * time.Second,
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		readyCh:             make(chan<- struct{}, 1),
+		lastPollCycleHealth: newHealthInfo(),
+		healthServer:        mockHealthServer{},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		autoScaler.Run(ctx)
+		close(stopped)
+	}()
+
+	select {
+	case <-updating:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Timeout waiting for the first poll to update replicas")
+	}
+	cancel()
+	fakeClock.Step(autoScaler.pollPeriod)
+	select {
+	case <-stopped:
+		t.Fatalf("Expected Run to wait for the poll in progress")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(unblock)
+	select {
+	case <-stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Timeout waiting for autoscaler to stop")
+	}
+	if mockK8s.NumOfReplicas != 3 {
+		t.Errorf("Expected the poll in progress to scale to 3 replicas, got %d", mockK8s.NumOfReplicas)
+	}
+	if updates != 1 {
+		t.Errorf("Expected no poll to start after the context is done, got %d updates", updates)
+	}
 }
 
-func (s mockHealthServer) Start() {
+func TestRun_PollJitter(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"},
+		Data:       map[string]string{"linear": `{"nodesPerReplica": 1}`},
+	}
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    1,
+		NumOfReplicas: 0,
+		ConfigMap:     &testConfigMap,
+	}
+	fakeClock := clock.NewFakeClock(time.Now())
+	fakePollPeriod := 10 * time.Second
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               fakeClock,
+		pollPeriod:          fakePollPeriod,
+		pollJitterFactor:    0.5,
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		readyCh:             make(chan<- struct{}, 1),
+		lastPollCycleHealth: newHealthInfo(),
+		healthServer:        mockHealthServer{},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go autoScaler.Run(ctx)
+	defer cancel()
+
+	if err := waitForReplicasNumberSatisfy(t, &mockK8s, 1); err != nil {
+		t.Fatalf("Timeout waiting for the first poll: %v", err)
+	}
+	// Each interval is between 1 and 1.5 poll periods.
+	for nodes := 2; nodes <= 4; nodes++ {
+		mockK8s.NumOfNodes = nodes
+		if err := wait.Poll(10*time.Millisecond, 3*time.Second, func() (bool, error) {
+			return fakeClock.HasWaiters(), nil
+		}); err != nil {
+			t.Fatalf("Timeout waiting for the next poll to be timed: %v", err)
+		}
+		fakeClock.Step(fakePollPeriod - time.Nanosecond)
+		time.Sleep(50 * time.Millisecond)
+		if mockK8s.NumOfReplicas != nodes-1 {
+			t.Fatalf("Expected no poll before a poll period, got %d replicas", mockK8s.NumOfReplicas)
+		}
+		fakeClock.Step(fakePollPeriod / 2)
+		if err := waitForReplicasNumberSatisfy(t, &mockK8s, nodes); err != nil {
+			t.Fatalf("Timeout waiting for a poll within 1.5 poll periods: %v", err)
+		}
+	}
+}
+
+func TestRun_Reload(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"},
+		Data:       map[string]string{"linear": `{"nodesPerReplica": 1}`},
+	}
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    4,
+		NumOfReplicas: 0,
+		ConfigMap:     &testConfigMap,
+	}
+	fakeClock := clock.NewFakeClock(time.Now())
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               fakeClock,
+		pollPeriod:          time.Minute,
+		reloadCh:            make(chan struct{}, 1),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		readyCh:             make(chan<- struct{}, 1),
+		lastPollCycleHealth: newHealthInfo(),
+		healthServer:        mockHealthServer{},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go autoScaler.Run(ctx)
+	defer cancel()
+
+	if err := waitForReplicasNumberSatisfy(t, &mockK8s, 4); err != nil {
+		t.Fatalf("Timeout waiting for the first poll: %v", err)
+	}
+
+	// A reload picks up new params without waiting for the next poll.
+	newConfigMap := v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{ResourceVersion: "2"},
+		Data:       map[string]string{"linear": `{"nodesPerReplica": 2}`},
+	}
+	mockK8s.ConfigMap = &newConfigMap
+	autoScaler.Reload()
+	autoScaler.Reload()
+	if err := waitForReplicasNumberSatisfy(t, &mockK8s, 2); err != nil {
+		t.Fatalf("Timeout waiting for a poll on reload: %v", err)
+	}
+}
+
+func TestChangedKeys(t *testing.T) {
+	testCases := []struct {
+		before  map[string]string
+		after   map[string]string
+		expKeys []string
+	}{
+		{nil, nil, nil},
+		{map[string]string{"linear": "a"}, map[string]string{"linear": "a"}, nil},
+		{map[string]string{"linear": "a"}, map[string]string{"linear": "b"}, []string{"linear"}},
+		{map[string]string{"linear": "a", "schedule": "c"}, map[string]string{"ladder": "b", "linear": "a"}, []string{"ladder", "schedule"}},
+		{nil, map[string]string{"linear": "a"}, []string{"linear"}},
+	}
+
+	for _, tc := range testCases {
+		if keys := changedKeys(tc.before, tc.after); !reflect.DeepEqual(keys, tc.expKeys) {
+			t.Errorf("From %v to %v expected changed keys %v, got %v", tc.before, tc.after, tc.expKeys, keys)
+		}
+	}
+}
+
+func TestRun_ReactiveNodeDelta(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"},
+		Data:       map[string]string{"linear": `{"nodesPerReplica": 1}`},
+	}
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    3,
+		NumOfReplicas: 0,
+		ConfigMap:     &testConfigMap,
+	}
+	fakeClock := clock.NewFakeClock(time.Now())
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               fakeClock,
+		pollPeriod:          time.Minute,
+		reactiveNodeDelta:   2,
+		reactiveCh:          make(chan struct{}, 1),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		readyCh:             make(chan<- struct{}, 1),
+		lastPollCycleHealth: newHealthInfo(),
+		healthServer:        mockHealthServer{},
+	}
+	autoScaler.onNodeCountChange(3)
+	ctx, cancel := context.WithCancel(context.Background())
+	go autoScaler.Run(ctx)
+	defer cancel()
+
+	if err := waitForReplicasNumberSatisfy(t, &mockK8s, 3); err != nil {
+		t.Fatalf("Timeout waiting for the first poll: %v", err)
+	}
+	if err := wait.Poll(10*time.Millisecond, 3*time.Second, func() (bool, error) {
+		return atomic.LoadInt32(&autoScaler.polledNodes) == 3, nil
+	}); err != nil {
+		t.Fatalf("Timeout waiting for the first poll to complete: %v", err)
+	}
+	fakeClock.Step(minReactivePollInterval)
+
+	// A change below the delta waits for the next poll.
+	mockK8s.NumOfNodes = 4
+	autoScaler.onNodeCountChange(4)
+	time.Sleep(50 * time.Millisecond)
+	if mockK8s.NumOfReplicas != 3 {
+		t.Fatalf("Expected no poll for a change of 1 node, got %d replicas", mockK8s.NumOfReplicas)
+	}
+
+	// A change of at least the delta polls right away.
+	mockK8s.NumOfNodes = 5
+	autoScaler.onNodeCountChange(5)
+	if err := waitForReplicasNumberSatisfy(t, &mockK8s, 5); err != nil {
+		t.Fatalf("Timeout waiting for a poll on node events: %v", err)
+	}
+
+	// Polls on node events are rate limited.
+	mockK8s.NumOfNodes = 8
+	autoScaler.onNodeCountChange(8)
+	time.Sleep(50 * time.Millisecond)
+	if mockK8s.NumOfReplicas != 5 {
+		t.Fatalf("Expected no poll right after the last one, got %d replicas", mockK8s.NumOfReplicas)
+	}
+	fakeClock.Step(minReactivePollInterval)
+	autoScaler.onNodeCountChange(8)
+	if err := waitForReplicasNumberSatisfy(t, &mockK8s, 8); err != nil {
+		t.Fatalf("Timeout waiting for a poll on node events: %v", err)
+	}
+}
+
+func TestRun_ConfigMapResync(t *testing.T) {
+	testConfigMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"},
+		Data:       map[string]string{"linear": `{"nodesPerReplica": 1}`},
+	}
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    3,
+		NumOfReplicas: 0,
+		ConfigMap:     testConfigMap,
+	}
+	fakeClock := clock.NewFakeClock(time.Now())
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               fakeClock,
+		pollPeriod:          time.Minute,
+		configMapResync:     5 * time.Second,
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		readyCh:             make(chan<- struct{}, 1),
+		lastPollCycleHealth: newHealthInfo(),
+		healthServer:        mockHealthServer{},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go autoScaler.Run(ctx)
+	defer cancel()
+
+	if err := waitForReplicasNumberSatisfy(t, &mockK8s, 3); err != nil {
+		t.Fatalf("Timeout waiting for the first poll: %v", err)
+	}
+	// Let the loop wait on its tickers.
+	time.Sleep(50 * time.Millisecond)
+
+	// Unchanged params wait for the next poll.
+	mockK8s.NumOfNodes = 4
+	fakeClock.Step(5 * time.Second)
+	time.Sleep(50 * time.Millisecond)
+	if mockK8s.NumOfReplicas != 3 {
+		t.Fatalf("Expected no poll for unchanged params, got %d replicas", mockK8s.NumOfReplicas)
+	}
+
+	// New params are applied at the next resync, before the next poll.
+	mockK8s.ConfigMap = &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{ResourceVersion: "2"},
+		Data:       map[string]string{"linear": `{"nodesPerReplica": 0.5}`},
+	}
+	fakeClock.Step(5 * time.Second)
+	if err := waitForReplicasNumberSatisfy(t, &mockK8s, 8); err != nil {
+		t.Fatalf("Timeout waiting for a poll on new params: %v", err)
+	}
+}
+
+func TestSyncConfigWithServer_Secret(t *testing.T) {
+	var createdSecret bool
+	mockK8s := k8sclient.MockK8sClient{
+		FetchConfigMapFn: func(namespace, configmap string) (*v1.ConfigMap, error) {
+			t.Errorf("Unexpected fetch of ConfigMap %s/%s", namespace, configmap)
+			return nil, errors.New("mocked error")
+		},
+		FetchSecretFn: func(namespace, secret string) (*v1.Secret, error) {
+			return nil, errors.New("mocked error")
+		},
+		CreateSecretFn: func(namespace, secret string, params map[string]string) (*v1.Secret, error) {
+			createdSecret = true
+			data := make(map[string][]byte)
+			for key, value := range params {
+				data[key] = []byte(value)
+			}
+			return &v1.Secret{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"}, Data: data}, nil
+		},
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:     &mockK8s,
+		secretName:    "fake-cluster-proportional-autoscaler-params",
+		defaultParams: map[string]string{"linear": `{"nodesPerReplica": 1}`},
+	}
+	configMap, err := autoScaler.syncConfigWithServer()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !createdSecret {
+		t.Errorf("Expected a Secret to be created with the default params")
+	}
+	if configMap.ObjectMeta.ResourceVersion != "1" || configMap.Data["linear"] != `{"nodesPerReplica": 1}` {
+		t.Errorf("Unexpected params from Secret: %v", configMap)
+	}
+	if _, err := plugin.EnsureController(nil, configMap); err != nil {
+		t.Errorf("Unexpected error parsing params from Secret: %v", err)
+	}
+}
+
+func TestProfileConfigMap(t *testing.T) {
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "fake-cluster-proportional-autoscaler-params", ResourceVersion: "1"},
+		Data: map[string]string{
+			"default": `{"linear": {"nodesPerReplica": 1}}`,
+			"large":   `{"linear": "{\"nodesPerReplica\": 2}", "schedule": [{"start": "0 8 * * 1-5", "end": "0 18 * * 1-5", "min": 3}]}`,
+			"invalid": `[1, 2]`,
+		},
+	}
+	testCases := []struct {
+		profile  string
+		expData  map[string]string
+		expError bool
+	}{
+		{"default", map[string]string{"linear": `{"nodesPerReplica":1}`}, false},
+		{"large", map[string]string{"linear": `{"nodesPerReplica": 2}`, "schedule": `[{"end":"0 18 * * 1-5","min":3,"start":"0 8 * * 1-5"}]`}, false},
+		{"invalid", nil, true},
+		{"missing", nil, true},
+	}
+
+	for _, tc := range testCases {
+		profileMap, err := nestedConfigMap(configMap, tc.profile)
+		if tc.expError {
+			if err == nil {
+				t.Errorf("Expected an error for profile %q", tc.profile)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for profile %q: %v", tc.profile, err)
+			continue
+		}
+		if !reflect.DeepEqual(profileMap.Data, tc.expData) {
+			t.Errorf("Expected params %v for profile %q, got %v", tc.expData, tc.profile, profileMap.Data)
+		}
+		if profileMap.ObjectMeta.ResourceVersion != "1" {
+			t.Errorf("Expected the resource version of the ConfigMap to be kept, got %q", profileMap.ObjectMeta.ResourceVersion)
+		}
+	}
+}
+
+func TestNestDefaultParams(t *testing.T) {
+	params := map[string]string{
+		"linear":   `{"nodesPerReplica": 1}`,
+		"schedule": `[{"start": "0 8 * * *", "end": "0 18 * * *", "min": 2}]`,
+	}
+	data, err := nestDefaultParams("cluster-proportional-autoscaler", params)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(data) != 1 {
+		t.Fatalf("Expected the params under a single key, got %v", data)
+	}
+	configMap, err := nestedConfigMap(&v1.ConfigMap{Data: data}, "cluster-proportional-autoscaler")
+	if err != nil {
+		t.Fatalf("Unexpected error reading the params back: %v", err)
+	}
+	if !reflect.DeepEqual(configMap.Data, params) {
+		t.Errorf("Expected params %v to be read back, got %v", params, configMap.Data)
+	}
+}
+
+func TestYAMLParamsToJSON(t *testing.T) {
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "params", ResourceVersion: "3"},
+		Data: map[string]string{
+			"linear":   "coresPerReplica: 256\nnodesPerReplica: 16\n",
+			"ladder":   `{"nodesToReplicas": [[1, 1], [4, 2]]}`,
+			"schedule": "- start: 0 8 * * *\n  end: 0 18 * * *\n  min: 2\n",
+		},
+	}
+	converted, err := yamlParamsToJSON(configMap)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expData := map[string]string{
+		"linear":   `{"coresPerReplica":256,"nodesPerReplica":16}`,
+		"ladder":   `{"nodesToReplicas": [[1, 1], [4, 2]]}`,
+		"schedule": `[{"end":"0 18 * * *","min":2,"start":"0 8 * * *"}]`,
+	}
+	if !reflect.DeepEqual(converted.Data, expData) {
+		t.Errorf("Expected params %v, got %v", expData, converted.Data)
+	}
+	if converted.ObjectMeta.ResourceVersion != "3" {
+		t.Errorf("Expected the resource version to be kept, got %q", converted.ObjectMeta.ResourceVersion)
+	}
+
+	if _, err := yamlParamsToJSON(&v1.ConfigMap{Data: map[string]string{"linear": "coresPerReplica: [256"}}); err == nil {
+		t.Errorf("Expected an error for invalid YAML")
+	}
+}
+
+func TestPollAPIServer_YAMLParams(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"},
+		Data: map[string]string{
+			linearcontroller.ControllerType: "nodesPerReplica: 2\nmin: 1\n",
+		},
+	}
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    8,
+		NumOfReplicas: 1,
+		ConfigMap:     &testConfigMap,
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		paramsFormat:        options.ParamsFormatJSON,
+		lastPollCycleHealth: newHealthInfo(),
+	}
+
+	// YAML params are rejected unless --params-format=yaml.
+	if err := autoScaler.pollAPIServer(); err == nil {
+		t.Errorf("Expected YAML params to fail with the JSON format")
+	}
+
+	autoScaler.paramsFormat = options.ParamsFormatYAML
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 4 {
+		t.Errorf("Expected 4 replicas, got %d", mockK8s.NumOfReplicas)
+	}
+}
+
+func TestPollAPIServer_ParamsCache(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"},
+		Data: map[string]string{
+			linearcontroller.ControllerType: "nodesPerReplica: 2\nmin: 1\n",
+		},
+	}
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    8,
+		NumOfReplicas: 1,
+		ConfigMap:     &testConfigMap,
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		paramsFormat:        options.ParamsFormatYAML,
+		lastPollCycleHealth: newHealthInfo(),
+	}
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 4 {
+		t.Errorf("Expected 4 replicas, got %d", mockK8s.NumOfReplicas)
+	}
+
+	// The params are not parsed again while the version is unchanged.
+	testConfigMap.Data[linearcontroller.ControllerType] = "nodesPerReplica: [\n"
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Errorf("Expected the cached params to be used, got %v", err)
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = "2"
+	if err := autoScaler.pollAPIServer(); err == nil {
+		t.Errorf("Expected the params of the new version to be parsed and rejected")
+	}
+
+	// Without a resource version, the params are parsed on every poll.
+	mockK8s.FetchConfigMapFn = func(namespace, configmap string) (*v1.ConfigMap, error) {
+		return &v1.ConfigMap{Data: testConfigMap.Data}, nil
+	}
+	testConfigMap.Data[linearcontroller.ControllerType] = "nodesPerReplica: 4\nmin: 1\n"
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 2 {
+		t.Errorf("Expected 2 replicas, got %d", mockK8s.NumOfReplicas)
+	}
+	testConfigMap.Data[linearcontroller.ControllerType] = "nodesPerReplica: 1\nmin: 1\n"
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 8 {
+		t.Errorf("Expected 8 replicas from the unversioned params, got %d", mockK8s.NumOfReplicas)
+	}
+}
+
+func TestPollAPIServer_ConfigMapKey(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "shared-config", ResourceVersion: "1"},
+		Data: map[string]string{
+			"other-tool":                      `{"enabled": true}`,
+			"cluster-proportional-autoscaler": `{"linear": {"nodesPerReplica": 2}}`,
+		},
+	}
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    8,
+		NumOfReplicas: 1,
+		ConfigMap:     &testConfigMap,
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "shared-config",
+		configMapKey:        "cluster-proportional-autoscaler",
+		lastPollCycleHealth: newHealthInfo(),
+	}
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 4 {
+		t.Errorf("Expected 4 replicas from the params under the key, got %d", mockK8s.NumOfReplicas)
+	}
+
+	delete(testConfigMap.Data, "cluster-proportional-autoscaler")
+	testConfigMap.ObjectMeta.ResourceVersion = "2"
+	err := autoScaler.pollAPIServer()
+	if err == nil || !strings.Contains(err.Error(), `key "cluster-proportional-autoscaler" not found in shared-config`) {
+		t.Errorf("Expected an error naming the missing key, got %v", err)
+	}
+}
+
+func TestPollAPIServer_ActiveProfile(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"},
+		Data: map[string]string{
+			"small": `{"linear": {"nodesPerReplica": 10}}`,
+			"large": `{"linear": {"nodesPerReplica": 2}}`,
+		},
+	}
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    10,
+		NumOfReplicas: 1,
+		ConfigMap:     &testConfigMap,
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		activeProfile:       "large",
+		lastPollCycleHealth: newHealthInfo(),
+	}
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 5 {
+		t.Errorf("Expected 5 replicas from the large profile, got %d", mockK8s.NumOfReplicas)
+	}
+
+	autoScaler.activeProfile = "medium"
+	if err := autoScaler.pollAPIServer(); err == nil {
+		t.Errorf("Expected an error for a missing profile")
+	}
+	if mockK8s.NumOfReplicas != 5 {
+		t.Errorf("Expected the replicas to be left alone for a missing profile, got %d", mockK8s.NumOfReplicas)
+	}
+}
+
+func TestValidateParams(t *testing.T) {
+	testCases := []struct {
+		params   map[string]string
+		expError bool
+	}{
+		{map[string]string{"linear": `{"coresPerReplica": 2}`}, false},
+		{map[string]string{"ladder": `{"coresToReplicas": [[1, 1], [64, 3]]}`}, false},
+		{map[string]string{"ladder": `{"coresToReplicas": [[1, 1], [64]]}`}, true},
+		{map[string]string{"linear": `{"coresPerReplica": -2}`}, true},
+		{map[string]string{"linear": `{"coresPerReplica": 2}`, "exponential": `{"base": 2}`}, true},
+		{map[string]string{"linear": `{"coresPerReplica": 2}`, "schedule": `[{"start": "0 8 * * 1-5", "end": "0 18 * * 1-5", "min": 3}]`}, false},
+		{map[string]string{"linear": `{"coresPerReplica": 2}`, "schedule": `[{"start": "tomorrow"}]`}, true},
+	}
+
+	for _, tc := range testCases {
+		err := ValidateParams(tc.params, "")
+		if tc.expError && err == nil {
+			t.Errorf("Expected an error for params %v", tc.params)
+		} else if !tc.expError && err != nil {
+			t.Errorf("Unexpected error for params %v: %v", tc.params, err)
+		}
+	}
+}
+
+func waitForReplicasNumberSatisfy(t *testing.T, mockK8s *k8sclient.MockK8sClient, replicas int) error {
+	return wait.Poll(50*time.Millisecond, 3*time.Second, func() (done bool, err error) {
+		if mockK8s.NumOfReplicas != replicas {
+			t.Logf("Error number of replicas, expected: %d, got %d\n", replicas, mockK8s.NumOfReplicas)
+			return false, nil
+		}
+		return true, nil
+	})
+}
+
+type mockHealthServer struct {
+}
+
+func (s mockHealthServer) Start() {
+}
+
+func (s mockHealthServer) Shutdown() {
+}
+
+func TestLimitReplicaChange(t *testing.T) {
+	testCases := []struct {
+		current     int32
+		expected    int32
+		maxChange   int32
+		expReplicas int32
+	}{
+		{100, 500, 50, 150},
+		{150, 500, 50, 200},
+		{480, 500, 50, 500},
+		{500, 100, 50, 450},
+		{120, 100, 50, 100},
+		{0, 3, 1, 1},
+		{10, 10, 1, 10},
+	}
+
+	for _, tc := range testCases {
+		if replicas := limitReplicaChange(tc.current, tc.expected, tc.maxChange); replicas != tc.expReplicas {
+			t.Errorf("Limit replicas change from %d to %d by %d: expected %d, got %d", tc.current, tc.expected, tc.maxChange, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestLimitReplicaChangePercent(t *testing.T) {
+	testCases := []struct {
+		current        int32
+		expected       int32
+		maxDownPercent int32
+		maxUpPercent   int32
+		expReplicas    int32
+	}{
+		{100, 10, 25, 0, 75},
+		{75, 10, 25, 0, 57},
+		{12, 10, 25, 0, 10},
+		// Rounding never stalls a scale down.
+		{3, 1, 25, 0, 2},
+		{1, 0, 25, 0, 0},
+		// Scale ups are unbounded without maxUpPercent.
+		{10, 100, 25, 0, 100},
+		{10, 100, 25, 50, 15},
+		{0, 3, 0, 50, 1},
+		// Scale downs are unbounded without maxDownPercent.
+		{100, 10, 0, 50, 10},
+		{10, 10, 25, 50, 10},
+	}
+
+	for _, tc := range testCases {
+		if replicas := limitReplicaChangePercent(tc.current, tc.expected, tc.maxDownPercent, tc.maxUpPercent); replicas != tc.expReplicas {
+			t.Errorf("Limit replicas change from %d to %d by %d%% down and %d%% up: expected %d, got %d", tc.current, tc.expected, tc.maxDownPercent, tc.maxUpPercent, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestDecayGuardReplicas(t *testing.T) {
+	testCases := []struct {
+		current     int32
+		expected    int32
+		elapsed     time.Duration
+		expReplicas int32
+	}{
+		// No scale down right after the scale.
+		{20, 10, 0, 20},
+		{20, 10, 100 * time.Second, 17},
+		{20, 10, 150 * time.Second, 15},
+		{20, 10, 300 * time.Second, 10},
+		{20, 10, time.Hour, 10},
+		// Rounding down may hold small scale downs until the guard is over.
+		{3, 2, 299 * time.Second, 3},
+		// Scale ups are not guarded.
+		{10, 20, 0, 20},
+		{10, 10, 0, 10},
+	}
+
+	for _, tc := range testCases {
+		if replicas := decayGuardReplicas(tc.current, tc.expected, tc.elapsed, 300*time.Second); replicas != tc.expReplicas {
+			t.Errorf("Guard scale down from %d to %d %v after a scale: expected %d, got %d", tc.current, tc.expected, tc.elapsed, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestPollAPIServer_DecayGuard(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    20,
+		NumOfReplicas: 1,
+		ConfigMap:     &testConfigMap,
+	}
+	fakeClock := clock.NewFakeClock(time.Now())
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               fakeClock,
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+		decayGuard:          300 * time.Second,
+	}
+
+	testCases := []struct {
+		step        time.Duration
+		numOfNodes  int
+		expReplicas int
+	}{
+		// Scale ups are not guarded, and start the guard.
+		{0, 20, 20},
+		{0, 10, 20},
+		{100 * time.Second, 10, 17},
+		// The guarded scale down didn't restart the guard.
+		{100 * time.Second, 10, 13},
+		{100 * time.Second, 10, 10},
+		// A scale up starts it over.
+		{0, 15, 15},
+		{30 * time.Second, 10, 15},
+		{270 * time.Second, 10, 10},
+	}
+
+	for _, tc := range testCases {
+		fakeClock.Step(tc.step)
+		mockK8s.NumOfNodes = tc.numOfNodes
+		if err := autoScaler.pollAPIServer(); err != nil {
+			t.Fatalf("Unexpected poll failure: %v", err)
+		}
+		if mockK8s.NumOfReplicas != tc.expReplicas {
+			t.Errorf("After %v with %d nodes expected %d replicas, got %d", tc.step, tc.numOfNodes, tc.expReplicas, mockK8s.NumOfReplicas)
+		}
+	}
+}
+
+func TestPollAPIServer_MaxScaleDownPercent(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    10,
+		NumOfReplicas: 100,
+		ConfigMap:     &testConfigMap,
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+		maxScaleDownPercent: 25,
+	}
+
+	for _, expReplicas := range []int{75, 57, 43, 33, 25, 19, 15, 12, 10, 10} {
+		if err := autoScaler.pollAPIServer(); err != nil {
+			t.Fatalf("Unexpected poll failure: %v", err)
+		}
+		if mockK8s.NumOfReplicas != expReplicas {
+			t.Fatalf("Expected replicas to ramp down to %d, got %d", expReplicas, mockK8s.NumOfReplicas)
+		}
+	}
+
+	// Scale ups stay immediate.
+	mockK8s.NumOfNodes = 50
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 50 {
+		t.Errorf("Expected replicas to scale up to 50, got %d", mockK8s.NumOfReplicas)
+	}
+}
+
+func TestRun_MaxReplicaChange(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    500,
+		NumOfReplicas: 100,
+		ConfigMap:     &testConfigMap,
+	}
+
+	fakeClock := clock.NewFakeClock(time.Now())
+	fakePollPeriod := 5 * time.Second
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               fakeClock,
+		pollPeriod:          fakePollPeriod,
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		readyCh:             make(chan<- struct{}, 1),
+		lastPollCycleHealth: newHealthInfo(),
+		healthServer:        mockHealthServer{},
+		maxReplicaChange:    200,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go autoScaler.Run(ctx)
+	defer cancel()
+
+	t.Logf("Wait for the number of replicas be throttled to 300 on the first poll\n")
+	if err := waitForReplicasNumberSatisfy(t, &mockK8s, 300); err != nil {
+		t.Fatalf("Timeout waiting for condition: %v", err)
+	}
+
+	fakeClock.Step(fakePollPeriod)
+	t.Logf("Wait for the number of replicas to converge to 500\n")
+	if err := waitForReplicasNumberSatisfy(t, &mockK8s, 500); err != nil {
+		t.Fatalf("Timeout waiting for condition: %v", err)
+	}
+}
+
+func TestPollAPIServer_DryRun(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    10,
+		NumOfReplicas: 2,
+		ConfigMap:     &testConfigMap,
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+		dryRun:              true,
+	}
+
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if autoScaler.controller == nil || autoScaler.controller.GetParamsVersion() != "1" {
+		t.Errorf("Expected params to be synced in dry run mode")
+	}
+	if mockK8s.NumOfReplicas != 2 {
+		t.Errorf("Expected replicas to stay at 2 in dry run mode, got %d", mockK8s.NumOfReplicas)
+	}
+	expEvents := []string{"deployment/mock Normal DryRunScale Dry run: would scale replicas from 2 to 10, computed 10 replicas from 10 schedulable nodes and 0 schedulable cores"}
+	if !reflect.DeepEqual(mockK8s.Events, expEvents) {
+		t.Errorf("Expected events %v in dry run mode, got %v", expEvents, mockK8s.Events)
+	}
+}
+
+func TestNoopLog(t *testing.T) {
+	// Tests run at the default verbosity, below V(4).
+	autoScaler := &AutoScaler{}
+	if autoScaler.noopLog() {
+		t.Errorf("Expected polls leaving the replicas unchanged not to be logged by default")
+	}
+	autoScaler.logNoopCycles = true
+	if !autoScaler.noopLog() {
+		t.Errorf("Expected polls leaving the replicas unchanged to be logged with --log-noop-cycles")
+	}
+}
+
+func TestPollAPIServer_ScaleToZeroAndBack(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 10, "min": 0, "allowScaleToZero": true}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    0,
+		NumOfReplicas: 3,
+		ConfigMap:     &testConfigMap,
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+	}
+
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 0 {
+		t.Errorf("Expected replicas to be scaled to 0, got %d", mockK8s.NumOfReplicas)
+	}
+
+	mockK8s.NumOfNodes = 15
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 2 {
+		t.Errorf("Expected replicas to be scaled back up from 0 to 2, got %d", mockK8s.NumOfReplicas)
+	}
+}
+
+func TestPollAPIServer_RecordsPollStatus(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 2}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    10,
+		NumOfReplicas: 3,
+		ConfigMap:     &testConfigMap,
+	}
+	fakeClock := clock.NewFakeClock(time.Now())
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               fakeClock,
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+	}
+
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	status := autoScaler.lastPollCycleHealth.getLastPollStatus()
+	if status == nil {
+		t.Fatalf("Expected the status of the poll to be recorded")
+	}
+	if !status.Time.Equal(fakeClock.Now()) || status.ControllerType != linearcontroller.ControllerType || status.ParamsVersion != "1" || status.Params == nil {
+		t.Errorf("Unexpected poll status %+v", status)
+	}
+	if status.ClusterStatus.SchedulableNodes != 10 {
+		t.Errorf("Expected 10 schedulable nodes in the poll status, got %d", status.ClusterStatus.SchedulableNodes)
+	}
+	ts := status.Targets["deployment/mock"]
+	if ts == nil {
+		t.Fatalf("Expected the status of deployment/mock in %+v", status.Targets)
+	}
+	if ts.CurrentReplicas != 3 || ts.ExpectedReplicas != 5 || ts.DesiredReplicas != 5 || ts.Replicas != 5 || ts.BindingTerm != "nodes" || ts.LastScaleTime == nil {
+		t.Errorf("Unexpected target status %+v", ts)
+	}
+}
+
+func TestPollAPIServer_TargetBounds(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1, "max": 100}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes: 10,
+		Targets:    []string{"deployment/first", "deployment/second", "deployment/third"},
+		TargetReplicas: map[string]int{
+			"deployment/first":  1,
+			"deployment/second": 1,
+			"deployment/third":  1,
+		},
+		ConfigMap: &testConfigMap,
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+		targetBounds: map[string]options.TargetBounds{
+			"deployment/first":  {Min: 2, Max: 5},
+			"deployment/second": {Min: 20},
+		},
+	}
+
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	expReplicas := map[string]int{
+		"deployment/first":  5,
+		"deployment/second": 20,
+		"deployment/third":  10,
+	}
+	if !reflect.DeepEqual(mockK8s.TargetReplicas, expReplicas) {
+		t.Errorf("Expected replicas %v, got %v", expReplicas, mockK8s.TargetReplicas)
+	}
+}
+
+func TestPollAPIServer_AbsoluteMaxReplicas(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1, "max": 100}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes: 10,
+		Targets:    []string{"deployment/first", "deployment/second", "deployment/third"},
+		TargetReplicas: map[string]int{
+			"deployment/first":  1,
+			"deployment/second": 1,
+			"deployment/third":  1,
+		},
+		ConfigMap: &testConfigMap,
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+		absoluteMaxReplicas: 8,
+		targetBounds: map[string]options.TargetBounds{
+			"deployment/first":  {Max: 5},
+			"deployment/second": {Min: 20},
+		},
+	}
+
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	// The ceiling applies over the params and the min of the target alike.
+	expReplicas := map[string]int{
+		"deployment/first":  5,
+		"deployment/second": 8,
+		"deployment/third":  8,
+	}
+	if !reflect.DeepEqual(mockK8s.TargetReplicas, expReplicas) {
+		t.Errorf("Expected replicas %v, got %v", expReplicas, mockK8s.TargetReplicas)
+	}
+}
+
+func TestPollAPIServer_RequireRunningPods(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    10,
+		NumOfReplicas: 2,
+		ConfigMap:     &testConfigMap,
+	}
+	fakeClock := clock.NewFakeClock(time.Now())
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               fakeClock,
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+		requireRunningPods:  options.RequireRunningPodsRefuse,
+		noPodsGracePeriod:   time.Minute,
+	}
+
+	// Matching no pods is tolerated for the grace period.
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 10 {
+		t.Errorf("Expected 10 replicas within the grace period, got %d", mockK8s.NumOfReplicas)
+	}
+
+	mockK8s.NumOfNodes = 20
+	fakeClock.Step(time.Minute)
+	if err := autoScaler.pollAPIServer(); err == nil {
+		t.Errorf("Expected the poll to fail once no pods matched for the grace period")
+	}
+	if mockK8s.NumOfReplicas != 10 {
+		t.Errorf("Expected scaling to be refused at 10 replicas, got %d", mockK8s.NumOfReplicas)
+	}
+
+	// Warning records an event and scales.
+	autoScaler.requireRunningPods = options.RequireRunningPodsWarn
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 20 {
+		t.Errorf("Expected 20 replicas when only warning, got %d", mockK8s.NumOfReplicas)
+	}
+	if len(mockK8s.Events) != 3 || !strings.Contains(mockK8s.Events[1], "Warning NoMatchingPods") {
+		t.Errorf("Expected a NoMatchingPods event, got %v", mockK8s.Events)
+	}
+
+	// Matching pods again resets the grace period.
+	autoScaler.requireRunningPods = options.RequireRunningPodsRefuse
+	mockK8s.TargetPods = map[string]int32{"deployment/mock": 20}
+	mockK8s.NumOfNodes = 30
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 30 {
+		t.Errorf("Expected 30 replicas once pods match, got %d", mockK8s.NumOfReplicas)
+	}
+	if _, ok := autoScaler.noPodsSince["deployment/mock"]; ok {
+		t.Errorf("Expected the grace period to be reset once pods match")
+	}
+}
+
+func TestPollAPIServer_AnnotateTarget(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 2}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    10,
+		NumOfCores:    40,
+		NumOfReplicas: 2,
+		ConfigMap:     &testConfigMap,
+	}
+	fakeClock := clock.NewFakeClock(time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC))
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               fakeClock,
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+		annotateTarget:      true,
+	}
+
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	expAnnotations := map[string]string{
+		recommendedReplicasAnnotation: "5",
+		schedulableNodesAnnotation:    "10",
+		schedulableCoresAnnotation:    "40",
+		lastScaledAtAnnotation:        "2020-01-02T15:04:05Z",
+	}
+	if !reflect.DeepEqual(mockK8s.Annotations["deployment/mock"], expAnnotations) {
+		t.Errorf("Expected annotations %v, got %v", expAnnotations, mockK8s.Annotations["deployment/mock"])
+	}
+
+	// Failing to annotate the target doesn't fail the poll.
+	mockK8s.NumOfNodes = 20
+	mockK8s.AnnotateTargetFn = func(target string, annotations map[string]string) error {
+		return errors.New("forbidden")
+	}
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 10 {
+		t.Errorf("Expected replicas to be scaled to 10 despite the annotation failure, got %d", mockK8s.NumOfReplicas)
+	}
+}
+
+func TestPollAPIServer_Paused(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    10,
+		NumOfReplicas: 2,
+		ConfigMap:     &testConfigMap,
+		Annotations: map[string]map[string]string{
+			"deployment/mock": {pausedAnnotation: "true"},
+		},
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+	}
+
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 2 {
+		t.Errorf("Expected replicas to stay at 2 while paused, got %d", mockK8s.NumOfReplicas)
+	}
+	if ts := autoScaler.lastPollCycleHealth.getLastPollStatus().Targets["deployment/mock"]; ts.DesiredReplicas != 10 {
+		t.Errorf("Expected recommendations to be computed while paused, got %+v", ts)
+	}
+
+	mockK8s.Annotations["deployment/mock"][pausedAnnotation] = "false"
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 10 {
+		t.Errorf("Expected replicas to be scaled to 10 once resumed, got %d", mockK8s.NumOfReplicas)
+	}
+
+	// Targets which can't be read are not paused.
+	mockK8s.NumOfNodes = 5
+	mockK8s.GetAnnotationsFn = func(target string) (map[string]string, error) {
+		return nil, apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, "mock", errors.New("forbidden"))
+	}
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 5 {
+		t.Errorf("Expected replicas to be scaled to 5 when annotations are forbidden, got %d", mockK8s.NumOfReplicas)
+	}
+
+	// Other errors skip scaling.
+	mockK8s.NumOfNodes = 7
+	mockK8s.GetAnnotationsFn = func(target string) (map[string]string, error) {
+		return nil, errors.New("connection refused")
+	}
+	if err := autoScaler.pollAPIServer(); err == nil {
+		t.Errorf("Expected poll to fail when the annotations can't be fetched")
+	}
+	if mockK8s.NumOfReplicas != 5 {
+		t.Errorf("Expected replicas to stay at 5 when the annotations can't be fetched, got %d", mockK8s.NumOfReplicas)
+	}
+}
+
+func TestPollAPIServer_MinReplicasFromTarget(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 2, "min": 2, "minReplicasFromTarget": "deployment/companion"}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:         10,
+		NumOfReplicas:      1,
+		ConfigMap:          &testConfigMap,
+		ReferencedReplicas: map[string]int32{"deployment/companion": 8},
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+	}
+
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 8 {
+		t.Errorf("Expected replicas to be raised to the 8 replicas of the companion, got %d", mockK8s.NumOfReplicas)
+	}
+
+	// Replicas computed above the floor are kept.
+	mockK8s.NumOfNodes = 20
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 10 {
+		t.Errorf("Expected 10 replicas computed from nodes, got %d", mockK8s.NumOfReplicas)
+	}
+
+	// A missing companion falls back to the static min.
+	mockK8s.NumOfNodes = 2
+	delete(mockK8s.ReferencedReplicas, "deployment/companion")
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 2 {
+		t.Errorf("Expected replicas to fall back to the min of 2, got %d", mockK8s.NumOfReplicas)
+	}
+}
+
+func TestPollAPIServer_MinReplicasFromDaemonSet(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 4, "min": 2, "minReplicasFromDaemonSet": "kube-system/node-agent"}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:           12,
+		NumOfReplicas:        1,
+		ConfigMap:            &testConfigMap,
+		DaemonSetDesiredPods: map[string]int32{"kube-system/node-agent": 7},
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+	}
+
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 7 {
+		t.Errorf("Expected replicas to be raised to the 7 desired pods of the DaemonSet, got %d", mockK8s.NumOfReplicas)
+	}
+
+	// The floor follows the DaemonSet.
+	mockK8s.DaemonSetDesiredPods["kube-system/node-agent"] = 9
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 9 {
+		t.Errorf("Expected replicas to be raised to the 9 desired pods of the DaemonSet, got %d", mockK8s.NumOfReplicas)
+	}
+
+	// A missing DaemonSet falls back to the static min.
+	mockK8s.NumOfNodes = 4
+	delete(mockK8s.DaemonSetDesiredPods, "kube-system/node-agent")
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 2 {
+		t.Errorf("Expected replicas to fall back to the min of 2, got %d", mockK8s.NumOfReplicas)
+	}
+}
+
+func TestPollAPIServer_NodeDropBreaker(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    100,
+		NumOfReplicas: 1,
+		ConfigMap:     &testConfigMap,
+	}
+	fakeClock := clock.NewFakeClock(time.Now())
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               fakeClock,
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+		nodeDropBreaker:     newNodeDropBreaker(0.5, 0, fakeClock),
+	}
+
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 100 {
+		t.Errorf("Expected 100 replicas, got %d", mockK8s.NumOfReplicas)
+	}
+
+	// A partial node list doesn't scale down.
+	mockK8s.NumOfNodes = 20
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 100 {
+		t.Errorf("Expected replicas to be held at 100, got %d", mockK8s.NumOfReplicas)
+	}
+	if status := autoScaler.lastPollCycleHealth.getLastPollStatus(); status == nil || !status.ScalingFrozen {
+		t.Errorf("Expected the poll status to report scaling as frozen, got %+v", status)
+	}
+
+	// Params are still synced while frozen.
+	testConfigMap.Data[linearcontroller.ControllerType] = `{"nodesPerReplica": 2}`
+	testConfigMap.ObjectMeta.ResourceVersion = `2`
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 100 {
+		t.Errorf("Expected replicas to be held at 100, got %d", mockK8s.NumOfReplicas)
+	}
+	if version := autoScaler.controller.GetParamsVersion(); version != "2" {
+		t.Errorf("Expected params version 2 to be synced while frozen, got %q", version)
+	}
+
+	// Scaling resumes once the node count is back within the fraction.
+	mockK8s.NumOfNodes = 90
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 45 {
+		t.Errorf("Expected 45 replicas, got %d", mockK8s.NumOfReplicas)
+	}
+	if status := autoScaler.lastPollCycleHealth.getLastPollStatus(); status == nil || status.ScalingFrozen {
+		t.Errorf("Expected the poll status not to report scaling as frozen, got %+v", status)
+	}
+}
+
+func TestPollAPIServer_DrainBoost(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 10, "min": 2, "max": 5}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    10,
+		NumOfReplicas: 2,
+		ConfigMap:     &testConfigMap,
+	}
+	fakeClock := clock.NewFakeClock(time.Now())
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               fakeClock,
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+		drainBoost:          newDrainBoost(2, time.Minute, fakeClock),
+	}
+
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 2 {
+		t.Errorf("Expected 2 replicas, got %d", mockK8s.NumOfReplicas)
+	}
+
+	// Draining nodes raise the min by 2.
+	mockK8s.DrainingNodes = 1
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 4 {
+		t.Errorf("Expected replicas to be boosted to 4, got %d", mockK8s.NumOfReplicas)
+	}
+
+	// The boost goes away after the window.
+	fakeClock.Step(time.Minute)
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 2 {
+		t.Errorf("Expected replicas to go back to 2, got %d", mockK8s.NumOfReplicas)
+	}
+
+	// The boosted min is bounded by max.
+	autoScaler.drainBoost = newDrainBoost(4, time.Minute, fakeClock)
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 5 {
+		t.Errorf("Expected replicas to be boosted up to the max of 5, got %d", mockK8s.NumOfReplicas)
+	}
+}
+
+func TestPollAPIServer_LadderStabilization(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			laddercontroller.ControllerType: `{"nodesToReplicas": [[0, 1], [10, 2]], "stabilizationSeconds": 300}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    9,
+		NumOfReplicas: 1,
+		ConfigMap:     &testConfigMap,
+	}
+	fakeClock := clock.NewFakeClock(time.Now())
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               fakeClock,
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+	}
+
+	// The node count sits on the threshold of the second step, moving up is
+	// immediate while moving down waits for the lower step to last 5 minutes.
+	polls := []struct {
+		nodes       int
+		elapsed     time.Duration
+		expReplicas int
+	}{
+		{9, 0, 1},
+		{10, 10 * time.Second, 2},
+		{9, 10 * time.Second, 2},
+		{10, 10 * time.Second, 2},
+		{9, 10 * time.Second, 2},
+		{9, 4 * time.Minute, 2},
+		{9, time.Minute, 1},
+		{10, 10 * time.Second, 2},
+	}
+	for i, p := range polls {
+		fakeClock.Step(p.elapsed)
+		mockK8s.NumOfNodes = p.nodes
+		if err := autoScaler.pollAPIServer(); err != nil {
+			t.Fatalf("Poll %d: unexpected poll failure: %v", i, err)
+		}
+		if mockK8s.NumOfReplicas != p.expReplicas {
+			t.Errorf("Poll %d with %d nodes: expected %d replicas, got %d", i, p.nodes, p.expReplicas, mockK8s.NumOfReplicas)
+		}
+	}
+}
+
+func TestPollAPIServer_StartupDelay(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    5,
+		NumOfReplicas: 1,
+		ConfigMap:     &testConfigMap,
+	}
+	fakeClock := clock.NewFakeClock(time.Now())
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               fakeClock,
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+		startupDelay:        30 * time.Second,
+		startTime:           fakeClock.Now(),
+	}
+
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 1 {
+		t.Errorf("Expected no scaling while warming up, got %d replicas", mockK8s.NumOfReplicas)
+	}
+	if status := autoScaler.lastPollCycleHealth.getLastPollStatus(); status.Targets["deployment/mock"].DesiredReplicas != 5 {
+		t.Errorf("Expected the recommendation to be computed while warming up, got %+v", status.Targets["deployment/mock"])
+	}
+
+	// Scales to the recommendation of the moment after the delay.
+	fakeClock.Step(30 * time.Second)
+	mockK8s.NumOfNodes = 7
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 7 {
+		t.Errorf("Expected 7 replicas after warming up, got %d", mockK8s.NumOfReplicas)
+	}
+}
+
+func TestPollAPIServer_MaxConsecutiveScaleUps(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfReplicas: 1,
+		ConfigMap:     &testConfigMap,
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+		maxConsecutiveUps:   2,
+	}
+
+	for i, tc := range []struct {
+		nodes       int
+		version     string
+		expReplicas int
+		expBlocked  bool
+	}{
+		{2, "1", 2, false},
+		{3, "1", 3, false},
+		// Two scale ups in a row: scale ups are blocked, without failing
+		// the poll.
+		{4, "1", 3, true},
+		{5, "1", 3, true},
+		// A scale down resets the count.
+		{2, "1", 2, false},
+		{3, "1", 3, false},
+		{4, "1", 4, false},
+		{5, "1", 4, true},
+		// So do new params.
+		{5, "2", 5, false},
+	} {
+		mockK8s.NumOfNodes = tc.nodes
+		testConfigMap.ObjectMeta.ResourceVersion = tc.version
+		if err := autoScaler.pollAPIServer(); err != nil {
+			t.Errorf("Poll %d: unexpected error: %v", i, err)
+		}
+		if mockK8s.NumOfReplicas != tc.expReplicas {
+			t.Errorf("Poll %d: expected %d replicas for %d nodes, got %d", i, tc.expReplicas, tc.nodes, mockK8s.NumOfReplicas)
+		}
+		blocked := autoScaler.lastPollCycleHealth.getScaleUpsBlocked()
+		if tc.expBlocked != (len(blocked) == 1) {
+			t.Errorf("Poll %d: expected blocked %v, got blocked targets %v", i, tc.expBlocked, blocked)
+		}
+		if ts := autoScaler.lastPollCycleHealth.getLastPollStatus().Targets["deployment/mock"]; ts.ScaleUpsBlocked != tc.expBlocked {
+			t.Errorf("Poll %d: expected the status of the target to report blocked %v", i, tc.expBlocked)
+		}
+		if value := testutil.ToFloat64(scaleUpsBlockedGauge.WithLabelValues("deployment/mock")); (value == 1) != tc.expBlocked {
+			t.Errorf("Poll %d: expected scale_ups_blocked to report blocked %v, got %v", i, tc.expBlocked, value)
+		}
+	}
+}
+
+func TestPollAPIServer_NodeCountSmoothing(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    10,
+		NumOfReplicas: 1,
+		ConfigMap:     &testConfigMap,
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+		smoother:            newNodeCountSmoother(2),
+	}
+
+	for i, tc := range []struct {
+		nodes       int
+		expReplicas int
+	}{
+		{10, 10},
+		// A spike is averaged with the previous sample.
+		{30, 20},
+		{10, 20},
+		{10, 10},
+	} {
+		mockK8s.NumOfNodes = tc.nodes
+		if err := autoScaler.pollAPIServer(); err != nil {
+			t.Fatalf("Poll %d: unexpected failure: %v", i, err)
+		}
+		if mockK8s.NumOfReplicas != tc.expReplicas {
+			t.Errorf("Poll %d: expected %d replicas for %d nodes, got %d", i, tc.expReplicas, tc.nodes, mockK8s.NumOfReplicas)
+		}
+	}
+}
+
+func TestPollAPIServer_MaxReplicasFromMemoryRequests(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1, "max": 100}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:        20,
+		NumOfMemory:       10 * 1024 * 1024 * 1024,
+		NumOfReplicas:     1,
+		ConfigMap:         &testConfigMap,
+		PodMemoryRequests: map[string]int64{"deployment/mock": 1024 * 1024 * 1024},
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+		capByMemoryRequests: true,
+	}
+
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 10 {
+		t.Errorf("Expected replicas to be capped to the 10 pods of 1Gi the memory can host, got %d", mockK8s.NumOfReplicas)
+	}
+
+	// The cap is at least 1 replica.
+	mockK8s.PodMemoryRequests["deployment/mock"] = 20 * 1024 * 1024 * 1024
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 1 {
+		t.Errorf("Expected replicas to be capped to 1, got %d", mockK8s.NumOfReplicas)
+	}
+
+	// Without a memory request only the static max applies.
+	delete(mockK8s.PodMemoryRequests, "deployment/mock")
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if mockK8s.NumOfReplicas != 20 {
+		t.Errorf("Expected 20 replicas without a memory request, got %d", mockK8s.NumOfReplicas)
+	}
+}
+
+func TestPollAPIServer_MultipleTargets(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes: 5,
+		Targets:    []string{"deployment/first", "deployment/broken", "replicaset/last"},
+		TargetReplicas: map[string]int{
+			"deployment/first":  1,
+			"deployment/broken": 1,
+			"replicaset/last":   1,
+		},
+		ConfigMap: &testConfigMap,
+	}
+	updatedTargets := make(map[string]int32)
+	mockK8s.UpdateReplicasFn = func(target string, expReplicas int32) (int32, error) {
+		if target == "deployment/broken" {
+			return 0, errors.New("update failure")
+		}
+		updatedTargets[target] = expReplicas
+		return 1, nil
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+	}
+
+	if err := autoScaler.pollAPIServer(); err == nil {
+		t.Errorf("Expected poll to report the failed target")
+	}
+	for _, target := range []string{"deployment/first", "replicaset/last"} {
+		if updatedTargets[target] != 5 {
+			t.Errorf("Expected %s to be scaled to 5 despite the failed target, got %d", target, updatedTargets[target])
+		}
+	}
+}
+
+func TestPollAPIServer_MinReplicasPerZone(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 4}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+
+	testCases := []struct {
+		numOfNodes  int
+		expReplicas int
+	}{
+		{6, 3},
+		{16, 4},
+	}
+
+	for _, tc := range testCases {
+		mockK8s := k8sclient.MockK8sClient{
+			NumOfNodes:    tc.numOfNodes,
+			NumOfZones:    3,
+			NumOfReplicas: 1,
+			ConfigMap:     &testConfigMap,
+		}
+		autoScaler := &AutoScaler{
+			k8sClient:           &mockK8s,
+			clock:               clock.NewFakeClock(time.Now()),
+			configMapName:       "fake-cluster-proportional-autoscaler-params",
+			lastPollCycleHealth: newHealthInfo(),
+			minReplicasPerZone:  1,
+		}
+
+		if err := autoScaler.pollAPIServer(); err != nil {
+			t.Fatalf("Unexpected poll failure: %v", err)
+		}
+		if mockK8s.NumOfReplicas != tc.expReplicas {
+			t.Errorf("With %d nodes in 3 zones expect %d replicas, got %d", tc.numOfNodes, tc.expReplicas, mockK8s.NumOfReplicas)
+		}
+	}
+}
+
+func TestPollAPIServer_RecordsScaleEvents(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"coresPerReplica": 4}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    2,
+		NumOfCores:    12,
+		NumOfReplicas: 1,
+		ConfigMap:     &testConfigMap,
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+	}
+
+	// Only the first poll changes the replicas.
+	for i := 0; i < 2; i++ {
+		if err := autoScaler.pollAPIServer(); err != nil {
+			t.Fatalf("Unexpected poll failure: %v", err)
+		}
+	}
+	expEvents := []string{"deployment/mock Normal ScaledReplicas Scaled replicas from 1 to 3, computed 3 replicas from 2 schedulable nodes and 12 schedulable cores"}
+	if !reflect.DeepEqual(mockK8s.Events, expEvents) {
+		t.Errorf("Expected events %v, got %v", expEvents, mockK8s.Events)
+	}
+}
+
+func TestPollAPIServer_MinScaleInterval(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    2,
+		NumOfReplicas: 1,
+		ConfigMap:     &testConfigMap,
+	}
+	fakeClock := clock.NewFakeClock(time.Now())
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               fakeClock,
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+		minScaleInterval:    time.Minute,
+	}
+
+	testCases := []struct {
+		step        time.Duration
+		numOfNodes  int
+		expReplicas int
+	}{
+		// The first scale is not deferred.
+		{0, 2, 2},
+		// Scaled less than a minute ago, deferred.
+		{30 * time.Second, 5, 2},
+		// Cooldown is over, the latest recommendation is applied.
+		{30 * time.Second, 4, 4},
+		// Deferred again, in the other direction.
+		{10 * time.Second, 1, 4},
+		{50 * time.Second, 1, 1},
+	}
+
+	for _, tc := range testCases {
+		fakeClock.Step(tc.step)
+		mockK8s.NumOfNodes = tc.numOfNodes
+		currentReplicasGauge.WithLabelValues("deployment/mock").Set(0)
+		if err := autoScaler.pollAPIServer(); err != nil {
+			t.Fatalf("Unexpected poll failure: %v", err)
+		}
+		if mockK8s.NumOfReplicas != tc.expReplicas {
+			t.Errorf("After %v with %d nodes expected %d replicas, got %d", tc.step, tc.numOfNodes, tc.expReplicas, mockK8s.NumOfReplicas)
+		}
+		// Deferred polls still report the current replicas.
+		if value := testutil.ToFloat64(currentReplicasGauge.WithLabelValues("deployment/mock")); value != float64(tc.expReplicas) {
+			t.Errorf("After %v with %d nodes expected the current_replicas metric at %d, got %v", tc.step, tc.numOfNodes, tc.expReplicas, value)
+		}
+	}
+}
+
+func TestPollAPIServer_RespectPDB(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:         10,
+		NumOfReplicas:      10,
+		ConfigMap:          &testConfigMap,
+		DisruptionsAllowed: map[string]int32{"deployment/mock": 2},
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+		respectPDB:          true,
+	}
+
+	testCases := []struct {
+		numOfNodes  int
+		expReplicas int
+	}{
+		// Scale ups are unaffected.
+		{12, 12},
+		// Scale downs are limited by the disruptions allowed.
+		{5, 10},
+		{5, 8},
+		{5, 6},
+		{5, 5},
+	}
+
+	for _, tc := range testCases {
+		mockK8s.NumOfNodes = tc.numOfNodes
+		if err := autoScaler.pollAPIServer(); err != nil {
+			t.Fatalf("Unexpected poll failure: %v", err)
+		}
+		if mockK8s.NumOfReplicas != tc.expReplicas {
+			t.Errorf("With %d nodes expected %d replicas, got %d", tc.numOfNodes, tc.expReplicas, mockK8s.NumOfReplicas)
+		}
+	}
+}
+
+func TestTryPollAPIServer_TargetUnavailableBackoff(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes: 3,
+		ConfigMap:  &testConfigMap,
+	}
+	targetAvailable := false
+	var polls []int
+	tick := 0
+	mockK8s.UpdateReplicasFn = func(target string, expReplicas int32) (int32, error) {
+		polls = append(polls, tick)
+		if !targetAvailable {
+			return 0, &k8sclient.TargetNotFoundError{Target: target, Err: errors.New("not found")}
+		}
+		return expReplicas, nil
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		pollPeriod:          10 * time.Second,
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+	}
+
+	for tick = 1; tick <= 10; tick++ {
+		autoScaler.tryPollAPIServer()
+	}
+	if expPolls := []int{1, 3, 6}; !reflect.DeepEqual(polls, expPolls) {
+		t.Errorf("Expected polls at ticks %v while the target is unavailable, got %v", expPolls, polls)
+	}
+
+	// Normal polling resumes once the target appears.
+	targetAvailable = true
+	polls = nil
+	for tick = 11; tick <= 13; tick++ {
+		autoScaler.tryPollAPIServer()
+	}
+	if expPolls := []int{11, 12, 13}; !reflect.DeepEqual(polls, expPolls) {
+		t.Errorf("Expected polls at ticks %v once the target is available, got %v", expPolls, polls)
+	}
 }
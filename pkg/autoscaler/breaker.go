@@ -0,0 +1,63 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
+)
+
+// nodeDropBreaker freezes scaling when the node count drops by more than a
+// fraction of the last count which was acted upon, e.g. when the apiserver
+// returns partial node lists during an outage. The drop is accepted as real
+// once it has lasted for the hold, if any.
+type nodeDropBreaker struct {
+	maxDropFraction float64
+	hold            time.Duration
+	clock           clock.Clock
+	// nodes is the last node count which was acted upon, 0 until the first.
+	nodes     int32
+	dropSince time.Time
+}
+
+func newNodeDropBreaker(maxDropFraction float64, hold time.Duration, clock clock.Clock) *nodeDropBreaker {
+	return &nodeDropBreaker{maxDropFraction: maxDropFraction, hold: hold, clock: clock}
+}
+
+// tripped records the observed node count and returns whether scaling should
+// be frozen for this poll.
+func (b *nodeDropBreaker) tripped(nodes int32) bool {
+	if b.nodes > 0 && float64(b.nodes-nodes) > b.maxDropFraction*float64(b.nodes) {
+		now := b.clock.Now()
+		if b.dropSince.IsZero() {
+			b.dropSince = now
+		}
+		if b.hold == 0 || now.Sub(b.dropSince) < b.hold {
+			logging.Warningf("Node count dropped from %d to %d, by more than %v of the nodes, holding the replicas until it recovers", b.nodes, nodes, b.maxDropFraction)
+			return true
+		}
+		logging.Warningf("Node count stayed at %d, down from %d, for %v, resuming scaling", nodes, b.nodes, b.hold)
+	} else if !b.dropSince.IsZero() {
+		logging.V(0).Infof("Node count recovered to %d, resuming scaling", nodes)
+	}
+	b.nodes = nodes
+	b.dropSince = time.Time{}
+	return false
+}
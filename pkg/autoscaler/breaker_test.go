@@ -0,0 +1,90 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestNodeDropBreaker(t *testing.T) {
+	type poll struct {
+		nodes      int32
+		elapsed    time.Duration
+		expTripped bool
+	}
+	testCases := []struct {
+		hold  time.Duration
+		polls []poll
+	}{
+		// Small drops go through, a large one holds until the count recovers.
+		{
+			hold: 0,
+			polls: []poll{
+				{100, 0, false},
+				{80, 10 * time.Second, false},
+				{30, 10 * time.Second, true},
+				{30, time.Hour, true},
+				{75, 10 * time.Second, false},
+				{60, 10 * time.Second, false},
+			},
+		},
+		// A drop lasting for the hold is accepted.
+		{
+			hold: time.Minute,
+			polls: []poll{
+				{100, 0, false},
+				{10, 10 * time.Second, true},
+				{10, 30 * time.Second, true},
+				{10, 30 * time.Second, false},
+				{5, 10 * time.Second, false},
+			},
+		},
+		// Counts recovering before the hold restart it.
+		{
+			hold: time.Minute,
+			polls: []poll{
+				{100, 0, false},
+				{10, 10 * time.Second, true},
+				{100, 40 * time.Second, false},
+				{10, 10 * time.Second, true},
+				{10, 40 * time.Second, true},
+			},
+		},
+		// Growing from zero nodes is never held.
+		{
+			hold: 0,
+			polls: []poll{
+				{0, 0, false},
+				{10, 10 * time.Second, false},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		fakeClock := clock.NewFakeClock(time.Now())
+		b := newNodeDropBreaker(0.5, tc.hold, fakeClock)
+		for j, p := range tc.polls {
+			fakeClock.Step(p.elapsed)
+			if tripped := b.tripped(p.nodes); tripped != p.expTripped {
+				t.Errorf("Case %d poll %d with %d nodes: expected tripped %v, got %v", i, j, p.nodes, p.expTripped, tripped)
+			}
+		}
+	}
+}
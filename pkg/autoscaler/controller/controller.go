@@ -17,19 +17,92 @@ limitations under the License.
 package controller
 
 import (
+	"time"
+
 	"k8s.io/api/core/v1"
 
 	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
 )
 
+// Terms reported as the binding constraint of the expected replicas by the
+// controllers.
+const (
+	TermNodes = "nodes"
+	TermCores = "cores"
+	TermPods  = "pods"
+	TermMin   = "min"
+	TermMax   = "max"
+)
+
+// BoundTerm returns TermMax or TermMin when replicas, rounded but not bounded
+// yet, are clamped to max or min, a max of 0 meaning unbounded, and term
+// otherwise.
+func BoundTerm(term string, replicas float64, min, max int) string {
+	if max != 0 && replicas > float64(max) {
+		return TermMax
+	}
+	if replicas < float64(min) {
+		return TermMin
+	}
+	return term
+}
+
+// ExpectedReplicas is the expected replicas computed by a controller along with
+// the term which yielded them.
+type ExpectedReplicas struct {
+	// Replicas is the expected replicas number.
+	Replicas int32
+	// BindingTerm is the metric, e.g. cores or nodes, which was the binding
+	// constraint on Replicas, or min or max when Replicas were clamped to
+	// that bound.
+	BindingTerm string
+	// RawValue is the replicas computed from the binding metric before
+	// rounding and bounding them.
+	RawValue float64
+}
+
 // Controller defines the interface every controller should implement
 type Controller interface {
 	// GetExpectedReplicas returns the expected replicas based on cluster status
-	GetExpectedReplicas(*k8sclient.ClusterStatus) (int32, error)
+	// and the current replicas of the target
+	GetExpectedReplicas(status *k8sclient.ClusterStatus, currentReplicas int32) (ExpectedReplicas, error)
 	// SyncConfig syncs the ConfigMap with controller
 	SyncConfig(*v1.ConfigMap) error
 	// GetParamsVersion returns the latest parameters version from controller
 	GetParamsVersion() string
+	// GetParams returns the parsed parameters, for debugging
+	GetParams() interface{}
 	// GetControllerType returns the controller type
 	GetControllerType() string
 }
+
+// TargetFloorController is implemented by controllers whose params can name
+// another resource whose current replicas are a floor on the expected replicas.
+type TargetFloorController interface {
+	// GetMinReplicasFromTarget returns the resource, in the format of a
+	// target, or an empty string if not set
+	GetMinReplicasFromTarget() string
+}
+
+// DaemonSetFloorController is implemented by controllers whose params can name
+// a DaemonSet whose desired pods are a floor on the expected replicas.
+type DaemonSetFloorController interface {
+	// GetMinReplicasFromDaemonSet returns the DaemonSet, as
+	// [<namespace>/]<name>, or an empty string if not set
+	GetMinReplicasFromDaemonSet() string
+}
+
+// StabilizedController is implemented by controllers whose params can delay
+// scale downs until the lower expected replicas have lasted for a window.
+type StabilizedController interface {
+	// GetStabilizationWindow returns the window, 0 if not set
+	GetStabilizationWindow() time.Duration
+}
+
+// BoundedController is implemented by controllers whose params bound the
+// expected replicas by a min and a max.
+type BoundedController interface {
+	// GetBounds returns the min and max of the params as given, i.e. replicas
+	// or percentages, a max of 0 meaning unbounded
+	GetBounds() (min, max string)
+}
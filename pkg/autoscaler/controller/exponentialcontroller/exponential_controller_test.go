@@ -0,0 +1,235 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exponentialcontroller
+
+import (
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
+)
+
+func verifyParams(t *testing.T, scalerParams, expScalerParams *exponentialParams) {
+	if *scalerParams != *expScalerParams {
+		t.Errorf("Parser error - Expected params %v MISMATCHED: Got %v", expScalerParams, scalerParams)
+	}
+}
+
+func TestControllerParser(t *testing.T) {
+	testCases := []struct {
+		jsonData  string
+		expError  bool
+		expParams *exponentialParams
+	}{
+		{
+			`{
+		      "base": 2,
+		      "coefficient": 1.5,
+		      "exponent": 0.1,
+		      "min": 2,
+		      "max": 100
+		    }`,
+			false,
+			&exponentialParams{
+				Base:        2,
+				Coefficient: 1.5,
+				Exponent:    0.1,
+				Min:         2,
+				Max:         100,
+			},
+		},
+		{ // Min defaults to 1
+			`{
+		      "base": 2,
+		      "coefficient": 1,
+		      "exponent": 0.5
+		    }`,
+			false,
+			&exponentialParams{
+				Base:        2,
+				Coefficient: 1,
+				Exponent:    0.5,
+				Min:         1,
+			},
+		},
+		{ // Invalid JSON
+			`{ "base": {{ 1:1 } }`,
+			true,
+			&exponentialParams{},
+		},
+		{ // Missing base
+			`{ "coefficient": 1, "exponent": 1 }`,
+			true,
+			&exponentialParams{},
+		},
+		{ // Missing coefficient
+			`{ "base": 2, "exponent": 1 }`,
+			true,
+			&exponentialParams{},
+		},
+		{ // Invalid negative exponent
+			`{ "base": 2, "coefficient": 1, "exponent": -1 }`,
+			true,
+			&exponentialParams{},
+		},
+		{ // Invalid max that smaller than min
+			`{ "base": 2, "coefficient": 1, "exponent": 1, "min": 10, "max": 5 }`,
+			true,
+			&exponentialParams{},
+		},
+		{ // Invalid negative replicaStep
+			`{ "base": 2, "coefficient": 1, "exponent": 1, "replicaStep": -1 }`,
+			true,
+			&exponentialParams{},
+		},
+		{ // No multiple of replicaStep between min and max
+			`{ "base": 2, "coefficient": 1, "exponent": 1, "min": 5, "max": 7, "replicaStep": 4 }`,
+			true,
+			&exponentialParams{},
+		},
+	}
+
+	for _, tc := range testCases {
+		params, err := parseParams([]byte(tc.jsonData))
+		if tc.expError {
+			if err == nil {
+				t.Errorf("Unexpected parsing success. Expected failure")
+				spew.Dump(tc)
+				spew.Dump(params)
+			}
+			continue
+		}
+		if err != nil && !tc.expError {
+			t.Errorf("Unexpected parse failure: %v", err)
+			spew.Dump(tc)
+			continue
+		}
+		verifyParams(t, params, tc.expParams)
+	}
+}
+
+func TestScaleFromNodes(t *testing.T) {
+	testController := &ExponentialController{}
+	testController.params = &exponentialParams{
+		Base:        2,
+		Coefficient: 1,
+		Exponent:    0.1,
+		Min:         2,
+		Max:         100,
+	}
+
+	testCases := []struct {
+		numNodes    int
+		expReplicas int
+	}{
+		{0, 2},
+		{1, 2},
+		{10, 2},
+		{11, 3},
+		{20, 4},
+		{30, 8},
+		{50, 32},
+		{66, 98},
+		{67, 100},
+		{5000, 100},
+	}
+
+	for _, tc := range testCases {
+		if replicas := testController.getExpectedReplicasFromParams(tc.numNodes); tc.expReplicas != replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestScaleWithoutMax(t *testing.T) {
+	testController := &ExponentialController{}
+	testController.params = &exponentialParams{
+		Base:        10,
+		Coefficient: 1,
+		Exponent:    1,
+		Min:         1,
+	}
+
+	if replicas := testController.getExpectedReplicasFromParams(5000); replicas != 2147483647 {
+		t.Errorf("Scaler Lookup failed: Expected replicas to be capped at 2147483647, Got %d", replicas)
+	}
+}
+
+func TestScaleWithReplicaStep(t *testing.T) {
+	testController := &ExponentialController{}
+	testController.params = &exponentialParams{
+		Base:        2,
+		Coefficient: 1,
+		Exponent:    0.1,
+		Min:         2,
+		Max:         99,
+		ReplicaStep: 4,
+	}
+
+	testCases := []struct {
+		numNodes    int
+		expReplicas int
+	}{
+		{0, 4},
+		{11, 4},
+		{20, 4},
+		{30, 8},
+		{50, 32},
+		{66, 96},
+		{5000, 96},
+	}
+
+	for _, tc := range testCases {
+		if replicas := testController.getExpectedReplicasFromParams(tc.numNodes); tc.expReplicas != replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestBindingTerm(t *testing.T) {
+	testController := &ExponentialController{}
+	testController.params = &exponentialParams{
+		Base:        2,
+		Coefficient: 1,
+		Exponent:    0.1,
+		Min:         2,
+		Max:         100,
+	}
+
+	testCases := []struct {
+		numNodes       int
+		expReplicas    int32
+		expBindingTerm string
+	}{
+		// Clamped to min or max.
+		{0, 2, controller.TermMin},
+		{30, 8, controller.TermNodes},
+		{5000, 100, controller.TermMax},
+	}
+
+	for _, tc := range testCases {
+		expected, err := testController.GetExpectedReplicas(&k8sclient.ClusterStatus{SchedulableNodes: int32(tc.numNodes)}, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if expected.Replicas != tc.expReplicas || expected.BindingTerm != tc.expBindingTerm {
+			t.Errorf("For case %v expected %d replicas from %s, got %d from %s", tc, tc.expReplicas, tc.expBindingTerm, expected.Replicas, expected.BindingTerm)
+		}
+	}
+}
@@ -20,16 +20,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"time"
 
 	"k8s.io/api/core/v1"
 
 	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller"
 	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
-
-	"github.com/golang/glog"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
 )
 
 var _ = controller.Controller(&LadderController{})
+var _ = controller.TargetFloorController(&LadderController{})
+var _ = controller.DaemonSetFloorController(&LadderController{})
+var _ = controller.StabilizedController(&LadderController{})
 
 const (
 	// ControllerType defines the controller type string
@@ -49,6 +52,20 @@ func NewLadderController() controller.Controller {
 
 type paramEntry [2]int
 
+// UnmarshalJSON rejects entries which are not exactly a [threshold, replicas]
+// pair, which would otherwise be silently truncated or zero-filled.
+func (e *paramEntry) UnmarshalJSON(data []byte) error {
+	var values []int
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	if len(values) != 2 {
+		return fmt.Errorf("invalid entry %v, expected a [threshold, replicas] pair", values)
+	}
+	copy(e[:], values)
+	return nil
+}
+
 type paramEntries []paramEntry
 
 func (entries paramEntries) Len() int {
@@ -66,17 +83,34 @@ func (entries paramEntries) Swap(i, j int) {
 type ladderParams struct {
 	CoresToReplicas paramEntries `json:"coresToReplicas"`
 	NodesToReplicas paramEntries `json:"nodesToReplicas"`
+	PodsToReplicas  paramEntries `json:"podsToReplicas"`
+	// TierHysteresis lowers the thresholds by that many nodes, cores or pods
+	// when scaling down, so that a cluster sitting at a threshold doesn't flap
+	// between two steps.
+	TierHysteresis int `json:"tierHysteresis"`
+	// StabilizationSeconds delays moving to a lower step until the lower
+	// expected replicas have lasted that long.
+	StabilizationSeconds int `json:"stabilizationSeconds"`
+	// MinReplicasFromTarget names a resource whose current replicas are a
+	// floor on the expected replicas.
+	MinReplicasFromTarget string `json:"minReplicasFromTarget"`
+	// MinReplicasFromDaemonSet names a DaemonSet whose desired pods are a
+	// floor on the expected replicas.
+	MinReplicasFromDaemonSet string `json:"minReplicasFromDaemonSet"`
+	// AllowScaleToZero lets the steps scale the target to 0 replicas, which
+	// are otherwise raised to 1.
+	AllowScaleToZero bool `json:"allowScaleToZero"`
+	// ReplicaStep rounds the expected replicas up to a multiple of it.
+	ReplicaStep int `json:"replicaStep"`
 }
 
 func (c *LadderController) SyncConfig(configMap *v1.ConfigMap) error {
-	glog.V(0).Infof("Detected ConfigMap version change (old: %s new: %s) - rebuilding lookup entries", c.version, configMap.ObjectMeta.ResourceVersion)
-	glog.V(2).Infof("Params from apiserver: \n%v", configMap.Data[ControllerType])
+	logging.V(0).Infof("Detected ConfigMap version change (old: %s new: %s) - rebuilding lookup entries", c.version, configMap.ObjectMeta.ResourceVersion)
+	logging.V(2).Infof("Params from apiserver: \n%v", configMap.Data[ControllerType])
 	params, err := parseParams([]byte(configMap.Data[ControllerType]))
 	if err != nil {
 		return fmt.Errorf("error parsing ladder params: %s", err)
 	}
-	sort.Sort(params.CoresToReplicas)
-	sort.Sort(params.NodesToReplicas)
 	c.params = params
 	c.version = configMap.ObjectMeta.ResourceVersion
 	return nil
@@ -85,7 +119,7 @@ func (c *LadderController) SyncConfig(configMap *v1.ConfigMap) error {
 // parseParams Parse the params from JSON string
 func parseParams(data []byte) (*ladderParams, error) {
 	var p ladderParams
-	if err := json.Unmarshal(data, &p); err != nil {
+	if err := controller.DecodeParams(data, &p); err != nil {
 		return nil, fmt.Errorf("could not parse parameters (%s)", err)
 	}
 	for _, e := range p.CoresToReplicas {
@@ -104,29 +138,125 @@ func parseParams(data []byte) (*ladderParams, error) {
 			return nil, fmt.Errorf("invalid negative values in entry %v in nodes_to_replicas_map", e)
 		}
 	}
+	for _, e := range p.PodsToReplicas {
+		if len(e) != 2 {
+			return nil, fmt.Errorf("invalid element %v in pods_to_replicas_map", e)
+		}
+		if e[0] < 0 || e[1] < 0 {
+			return nil, fmt.Errorf("invalid negative values in entry %v in pods_to_replicas_map", e)
+		}
+	}
+	if err := sortEntries(p.CoresToReplicas); err != nil {
+		return nil, fmt.Errorf("%s in cores_to_replicas_map", err)
+	}
+	if err := sortEntries(p.NodesToReplicas); err != nil {
+		return nil, fmt.Errorf("%s in nodes_to_replicas_map", err)
+	}
+	if err := sortEntries(p.PodsToReplicas); err != nil {
+		return nil, fmt.Errorf("%s in pods_to_replicas_map", err)
+	}
+	if p.TierHysteresis < 0 {
+		return nil, fmt.Errorf("invalid negative value for tierHysteresis: %v", p.TierHysteresis)
+	}
+	if p.StabilizationSeconds < 0 {
+		return nil, fmt.Errorf("invalid negative value for stabilizationSeconds: %v", p.StabilizationSeconds)
+	}
+	if err := controller.ValidateReplicaStep(p.ReplicaStep, 0, 0); err != nil {
+		return nil, err
+	}
+	if p.MinReplicasFromTarget != "" {
+		if err := k8sclient.ValidateTargetFormat(p.MinReplicasFromTarget); err != nil {
+			return nil, fmt.Errorf("invalid value for minReplicasFromTarget: %v", err)
+		}
+	}
+	if p.MinReplicasFromDaemonSet != "" {
+		if err := k8sclient.ValidateDaemonSetFormat(p.MinReplicasFromDaemonSet); err != nil {
+			return nil, fmt.Errorf("invalid value for minReplicasFromDaemonSet: %v", err)
+		}
+	}
 	return &p, nil
 }
 
+// sortEntries sorts the entries by threshold so that they can be binary
+// searched, and rejects thresholds which appear more than once.
+func sortEntries(entries paramEntries) error {
+	sort.Sort(entries)
+	for i := 1; i < len(entries); i++ {
+		if entries[i][0] == entries[i-1][0] {
+			return fmt.Errorf("duplicate threshold %d", entries[i][0])
+		}
+	}
+	return nil
+}
+
 func (c *LadderController) GetParamsVersion() string {
 	return c.version
 }
 
-func (c *LadderController) GetExpectedReplicas(status *k8sclient.ClusterStatus) (int32, error) {
-	// Get the expected replicas for the currently schedulable nodes and cores
-	expReplicas := int32(c.getExpectedReplicasFromParams(int(status.SchedulableNodes), int(status.SchedulableCores)))
+func (c *LadderController) GetParams() interface{} {
+	return c.params
+}
+
+func (c *LadderController) GetMinReplicasFromTarget() string {
+	return c.params.MinReplicasFromTarget
+}
 
-	return expReplicas, nil
+func (c *LadderController) GetMinReplicasFromDaemonSet() string {
+	return c.params.MinReplicasFromDaemonSet
 }
 
-func (c *LadderController) getExpectedReplicasFromParams(schedulableNodes, schedulableCores int) int {
-	replicasFromCore := getExpectedReplicasFromEntries(schedulableCores, c.params.CoresToReplicas)
-	replicasFromNode := getExpectedReplicasFromEntries(schedulableNodes, c.params.NodesToReplicas)
+func (c *LadderController) GetStabilizationWindow() time.Duration {
+	return time.Second * time.Duration(c.params.StabilizationSeconds)
+}
+
+func (c *LadderController) GetExpectedReplicas(status *k8sclient.ClusterStatus, currentReplicas int32) (controller.ExpectedReplicas, error) {
+	// Get the expected replicas for the currently schedulable nodes, cores and pods capacity.
+	// The step tables were sorted by parseParams.
+	expected := c.computeExpectedReplicas(status, currentReplicas)
+	// Without a max, the replicas are always rounded up.
+	expected.Replicas = int32(controller.RoundToStep(int(expected.Replicas), c.params.ReplicaStep, 0, 0))
+	return expected, nil
+}
+
+// computeExpectedReplicas returns the expected replicas along with the step
+// table which yielded them. Steps below currentReplicas are only taken once
+// the resources fall TierHysteresis below their threshold. The replicas are at
+// least 1 unless AllowScaleToZero is set.
+func (c *LadderController) computeExpectedReplicas(status *k8sclient.ClusterStatus, currentReplicas int32) controller.ExpectedReplicas {
+	terms := []struct {
+		name      string
+		resources int32
+		entries   []paramEntry
+	}{
+		{controller.TermNodes, status.SchedulableNodes, c.params.NodesToReplicas},
+		{controller.TermCores, status.SchedulableCores, c.params.CoresToReplicas},
+		{controller.TermPods, status.SchedulablePods, c.params.PodsToReplicas},
+	}
 
 	// Returns the results which yields the most replicas
-	if replicasFromCore > replicasFromNode {
-		return replicasFromCore
+	var expected controller.ExpectedReplicas
+	for _, term := range terms {
+		replicas := int32(getExpectedReplicasFromEntries(int(term.resources), term.entries))
+		if replicas < currentReplicas && c.params.TierHysteresis > 0 {
+			// Stay on the current step until the resources fall below the
+			// lowered thresholds, but no higher than the current replicas.
+			held := int32(getExpectedReplicasFromEntries(int(term.resources)+c.params.TierHysteresis, term.entries))
+			if held > currentReplicas {
+				held = currentReplicas
+			}
+			if held > replicas {
+				replicas = held
+			}
+		}
+		if expected.BindingTerm == "" || replicas > expected.Replicas {
+			expected = controller.ExpectedReplicas{Replicas: replicas, BindingTerm: term.name, RawValue: float64(replicas)}
+		}
+	}
+	if expected.Replicas < 1 && !c.params.AllowScaleToZero {
+		expected.Replicas = 1
+		expected.BindingTerm = controller.TermMin
 	}
-	return replicasFromNode
+	return expected
 }
 
 func getExpectedReplicasFromEntries(schedulableResources int, entries []paramEntry) int {
@@ -20,6 +20,11 @@ import (
 	"sort"
 	"testing"
 
+	"k8s.io/api/core/v1"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
+
 	"github.com/davecgh/go-spew/spew"
 )
 
@@ -45,6 +50,33 @@ func verifyParams(t *testing.T, scalerParams, expScalerParams *ladderParams) {
 			t.Errorf("Scaler parser error - Expected value %v MISMATCHED: Got %v", expected, parsed)
 		}
 	}
+
+	if len(expScalerParams.PodsToReplicas) != len(scalerParams.PodsToReplicas) {
+		t.Errorf("Scaler Params length mismatch Expected: %d, Got %d", len(expScalerParams.PodsToReplicas), len(scalerParams.PodsToReplicas))
+		return
+	}
+	for n, expected := range expScalerParams.PodsToReplicas {
+		parsed := scalerParams.PodsToReplicas[n]
+		if expected[0] != parsed[0] || expected[1] != parsed[1] {
+			t.Errorf("Scaler parser error - Expected value %v MISMATCHED: Got %v", expected, parsed)
+		}
+	}
+
+	if expScalerParams.TierHysteresis != scalerParams.TierHysteresis {
+		t.Errorf("Scaler parser error - Expected tierHysteresis %d MISMATCHED: Got %d", expScalerParams.TierHysteresis, scalerParams.TierHysteresis)
+	}
+
+	if expScalerParams.StabilizationSeconds != scalerParams.StabilizationSeconds {
+		t.Errorf("Scaler parser error - Expected stabilizationSeconds %d MISMATCHED: Got %d", expScalerParams.StabilizationSeconds, scalerParams.StabilizationSeconds)
+	}
+
+	if expScalerParams.MinReplicasFromTarget != scalerParams.MinReplicasFromTarget {
+		t.Errorf("Scaler parser error - Expected minReplicasFromTarget %q MISMATCHED: Got %q", expScalerParams.MinReplicasFromTarget, scalerParams.MinReplicasFromTarget)
+	}
+
+	if expScalerParams.MinReplicasFromDaemonSet != scalerParams.MinReplicasFromDaemonSet {
+		t.Errorf("Scaler parser error - Expected minReplicasFromDaemonSet %q MISMATCHED: Got %q", expScalerParams.MinReplicasFromDaemonSet, scalerParams.MinReplicasFromDaemonSet)
+	}
 }
 
 func TestControllerParser(t *testing.T) {
@@ -58,6 +90,31 @@ func TestControllerParser(t *testing.T) {
 			false,
 			&ladderParams{CoresToReplicas: []paramEntry{{1, 1}}},
 		},
+		{
+			`{ "podsToReplicas" : [ [0,1], [1000,3] ] }`,
+			false,
+			&ladderParams{PodsToReplicas: []paramEntry{{0, 1}, {1000, 3}}},
+		},
+		{ // Invalid negative in pods list
+			`{ "podsToReplicas" : [ [-1,1] ] }`,
+			true,
+			&ladderParams{},
+		},
+		{ // Step missing its replicas
+			`{ "coresToReplicas" : [ [1,1], [64] ] }`,
+			true,
+			&ladderParams{},
+		},
+		{ // Step with too many values
+			`{ "nodesToReplicas" : [ [1,1,2] ] }`,
+			true,
+			&ladderParams{},
+		},
+		{ // Unknown key
+			`{ "coreToReplicas" : [ [1,1] ] }`,
+			true,
+			&ladderParams{},
+		},
 		{ // Invalid JSON
 			`{ "coresToReplicas" : {{ 1:1 } }`,
 			true,
@@ -112,11 +169,100 @@ func TestControllerParser(t *testing.T) {
 					{20480, 50},
 					{24576, 60},
 					{28672, 70},
-					{65535, 100},
 					{32768, 80},
+					{65535, 100},
 				},
 			},
 		},
+		{ // Unsorted steps in every table
+			`{
+				"coresToReplicas": [ [64,3], [1,1], [16,2] ],
+				"nodesToReplicas": [ [10,2], [0,1] ],
+				"podsToReplicas": [ [1000,4], [5000,6], [0,1] ]
+			}`,
+			false,
+			&ladderParams{
+				CoresToReplicas: []paramEntry{{1, 1}, {16, 2}, {64, 3}},
+				NodesToReplicas: []paramEntry{{0, 1}, {10, 2}},
+				PodsToReplicas:  []paramEntry{{0, 1}, {1000, 4}, {5000, 6}},
+			},
+		},
+		{ // Empty tables
+			`{ "coresToReplicas" : [], "nodesToReplicas" : [] }`,
+			false,
+			&ladderParams{},
+		},
+		{ // Duplicate threshold in cores list
+			`{ "coresToReplicas" : [ [1,1], [64,3], [1,2] ] }`,
+			true,
+			&ladderParams{},
+		},
+		{ // Duplicate threshold in nodes list
+			`{ "nodesToReplicas" : [ [2,2], [2,2] ] }`,
+			true,
+			&ladderParams{},
+		},
+		{ // Duplicate threshold in pods list
+			`{ "podsToReplicas" : [ [0,1], [1000,4], [1000,6] ] }`,
+			true,
+			&ladderParams{},
+		},
+		{
+			`{ "nodesToReplicas" : [ [0,1] ], "minReplicasFromTarget": "deployment/companion" }`,
+			false,
+			&ladderParams{
+				NodesToReplicas:       []paramEntry{{0, 1}},
+				MinReplicasFromTarget: "deployment/companion",
+			},
+		},
+		{
+			`{ "nodesToReplicas" : [ [0,1], [10,2] ], "tierHysteresis": 2 }`,
+			false,
+			&ladderParams{NodesToReplicas: []paramEntry{{0, 1}, {10, 2}}, TierHysteresis: 2},
+		},
+		{ // Invalid negative tierHysteresis
+			`{ "nodesToReplicas" : [ [0,1], [10,2] ], "tierHysteresis": -1 }`,
+			true,
+			&ladderParams{},
+		},
+		{
+			`{ "nodesToReplicas" : [ [0,1], [10,2] ], "stabilizationSeconds": 300 }`,
+			false,
+			&ladderParams{NodesToReplicas: []paramEntry{{0, 1}, {10, 2}}, StabilizationSeconds: 300},
+		},
+		{ // Invalid negative stabilizationSeconds
+			`{ "nodesToReplicas" : [ [0,1], [10,2] ], "stabilizationSeconds": -1 }`,
+			true,
+			&ladderParams{},
+		},
+		{ // Invalid format of minReplicasFromTarget
+			`{ "nodesToReplicas" : [ [0,1] ], "minReplicasFromTarget": "deployment/" }`,
+			true,
+			&ladderParams{},
+		},
+		{
+			`{ "nodesToReplicas" : [ [0,1] ], "minReplicasFromDaemonSet": "node-agent" }`,
+			false,
+			&ladderParams{
+				NodesToReplicas:          []paramEntry{{0, 1}},
+				MinReplicasFromDaemonSet: "node-agent",
+			},
+		},
+		{ // Invalid format of minReplicasFromDaemonSet
+			`{ "nodesToReplicas" : [ [0,1] ], "minReplicasFromDaemonSet": "kube-system/" }`,
+			true,
+			&ladderParams{},
+		},
+		{
+			`{ "nodesToReplicas" : [ [0,1] ], "replicaStep": 3 }`,
+			false,
+			&ladderParams{NodesToReplicas: []paramEntry{{0, 1}}, ReplicaStep: 3},
+		},
+		{ // Invalid negative replicaStep
+			`{ "nodesToReplicas" : [ [0,1] ], "replicaStep": -1 }`,
+			true,
+			&ladderParams{},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -299,3 +445,264 @@ func TestControllerScalerFromZero(t *testing.T) {
 		}
 	}
 }
+
+func TestControllerScalerFromMultipleParams(t *testing.T) {
+	testController := &LadderController{}
+	testController.params = &ladderParams{
+		CoresToReplicas: []paramEntry{{1, 1}, {64, 3}, {512, 5}},
+		NodesToReplicas: []paramEntry{{1, 1}, {2, 2}},
+		PodsToReplicas:  []paramEntry{{0, 1}, {1000, 4}, {5000, 6}},
+	}
+
+	testCases := []struct {
+		numCores    int
+		numNodes    int
+		numPods     int
+		expReplicas int
+	}{
+		{0, 0, 0, 1},
+		{10, 2, 220, 2},
+		{100, 2, 220, 3},
+		{100, 10, 1100, 4},
+		{1000, 10, 1100, 5},
+		{1000, 50, 5500, 6},
+	}
+
+	for _, tc := range testCases {
+		status := &k8sclient.ClusterStatus{
+			SchedulableCores: int32(tc.numCores),
+			SchedulableNodes: int32(tc.numNodes),
+			SchedulablePods:  int32(tc.numPods),
+		}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if replicas := int(expected.Replicas); tc.expReplicas != replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestBindingTerm(t *testing.T) {
+	testController := &LadderController{}
+	testController.params = &ladderParams{
+		CoresToReplicas: []paramEntry{{1, 1}, {64, 3}, {512, 5}},
+		NodesToReplicas: []paramEntry{{1, 1}, {2, 2}},
+	}
+
+	testCases := []struct {
+		numCores       int
+		numNodes       int
+		expBindingTerm string
+	}{
+		{10, 2, "nodes"},
+		{100, 2, "cores"},
+	}
+
+	for _, tc := range testCases {
+		status := &k8sclient.ClusterStatus{
+			SchedulableCores: int32(tc.numCores),
+			SchedulableNodes: int32(tc.numNodes),
+		}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Errorf("Unexpected error for case %v: %v", tc, err)
+			continue
+		}
+		if expected.BindingTerm != tc.expBindingTerm {
+			t.Errorf("For case %v expected binding term %s, got %s", tc, tc.expBindingTerm, expected.BindingTerm)
+		}
+	}
+}
+
+func TestTierHysteresis(t *testing.T) {
+	testController := &LadderController{}
+	testController.params = &ladderParams{
+		NodesToReplicas: []paramEntry{{0, 1}, {10, 2}, {20, 3}},
+		TierHysteresis:  2,
+	}
+
+	// The node count sits at the boundary of the second step, the replicas
+	// move up once and stay there.
+	replicas := int32(1)
+	for i, numNodes := range []int{9, 10, 9, 10, 9, 8, 9, 10} {
+		status := &k8sclient.ClusterStatus{SchedulableNodes: int32(numNodes)}
+		expected, err := testController.GetExpectedReplicas(status, replicas)
+		if err != nil {
+			t.Fatalf("Unexpected error at step %d: %v", i, err)
+		}
+		if i > 0 && expected.Replicas != 2 {
+			t.Errorf("Step %d with %d nodes: expected 2 replicas, got %d", i, numNodes, expected.Replicas)
+		}
+		replicas = expected.Replicas
+	}
+
+	testCases := []struct {
+		numNodes        int
+		currentReplicas int32
+		expReplicas     int32
+	}{
+		// Scaling up uses the table thresholds.
+		{10, 1, 2},
+		{20, 2, 3},
+		// Scaling down needs to fall below threshold minus hysteresis.
+		{8, 2, 2},
+		{7, 2, 1},
+		{18, 3, 3},
+		{17, 3, 2},
+		// Falling through several steps at once.
+		{5, 3, 1},
+		// Never held above the current replicas.
+		{9, 3, 2},
+	}
+
+	for _, tc := range testCases {
+		status := &k8sclient.ClusterStatus{SchedulableNodes: int32(tc.numNodes)}
+		expected, err := testController.GetExpectedReplicas(status, tc.currentReplicas)
+		if err != nil {
+			t.Errorf("Unexpected error for case %v: %v", tc, err)
+			continue
+		}
+		if expected.Replicas != tc.expReplicas {
+			t.Errorf("For case %v expected %d replicas, got %d", tc, tc.expReplicas, expected.Replicas)
+		}
+	}
+
+	// Without hysteresis the replicas follow the boundary.
+	testController.params.TierHysteresis = 0
+	status := &k8sclient.ClusterStatus{SchedulableNodes: 9}
+	if expected, _ := testController.GetExpectedReplicas(status, 2); expected.Replicas != 1 {
+		t.Errorf("Expected 1 replica without hysteresis, got %d", expected.Replicas)
+	}
+}
+
+func TestControllerScalerFromUnsortedConfig(t *testing.T) {
+	testController := &LadderController{}
+	configMap := &v1.ConfigMap{
+		Data: map[string]string{
+			ControllerType: `{ "coresToReplicas" : [ [512,5], [1,1], [64,3] ] }`,
+		},
+	}
+	if err := testController.SyncConfig(configMap); err != nil {
+		t.Fatalf("Unexpected error syncing config: %v", err)
+	}
+
+	testCases := []struct {
+		numCores    int
+		expReplicas int
+	}{
+		{0, 1},
+		{1, 1},
+		{63, 1},
+		{64, 3},
+		{511, 3},
+		{512, 5},
+		{1000, 5},
+	}
+
+	for _, tc := range testCases {
+		status := &k8sclient.ClusterStatus{SchedulableCores: int32(tc.numCores)}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if replicas := int(expected.Replicas); tc.expReplicas != replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestControllerScalerFromEmptyConfig(t *testing.T) {
+	testCases := []struct {
+		params      string
+		expReplicas int
+	}{
+		// Raised to 1 without allowScaleToZero.
+		{`{ "coresToReplicas" : [], "nodesToReplicas" : [] }`, 1},
+		{`{ "coresToReplicas" : [], "nodesToReplicas" : [], "allowScaleToZero": true }`, 0},
+	}
+
+	for _, tc := range testCases {
+		testController := &LadderController{}
+		configMap := &v1.ConfigMap{
+			Data: map[string]string{
+				ControllerType: tc.params,
+			},
+		}
+		if err := testController.SyncConfig(configMap); err != nil {
+			t.Fatalf("Unexpected error syncing config %s: %v", tc.params, err)
+		}
+
+		status := &k8sclient.ClusterStatus{SchedulableCores: 100, SchedulableNodes: 10, SchedulablePods: 1000}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if replicas := int(expected.Replicas); replicas != tc.expReplicas {
+			t.Errorf("Expected %d replicas from empty tables with %s, Got %d", tc.expReplicas, tc.params, replicas)
+		}
+	}
+}
+
+func TestReplicaStep(t *testing.T) {
+	testController := &LadderController{}
+	testController.params = &ladderParams{
+		NodesToReplicas:  []paramEntry{{0, 0}, {3, 1}, {10, 4}, {20, 7}},
+		AllowScaleToZero: true,
+		ReplicaStep:      3,
+	}
+
+	testCases := []struct {
+		numNodes    int
+		expReplicas int32
+	}{
+		{0, 0},
+		{3, 3},
+		{10, 6},
+		{20, 9},
+	}
+
+	for _, tc := range testCases {
+		status := &k8sclient.ClusterStatus{SchedulableNodes: int32(tc.numNodes)}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if expected.Replicas != tc.expReplicas {
+			t.Errorf("For case %v expected %d replicas, got %d", tc, tc.expReplicas, expected.Replicas)
+		}
+	}
+}
+
+func TestAllowScaleToZero(t *testing.T) {
+	testCases := []struct {
+		allowScaleToZero bool
+		numNodes         int
+		expReplicas      int32
+		expBindingTerm   string
+	}{
+		// A step mapping to 0 replicas is raised to 1 without allowScaleToZero.
+		{false, 3, 1, controller.TermMin},
+		{false, 6, 1, controller.TermNodes},
+		{true, 3, 0, controller.TermNodes},
+		{true, 6, 1, controller.TermNodes},
+	}
+
+	for _, tc := range testCases {
+		testController := &LadderController{}
+		testController.params = &ladderParams{
+			NodesToReplicas:  []paramEntry{{0, 0}, {6, 1}},
+			AllowScaleToZero: tc.allowScaleToZero,
+		}
+		status := &k8sclient.ClusterStatus{SchedulableNodes: int32(tc.numNodes)}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Errorf("Unexpected error for case %v: %v", tc, err)
+			continue
+		}
+		if expected.Replicas != tc.expReplicas || expected.BindingTerm != tc.expBindingTerm {
+			t.Errorf("For case %v expected %d replicas from %s, got %d from %s", tc, tc.expReplicas, tc.expBindingTerm, expected.Replicas, expected.BindingTerm)
+		}
+	}
+}
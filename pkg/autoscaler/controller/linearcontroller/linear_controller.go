@@ -20,22 +20,58 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"strconv"
+	"strings"
 
 	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller"
 	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
-
-	"github.com/golang/glog"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
 )
 
 var _ = controller.Controller(&LinearController{})
+var _ = controller.TargetFloorController(&LinearController{})
+var _ = controller.DaemonSetFloorController(&LinearController{})
+var _ = controller.BoundedController(&LinearController{})
 
 const (
 	// ControllerType defines the controller type string
 	ControllerType = "linear"
 )
 
+const (
+	roundingModeCeil    = "ceil"
+	roundingModeFloor   = "floor"
+	roundingModeNearest = "nearest"
+)
+
+// Terms reported as the binding constraint of the expected replicas.
+const (
+	termNodes                     = controller.TermNodes
+	termCores                     = controller.TermCores
+	termMemory                    = "memory"
+	termEphemeralStorage          = "ephemeralStorage"
+	termPods                      = "pods"
+	termGPUs                      = "gpus"
+	termUnschedulableNodes        = "unschedulableNodes"
+	termMatchingPods              = "podsMatchingSelector"
+	termPendingPods               = "pendingPods"
+	termNamespaces                = "namespaces"
+	termSum                       = "sum"
+	termMin                       = controller.TermMin
+	termMax                       = controller.TermMax
+	termPreventSinglePointFailure = "preventSinglePointFailure"
+	termTolerance                 = "tolerance"
+)
+
+const (
+	combineModeMax = "max"
+	combineModeSum = "sum"
+)
+
 // LinearController uses linear control pattern
 type LinearController struct {
 	params  *linearParams
@@ -48,17 +84,134 @@ func NewLinearController() controller.Controller {
 }
 
 type linearParams struct {
-	CoresPerReplica           float64 `json:"coresPerReplica"`
-	NodesPerReplica           float64 `json:"nodesPerReplica"`
-	Min                       int     `json:"min"`
-	Max                       int     `json:"max"`
-	PreventSinglePointFailure bool    `json:"preventSinglePointFailure"`
-	IncludeUnschedulableNodes bool    `json:"includeUnschedulableNodes"`
+	CoresPerReplica  float64            `json:"coresPerReplica"`
+	NodesPerReplica  float64            `json:"nodesPerReplica"`
+	MemoryPerReplica *resource.Quantity `json:"memoryPerReplica"`
+	PodsPerReplica   float64            `json:"podsPerReplica"`
+	GPUsPerReplica   float64            `json:"gpusPerReplica"`
+	// Min and Max are the integer bounds, or the bounds evaluated for the
+	// current schedulable nodes when MinBound or MaxBound are percentages.
+	Min                       int                 `json:"-"`
+	Max                       int                 `json:"-"`
+	MinBound                  *intstr.IntOrString `json:"min"`
+	MaxBound                  *intstr.IntOrString `json:"max"`
+	PreventSinglePointFailure bool                `json:"preventSinglePointFailure"`
+	IncludeUnschedulableNodes bool                `json:"includeUnschedulableNodes"`
+	AllowScaleToZero          bool                `json:"allowScaleToZero"`
+	RoundingMode              string              `json:"roundingMode"`
+	Tolerance                 float64             `json:"tolerance"`
+	CombineMode               string              `json:"combineMode"`
+	CoresWeight               *float64            `json:"coresWeight"`
+	NodesWeight               *float64            `json:"nodesWeight"`
+	MemoryWeight              *float64            `json:"memoryWeight"`
+	PodsWeight                *float64            `json:"podsWeight"`
+	GPUsWeight                *float64            `json:"gpusWeight"`
+	// UnschedulableNodesPerReplica adds a term for the cordoned nodes only,
+	// regardless of IncludeUnschedulableNodes. It can't be used alone, as no
+	// nodes are cordoned most of the time, and needs one of the other terms.
+	UnschedulableNodesPerReplica float64 `json:"unschedulableNodesPerReplica"`
+	// PodsMatchingSelectorPerReplica adds a term for the pods matching
+	// --backend-selector.
+	PodsMatchingSelectorPerReplica float64 `json:"podsMatchingSelectorPerReplica"`
+	// PendingPodsPerReplica adds a term for the unschedulable pending pods,
+	// counted with --count-pending-pods. It can't be used alone, as there are
+	// no pending pods most of the time, and needs one of the other terms.
+	PendingPodsPerReplica float64 `json:"pendingPodsPerReplica"`
+	// NamespacesPerReplica adds a term for the namespaces counted with
+	// --count-namespaces.
+	NamespacesPerReplica float64 `json:"namespacesPerReplica"`
+	// EphemeralStoragePerReplica is a resource quantity compared against the
+	// allocatable ephemeral-storage of the nodes.
+	EphemeralStoragePerReplica *resource.Quantity `json:"ephemeralStoragePerReplica"`
+	// ReplicaStep rounds the expected replicas up to a multiple of it, or
+	// down if that would exceed Max.
+	ReplicaStep int `json:"replicaStep"`
+	// MinReplicasFromTarget names a resource whose current replicas are a
+	// floor on the expected replicas, on top of Min.
+	MinReplicasFromTarget string `json:"minReplicasFromTarget"`
+	// MinReplicasFromDaemonSet names a DaemonSet whose desired pods are a
+	// floor on the expected replicas.
+	MinReplicasFromDaemonSet string `json:"minReplicasFromDaemonSet"`
+}
+
+// round rounds the replicas computed from a single resource per RoundingMode.
+func (p *linearParams) round(replicas float64) float64 {
+	switch p.RoundingMode {
+	case roundingModeFloor:
+		return math.Floor(replicas)
+	case roundingModeNearest:
+		return math.Round(replicas)
+	default:
+		return math.Ceil(replicas)
+	}
+}
+
+// isPercent returns whether the bound is a percentage of the schedulable nodes.
+func isPercent(b *intstr.IntOrString) bool {
+	return b != nil && b.Type == intstr.String
+}
+
+// withBounds returns the params with percentage bounds evaluated against the
+// schedulable nodes, rounding min up and max down, both to at least 1. min may
+// be 0 when AllowScaleToZero is set.
+func (p *linearParams) withBounds(schedulableNodes int) *linearParams {
+	if !isPercent(p.MinBound) && !isPercent(p.MaxBound) {
+		return p
+	}
+	resolved := *p
+	if isPercent(p.MinBound) {
+		min, _ := intstr.GetValueFromIntOrPercent(p.MinBound, schedulableNodes, true)
+		lowest := 1.0
+		if p.AllowScaleToZero {
+			lowest = 0
+		}
+		resolved.Min = int(math.Max(lowest, float64(min)))
+	}
+	if isPercent(p.MaxBound) {
+		max, _ := intstr.GetValueFromIntOrPercent(p.MaxBound, schedulableNodes, false)
+		resolved.Max = int(math.Max(1, float64(max)))
+	}
+	return &resolved
+}
+
+// counted returns the resources counted from the schedulable and total
+// resources: the total with IncludeUnschedulableNodes, the schedulable ones
+// otherwise.
+func (p *linearParams) counted(schedulable, total int64) int64 {
+	if p.IncludeUnschedulableNodes {
+		return total
+	}
+	return schedulable
+}
+
+// weight returns the weight of a term in sum mode, 1 if it is not set.
+func weight(w *float64) float64 {
+	if w == nil {
+		return 1
+	}
+	return *w
+}
+
+// memoryPerReplicaBytes returns memoryPerReplica in bytes, or 0 if it is not set.
+func (p *linearParams) memoryPerReplicaBytes() float64 {
+	if p.MemoryPerReplica == nil {
+		return 0
+	}
+	return float64(p.MemoryPerReplica.Value())
+}
+
+// ephemeralStoragePerReplicaBytes returns ephemeralStoragePerReplica in
+// bytes, or 0 if it is not set.
+func (p *linearParams) ephemeralStoragePerReplicaBytes() float64 {
+	if p.EphemeralStoragePerReplica == nil {
+		return 0
+	}
+	return float64(p.EphemeralStoragePerReplica.Value())
 }
 
 func (c *LinearController) SyncConfig(configMap *v1.ConfigMap) error {
-	glog.V(0).Infof("ConfigMap version change (old: %s new: %s) - rebuilding params", c.version, configMap.ObjectMeta.ResourceVersion)
-	glog.V(2).Infof("Params from apiserver: \n%v", configMap.Data[ControllerType])
+	logging.V(0).Infof("ConfigMap version change (old: %s new: %s) - rebuilding params", c.version, configMap.ObjectMeta.ResourceVersion)
+	logging.V(2).Infof("Params from apiserver: \n%v", configMap.Data[ControllerType])
 	params, err := parseParams([]byte(configMap.Data[ControllerType]))
 	if err != nil {
 		return fmt.Errorf("error parsing linear params: %s", err)
@@ -71,20 +224,50 @@ func (c *LinearController) SyncConfig(configMap *v1.ConfigMap) error {
 // parseParams Parse the params from JSON string
 func parseParams(data []byte) (*linearParams, error) {
 	var p linearParams
-	if err := json.Unmarshal(data, &p); err != nil {
+	if err := controller.DecodeParams(data, &p); err != nil {
 		return nil, fmt.Errorf("could not parse parameters (%s)", err)
 	}
+	for name, b := range map[string]*intstr.IntOrString{"min": p.MinBound, "max": p.MaxBound} {
+		if !isPercent(b) {
+			continue
+		}
+		if !strings.HasSuffix(b.StrVal, "%") {
+			return nil, fmt.Errorf("invalid value for %s: %q, should be an integer or a percentage", name, b.StrVal)
+		}
+		if percent, err := intstr.GetValueFromIntOrPercent(b, 100, false); err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %v", name, err)
+		} else if percent < 0 {
+			return nil, fmt.Errorf("invalid negative value for %s: %v", name, b.String())
+		}
+	}
+	if p.MinBound != nil && !isPercent(p.MinBound) {
+		p.Min = p.MinBound.IntValue()
+	}
+	if p.MaxBound != nil && !isPercent(p.MaxBound) {
+		p.Max = p.MaxBound.IntValue()
+	}
+	// Guard against scaling to zero by accident, min has to be set explicitly.
+	if p.AllowScaleToZero && p.MinBound == nil {
+		return nil, fmt.Errorf("allowScaleToZero requires min to be set, e.g. to 0")
+	}
 	if p.Min < 0 {
 		return nil, fmt.Errorf("invalid negative value for min: %v", p.Min)
-	} else if p.Min == 0 {
-		glog.V(2).Infof("Defaulting min replicas count to 1 for linear controller")
+	} else if p.Min == 0 && !p.AllowScaleToZero {
+		logging.V(2).Infof("Defaulting min replicas count to 1 for linear controller")
 		p.Min = 1
 	}
 	if p.Max != 0 && p.Max < p.Min {
 		return nil, fmt.Errorf("max replicas count %v should be greater than / equal to min replicas count %v", p.Max, p.Min)
 	}
-	if p.CoresPerReplica == 0 && p.NodesPerReplica == 0 {
-		return nil, fmt.Errorf("should at least provide either CoresPerReplica or NodesPerReplica (Greater than 0)")
+	if isPercent(p.MinBound) && isPercent(p.MaxBound) {
+		minPercent, _ := intstr.GetValueFromIntOrPercent(p.MinBound, 100, false)
+		maxPercent, _ := intstr.GetValueFromIntOrPercent(p.MaxBound, 100, false)
+		if maxPercent < minPercent {
+			return nil, fmt.Errorf("max replicas percentage %v should be greater than / equal to min replicas percentage %v", p.MaxBound.String(), p.MinBound.String())
+		}
+	}
+	if p.CoresPerReplica == 0 && p.NodesPerReplica == 0 && p.memoryPerReplicaBytes() == 0 && p.ephemeralStoragePerReplicaBytes() == 0 && p.PodsPerReplica == 0 && p.GPUsPerReplica == 0 && p.PodsMatchingSelectorPerReplica == 0 && p.NamespacesPerReplica == 0 {
+		return nil, fmt.Errorf("should at least provide one of CoresPerReplica, NodesPerReplica, MemoryPerReplica, EphemeralStoragePerReplica, PodsPerReplica, GPUsPerReplica, PodsMatchingSelectorPerReplica or NamespacesPerReplica (Greater than 0)")
 	}
 	if p.CoresPerReplica < 0 {
 		return nil, fmt.Errorf("invalid negative value for coresPerReplica: %v", p.CoresPerReplica)
@@ -92,6 +275,87 @@ func parseParams(data []byte) (*linearParams, error) {
 	if p.NodesPerReplica < 0 {
 		return nil, fmt.Errorf("invalid negative value for nodesPerReplica: %v", p.NodesPerReplica)
 	}
+	if p.memoryPerReplicaBytes() < 0 {
+		return nil, fmt.Errorf("invalid negative value for memoryPerReplica: %v", p.MemoryPerReplica)
+	}
+	if p.ephemeralStoragePerReplicaBytes() < 0 {
+		return nil, fmt.Errorf("invalid negative value for ephemeralStoragePerReplica: %v", p.EphemeralStoragePerReplica)
+	}
+	if p.PodsPerReplica < 0 {
+		return nil, fmt.Errorf("invalid negative value for podsPerReplica: %v", p.PodsPerReplica)
+	}
+	if p.GPUsPerReplica < 0 {
+		return nil, fmt.Errorf("invalid negative value for gpusPerReplica: %v", p.GPUsPerReplica)
+	}
+	if p.UnschedulableNodesPerReplica < 0 {
+		return nil, fmt.Errorf("invalid negative value for unschedulableNodesPerReplica: %v", p.UnschedulableNodesPerReplica)
+	}
+	if p.PodsMatchingSelectorPerReplica < 0 {
+		return nil, fmt.Errorf("invalid negative value for podsMatchingSelectorPerReplica: %v", p.PodsMatchingSelectorPerReplica)
+	}
+	if p.PendingPodsPerReplica < 0 {
+		return nil, fmt.Errorf("invalid negative value for pendingPodsPerReplica: %v", p.PendingPodsPerReplica)
+	}
+	if p.NamespacesPerReplica < 0 {
+		return nil, fmt.Errorf("invalid negative value for namespacesPerReplica: %v", p.NamespacesPerReplica)
+	}
+	// Ratios may be omitted, but not set to 0, which would otherwise be read
+	// as omitted.
+	var present map[string]json.RawMessage
+	if err := json.Unmarshal(data, &present); err != nil {
+		return nil, fmt.Errorf("could not parse parameters (%s)", err)
+	}
+	for name, ratio := range map[string]float64{
+		"coresPerReplica":                p.CoresPerReplica,
+		"nodesPerReplica":                p.NodesPerReplica,
+		"memoryPerReplica":               p.memoryPerReplicaBytes(),
+		"ephemeralStoragePerReplica":     p.ephemeralStoragePerReplicaBytes(),
+		"podsPerReplica":                 p.PodsPerReplica,
+		"gpusPerReplica":                 p.GPUsPerReplica,
+		"unschedulableNodesPerReplica":   p.UnschedulableNodesPerReplica,
+		"podsMatchingSelectorPerReplica": p.PodsMatchingSelectorPerReplica,
+		"pendingPodsPerReplica":          p.PendingPodsPerReplica,
+		"namespacesPerReplica":           p.NamespacesPerReplica,
+	} {
+		if _, ok := present[name]; ok && ratio == 0 {
+			return nil, fmt.Errorf("invalid value for %s: 0, should be greater than 0 or omitted", name)
+		}
+	}
+	switch p.RoundingMode {
+	case "":
+		p.RoundingMode = roundingModeCeil
+	case roundingModeCeil, roundingModeFloor, roundingModeNearest:
+	default:
+		return nil, fmt.Errorf("invalid roundingMode %q, should be one of %s, %s or %s", p.RoundingMode, roundingModeCeil, roundingModeFloor, roundingModeNearest)
+	}
+	switch p.CombineMode {
+	case "":
+		p.CombineMode = combineModeMax
+	case combineModeMax, combineModeSum:
+	default:
+		return nil, fmt.Errorf("invalid combineMode %q, should be %s or %s", p.CombineMode, combineModeMax, combineModeSum)
+	}
+	for name, w := range map[string]*float64{"coresWeight": p.CoresWeight, "nodesWeight": p.NodesWeight, "memoryWeight": p.MemoryWeight, "podsWeight": p.PodsWeight, "gpusWeight": p.GPUsWeight} {
+		if weight(w) < 0 {
+			return nil, fmt.Errorf("invalid negative value for %s: %v", name, *w)
+		}
+	}
+	if p.Tolerance < 0 || p.Tolerance >= 1 {
+		return nil, fmt.Errorf("invalid value for tolerance: %v, should be in [0, 1)", p.Tolerance)
+	}
+	if err := controller.ValidateReplicaStep(p.ReplicaStep, p.Min, p.Max); err != nil {
+		return nil, err
+	}
+	if p.MinReplicasFromTarget != "" {
+		if err := k8sclient.ValidateTargetFormat(p.MinReplicasFromTarget); err != nil {
+			return nil, fmt.Errorf("invalid value for minReplicasFromTarget: %v", err)
+		}
+	}
+	if p.MinReplicasFromDaemonSet != "" {
+		if err := k8sclient.ValidateDaemonSetFormat(p.MinReplicasFromDaemonSet); err != nil {
+			return nil, fmt.Errorf("invalid value for minReplicasFromDaemonSet: %v", err)
+		}
+	}
 	return &p, nil
 }
 
@@ -99,42 +363,192 @@ func (c *LinearController) GetParamsVersion() string {
 	return c.version
 }
 
-func (c *LinearController) GetExpectedReplicas(status *k8sclient.ClusterStatus) (int32, error) {
-	// Get the expected replicas for the currently number of nodes and cores
-	expReplicas := int32(c.getExpectedReplicasFromParams(int(status.SchedulableNodes), int(status.SchedulableCores), int(status.TotalNodes), int(status.TotalCores)))
+func (c *LinearController) GetParams() interface{} {
+	return c.params
+}
+
+func (c *LinearController) GetBounds() (min, max string) {
+	min, max = strconv.Itoa(c.params.Min), strconv.Itoa(c.params.Max)
+	if isPercent(c.params.MinBound) {
+		min = c.params.MinBound.StrVal
+	}
+	if isPercent(c.params.MaxBound) {
+		max = c.params.MaxBound.StrVal
+	}
+	return min, max
+}
+
+func (c *LinearController) GetMinReplicasFromTarget() string {
+	return c.params.MinReplicasFromTarget
+}
+
+func (c *LinearController) GetMinReplicasFromDaemonSet() string {
+	return c.params.MinReplicasFromDaemonSet
+}
+
+func (c *LinearController) GetExpectedReplicas(status *k8sclient.ClusterStatus, currentReplicas int32) (controller.ExpectedReplicas, error) {
+	// Percentage bounds change with the schedulable nodes, evaluate them for this poll.
+	bounded := &LinearController{params: c.params.withBounds(int(status.SchedulableNodes)), version: c.version}
+	// Get the expected replicas for the currently number of nodes, cores, memory, pods capacity and GPUs
+	expected := bounded.computeExpectedReplicas(status)
+	if isPercent(c.params.MinBound) || isPercent(c.params.MaxBound) {
+		// Percentage bounds are only known now, check them against the step.
+		if err := controller.ValidateReplicaStep(c.params.ReplicaStep, bounded.params.Min, bounded.params.Max); err != nil {
+			logging.Warningf("With %d schedulable nodes, %v, keeping the replicas within the bounds instead", status.SchedulableNodes, err)
+		}
+	}
+	expected.Replicas = int32(controller.RoundToStep(int(expected.Replicas), c.params.ReplicaStep, bounded.params.Min, bounded.params.Max))
+	if bounded.withinTolerance(expected.Replicas, currentReplicas) {
+		logging.V(4).Infof("Keeping %d replicas: %d expected replicas are within tolerance %v", currentReplicas, expected.Replicas, c.params.Tolerance)
+		return controller.ExpectedReplicas{Replicas: currentReplicas, BindingTerm: termTolerance, RawValue: float64(expected.Replicas)}, nil
+	}
+
+	return expected, nil
+}
 
-	return expReplicas, nil
+// withinTolerance returns whether the expected replicas differ from the
+// current replicas by no more than the tolerance fraction of the current
+// replicas, as long as the current replicas are still within min and max.
+func (c *LinearController) withinTolerance(expReplicas, currentReplicas int32) bool {
+	if c.params.Tolerance == 0 || currentReplicas <= 0 {
+		return false
+	}
+	if int(currentReplicas) < c.params.Min || (c.params.Max != 0 && int(currentReplicas) > c.params.Max) {
+		return false
+	}
+	return math.Abs(float64(expReplicas-currentReplicas)) <= c.params.Tolerance*float64(currentReplicas)
 }
 
-func (c *LinearController) getExpectedReplicasFromParams(schedulableNodes, schedulableCores, totalNodes, totalCores int) int {
-	nodes := schedulableNodes
-	cores := schedulableCores
-	if c.params.IncludeUnschedulableNodes {
-		nodes = totalNodes
-		cores = totalCores
+// computeExpectedReplicas returns the expected replicas along with the term
+// which yielded them, or the bound they were clamped to. Unset terms are
+// skipped.
+func (c *LinearController) computeExpectedReplicas(status *k8sclient.ClusterStatus) controller.ExpectedReplicas {
+	nodes := int(c.params.counted(int64(status.SchedulableNodes), int64(status.TotalNodes)))
+	cores := int(c.params.counted(int64(status.SchedulableCores), int64(status.TotalCores)))
+	memory := c.params.counted(status.SchedulableMemory, status.TotalMemory)
+	ephemeralStorage := c.params.counted(status.SchedulableEphemeralStorage, status.TotalEphemeralStorage)
+	pods := int(c.params.counted(int64(status.SchedulablePods), int64(status.TotalPods)))
+	gpus := int(c.params.counted(int64(status.SchedulableGPUs), int64(status.TotalGPUs)))
+	unschedulableNodes := int(status.UnschedulableNodes)
+	matchingPods := int(status.MatchingPods)
+	pendingPods := int(status.PendingPods)
+	namespaces := int(status.MatchingNamespaces)
+	scaleResourceTerms := c.scaleResourceTerms(status)
+	if c.params.CombineMode == combineModeSum {
+		return c.getExpectedReplicasFromWeightedSum(nodes, cores, memory, ephemeralStorage, pods, gpus, unschedulableNodes, matchingPods, pendingPods, namespaces, scaleResourceTerms)
+	}
+	terms := append([]term{
+		{termNodes, float64(nodes), c.params.NodesPerReplica},
+		{termCores, float64(cores), c.params.CoresPerReplica},
+		{termMemory, float64(memory), c.params.memoryPerReplicaBytes()},
+		{termEphemeralStorage, float64(ephemeralStorage), c.params.ephemeralStoragePerReplicaBytes()},
+		{termPods, float64(pods), c.params.PodsPerReplica},
+		{termGPUs, float64(gpus), c.params.GPUsPerReplica},
+		{termUnschedulableNodes, float64(unschedulableNodes), c.params.UnschedulableNodesPerReplica},
+		{termMatchingPods, float64(matchingPods), c.params.PodsMatchingSelectorPerReplica},
+		{termPendingPods, float64(pendingPods), c.params.PendingPodsPerReplica},
+		{termNamespaces, float64(namespaces), c.params.NamespacesPerReplica},
+	}, scaleResourceTerms...)
+
+	// Returns the results which yields the most replicas
+	var expected controller.ExpectedReplicas
+	for _, term := range terms {
+		if term.resourcesPerReplica == 0 {
+			continue
+		}
+		rawValue := term.resources / term.resourcesPerReplica
+		replicas := c.getExpectedReplicasFromParam(term.resources, term.resourcesPerReplica)
+		if expected.BindingTerm == "" || int32(replicas) > expected.Replicas {
+			expected = controller.ExpectedReplicas{
+				Replicas:    int32(replicas),
+				BindingTerm: c.boundTerm(term.name, c.params.round(rawValue)),
+				RawValue:    rawValue,
+			}
+		}
+	}
+	if expected.BindingTerm == "" {
+		// No term is set, the replicas are the lowest allowed.
+		expected = controller.ExpectedReplicas{Replicas: int32(c.getExpectedReplicasFromParam(0, 0)), BindingTerm: termMin}
 	}
-	replicasFromCore := c.getExpectedReplicasFromParam(cores, c.params.CoresPerReplica)
-	replicasFromNode := c.getExpectedReplicasFromParam(nodes, c.params.NodesPerReplica)
 	// Prevent single point of failure by having at least 2 replicas when
 	// there are more than one node.
-	if c.params.PreventSinglePointFailure &&
-		nodes > 1 &&
-		replicasFromNode < 2 {
-		replicasFromNode = 2
+	if c.params.PreventSinglePointFailure && nodes > 1 && expected.Replicas < 2 {
+		expected.Replicas = 2
+		expected.BindingTerm = termPreventSinglePointFailure
 	}
+	return expected
+}
 
-	// Returns the results which yields the most replicas
-	if replicasFromCore > replicasFromNode {
-		return replicasFromCore
+// boundTerm returns min or max when replicas, before bounding, are clamped to
+// that bound, and term otherwise.
+func (c *LinearController) boundTerm(term string, replicas float64) string {
+	return controller.BoundTerm(term, replicas, c.params.Min, c.params.Max)
+}
+
+// term is a resource the expected replicas are computed from.
+type term struct {
+	name                string
+	resources           float64
+	resourcesPerReplica float64
+}
+
+// scaleResourceTerms returns the terms of the resources given with
+// --scale-resource, named after the resources.
+func (c *LinearController) scaleResourceTerms(status *k8sclient.ClusterStatus) []term {
+	terms := make([]term, 0, len(status.ScaleResources))
+	for _, scaleResource := range status.ScaleResources {
+		resources := c.params.counted(scaleResource.Schedulable, scaleResource.Total)
+		terms = append(terms, term{string(scaleResource.Name), float64(resources), scaleResource.PerReplica})
+	}
+	return terms
+}
+
+// getExpectedReplicasFromWeightedSum sums the weighted resources / resourcesPerReplica
+// terms before rounding the result and bounding it by min and max. The
+// ephemeral storage, unschedulable nodes, matching pods, pending pods,
+// namespaces and --scale-resource terms are not weighted.
+func (c *LinearController) getExpectedReplicasFromWeightedSum(nodes, cores int, memory, ephemeralStorage int64, pods, gpus, unschedulableNodes, matchingPods, pendingPods, namespaces int, scaleResourceTerms []term) controller.ExpectedReplicas {
+	var sum float64
+	addTerm := func(schedulableResources float64, resourcesPerReplica float64, w *float64) {
+		if resourcesPerReplica > 0 {
+			sum += weight(w) * schedulableResources / resourcesPerReplica
+		}
+	}
+	addTerm(float64(cores), c.params.CoresPerReplica, c.params.CoresWeight)
+	addTerm(float64(nodes), c.params.NodesPerReplica, c.params.NodesWeight)
+	addTerm(float64(memory), c.params.memoryPerReplicaBytes(), c.params.MemoryWeight)
+	addTerm(float64(ephemeralStorage), c.params.ephemeralStoragePerReplicaBytes(), nil)
+	addTerm(float64(pods), c.params.PodsPerReplica, c.params.PodsWeight)
+	addTerm(float64(gpus), c.params.GPUsPerReplica, c.params.GPUsWeight)
+	addTerm(float64(unschedulableNodes), c.params.UnschedulableNodesPerReplica, nil)
+	addTerm(float64(matchingPods), c.params.PodsMatchingSelectorPerReplica, nil)
+	addTerm(float64(pendingPods), c.params.PendingPodsPerReplica, nil)
+	addTerm(float64(namespaces), c.params.NamespacesPerReplica, nil)
+	for _, t := range scaleResourceTerms {
+		addTerm(t.resources, t.resourcesPerReplica, nil)
+	}
+	replicas := c.params.round(sum)
+	bindingTerm := termSum
+	if c.params.PreventSinglePointFailure && nodes > 1 && replicas < 2 {
+		replicas = 2
+		bindingTerm = termPreventSinglePointFailure
+	}
+	bindingTerm = c.boundTerm(bindingTerm, replicas)
+	if c.params.Max != 0 {
+		replicas = math.Min(float64(c.params.Max), replicas)
 	}
-	return replicasFromNode
+	replicas = math.Max(float64(c.params.Min), replicas)
+	return controller.ExpectedReplicas{Replicas: int32(replicas), BindingTerm: bindingTerm, RawValue: sum}
 }
 
-func (c *LinearController) getExpectedReplicasFromParam(schedulableResources int, resourcesPerReplica float64) int {
+func (c *LinearController) getExpectedReplicasFromParam(schedulableResources float64, resourcesPerReplica float64) int {
 	if resourcesPerReplica == 0 {
+		if c.params.AllowScaleToZero {
+			return c.params.Min
+		}
 		return 1
 	}
-	res := math.Ceil(float64(schedulableResources) / resourcesPerReplica)
+	res := c.params.round(schedulableResources / resourcesPerReplica)
 	if c.params.Max != 0 {
 		res = math.Min(float64(c.params.Max), res)
 	}
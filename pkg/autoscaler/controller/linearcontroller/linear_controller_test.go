@@ -17,8 +17,14 @@ limitations under the License.
 package linearcontroller
 
 import (
+	"fmt"
 	"testing"
 
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
+
 	"github.com/davecgh/go-spew/spew"
 )
 
@@ -26,7 +32,17 @@ func verifyParams(t *testing.T, scalerParams, expScalerParams *linearParams) {
 	if scalerParams.CoresPerReplica != expScalerParams.CoresPerReplica ||
 		scalerParams.NodesPerReplica != expScalerParams.NodesPerReplica ||
 		scalerParams.Min != expScalerParams.Min ||
-		scalerParams.Max != expScalerParams.Max {
+		scalerParams.Max != expScalerParams.Max ||
+		scalerParams.memoryPerReplicaBytes() != expScalerParams.memoryPerReplicaBytes() ||
+		scalerParams.ephemeralStoragePerReplicaBytes() != expScalerParams.ephemeralStoragePerReplicaBytes() ||
+		scalerParams.PodsPerReplica != expScalerParams.PodsPerReplica ||
+		scalerParams.GPUsPerReplica != expScalerParams.GPUsPerReplica ||
+		scalerParams.UnschedulableNodesPerReplica != expScalerParams.UnschedulableNodesPerReplica ||
+		scalerParams.PodsMatchingSelectorPerReplica != expScalerParams.PodsMatchingSelectorPerReplica ||
+		scalerParams.PendingPodsPerReplica != expScalerParams.PendingPodsPerReplica ||
+		scalerParams.NamespacesPerReplica != expScalerParams.NamespacesPerReplica ||
+		scalerParams.MinReplicasFromTarget != expScalerParams.MinReplicasFromTarget ||
+		scalerParams.MinReplicasFromDaemonSet != expScalerParams.MinReplicasFromDaemonSet {
 		t.Errorf("Parser error - Expected params %v MISMATCHED: Got %v", expScalerParams, scalerParams)
 	}
 }
@@ -56,6 +72,91 @@ func TestControllerParser(t *testing.T) {
 				IncludeUnschedulableNodes: true,
 			},
 		},
+		{ // Unknown key
+			`{
+		      "coresPerRepluca": 2,
+		      "nodesPerReplica": 1
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{ // Type mismatch
+			`{
+		      "coresPerReplica": "2",
+		      "nodesPerReplica": 1
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{ // Unknown combineMode
+			`{
+		      "nodesPerReplica": 1,
+		      "combineMode": "avg"
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{ // Negative weight
+			`{
+		      "nodesPerReplica": 1,
+		      "combineMode": "sum",
+		      "nodesWeight": -1
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{ // Percentage bounds
+			`{
+		      "nodesPerReplica": 1,
+		      "min": "10%",
+		      "max": "20%"
+		    }`,
+			false,
+			&linearParams{
+				NodesPerReplica: 1,
+				Min:             1,
+			},
+		},
+		{ // Invalid percentage
+			`{
+		      "nodesPerReplica": 1,
+		      "max": "twenty%"
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{ // Number as a string
+			`{
+		      "nodesPerReplica": 1,
+		      "max": "20"
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{ // Negative percentage
+			`{
+		      "nodesPerReplica": 1,
+		      "min": "-10%"
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{ // Negative tolerance
+			`{
+		      "nodesPerReplica": 1,
+		      "tolerance": -0.1
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{ // Tolerance of a whole replica count
+			`{
+		      "nodesPerReplica": 1,
+		      "tolerance": 1
+		    }`,
+			true,
+			&linearParams{},
+		},
 		// IncludeUnschedulableNodes must default to false for backwards compatibility.
 		{
 			`{
@@ -74,6 +175,93 @@ func TestControllerParser(t *testing.T) {
 				IncludeUnschedulableNodes: false,
 			},
 		},
+		{ // Memory only, with binary suffix
+			`{
+		      "memoryPerReplica": "4Gi"
+		    }`,
+			false,
+			&linearParams{
+				MemoryPerReplica: resourcePtr("4Gi"),
+				Min:              1,
+			},
+		},
+		{ // Memory with decimal suffix alongside cores
+			`{
+		      "coresPerReplica": 2,
+		      "memoryPerReplica": "512M",
+		      "min": 1,
+		      "max": 100
+		    }`,
+			false,
+			&linearParams{
+				CoresPerReplica:  2,
+				MemoryPerReplica: resourcePtr("512M"),
+				Min:              1,
+				Max:              100,
+			},
+		},
+		{ // Ephemeral storage only
+			`{
+		      "ephemeralStoragePerReplica": "100Gi"
+		    }`,
+			false,
+			&linearParams{
+				EphemeralStoragePerReplica: resourcePtr("100Gi"),
+				Min:                        1,
+			},
+		},
+		{ // Invalid ephemeral storage quantity
+			`{ "ephemeralStoragePerReplica": "lots" }`,
+			true,
+			&linearParams{},
+		},
+		{ // Invalid negative ephemeral storage
+			`{ "ephemeralStoragePerReplica": "-1Gi" }`,
+			true,
+			&linearParams{},
+		},
+		{ // Pods only
+			`{
+		      "podsPerReplica": 500
+		    }`,
+			false,
+			&linearParams{
+				PodsPerReplica: 500,
+				Min:            1,
+			},
+		},
+		{ // Invalid negative pods
+			`{ "nodesPerReplica": 1, "podsPerReplica": -1 }`,
+			true,
+			&linearParams{},
+		},
+		{ // GPUs only
+			`{
+		      "gpusPerReplica": 8,
+		      "max": 10
+		    }`,
+			false,
+			&linearParams{
+				GPUsPerReplica: 8,
+				Min:            1,
+				Max:            10,
+			},
+		},
+		{ // Invalid negative GPUs
+			`{ "gpusPerReplica": -8 }`,
+			true,
+			&linearParams{},
+		},
+		{ // Invalid memory quantity
+			`{ "memoryPerReplica": "lots" }`,
+			true,
+			&linearParams{},
+		},
+		{ // Invalid negative memory
+			`{ "memoryPerReplica": "-1Gi" }`,
+			true,
+			&linearParams{},
+		},
 		{ // Invalid JSON
 			`{ "coresPerReplica": {{ 1:1 } }`,
 			true,
@@ -98,6 +286,31 @@ func TestControllerParser(t *testing.T) {
 			true,
 			&linearParams{},
 		},
+		{ // Invalid max percentage that smaller than min percentage
+			`{
+		      "nodesPerReplica": 1,
+		      "min": "10%",
+		      "max": "5%"
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{ // Ratio set to zero
+			`{
+		      "coresPerReplica": 0,
+		      "nodesPerReplica": 1
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{ // Memory ratio set to zero
+			`{
+		      "nodesPerReplica": 1,
+		      "memoryPerReplica": "0"
+		    }`,
+			true,
+			&linearParams{},
+		},
 		{ // Both coresPerReplica and nodesPerReplica are unset
 			`{
 		      "min": 1,
@@ -118,6 +331,182 @@ func TestControllerParser(t *testing.T) {
 			true,
 			&linearParams{},
 		},
+		{ // Unknown roundingMode
+			`{
+		      "nodesPerReplica": 1,
+		      "roundingMode": "up"
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{
+			`{
+		      "nodesPerReplica": 16,
+		      "unschedulableNodesPerReplica": 4
+		    }`,
+			false,
+			&linearParams{
+				NodesPerReplica:              16,
+				Min:                          1,
+				UnschedulableNodesPerReplica: 4,
+			},
+		},
+		{ // Invalid negative unschedulableNodesPerReplica
+			`{
+		      "nodesPerReplica": 1,
+		      "unschedulableNodesPerReplica": -2
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{ // unschedulableNodesPerReplica alone is not enough
+			`{
+		      "unschedulableNodesPerReplica": 4
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{ // podsMatchingSelectorPerReplica alone is enough
+			`{
+		      "podsMatchingSelectorPerReplica": 5
+		    }`,
+			false,
+			&linearParams{
+				Min:                            1,
+				PodsMatchingSelectorPerReplica: 5,
+			},
+		},
+		{ // Invalid negative podsMatchingSelectorPerReplica
+			`{
+		      "nodesPerReplica": 1,
+		      "podsMatchingSelectorPerReplica": -5
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{
+			`{
+		      "nodesPerReplica": 4,
+		      "pendingPodsPerReplica": 20
+		    }`,
+			false,
+			&linearParams{
+				NodesPerReplica:       4,
+				Min:                   1,
+				PendingPodsPerReplica: 20,
+			},
+		},
+		{
+			`{
+		      "namespacesPerReplica": 10
+		    }`,
+			false,
+			&linearParams{
+				Min:                  1,
+				NamespacesPerReplica: 10,
+			},
+		},
+		{ // Invalid negative namespacesPerReplica
+			`{
+		      "namespacesPerReplica": -10
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{ // Invalid negative pendingPodsPerReplica
+			`{
+		      "nodesPerReplica": 1,
+		      "pendingPodsPerReplica": -20
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{ // pendingPodsPerReplica alone is not enough
+			`{
+		      "pendingPodsPerReplica": 20
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{ // pendingPodsPerReplica with only a min is not enough either
+			`{
+		      "min": 2,
+		      "pendingPodsPerReplica": 20
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{
+			`{
+		      "nodesPerReplica": 10,
+		      "min": 0,
+		      "allowScaleToZero": true
+		    }`,
+			false,
+			&linearParams{
+				NodesPerReplica: 10,
+				Min:             0,
+			},
+		},
+		{ // min 0 is raised to 1 without allowScaleToZero
+			`{
+		      "nodesPerReplica": 10,
+		      "min": 0
+		    }`,
+			false,
+			&linearParams{
+				NodesPerReplica: 10,
+				Min:             1,
+			},
+		},
+		{ // allowScaleToZero without an explicit min
+			`{
+		      "nodesPerReplica": 10,
+		      "allowScaleToZero": true
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{
+			`{
+		      "nodesPerReplica": 10,
+		      "minReplicasFromTarget": "deployment/companion"
+		    }`,
+			false,
+			&linearParams{
+				NodesPerReplica:       10,
+				Min:                   1,
+				MinReplicasFromTarget: "deployment/companion",
+			},
+		},
+		{ // Invalid format of minReplicasFromTarget
+			`{
+		      "nodesPerReplica": 10,
+		      "minReplicasFromTarget": "companion"
+		    }`,
+			true,
+			&linearParams{},
+		},
+		{
+			`{
+		      "nodesPerReplica": 10,
+		      "minReplicasFromDaemonSet": "kube-system/node-agent"
+		    }`,
+			false,
+			&linearParams{
+				NodesPerReplica:          10,
+				Min:                      1,
+				MinReplicasFromDaemonSet: "kube-system/node-agent",
+			},
+		},
+		{ // Invalid format of minReplicasFromDaemonSet
+			`{
+		      "nodesPerReplica": 10,
+		      "minReplicasFromDaemonSet": "daemonset/kube-system/node-agent"
+		    }`,
+			true,
+			&linearParams{},
+		},
 		// Wrong input for IncludeUnschedulableNodes.
 		{
 			`{
@@ -130,6 +519,16 @@ func TestControllerParser(t *testing.T) {
 			true,
 			&linearParams{},
 		},
+		{ // Invalid negative replicaStep
+			`{ "nodesPerReplica": 1, "replicaStep": -1 }`,
+			true,
+			&linearParams{},
+		},
+		{ // No multiple of replicaStep between min and max
+			`{ "nodesPerReplica": 1, "min": 5, "max": 7, "replicaStep": 4 }`,
+			true,
+			&linearParams{},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -180,7 +579,7 @@ func TestScaleFromSingleParam(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		if replicas := testController.getExpectedReplicasFromParam(tc.numResources, testController.params.CoresPerReplica); tc.expReplicas != replicas {
+		if replicas := testController.getExpectedReplicasFromParam(float64(tc.numResources), testController.params.CoresPerReplica); tc.expReplicas != replicas {
 			t.Errorf("Scaler Lookup failed Expected %d, Got %d", tc.expReplicas, replicas)
 		}
 	}
@@ -220,7 +619,17 @@ func TestScaleFromMultipleParams(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		if replicas := testController.getExpectedReplicasFromParams(tc.numNodes, tc.numCores, tc.numNodes, tc.numNodes); tc.expReplicas != replicas {
+		status := &k8sclient.ClusterStatus{
+			SchedulableNodes: int32(tc.numNodes),
+			SchedulableCores: int32(tc.numCores),
+			TotalNodes:       int32(tc.numNodes),
+			TotalCores:       int32(tc.numNodes),
+		}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if replicas := int(expected.Replicas); tc.expReplicas != replicas {
 			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
 		}
 	}
@@ -255,8 +664,801 @@ func TestScaleFromUnschedulableNodes(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		if replicas := testController.getExpectedReplicasFromParams(tc.numSchedulableNodes, tc.numSchedulableCores, tc.numNodes, tc.numNodes); tc.expReplicas != replicas {
+		status := &k8sclient.ClusterStatus{
+			SchedulableNodes: int32(tc.numSchedulableNodes),
+			SchedulableCores: int32(tc.numSchedulableCores),
+			TotalNodes:       int32(tc.numNodes),
+			TotalCores:       int32(tc.numNodes),
+		}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if replicas := int(expected.Replicas); tc.expReplicas != replicas {
 			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
 		}
 	}
 }
+
+func TestScaleFromCordonedNodes(t *testing.T) {
+	testCases := []struct {
+		includeUnschedulableNodes bool
+		combineMode               string
+		numSchedulableNodes       int
+		numUnschedulableNodes     int
+		expReplicas               int
+		expBindingTerm            string
+	}{
+		// No drain in progress: ceil(40/10) = 4 from nodes.
+		{false, combineModeMax, 40, 0, 4, termNodes},
+		// Draining 12 nodes: ceil(12/2) = 6 beats ceil(28/10) = 3.
+		{false, combineModeMax, 28, 12, 6, termUnschedulableNodes},
+		// The term counts the cordoned nodes whatever includeUnschedulableNodes is.
+		{true, combineModeMax, 28, 12, 6, termUnschedulableNodes},
+		// The term is bounded by max.
+		{false, combineModeMax, 0, 40, 10, termMax},
+		// In sum mode: ceil(28/10 + 12/2) = ceil(8.8) = 9.
+		{false, combineModeSum, 28, 12, 9, termSum},
+	}
+
+	for _, tc := range testCases {
+		testController := &LinearController{}
+		testController.params = &linearParams{
+			NodesPerReplica:              10,
+			UnschedulableNodesPerReplica: 2,
+			Min:                          1,
+			Max:                          10,
+			IncludeUnschedulableNodes:    tc.includeUnschedulableNodes,
+			RoundingMode:                 roundingModeCeil,
+			CombineMode:                  tc.combineMode,
+		}
+		status := &k8sclient.ClusterStatus{
+			SchedulableNodes:   int32(tc.numSchedulableNodes),
+			TotalNodes:         int32(tc.numSchedulableNodes + tc.numUnschedulableNodes),
+			UnschedulableNodes: int32(tc.numUnschedulableNodes),
+		}
+		expected := testController.computeExpectedReplicas(status)
+		if int(expected.Replicas) != tc.expReplicas || expected.BindingTerm != tc.expBindingTerm {
+			t.Errorf("For case %v expected %d replicas from %s, got %d from %s", tc, tc.expReplicas, tc.expBindingTerm, expected.Replicas, expected.BindingTerm)
+		}
+	}
+}
+
+func TestScaleToZero(t *testing.T) {
+	testCases := []struct {
+		allowScaleToZero bool
+		min              int
+		minBound         string
+		numNodes         int
+		expReplicas      int
+	}{
+		{true, 0, "", 0, 0},
+		{true, 0, "", 1, 1},
+		{true, 0, "", 25, 3},
+		// Without allowScaleToZero min is 1.
+		{false, 1, "", 0, 1},
+		// min still applies when allowScaleToZero is set.
+		{true, 2, "", 0, 2},
+		// Percentage min may round to 0 with allowScaleToZero.
+		{true, 0, "0%", 0, 0},
+		{false, 1, "0%", 0, 1},
+	}
+
+	for _, tc := range testCases {
+		testController := &LinearController{}
+		testController.params = &linearParams{
+			NodesPerReplica:  10,
+			Min:              tc.min,
+			AllowScaleToZero: tc.allowScaleToZero,
+			RoundingMode:     roundingModeCeil,
+		}
+		if tc.minBound != "" {
+			minBound := intstr.FromString(tc.minBound)
+			testController.params.MinBound = &minBound
+		}
+		status := &k8sclient.ClusterStatus{
+			SchedulableNodes: int32(tc.numNodes),
+		}
+		expected, err := testController.GetExpectedReplicas(status, 1)
+		if err != nil {
+			t.Errorf("Unexpected error for case %v: %v", tc, err)
+			continue
+		}
+		if int(expected.Replicas) != tc.expReplicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, expected.Replicas)
+		}
+	}
+}
+
+func TestScaleFromMemory(t *testing.T) {
+	testController := &LinearController{}
+	testController.params = &linearParams{
+		CoresPerReplica:  4,
+		MemoryPerReplica: resourcePtr("8Gi"),
+		Min:              1,
+		Max:              100,
+	}
+
+	const gi = 1024 * 1024 * 1024
+	testCases := []struct {
+		numCores    int
+		memory      int64
+		expReplicas int
+	}{
+		{0, 0, 1},
+		{4, 8 * gi, 1},
+		{4, 8*gi + 1, 2},
+		{16, 8 * gi, 4},
+		{16, 64 * gi, 8},
+		{4, 1024 * gi, 100},
+	}
+
+	for _, tc := range testCases {
+		status := &k8sclient.ClusterStatus{
+			SchedulableNodes:  1,
+			SchedulableCores:  int32(tc.numCores),
+			SchedulableMemory: tc.memory,
+		}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if replicas := int(expected.Replicas); tc.expReplicas != replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
+		}
+	}
+
+	// Omitted memoryPerReplica must be ignored rather than treated as zero.
+	testController.params.MemoryPerReplica = nil
+	status := &k8sclient.ClusterStatus{
+		SchedulableNodes:  1,
+		SchedulableCores:  8,
+		SchedulableMemory: 1024 * gi,
+	}
+	expected, err := testController.GetExpectedReplicas(status, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if replicas := int(expected.Replicas); replicas != 2 {
+		t.Errorf("Scaler Lookup failed without memoryPerReplica: Expected 2, Got %d", replicas)
+	}
+}
+
+func TestScaleFromEphemeralStorage(t *testing.T) {
+	testController := &LinearController{}
+	testController.params = &linearParams{
+		NodesPerReplica:            4,
+		EphemeralStoragePerReplica: resourcePtr("100Gi"),
+		Min:                        1,
+		Max:                        10,
+	}
+
+	const gi = 1024 * 1024 * 1024
+	testCases := []struct {
+		numNodes         int
+		ephemeralStorage int64
+		expReplicas      int
+	}{
+		// Nodes not reporting ephemeral storage count as none.
+		{4, 0, 1},
+		{4, 100 * gi, 1},
+		{4, 100*gi + 1, 2},
+		{4, 350 * gi, 4},
+		{8, 50 * gi, 2},
+		{4, 10000 * gi, 10},
+	}
+
+	for _, tc := range testCases {
+		status := &k8sclient.ClusterStatus{
+			SchedulableNodes:            int32(tc.numNodes),
+			SchedulableEphemeralStorage: tc.ephemeralStorage,
+		}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if replicas := int(expected.Replicas); tc.expReplicas != replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestScaleFromPods(t *testing.T) {
+	testController := &LinearController{}
+	testController.params = &linearParams{
+		NodesPerReplica: 4,
+		PodsPerReplica:  100,
+		Min:             1,
+		Max:             10,
+	}
+
+	testCases := []struct {
+		numNodes    int
+		numPods     int
+		expReplicas int
+	}{
+		{0, 0, 1},
+		{1, 110, 2},
+		{4, 330, 4},
+		{8, 100, 2},
+		{10, 1100, 10},
+		{3, 0, 1},
+	}
+
+	for _, tc := range testCases {
+		status := &k8sclient.ClusterStatus{
+			SchedulableNodes: int32(tc.numNodes),
+			SchedulablePods:  int32(tc.numPods),
+		}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if replicas := int(expected.Replicas); tc.expReplicas != replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestScaleFromMatchingPods(t *testing.T) {
+	testCases := []struct {
+		combineMode     string
+		numMatchingPods int
+		expReplicas     int
+		expBindingTerm  string
+	}{
+		// No matching pods yield the min.
+		{combineModeMax, 0, 2, termMin},
+		{combineModeMax, 10, 2, termMin},
+		{combineModeMax, 25, 3, termMatchingPods},
+		{combineModeMax, 1000, 8, termMax},
+		{combineModeSum, 0, 2, termMin},
+		{combineModeSum, 25, 4, termSum},
+	}
+
+	for _, tc := range testCases {
+		testController := &LinearController{params: &linearParams{
+			NodesPerReplica:                4,
+			PodsMatchingSelectorPerReplica: 10,
+			Min:                            2,
+			Max:                            8,
+			CombineMode:                    tc.combineMode,
+		}}
+		status := &k8sclient.ClusterStatus{
+			SchedulableNodes: 4,
+			MatchingPods:     int32(tc.numMatchingPods),
+		}
+		expected := testController.computeExpectedReplicas(status)
+		if int(expected.Replicas) != tc.expReplicas || expected.BindingTerm != tc.expBindingTerm {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d from %s, Got %d from %s", tc, tc.expReplicas, tc.expBindingTerm, expected.Replicas, expected.BindingTerm)
+		}
+	}
+}
+
+func TestScaleFromPendingPods(t *testing.T) {
+	testController := &LinearController{}
+	testController.params = &linearParams{
+		NodesPerReplica:       4,
+		PendingPodsPerReplica: 20,
+		Min:                   1,
+		Max:                   10,
+	}
+
+	testCases := []struct {
+		numNodes       int
+		numPendingPods int
+		expReplicas    int
+	}{
+		{8, 0, 2},
+		{8, 20, 2},
+		{8, 50, 3},
+		// The extra replicas go away once the pods are scheduled.
+		{12, 0, 3},
+		{8, 1000, 10},
+	}
+
+	for _, tc := range testCases {
+		status := &k8sclient.ClusterStatus{
+			SchedulableNodes: int32(tc.numNodes),
+			PendingPods:      int32(tc.numPendingPods),
+		}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if replicas := int(expected.Replicas); tc.expReplicas != replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestScaleFromNamespaces(t *testing.T) {
+	testController := &LinearController{}
+	testController.params = &linearParams{
+		NamespacesPerReplica: 10,
+		Min:                  1,
+		Max:                  5,
+	}
+
+	testCases := []struct {
+		numNodes      int
+		numNamespaces int
+		expReplicas   int
+	}{
+		{8, 0, 1},
+		{8, 10, 1},
+		{8, 11, 2},
+		{100, 25, 3},
+		{8, 1000, 5},
+	}
+
+	for _, tc := range testCases {
+		status := &k8sclient.ClusterStatus{
+			SchedulableNodes:   int32(tc.numNodes),
+			MatchingNamespaces: int32(tc.numNamespaces),
+		}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if replicas := int(expected.Replicas); tc.expReplicas != replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestScaleWithReplicaStep(t *testing.T) {
+	testController := &LinearController{}
+	testController.params = &linearParams{
+		NodesPerReplica: 1,
+		Min:             1,
+		Max:             10,
+		ReplicaStep:     4,
+	}
+
+	testCases := []struct {
+		numNodes    int
+		expReplicas int32
+	}{
+		{1, 4},
+		{4, 4},
+		{5, 8},
+		{9, 8},
+		{100, 8},
+	}
+
+	for _, tc := range testCases {
+		status := &k8sclient.ClusterStatus{SchedulableNodes: int32(tc.numNodes)}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if tc.expReplicas != expected.Replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, expected.Replicas)
+		}
+	}
+}
+
+func TestScaleWithReplicaStepAndPercentageMax(t *testing.T) {
+	params, err := parseParams([]byte(`{"nodesPerReplica": 1, "max": "10%", "replicaStep": 3}`))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing params: %v", err)
+	}
+	testController := &LinearController{params: params}
+
+	testCases := []struct {
+		numNodes    int
+		expReplicas int32
+	}{
+		// max is 2: no multiple of 3 fits, min wins rather than 0.
+		{20, 1},
+		// max is 3.
+		{30, 3},
+		// max is 5: rounded down to 3.
+		{50, 3},
+		{100, 9},
+	}
+
+	for _, tc := range testCases {
+		status := &k8sclient.ClusterStatus{SchedulableNodes: int32(tc.numNodes)}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if tc.expReplicas != expected.Replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, expected.Replicas)
+		}
+	}
+}
+
+func TestScaleFromGPUs(t *testing.T) {
+	testController := &LinearController{}
+	testController.params = &linearParams{
+		CoresPerReplica:           64,
+		GPUsPerReplica:            4,
+		Min:                       1,
+		Max:                       20,
+		IncludeUnschedulableNodes: true,
+	}
+
+	testCases := []struct {
+		numCores    int
+		numGPUs     int
+		expReplicas int
+	}{
+		{0, 0, 1},
+		{64, 0, 1},
+		{64, 4, 1},
+		{64, 5, 2},
+		{32, 16, 4},
+		{512, 16, 8},
+		{64, 1000, 20},
+	}
+
+	for _, tc := range testCases {
+		status := &k8sclient.ClusterStatus{
+			TotalNodes: 1,
+			TotalCores: int32(tc.numCores),
+			TotalGPUs:  int32(tc.numGPUs),
+		}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if replicas := int(expected.Replicas); tc.expReplicas != replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestScaleFromScaleResources(t *testing.T) {
+	testCases := []struct {
+		params      linearParams
+		fpgas       int64
+		expReplicas int
+	}{
+		{linearParams{CoresPerReplica: 64, Min: 1, Max: 20, CombineMode: combineModeMax}, 0, 1},
+		{linearParams{CoresPerReplica: 64, Min: 1, Max: 20, CombineMode: combineModeMax}, 8, 2},
+		{linearParams{CoresPerReplica: 64, Min: 1, Max: 20, CombineMode: combineModeMax}, 9, 3},
+		{linearParams{CoresPerReplica: 64, Min: 1, Max: 20, CombineMode: combineModeMax}, 1000, 20},
+		{linearParams{CoresPerReplica: 64, Min: 1, Max: 20, CombineMode: combineModeMax, IncludeUnschedulableNodes: true}, 8, 3},
+		{linearParams{CoresPerReplica: 64, Min: 1, Max: 20, CombineMode: combineModeSum}, 8, 3},
+	}
+
+	for _, tc := range testCases {
+		testController := &LinearController{params: &tc.params}
+		status := &k8sclient.ClusterStatus{
+			TotalNodes:       1,
+			SchedulableNodes: 1,
+			TotalCores:       64,
+			SchedulableCores: 64,
+			ScaleResources: []k8sclient.ScaleResourceStatus{{
+				ScaleResource: k8sclient.ScaleResource{Name: "example.com/fpga", PerReplica: 4},
+				Total:         tc.fpgas + 4,
+				Schedulable:   tc.fpgas,
+			}},
+		}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if replicas := int(expected.Replicas); tc.expReplicas != replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
+		}
+	}
+
+	testController := &LinearController{params: &linearParams{CoresPerReplica: 64, Min: 1, CombineMode: combineModeMax}}
+	status := &k8sclient.ClusterStatus{
+		SchedulableCores: 64,
+		ScaleResources: []k8sclient.ScaleResourceStatus{{
+			ScaleResource: k8sclient.ScaleResource{Name: "example.com/fpga", PerReplica: 4},
+			Schedulable:   16,
+		}},
+	}
+	if expected := testController.computeExpectedReplicas(status); expected.BindingTerm != "example.com/fpga" {
+		t.Errorf("Expected the binding term to be example.com/fpga, got %+v", expected)
+	}
+}
+
+func TestGetBounds(t *testing.T) {
+	testCases := []struct {
+		jsonData string
+		expMin   string
+		expMax   string
+	}{
+		{`{"nodesPerReplica": 1}`, "1", "0"},
+		{`{"nodesPerReplica": 1, "min": 2, "max": 10}`, "2", "10"},
+		{`{"nodesPerReplica": 1, "min": "10%", "max": "50%"}`, "10%", "50%"},
+	}
+
+	for _, tc := range testCases {
+		params, err := parseParams([]byte(tc.jsonData))
+		if err != nil {
+			t.Fatalf("Unexpected parse failure: %v", err)
+		}
+		testController := &LinearController{params: params}
+		if min, max := testController.GetBounds(); min != tc.expMin || max != tc.expMax {
+			t.Errorf("Expected bounds %s and %s for %s, got %s and %s", tc.expMin, tc.expMax, tc.jsonData, min, max)
+		}
+	}
+}
+
+func resourcePtr(s string) *resource.Quantity {
+	q := resource.MustParse(s)
+	return &q
+}
+
+func TestRoundingModes(t *testing.T) {
+	for _, mode := range []string{"", roundingModeCeil, roundingModeFloor, roundingModeNearest} {
+		params, err := parseParams([]byte(fmt.Sprintf(`{"nodesPerReplica": 1, "roundingMode": %q}`, mode)))
+		if err != nil {
+			t.Errorf("Unexpected parse failure for roundingMode %q: %v", mode, err)
+			continue
+		}
+		if mode == "" && params.RoundingMode != roundingModeCeil {
+			t.Errorf("Expected roundingMode to default to %s, got %s", roundingModeCeil, params.RoundingMode)
+		}
+	}
+
+	testCases := []struct {
+		roundingMode string
+		numCores     int
+		numNodes     int
+		expReplicas  int
+	}{
+		{roundingModeCeil, 10, 1, 3},
+		{roundingModeFloor, 10, 1, 2},
+		{roundingModeNearest, 10, 1, 3},
+		{roundingModeNearest, 9, 1, 2},
+		// Each term is rounded before taking the max.
+		{roundingModeFloor, 10, 11, 2},
+		// Min still applies after flooring.
+		{roundingModeFloor, 2, 1, 1},
+	}
+
+	for _, tc := range testCases {
+		testController := &LinearController{}
+		testController.params = &linearParams{
+			CoresPerReplica: 4,
+			NodesPerReplica: 5,
+			Min:             1,
+			RoundingMode:    tc.roundingMode,
+		}
+		status := &k8sclient.ClusterStatus{
+			SchedulableCores: int32(tc.numCores),
+			SchedulableNodes: int32(tc.numNodes),
+		}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if replicas := int(expected.Replicas); tc.expReplicas != replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestTolerance(t *testing.T) {
+	testCases := []struct {
+		tolerance       float64
+		numNodes        int
+		currentReplicas int32
+		expReplicas     int32
+	}{
+		// No tolerance always follows the cluster.
+		{0, 11, 10, 11},
+		// Within 10% of the current replicas, in both directions.
+		{0.1, 11, 10, 10},
+		{0.1, 9, 10, 10},
+		// Outside of the band.
+		{0.1, 12, 10, 12},
+		{0.1, 8, 10, 8},
+		// Unknown current replicas.
+		{0.1, 11, 0, 11},
+		// Current replicas above max are not held.
+		{0.5, 15, 25, 15},
+	}
+
+	for _, tc := range testCases {
+		testController := &LinearController{}
+		testController.params = &linearParams{
+			NodesPerReplica: 1,
+			Min:             1,
+			Max:             20,
+			RoundingMode:    roundingModeCeil,
+			Tolerance:       tc.tolerance,
+		}
+		status := &k8sclient.ClusterStatus{
+			SchedulableNodes: int32(tc.numNodes),
+		}
+		expected, err := testController.GetExpectedReplicas(status, tc.currentReplicas)
+		if err != nil {
+			t.Errorf("Unexpected error for case %v: %v", tc, err)
+			continue
+		}
+		if replicas := expected.Replicas; tc.expReplicas != replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestCombineModes(t *testing.T) {
+	half := 0.5
+	testCases := []struct {
+		combineMode string
+		coresWeight *float64
+		numCores    int
+		numNodes    int
+		max         int
+		expReplicas int
+	}{
+		// max takes the higher of ceil(10/4) = 3 and ceil(12/5) = 3.
+		{combineModeMax, nil, 10, 12, 0, 3},
+		// sum takes ceil(10/4 + 12/5) = ceil(4.9) = 5.
+		{combineModeSum, nil, 10, 12, 0, 5},
+		// Weights apply to each term: ceil(0.5*10/4 + 12/5) = ceil(3.65) = 4.
+		{combineModeSum, &half, 10, 12, 0, 4},
+		// The sum is bounded by max.
+		{combineModeSum, nil, 10, 12, 4, 4},
+		// And by min.
+		{combineModeSum, nil, 0, 0, 0, 1},
+	}
+
+	for _, tc := range testCases {
+		testController := &LinearController{}
+		testController.params = &linearParams{
+			CoresPerReplica: 4,
+			NodesPerReplica: 5,
+			Min:             1,
+			Max:             tc.max,
+			RoundingMode:    roundingModeCeil,
+			CombineMode:     tc.combineMode,
+			CoresWeight:     tc.coresWeight,
+		}
+		status := &k8sclient.ClusterStatus{
+			SchedulableCores: int32(tc.numCores),
+			SchedulableNodes: int32(tc.numNodes),
+		}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if replicas := int(expected.Replicas); tc.expReplicas != replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestBindingTerm(t *testing.T) {
+	testCases := []struct {
+		combineMode    string
+		numCores       int
+		numNodes       int
+		expBindingTerm string
+		expRawValue    float64
+	}{
+		{combineModeMax, 10, 5, termCores, 2.5},
+		{combineModeMax, 4, 15, termNodes, 3},
+		// Ties go to nodes.
+		{combineModeMax, 8, 10, termNodes, 2},
+		{combineModeSum, 10, 5, termSum, 3.5},
+	}
+
+	for _, tc := range testCases {
+		testController := &LinearController{}
+		testController.params = &linearParams{
+			CoresPerReplica: 4,
+			NodesPerReplica: 5,
+			Min:             1,
+			RoundingMode:    roundingModeCeil,
+			CombineMode:     tc.combineMode,
+		}
+		status := &k8sclient.ClusterStatus{
+			SchedulableCores: int32(tc.numCores),
+			SchedulableNodes: int32(tc.numNodes),
+		}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Errorf("Unexpected error for case %v: %v", tc, err)
+			continue
+		}
+		if expected.BindingTerm != tc.expBindingTerm || expected.RawValue != tc.expRawValue {
+			t.Errorf("For case %v expected binding term %s with raw value %v, got %s with %v", tc, tc.expBindingTerm, tc.expRawValue, expected.BindingTerm, expected.RawValue)
+		}
+	}
+}
+
+func TestBindingTermFromCoresOnly(t *testing.T) {
+	testCases := []struct {
+		numCores                  int
+		numNodes                  int
+		preventSinglePointFailure bool
+		expReplicas               int32
+		expBindingTerm            string
+		expRawValue               float64
+	}{
+		// The unset nodes term never binds, the min does.
+		{4, 10, false, 2, termMin, 0.5},
+		{40, 10, false, 5, termCores, 5},
+		{200, 10, false, 10, termMax, 25},
+		{4, 10, true, 2, termMin, 0.5},
+		{4, 1, false, 2, termMin, 0.5},
+	}
+
+	for _, tc := range testCases {
+		params, err := parseParams([]byte(fmt.Sprintf(`{"coresPerReplica": 8, "min": 2, "max": 10, "preventSinglePointFailure": %v}`, tc.preventSinglePointFailure)))
+		if err != nil {
+			t.Fatalf("Unexpected error parsing params: %v", err)
+		}
+		testController := &LinearController{params: params}
+		status := &k8sclient.ClusterStatus{
+			SchedulableCores: int32(tc.numCores),
+			SchedulableNodes: int32(tc.numNodes),
+		}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Errorf("Unexpected error for case %v: %v", tc, err)
+			continue
+		}
+		if expected.Replicas != tc.expReplicas || expected.BindingTerm != tc.expBindingTerm || expected.RawValue != tc.expRawValue {
+			t.Errorf("For case %v expected %d replicas from %s with raw value %v, got %d from %s with %v", tc, tc.expReplicas, tc.expBindingTerm, tc.expRawValue, expected.Replicas, expected.BindingTerm, expected.RawValue)
+		}
+	}
+}
+
+func TestPreventSinglePointFailureBindingTerm(t *testing.T) {
+	testController := &LinearController{}
+	testController.params = &linearParams{
+		CoresPerReplica:           8,
+		Min:                       1,
+		PreventSinglePointFailure: true,
+		RoundingMode:              roundingModeCeil,
+	}
+	status := &k8sclient.ClusterStatus{SchedulableCores: 4, SchedulableNodes: 2}
+	expected, err := testController.GetExpectedReplicas(status, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected.Replicas != 2 || expected.BindingTerm != termPreventSinglePointFailure {
+		t.Errorf("Expected 2 replicas from %s, got %d from %s", termPreventSinglePointFailure, expected.Replicas, expected.BindingTerm)
+	}
+}
+
+func TestPercentageBounds(t *testing.T) {
+	testCases := []struct {
+		params      string
+		numNodes    int
+		expReplicas int32
+	}{
+		// 20% of 50 nodes caps 50 replicas to 10.
+		{`{"nodesPerReplica": 1, "max": "20%"}`, 50, 10},
+		// The same params are evaluated again for a different cluster size.
+		{`{"nodesPerReplica": 1, "max": "20%"}`, 200, 40},
+		// max is rounded down, to at least 1.
+		{`{"nodesPerReplica": 1, "max": "20%"}`, 3, 1},
+		// min is rounded up.
+		{`{"nodesPerReplica": 10, "min": "25%"}`, 10, 3},
+		// Integer and percentage bounds can be mixed.
+		{`{"nodesPerReplica": 1, "min": 2, "max": "50%"}`, 2, 2},
+	}
+
+	for _, tc := range testCases {
+		params, err := parseParams([]byte(tc.params))
+		if err != nil {
+			t.Errorf("Unexpected parse failure for %s: %v", tc.params, err)
+			continue
+		}
+		testController := &LinearController{params: params}
+		status := &k8sclient.ClusterStatus{
+			SchedulableNodes: int32(tc.numNodes),
+		}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Errorf("Unexpected error for case %v: %v", tc, err)
+			continue
+		}
+		if expected.Replicas != tc.expReplicas {
+			t.Errorf("For %s with %d nodes expected %d replicas, got %d", tc.params, tc.numNodes, tc.expReplicas, expected.Replicas)
+		}
+	}
+}
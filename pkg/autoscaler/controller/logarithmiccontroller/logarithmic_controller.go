@@ -0,0 +1,134 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logarithmiccontroller
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"k8s.io/api/core/v1"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
+)
+
+var _ = controller.Controller(&LogarithmicController{})
+var _ = controller.BoundedController(&LogarithmicController{})
+
+const (
+	// ControllerType defines the controller type string
+	ControllerType = "logarithmic"
+)
+
+// LogarithmicController uses logarithmic control pattern
+type LogarithmicController struct {
+	params  *logarithmicParams
+	version string
+}
+
+// NewLogarithmicController returns a new logarithmic controller
+func NewLogarithmicController() controller.Controller {
+	return &LogarithmicController{}
+}
+
+type logarithmicParams struct {
+	NodesPerReplicaBase float64 `json:"nodesPerReplicaBase"`
+	Min                 int     `json:"min"`
+	Max                 int     `json:"max"`
+	ReplicaStep         int     `json:"replicaStep"`
+}
+
+func (c *LogarithmicController) SyncConfig(configMap *v1.ConfigMap) error {
+	logging.V(0).Infof("ConfigMap version change (old: %s new: %s) - rebuilding params", c.version, configMap.ObjectMeta.ResourceVersion)
+	logging.V(2).Infof("Params from apiserver: \n%v", configMap.Data[ControllerType])
+	params, err := parseParams([]byte(configMap.Data[ControllerType]))
+	if err != nil {
+		return fmt.Errorf("error parsing logarithmic params: %s", err)
+	}
+	c.params = params
+	c.version = configMap.ObjectMeta.ResourceVersion
+	return nil
+}
+
+// parseParams Parse the params from JSON string
+func parseParams(data []byte) (*logarithmicParams, error) {
+	var p logarithmicParams
+	if err := controller.DecodeParams(data, &p); err != nil {
+		return nil, fmt.Errorf("could not parse parameters (%s)", err)
+	}
+	if p.Min < 0 {
+		return nil, fmt.Errorf("invalid negative value for min: %v", p.Min)
+	} else if p.Min == 0 {
+		logging.V(2).Infof("Defaulting min replicas count to 1 for logarithmic controller")
+		p.Min = 1
+	}
+	if p.Max != 0 && p.Max < p.Min {
+		return nil, fmt.Errorf("max replicas count %v should be greater than / equal to min replicas count %v", p.Max, p.Min)
+	}
+	if err := controller.ValidateReplicaStep(p.ReplicaStep, p.Min, p.Max); err != nil {
+		return nil, err
+	}
+	if p.NodesPerReplicaBase <= 1 {
+		return nil, fmt.Errorf("nodesPerReplicaBase should be greater than 1, got: %v", p.NodesPerReplicaBase)
+	}
+	return &p, nil
+}
+
+func (c *LogarithmicController) GetParamsVersion() string {
+	return c.version
+}
+
+func (c *LogarithmicController) GetParams() interface{} {
+	return c.params
+}
+
+func (c *LogarithmicController) GetBounds() (min, max string) {
+	return strconv.Itoa(c.params.Min), strconv.Itoa(c.params.Max)
+}
+
+func (c *LogarithmicController) GetExpectedReplicas(status *k8sclient.ClusterStatus, currentReplicas int32) (controller.ExpectedReplicas, error) {
+	// Get the expected replicas for the currently schedulable nodes
+	schedulableNodes := int(status.SchedulableNodes)
+	var rawValue float64
+	if schedulableNodes > 0 {
+		rawValue = math.Log(float64(schedulableNodes)+1) / math.Log(c.params.NodesPerReplicaBase)
+	}
+	return controller.ExpectedReplicas{
+		Replicas:    int32(c.getExpectedReplicasFromParams(schedulableNodes)),
+		BindingTerm: controller.BoundTerm(controller.TermNodes, math.Ceil(rawValue), c.params.Min, c.params.Max),
+		RawValue:    rawValue,
+	}, nil
+}
+
+func (c *LogarithmicController) getExpectedReplicasFromParams(schedulableNodes int) int {
+	replicas := c.params.Min
+	if schedulableNodes > 0 {
+		// replicas = log_base(nodes+1)
+		res := math.Ceil(math.Log(float64(schedulableNodes)+1) / math.Log(c.params.NodesPerReplicaBase))
+		if c.params.Max != 0 {
+			res = math.Min(float64(c.params.Max), res)
+		}
+		replicas = int(math.Max(float64(c.params.Min), res))
+	}
+	return controller.RoundToStep(replicas, c.params.ReplicaStep, c.params.Min, c.params.Max)
+}
+
+func (c *LogarithmicController) GetControllerType() string {
+	return ControllerType
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logarithmiccontroller
+
+import (
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
+)
+
+func verifyParams(t *testing.T, scalerParams, expScalerParams *logarithmicParams) {
+	if *scalerParams != *expScalerParams {
+		t.Errorf("Parser error - Expected params %v MISMATCHED: Got %v", expScalerParams, scalerParams)
+	}
+}
+
+func TestControllerParser(t *testing.T) {
+	testCases := []struct {
+		jsonData  string
+		expError  bool
+		expParams *logarithmicParams
+	}{
+		{
+			`{
+		      "nodesPerReplicaBase": 2,
+		      "min": 2,
+		      "max": 20
+		    }`,
+			false,
+			&logarithmicParams{
+				NodesPerReplicaBase: 2,
+				Min:                 2,
+				Max:                 20,
+			},
+		},
+		{ // Min defaults to 1
+			`{ "nodesPerReplicaBase": 10 }`,
+			false,
+			&logarithmicParams{
+				NodesPerReplicaBase: 10,
+				Min:                 1,
+			},
+		},
+		{ // Invalid JSON
+			`{ "nodesPerReplicaBase": {{ 1:1 } }`,
+			true,
+			&logarithmicParams{},
+		},
+		{ // Missing base
+			`{ "min": 1, "max": 10 }`,
+			true,
+			&logarithmicParams{},
+		},
+		{ // Base of 1 is not a valid logarithm base
+			`{ "nodesPerReplicaBase": 1 }`,
+			true,
+			&logarithmicParams{},
+		},
+		{ // Invalid max that smaller than min
+			`{ "nodesPerReplicaBase": 2, "min": 10, "max": 5 }`,
+			true,
+			&logarithmicParams{},
+		},
+		{ // Invalid negative replicaStep
+			`{ "nodesPerReplicaBase": 2, "replicaStep": -2 }`,
+			true,
+			&logarithmicParams{},
+		},
+		{ // No multiple of replicaStep between min and max
+			`{ "nodesPerReplicaBase": 2, "min": 2, "max": 3, "replicaStep": 4 }`,
+			true,
+			&logarithmicParams{},
+		},
+	}
+
+	for _, tc := range testCases {
+		params, err := parseParams([]byte(tc.jsonData))
+		if tc.expError {
+			if err == nil {
+				t.Errorf("Unexpected parsing success. Expected failure")
+				spew.Dump(tc)
+				spew.Dump(params)
+			}
+			continue
+		}
+		if err != nil && !tc.expError {
+			t.Errorf("Unexpected parse failure: %v", err)
+			spew.Dump(tc)
+			continue
+		}
+		verifyParams(t, params, tc.expParams)
+	}
+}
+
+func TestScaleFromNodes(t *testing.T) {
+	testController := &LogarithmicController{}
+	testController.params = &logarithmicParams{
+		NodesPerReplicaBase: 2,
+		Min:                 2,
+		Max:                 12,
+	}
+
+	testCases := []struct {
+		numNodes    int
+		expReplicas int
+	}{
+		{0, 2},
+		{1, 2},
+		{3, 2},
+		{4, 3},
+		{7, 3},
+		{8, 4},
+		{1000, 10},
+		{1023, 10},
+		{1024, 11},
+		{5000, 12},
+		{100000, 12},
+	}
+
+	for _, tc := range testCases {
+		if replicas := testController.getExpectedReplicasFromParams(tc.numNodes); tc.expReplicas != replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestScaleWithReplicaStep(t *testing.T) {
+	testController := &LogarithmicController{}
+	testController.params = &logarithmicParams{
+		NodesPerReplicaBase: 2,
+		Min:                 2,
+		Max:                 11,
+		ReplicaStep:         3,
+	}
+
+	testCases := []struct {
+		numNodes    int
+		expReplicas int
+	}{
+		{0, 3},
+		{4, 3},
+		{8, 6},
+		{1000, 9},
+		{1024, 9},
+	}
+
+	for _, tc := range testCases {
+		if replicas := testController.getExpectedReplicasFromParams(tc.numNodes); tc.expReplicas != replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestBindingTerm(t *testing.T) {
+	testController := &LogarithmicController{}
+	testController.params = &logarithmicParams{
+		NodesPerReplicaBase: 2,
+		Min:                 2,
+		Max:                 12,
+	}
+
+	testCases := []struct {
+		numNodes       int
+		expReplicas    int32
+		expBindingTerm string
+	}{
+		// Clamped to min or max.
+		{0, 2, controller.TermMin},
+		{1, 2, controller.TermMin},
+		{8, 4, controller.TermNodes},
+		{5000, 12, controller.TermMax},
+	}
+
+	for _, tc := range testCases {
+		expected, err := testController.GetExpectedReplicas(&k8sclient.ClusterStatus{SchedulableNodes: int32(tc.numNodes)}, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if expected.Replicas != tc.expReplicas || expected.BindingTerm != tc.expBindingTerm {
+			t.Errorf("For case %v expected %d replicas from %s, got %d from %s", tc, tc.expReplicas, tc.expBindingTerm, expected.Replicas, expected.BindingTerm)
+		}
+	}
+}
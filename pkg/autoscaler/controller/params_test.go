@@ -0,0 +1,50 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+)
+
+func TestDecodeParams(t *testing.T) {
+	type testParams struct {
+		CoresPerReplica float64 `json:"coresPerReplica"`
+		Min             int     `json:"min"`
+	}
+
+	testCases := []struct {
+		jsonData string
+		expError bool
+	}{
+		{`{"coresPerReplica": 2, "min": 1}`, false},
+		{`{}`, false},
+		{`{"coresPerRepluca": 2}`, true},
+		{`{"min": "1"}`, true},
+		{`{"min": 1} {"min": 2}`, true},
+		{`{"min": 1`, true},
+	}
+
+	for _, tc := range testCases {
+		var p testParams
+		err := DecodeParams([]byte(tc.jsonData), &p)
+		if err != nil && !tc.expError {
+			t.Errorf("Unexpected decode failure for %s: %v", tc.jsonData, err)
+		} else if err == nil && tc.expError {
+			t.Errorf("Unexpected decode success for %s, expected failure", tc.jsonData)
+		}
+	}
+}
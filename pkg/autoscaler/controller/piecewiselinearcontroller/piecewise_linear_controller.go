@@ -0,0 +1,189 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package piecewiselinearcontroller
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"k8s.io/api/core/v1"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
+)
+
+var _ = controller.Controller(&PiecewiseLinearController{})
+var _ = controller.BoundedController(&PiecewiseLinearController{})
+
+const (
+	// ControllerType defines the controller type string
+	ControllerType = "piecewiseLinear"
+)
+
+// PiecewiseLinearController uses piecewise linear control pattern
+type PiecewiseLinearController struct {
+	params  *piecewiseLinearParams
+	version string
+}
+
+// NewPiecewiseLinearController returns a new piecewise linear controller
+func NewPiecewiseLinearController() controller.Controller {
+	return &PiecewiseLinearController{}
+}
+
+// segment is a band of nodes, from the end of the previous segment up to
+// UpToNodes, over which the replicas grow linearly. UpToNodes of 0 on the
+// last segment leaves it unbounded.
+type segment struct {
+	UpToNodes       int     `json:"upToNodes"`
+	CoresPerReplica float64 `json:"coresPerReplica"`
+	NodesPerReplica float64 `json:"nodesPerReplica"`
+}
+
+type piecewiseLinearParams struct {
+	Segments    []segment `json:"segments"`
+	Min         int       `json:"min"`
+	Max         int       `json:"max"`
+	ReplicaStep int       `json:"replicaStep"`
+}
+
+func (c *PiecewiseLinearController) SyncConfig(configMap *v1.ConfigMap) error {
+	logging.V(0).Infof("ConfigMap version change (old: %s new: %s) - rebuilding params", c.version, configMap.ObjectMeta.ResourceVersion)
+	logging.V(2).Infof("Params from apiserver: \n%v", configMap.Data[ControllerType])
+	params, err := parseParams([]byte(configMap.Data[ControllerType]))
+	if err != nil {
+		return fmt.Errorf("error parsing piecewise linear params: %s", err)
+	}
+	c.params = params
+	c.version = configMap.ObjectMeta.ResourceVersion
+	return nil
+}
+
+// parseParams Parse the params from JSON string
+func parseParams(data []byte) (*piecewiseLinearParams, error) {
+	var p piecewiseLinearParams
+	if err := controller.DecodeParams(data, &p); err != nil {
+		return nil, fmt.Errorf("could not parse parameters (%s)", err)
+	}
+	if len(p.Segments) == 0 {
+		return nil, fmt.Errorf("should provide at least one segment")
+	}
+	// Each segment starts where the previous one ends, so sorted segments
+	// are contiguous.
+	for i, s := range p.Segments {
+		last := i == len(p.Segments)-1
+		if s.UpToNodes < 0 || (s.UpToNodes == 0 && !last) {
+			return nil, fmt.Errorf("invalid value for upToNodes of segment %d: %v, should be greater than 0, or omitted on the last segment", i, s.UpToNodes)
+		}
+		if i > 0 && s.UpToNodes != 0 && s.UpToNodes <= p.Segments[i-1].UpToNodes {
+			return nil, fmt.Errorf("segments should be sorted by upToNodes, segment %d up to %d nodes follows segment %d up to %d nodes", i, s.UpToNodes, i-1, p.Segments[i-1].UpToNodes)
+		}
+		if s.CoresPerReplica < 0 {
+			return nil, fmt.Errorf("invalid negative value for coresPerReplica of segment %d: %v", i, s.CoresPerReplica)
+		}
+		if s.NodesPerReplica < 0 {
+			return nil, fmt.Errorf("invalid negative value for nodesPerReplica of segment %d: %v", i, s.NodesPerReplica)
+		}
+		if s.CoresPerReplica == 0 && s.NodesPerReplica == 0 {
+			return nil, fmt.Errorf("segment %d should at least provide one of coresPerReplica or nodesPerReplica (Greater than 0)", i)
+		}
+	}
+	if p.Min < 0 {
+		return nil, fmt.Errorf("invalid negative value for min: %v", p.Min)
+	} else if p.Min == 0 {
+		logging.V(2).Infof("Defaulting min replicas count to 1 for piecewise linear controller")
+		p.Min = 1
+	}
+	if p.Max != 0 && p.Max < p.Min {
+		return nil, fmt.Errorf("max replicas count %v should be greater than / equal to min replicas count %v", p.Max, p.Min)
+	}
+	if err := controller.ValidateReplicaStep(p.ReplicaStep, p.Min, p.Max); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (c *PiecewiseLinearController) GetParamsVersion() string {
+	return c.version
+}
+
+func (c *PiecewiseLinearController) GetParams() interface{} {
+	return c.params
+}
+
+func (c *PiecewiseLinearController) GetBounds() (min, max string) {
+	return strconv.Itoa(c.params.Min), strconv.Itoa(c.params.Max)
+}
+
+func (c *PiecewiseLinearController) GetExpectedReplicas(status *k8sclient.ClusterStatus, currentReplicas int32) (controller.ExpectedReplicas, error) {
+	// Get the expected replicas for the currently schedulable nodes and cores
+	nodes, cores := c.computeRawReplicas(int(status.SchedulableNodes), int(status.SchedulableCores))
+	expected := controller.ExpectedReplicas{BindingTerm: controller.TermNodes, RawValue: nodes}
+	if cores > nodes {
+		expected = controller.ExpectedReplicas{BindingTerm: controller.TermCores, RawValue: cores}
+	}
+	expected.Replicas = int32(c.bound(expected.RawValue))
+	expected.BindingTerm = controller.BoundTerm(expected.BindingTerm, math.Ceil(expected.RawValue), c.params.Min, c.params.Max)
+	return expected, nil
+}
+
+// computeRawReplicas sums up, over the segments, the nodes of each segment
+// divided by its nodesPerReplica, and its share of the cores, as many cores
+// per node as on average, divided by its coresPerReplica. The replicas are
+// thus continuous across the segments.
+func (c *PiecewiseLinearController) computeRawReplicas(schedulableNodes, schedulableCores int) (nodes, cores float64) {
+	if schedulableNodes <= 0 {
+		return 0, 0
+	}
+	coresPerNode := float64(schedulableCores) / float64(schedulableNodes)
+	start := 0
+	for i, s := range c.params.Segments {
+		end := s.UpToNodes
+		// The last segment extends to all the larger clusters.
+		if end == 0 || end > schedulableNodes || i == len(c.params.Segments)-1 {
+			end = schedulableNodes
+		}
+		segmentNodes := float64(end - start)
+		if s.NodesPerReplica > 0 {
+			nodes += segmentNodes / s.NodesPerReplica
+		}
+		if s.CoresPerReplica > 0 {
+			cores += segmentNodes * coresPerNode / s.CoresPerReplica
+		}
+		if end >= schedulableNodes {
+			break
+		}
+		start = end
+	}
+	return nodes, cores
+}
+
+// bound rounds the raw replicas up and bounds them by min and max.
+func (c *PiecewiseLinearController) bound(raw float64) int {
+	res := math.Ceil(raw)
+	if c.params.Max != 0 {
+		res = math.Min(float64(c.params.Max), res)
+	}
+	replicas := int(math.Max(float64(c.params.Min), res))
+	return controller.RoundToStep(replicas, c.params.ReplicaStep, c.params.Min, c.params.Max)
+}
+
+func (c *PiecewiseLinearController) GetControllerType() string {
+	return ControllerType
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package piecewiselinearcontroller
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
+)
+
+func TestControllerParser(t *testing.T) {
+	testCases := []struct {
+		jsonData  string
+		expError  bool
+		expParams *piecewiseLinearParams
+	}{
+		{
+			`{
+		      "segments": [
+		        {"upToNodes": 100, "coresPerReplica": 64, "nodesPerReplica": 8},
+		        {"coresPerReplica": 256}
+		      ],
+		      "min": 2,
+		      "max": 50
+		    }`,
+			false,
+			&piecewiseLinearParams{
+				Segments: []segment{
+					{UpToNodes: 100, CoresPerReplica: 64, NodesPerReplica: 8},
+					{CoresPerReplica: 256},
+				},
+				Min: 2,
+				Max: 50,
+			},
+		},
+		{ // Min defaults to 1, the last segment may be bounded
+			`{ "segments": [{"upToNodes": 10, "nodesPerReplica": 2}] }`,
+			false,
+			&piecewiseLinearParams{
+				Segments: []segment{{UpToNodes: 10, NodesPerReplica: 2}},
+				Min:      1,
+			},
+		},
+		{ // Invalid JSON
+			`{ "segments": {{ 1:1 } }`,
+			true,
+			nil,
+		},
+		{ // Missing segments
+			`{ "min": 1, "max": 10 }`,
+			true,
+			nil,
+		},
+		{ // Segments not sorted
+			`{ "segments": [{"upToNodes": 100, "nodesPerReplica": 8}, {"upToNodes": 50, "nodesPerReplica": 16}, {"nodesPerReplica": 32}] }`,
+			true,
+			nil,
+		},
+		{ // Segments overlapping
+			`{ "segments": [{"upToNodes": 100, "nodesPerReplica": 8}, {"upToNodes": 100, "nodesPerReplica": 16}] }`,
+			true,
+			nil,
+		},
+		{ // Only the last segment may be unbounded
+			`{ "segments": [{"nodesPerReplica": 8}, {"upToNodes": 100, "nodesPerReplica": 16}] }`,
+			true,
+			nil,
+		},
+		{ // Segment without ratios
+			`{ "segments": [{"upToNodes": 100, "nodesPerReplica": 8}, {"upToNodes": 200}] }`,
+			true,
+			nil,
+		},
+		{ // Invalid negative ratio
+			`{ "segments": [{"coresPerReplica": -1, "nodesPerReplica": 8}] }`,
+			true,
+			nil,
+		},
+		{ // Invalid max that smaller than min
+			`{ "segments": [{"nodesPerReplica": 8}], "min": 10, "max": 5 }`,
+			true,
+			nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		params, err := parseParams([]byte(tc.jsonData))
+		if tc.expError {
+			if err == nil {
+				t.Errorf("Unexpected parsing success. Expected failure")
+				spew.Dump(tc)
+				spew.Dump(params)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected parse failure: %v", err)
+			spew.Dump(tc)
+			continue
+		}
+		if !reflect.DeepEqual(params, tc.expParams) {
+			t.Errorf("Parser error - Expected params %v MISMATCHED: Got %v", tc.expParams, params)
+		}
+	}
+}
+
+func TestScaleFromSegments(t *testing.T) {
+	testController := &PiecewiseLinearController{}
+	testController.params = &piecewiseLinearParams{
+		Segments: []segment{
+			{UpToNodes: 100, CoresPerReplica: 64, NodesPerReplica: 8},
+			{UpToNodes: 1000, CoresPerReplica: 256, NodesPerReplica: 32},
+			{CoresPerReplica: 1024, NodesPerReplica: 128},
+		},
+		Min: 1,
+		Max: 100,
+	}
+
+	testCases := []struct {
+		numNodes    int
+		numCores    int
+		expReplicas int
+	}{
+		{0, 0, 1},
+		{8, 32, 1},
+		{50, 200, 7},
+		// The replicas are continuous at the boundary of the segments.
+		{100, 400, 13},
+		{101, 404, 13},
+		{200, 800, 16},
+		// Cores bind when the nodes are larger.
+		{200, 12800, 100},
+		{200, 3200, 32},
+		{2000, 16000, 49},
+		{100000, 400000, 100},
+	}
+
+	for _, tc := range testCases {
+		status := &k8sclient.ClusterStatus{SchedulableNodes: int32(tc.numNodes), SchedulableCores: int32(tc.numCores)}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if replicas := int(expected.Replicas); tc.expReplicas != replicas {
+			t.Errorf("Scaler Lookup failed for case %v: Expected %d, Got %d", tc, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestBoundedLastSegment(t *testing.T) {
+	testController := &PiecewiseLinearController{}
+	testController.params = &piecewiseLinearParams{
+		Segments: []segment{
+			{UpToNodes: 10, NodesPerReplica: 2},
+			{UpToNodes: 20, NodesPerReplica: 5},
+		},
+		Min: 1,
+	}
+
+	// The last segment extends to the larger clusters even when bounded.
+	expected, err := testController.GetExpectedReplicas(&k8sclient.ClusterStatus{SchedulableNodes: 40, SchedulableCores: 160}, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected.Replicas != 11 || expected.BindingTerm != controller.TermNodes || expected.RawValue != 11 {
+		t.Errorf("Expected 11 replicas bound by nodes, got %+v", expected)
+	}
+}
+
+func TestBindingTerm(t *testing.T) {
+	testController := &PiecewiseLinearController{}
+	testController.params = &piecewiseLinearParams{
+		Segments: []segment{
+			{UpToNodes: 100, CoresPerReplica: 64, NodesPerReplica: 8},
+			{CoresPerReplica: 1024, NodesPerReplica: 128},
+		},
+		Min: 2,
+		Max: 100,
+	}
+
+	testCases := []struct {
+		numNodes       int
+		numCores       int
+		expReplicas    int32
+		expBindingTerm string
+		expRawValue    float64
+	}{
+		// Clamped to min or max, the raw value is still that of the metric.
+		{8, 32, 2, controller.TermMin, 1},
+		{50, 200, 7, controller.TermNodes, 6.25},
+		{50, 800, 13, controller.TermCores, 12.5},
+		{200, 12800, 100, controller.TermMax, 106.25},
+	}
+
+	for _, tc := range testCases {
+		status := &k8sclient.ClusterStatus{SchedulableNodes: int32(tc.numNodes), SchedulableCores: int32(tc.numCores)}
+		expected, err := testController.GetExpectedReplicas(status, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if expected.Replicas != tc.expReplicas || expected.BindingTerm != tc.expBindingTerm || expected.RawValue != tc.expRawValue {
+			t.Errorf("For case %v expected %d replicas from %s with raw value %v, got %+v", tc, tc.expReplicas, tc.expBindingTerm, tc.expRawValue, expected)
+		}
+	}
+}
@@ -18,36 +18,125 @@ package plugin
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 
 	"k8s.io/api/core/v1"
 
 	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller/exponentialcontroller"
 	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller/laddercontroller"
 	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller/linearcontroller"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller/logarithmiccontroller"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller/piecewiselinearcontroller"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
+)
+
+// ScheduleKey is the ConfigMap key of the optional scaling schedule, which is
+// applied by the autoscaler on top of the control mode.
+const ScheduleKey = "schedule"
 
-	"github.com/golang/glog"
+// Factory returns a new controller for a control mode.
+type Factory func() controller.Controller
+
+var (
+	factoriesLock sync.RWMutex
+	factories     = make(map[string]Factory)
 )
 
+func init() {
+	Register(laddercontroller.ControllerType, laddercontroller.NewLadderController)
+	Register(linearcontroller.ControllerType, linearcontroller.NewLinearController)
+	Register(exponentialcontroller.ControllerType, exponentialcontroller.NewExponentialController)
+	Register(logarithmiccontroller.ControllerType, logarithmiccontroller.NewLogarithmicController)
+	Register(piecewiselinearcontroller.ControllerType, piecewiselinearcontroller.NewPiecewiseLinearController)
+}
+
+// Register makes a control mode available under its ConfigMap key, e.g. from
+// the init function of the package of a custom controller. The controllers
+// returned by the factory should report mode as their type. Register panics
+// if the mode is registered twice or is the schedule key.
+func Register(mode string, factory Factory) {
+	factoriesLock.Lock()
+	defer factoriesLock.Unlock()
+	if mode == "" || mode == ScheduleKey {
+		panic(fmt.Sprintf("plugin: invalid control mode %q", mode))
+	}
+	if factory == nil {
+		panic(fmt.Sprintf("plugin: nil factory for control mode %s", mode))
+	}
+	if _, dup := factories[mode]; dup {
+		panic(fmt.Sprintf("plugin: control mode %s registered twice", mode))
+	}
+	factories[mode] = factory
+}
+
+// SupportedModes returns the registered control modes, sorted.
+func SupportedModes() []string {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+	modes := make([]string, 0, len(factories))
+	for mode := range factories {
+		modes = append(modes, mode)
+	}
+	sort.Strings(modes)
+	return modes
+}
+
+func lookupFactory(mode string) (Factory, bool) {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+	factory, ok := factories[mode]
+	return factory, ok
+}
+
+// ValidateMode returns an error if mode is not a registered control mode.
+func ValidateMode(mode string) error {
+	if _, ok := lookupFactory(mode); !ok {
+		return fmt.Errorf("not a supported control mode: %v, should be one of %v", mode, SupportedModes())
+	}
+	return nil
+}
+
+// EnsureControllerOfMode is EnsureController for a configMap which must hold
+// the params of the given control mode, instead of the mode being detected
+// from its key. An empty mode detects it as EnsureController does.
+func EnsureControllerOfMode(cont controller.Controller, configMap *v1.ConfigMap, mode string) (controller.Controller, error) {
+	if mode != "" {
+		if err := ValidateMode(mode); err != nil {
+			return nil, err
+		}
+		if _, ok := configMap.Data[mode]; !ok {
+			return nil, fmt.Errorf("invalid configMap format, expected the params of the %s control mode set by --controller-type, got: %v", mode, configMap.Data)
+		}
+	}
+	return EnsureController(cont, configMap)
+}
+
 // EnsureController ensures controller type and scaling params
 func EnsureController(cont controller.Controller, configMap *v1.ConfigMap) (controller.Controller, error) {
-	// Expect only one entry, which uses the name of control mode as the key
-	if len(configMap.Data) != 1 {
-		return nil, fmt.Errorf("invalid configMap format, expected only one entry, got: %v", configMap.Data)
+	// Expect only one entry besides the schedule, which uses the name of control mode as the key
+	numModes := len(configMap.Data)
+	if _, ok := configMap.Data[ScheduleKey]; ok {
+		numModes--
+	}
+	if numModes != 1 {
+		return nil, fmt.Errorf("invalid configMap format, expected exactly one entry keyed by one of the control modes %v, got: %v", SupportedModes(), configMap.Data)
 	}
 	for mode := range configMap.Data {
+		if mode == ScheduleKey {
+			continue
+		}
 		// No need to reset controller if control pattern doesn't change
 		if cont != nil && mode == cont.GetControllerType() {
 			break
 		}
-		switch mode {
-		case laddercontroller.ControllerType:
-			cont = laddercontroller.NewLadderController()
-		case linearcontroller.ControllerType:
-			cont = linearcontroller.NewLinearController()
-		default:
+		factory, ok := lookupFactory(mode)
+		if !ok {
 			return nil, fmt.Errorf("not a supported control mode: %v", mode)
 		}
-		glog.V(1).Infof("Set control mode to %v", mode)
+		cont = factory()
+		logging.V(1).Infof("Set control mode to %v", mode)
 	}
 
 	// Sync config with controller
@@ -17,9 +17,13 @@ limitations under the License.
 package plugin
 
 import (
+	"reflect"
 	"testing"
 
 	"k8s.io/api/core/v1"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
 )
 
 func TestEnsureController(t *testing.T) {
@@ -52,6 +56,54 @@ func TestEnsureController(t *testing.T) {
 			},
 			false,
 		},
+		{
+			&v1.ConfigMap{
+				Data: map[string]string{
+					"exponential": "{\"base\":2,\"coefficient\":1,\"exponent\":0.1}",
+				},
+			},
+			false,
+		},
+		{
+			&v1.ConfigMap{
+				Data: map[string]string{
+					"logarithmic": "{\"nodesPerReplicaBase\":2}",
+				},
+			},
+			false,
+		},
+		{
+			&v1.ConfigMap{
+				Data: map[string]string{
+					"linear":      "{\"nodesPerReplica\":1}",
+					"exponential": "{\"base\":2,\"coefficient\":1,\"exponent\":0.1}",
+				},
+			},
+			true,
+		},
+		{
+			&v1.ConfigMap{
+				Data: map[string]string{},
+			},
+			true,
+		},
+		{
+			&v1.ConfigMap{
+				Data: map[string]string{
+					"linear":   "{\"nodesPerReplica\":1}",
+					"schedule": "[]",
+				},
+			},
+			false,
+		},
+		{
+			&v1.ConfigMap{
+				Data: map[string]string{
+					"schedule": "[]",
+				},
+			},
+			true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -65,3 +117,79 @@ func TestEnsureController(t *testing.T) {
 		}
 	}
 }
+
+func TestEnsureControllerOfMode(t *testing.T) {
+	testCases := []struct {
+		mode     string
+		data     map[string]string
+		expError bool
+	}{
+		// The mode is detected without --controller-type.
+		{"", map[string]string{"ladder": `{"nodesToReplicas": [[1, 1]]}`}, false},
+		{"linear", map[string]string{"linear": `{"nodesPerReplica": 1}`}, false},
+		{"linear", map[string]string{"linear": `{"nodesPerReplica": 1}`, ScheduleKey: "[]"}, false},
+		{"linear", map[string]string{"ladder": `{"nodesToReplicas": [[1, 1]]}`}, true},
+		{"linear", map[string]string{"linear": `{"nodesPerReplica": 1}`, "ladder": `{"nodesToReplicas": [[1, 1]]}`}, true},
+		{"unknown", map[string]string{"unknown": "{}"}, true},
+	}
+
+	for _, tc := range testCases {
+		cont, err := EnsureControllerOfMode(nil, &v1.ConfigMap{Data: tc.data}, tc.mode)
+		if (err != nil) != tc.expError {
+			t.Errorf("For mode %q and params %v expected error %v, got %v", tc.mode, tc.data, tc.expError, err)
+			continue
+		}
+		if err == nil && tc.mode != "" && cont.GetControllerType() != tc.mode {
+			t.Errorf("For mode %q expected the %s controller, got %s", tc.mode, tc.mode, cont.GetControllerType())
+		}
+	}
+}
+
+// fakeController always expects the replicas it was synced with.
+type fakeController struct {
+	version string
+}
+
+func (c *fakeController) GetExpectedReplicas(status *k8sclient.ClusterStatus, currentReplicas int32) (controller.ExpectedReplicas, error) {
+	return controller.ExpectedReplicas{Replicas: 3, BindingTerm: "fake"}, nil
+}
+
+func (c *fakeController) SyncConfig(configMap *v1.ConfigMap) error {
+	c.version = configMap.ObjectMeta.ResourceVersion
+	return nil
+}
+
+func (c *fakeController) GetParamsVersion() string  { return c.version }
+func (c *fakeController) GetParams() interface{}    { return nil }
+func (c *fakeController) GetControllerType() string { return "fake" }
+
+func TestRegister(t *testing.T) {
+	Register("fake", func() controller.Controller { return &fakeController{} })
+	defer func() {
+		factoriesLock.Lock()
+		delete(factories, "fake")
+		factoriesLock.Unlock()
+	}()
+
+	if modes := SupportedModes(); !reflect.DeepEqual(modes, []string{"exponential", "fake", "ladder", "linear", "logarithmic", "piecewiseLinear"}) {
+		t.Errorf("Unexpected supported modes %v", modes)
+	}
+	cont, err := EnsureController(nil, &v1.ConfigMap{Data: map[string]string{"fake": "{}"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cont.GetControllerType() != "fake" {
+		t.Errorf("Expected the fake controller, got %s", cont.GetControllerType())
+	}
+
+	for _, mode := range []string{"fake", "linear", ScheduleKey, ""} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected registering control mode %q to panic", mode)
+				}
+			}()
+			Register(mode, func() controller.Controller { return &fakeController{} })
+		}()
+	}
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+)
+
+// RoundToStep rounds the replicas up to the nearest multiple of step, or down
+// to the nearest one if that would exceed max, a max of 0 meaning unbounded.
+// Rounding down never goes below min, which wins over the step when no
+// multiple of it lies within the bounds. A step of 0 or 1 leaves the replicas
+// as is.
+func RoundToStep(replicas, step, min, max int) int {
+	if step <= 1 || replicas%step == 0 {
+		return replicas
+	}
+	rounded := (replicas/step + 1) * step
+	if max != 0 && rounded > max {
+		rounded = replicas / step * step
+		if rounded < min {
+			rounded = min
+		}
+	}
+	return rounded
+}
+
+// ValidateReplicaStep checks that step, if set, is positive and that one of
+// its multiples lies within min and max, so that rounding keeps the replicas
+// within bounds.
+func ValidateReplicaStep(step, min, max int) error {
+	if step < 0 {
+		return fmt.Errorf("invalid value for replicaStep: %v, should be at least 1", step)
+	}
+	if step > 1 && max != 0 && (min+step-1)/step*step > max {
+		return fmt.Errorf("no multiple of replicaStep %v between min %v and max %v", step, min, max)
+	}
+	return nil
+}
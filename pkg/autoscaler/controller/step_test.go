@@ -0,0 +1,76 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+)
+
+func TestRoundToStep(t *testing.T) {
+	testCases := []struct {
+		replicas    int
+		step        int
+		min         int
+		max         int
+		expReplicas int
+	}{
+		{4, 0, 1, 0, 4},
+		{4, 1, 1, 0, 4},
+		{3, 3, 1, 0, 3},
+		{4, 3, 1, 0, 6},
+		{5, 3, 1, 0, 6},
+		{7, 3, 1, 10, 9},
+		{10, 3, 1, 10, 9},
+		{8, 3, 1, 8, 6},
+		{0, 3, 0, 0, 0},
+		// Rounding down never goes below min, nor to 0.
+		{2, 3, 1, 2, 1},
+		{2, 3, 2, 2, 2},
+		{5, 3, 4, 5, 4},
+		{2, 3, 0, 2, 0},
+	}
+
+	for _, tc := range testCases {
+		if replicas := RoundToStep(tc.replicas, tc.step, tc.min, tc.max); replicas != tc.expReplicas {
+			t.Errorf("Rounding %d replicas to a step of %d with min %d and max %d: expected %d, got %d", tc.replicas, tc.step, tc.min, tc.max, tc.expReplicas, replicas)
+		}
+	}
+}
+
+func TestValidateReplicaStep(t *testing.T) {
+	testCases := []struct {
+		step     int
+		min      int
+		max      int
+		expError bool
+	}{
+		{0, 1, 0, false},
+		{1, 1, 1, false},
+		{3, 1, 0, false},
+		{3, 1, 3, false},
+		{3, 4, 6, false},
+		{3, 4, 5, true},
+		{3, 1, 2, true},
+		{-1, 1, 0, true},
+	}
+
+	for _, tc := range testCases {
+		if err := ValidateReplicaStep(tc.step, tc.min, tc.max); (err != nil) != tc.expError {
+			t.Errorf("Validating a step of %d with min %d and max %d: expected error %v, got %v", tc.step, tc.min, tc.max, tc.expError, err)
+		}
+	}
+}
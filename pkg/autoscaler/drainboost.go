@@ -0,0 +1,61 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
+)
+
+// drainBoost raises the min of the params by a number of replicas for a
+// window after nodes start being drained or deleted, e.g. by the cluster
+// autoscaler, so that targets get headroom while the pods of those nodes are
+// rescheduled. Each new drain restarts the window.
+type drainBoost struct {
+	replicas int32
+	window   time.Duration
+	clock    clock.Clock
+	// drainingNodes is the number of draining nodes at the last poll.
+	drainingNodes int32
+	since         time.Time
+}
+
+func newDrainBoost(replicas int32, window time.Duration, clock clock.Clock) *drainBoost {
+	return &drainBoost{replicas: replicas, window: window, clock: clock}
+}
+
+// observe records the number of draining nodes, starting the window when it
+// rises.
+func (b *drainBoost) observe(drainingNodes int32) {
+	if drainingNodes > b.drainingNodes {
+		logging.V(0).Infof("Draining nodes went up from %d to %d, raising the min replicas by %d for %v", b.drainingNodes, drainingNodes, b.replicas, b.window)
+		b.since = b.clock.Now()
+	}
+	b.drainingNodes = drainingNodes
+}
+
+// boost returns the replicas the min is raised by, 0 once the window since the
+// last drain has passed.
+func (b *drainBoost) boost() int32 {
+	if b.since.IsZero() || b.clock.Since(b.since) >= b.window {
+		return 0
+	}
+	return b.replicas
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestDrainBoost(t *testing.T) {
+	type poll struct {
+		drainingNodes int32
+		elapsed       time.Duration
+		expBoost      int32
+	}
+	testCases := []struct {
+		polls []poll
+	}{
+		// A drain boosts for the window, then the boost goes away.
+		{
+			polls: []poll{
+				{0, 0, 0},
+				{1, 10 * time.Second, 2},
+				{1, 30 * time.Second, 2},
+				{0, 20 * time.Second, 2},
+				{0, 10 * time.Second, 0},
+			},
+		},
+		// Another drain within the window restarts it.
+		{
+			polls: []poll{
+				{1, 0, 2},
+				{2, 50 * time.Second, 2},
+				{2, 50 * time.Second, 2},
+				{2, 10 * time.Second, 0},
+			},
+		},
+		// Nodes done draining don't boost.
+		{
+			polls: []poll{
+				{0, 0, 0},
+				{3, 0, 2},
+				{1, time.Minute, 0},
+				{1, time.Minute, 0},
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		fakeClock := clock.NewFakeClock(time.Now())
+		b := newDrainBoost(2, time.Minute, fakeClock)
+		for j, p := range tc.polls {
+			fakeClock.Step(p.elapsed)
+			b.observe(p.drainingNodes)
+			if boost := b.boost(); boost != p.expBoost {
+				t.Errorf("Case %d poll %d with %d draining nodes: expected boost %d, got %d", i, j, p.drainingNodes, p.expBoost, boost)
+			}
+		}
+	}
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
+)
+
+// grpcHealthCheckPath is the path of the Check method of the standard gRPC
+// health service, grpc.health.v1.Health.
+const grpcHealthCheckPath = "/grpc.health.v1.Health/Check"
+
+// Serving statuses of grpc.health.v1.HealthCheckResponse.
+const (
+	grpcServing    = 1
+	grpcNotServing = 2
+)
+
+// gRPC status codes returned by the health service.
+const (
+	grpcOK              = 0
+	grpcInvalidArgument = 3
+	grpcNotFound        = 5
+	grpcUnimplemented   = 12
+)
+
+// grpcIdleTimeout closes the connections of probes which went away.
+const grpcIdleTimeout = time.Minute
+
+// grpcHealthServer serves the Check method of the standard gRPC health
+// service, e.g. for grpc_health_probe, reporting SERVING while /healthz
+// succeeds. gRPC is spoken over cleartext HTTP/2 and the few bytes of protobuf
+// of the health service are encoded by hand, which spares depending on a gRPC
+// implementation.
+type grpcHealthServer struct {
+	lastPollCycleHealth *healthInfo
+	clock               clock.Clock
+	maxStall            time.Duration
+	server              *http2.Server
+	listener            net.Listener
+	doneCh              chan struct{}
+}
+
+func newGRPCHealthServer(lastPollCycleHealth *healthInfo, clock clock.Clock, pollPeriod time.Duration) *grpcHealthServer {
+	return &grpcHealthServer{
+		lastPollCycleHealth: lastPollCycleHealth,
+		clock:               clock,
+		maxStall:            stalledPollPeriods * pollPeriod,
+		server:              &http2.Server{IdleTimeout: grpcIdleTimeout},
+		doneCh:              make(chan struct{}),
+	}
+}
+
+// listen binds the address of the server ahead of Start, see listen.
+func (gs *grpcHealthServer) listen(addr string) (err error) {
+	gs.listener, err = listen("--grpc-health-bind-address", addr)
+	return err
+}
+
+func (gs *grpcHealthServer) Start() {
+	logging.V(0).Infof("Serving gRPC health checks on %s", gs.listener.Addr())
+	handler := http.HandlerFunc(gs.serveGRPC)
+	for {
+		conn, err := gs.listener.Accept()
+		if err != nil {
+			select {
+			case <-gs.doneCh:
+			default:
+				logging.Errorf("gRPC health server failed: %v", err)
+			}
+			return
+		}
+		go gs.server.ServeConn(conn, &http2.ServeConnOpts{Handler: handler})
+	}
+}
+
+func (gs *grpcHealthServer) Shutdown() {
+	close(gs.doneCh)
+	if err := gs.listener.Close(); err != nil {
+		logging.Errorf("Error shutting down gRPC health server: %v", err)
+	}
+}
+
+func (gs *grpcHealthServer) serveGRPC(w http.ResponseWriter, req *http.Request) {
+	// The status goes in the trailers, after the response message if any.
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.Header().Set("Content-Type", "application/grpc")
+	if req.URL.Path != grpcHealthCheckPath {
+		writeGRPCStatus(w, grpcUnimplemented, fmt.Sprintf("unknown method %s", req.URL.Path))
+		return
+	}
+	if !strings.HasPrefix(req.Header.Get("Content-Type"), "application/grpc") {
+		writeGRPCStatus(w, grpcInvalidArgument, fmt.Sprintf("unsupported content type %q", req.Header.Get("Content-Type")))
+		return
+	}
+	service, err := readHealthCheckRequest(req.Body)
+	if err != nil {
+		writeGRPCStatus(w, grpcInvalidArgument, err.Error())
+		return
+	}
+	// Only the overall health of the server, the empty service, is known.
+	if service != "" {
+		writeGRPCStatus(w, grpcNotFound, fmt.Sprintf("unknown service %s", service))
+		return
+	}
+	status := grpcServing
+	if since := gs.lastPollCycleHealth.sinceLastSuccessfulPoll(gs.clock.Now()); since > gs.maxStall {
+		status = grpcNotServing
+	}
+	// A HealthCheckResponse with its status, field 1, as a varint.
+	w.Write(grpcMessage([]byte{0x08, byte(status)}))
+	writeGRPCStatus(w, grpcOK, "")
+}
+
+// readHealthCheckRequest returns the service of the HealthCheckRequest read
+// from body, a single uncompressed gRPC message.
+func readHealthCheckRequest(body io.Reader) (string, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(body, 64<<10))
+	if err != nil {
+		return "", err
+	}
+	if len(data) < 5 || data[0] != 0 || int(binary.BigEndian.Uint32(data[1:5])) != len(data)-5 {
+		return "", fmt.Errorf("malformed request message")
+	}
+	message := data[5:]
+	var service string
+	for len(message) > 0 {
+		key, n := binary.Uvarint(message)
+		if n <= 0 {
+			return "", fmt.Errorf("malformed request message")
+		}
+		message = message[n:]
+		var value []byte
+		switch key & 7 {
+		case 0: // Varint
+			if _, n = binary.Uvarint(message); n <= 0 {
+				return "", fmt.Errorf("malformed request message")
+			}
+		case 1: // 64-bit
+			n = 8
+		case 2: // Length-delimited
+			length, m := binary.Uvarint(message)
+			if m <= 0 || uint64(len(message)-m) < length {
+				return "", fmt.Errorf("malformed request message")
+			}
+			value = message[m : m+int(length)]
+			n = m + int(length)
+		case 5: // 32-bit
+			n = 4
+		default:
+			return "", fmt.Errorf("malformed request message")
+		}
+		if len(message) < n {
+			return "", fmt.Errorf("malformed request message")
+		}
+		message = message[n:]
+		// The service is field 1, a string.
+		if key == 0x0a {
+			service = string(value)
+		}
+	}
+	return service, nil
+}
+
+// grpcMessage prefixes message with the uncompressed flag and its length.
+func grpcMessage(message []byte) []byte {
+	framed := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(framed[1:], uint32(len(message)))
+	return append(framed, message...)
+}
+
+func writeGRPCStatus(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Grpc-Status", fmt.Sprintf("%d", code))
+	if message != "" {
+		w.Header().Set("Grpc-Message", message)
+	}
+}
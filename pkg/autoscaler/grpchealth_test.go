@@ -0,0 +1,115 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestGRPCHealthServer(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	pollPeriod := 10 * time.Second
+	health := newHealthInfo()
+	gs := newGRPCHealthServer(health, fakeClock, pollPeriod)
+	if err := gs.listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	go gs.Start()
+	defer gs.Shutdown()
+
+	// Talk cleartext HTTP/2 with prior knowledge, as gRPC clients do.
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	check := func(path string, request []byte) (body []byte, trailer http.Header) {
+		req, err := http.NewRequest("POST", "http://"+gs.listener.Addr().String()+path, bytes.NewReader(grpcMessage(request)))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/grpc")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Unexpected error calling %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		if body, err = ioutil.ReadAll(resp.Body); err != nil {
+			t.Fatalf("Unexpected error reading the response: %v", err)
+		}
+		return body, resp.Trailer
+	}
+	serving := grpcMessage([]byte{0x08, grpcServing})
+	notServing := grpcMessage([]byte{0x08, grpcNotServing})
+
+	health.setPolling(fakeClock.Now(), true)
+	if body, trailer := check(grpcHealthCheckPath, nil); !bytes.Equal(body, serving) || trailer.Get("Grpc-Status") != "0" {
+		t.Errorf("Expected SERVING while polling, got %v with trailer %v", body, trailer)
+	}
+
+	// No successful poll for more than 3 poll periods.
+	fakeClock.Step(3*pollPeriod + time.Second)
+	if body, trailer := check(grpcHealthCheckPath, nil); !bytes.Equal(body, notServing) || trailer.Get("Grpc-Status") != "0" {
+		t.Errorf("Expected NOT_SERVING once the poll loop stalled, got %v with trailer %v", body, trailer)
+	}
+	health.setLastSuccessfulPoll(fakeClock.Now())
+	// The request names no service, field 1 is empty.
+	if body, _ := check(grpcHealthCheckPath, []byte{0x0a, 0x00}); !bytes.Equal(body, serving) {
+		t.Errorf("Expected SERVING after a successful poll, got %v", body)
+	}
+
+	// A request for another service.
+	if _, trailer := check(grpcHealthCheckPath, append([]byte{0x0a, 0x03}, "foo"...)); trailer.Get("Grpc-Status") != "5" {
+		t.Errorf("Expected NOT_FOUND for an unknown service, got trailer %v", trailer)
+	}
+	if _, trailer := check("/grpc.health.v1.Health/Watch", nil); trailer.Get("Grpc-Status") != "12" {
+		t.Errorf("Expected UNIMPLEMENTED for Watch, got trailer %v", trailer)
+	}
+}
+
+func TestReadHealthCheckRequest(t *testing.T) {
+	testCases := []struct {
+		data       []byte
+		expService string
+		expError   bool
+	}{
+		{grpcMessage(nil), "", false},
+		{grpcMessage(append([]byte{0x0a, 0x03}, "foo"...)), "foo", false},
+		// Unknown fields are skipped.
+		{grpcMessage(append([]byte{0x10, 0x01, 0x0a, 0x03}, "foo"...)), "foo", false},
+		{grpcMessage([]byte{0x0a, 0x05, 'f'}), "", true},
+		{[]byte{0, 0, 0}, "", true},
+		// Compressed messages are not supported.
+		{append([]byte{1}, grpcMessage(nil)[1:]...), "", true},
+	}
+	for _, tc := range testCases {
+		service, err := readHealthCheckRequest(bytes.NewReader(tc.data))
+		if (err != nil) != tc.expError || service != tc.expService {
+			t.Errorf("For %v expected service %q and error %v, got %q and %v", tc.data, tc.expService, tc.expError, service, err)
+		}
+	}
+}
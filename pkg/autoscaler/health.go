@@ -16,24 +16,88 @@ limitations under the License.
 package autoscaler
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
-	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
 )
 
+// pollStatus is what the autoscaler observed and computed during its last
+// completed poll, served as JSON at /debug/status.
+type pollStatus struct {
+	Time           time.Time                `json:"time"`
+	ClusterStatus  *k8sclient.ClusterStatus `json:"clusterStatus"`
+	ControllerType string                   `json:"controllerType"`
+	ParamsVersion  string                   `json:"paramsVersion"`
+	Params         interface{}              `json:"params"`
+	Targets        map[string]*targetStatus `json:"targets"`
+	// ScalingFrozen is set when the node count drop froze the poll, none of
+	// the targets were scaled then.
+	ScalingFrozen bool `json:"scalingFrozen,omitempty"`
+}
+
+// targetStatus is the outcome of the last poll for a single target.
+type targetStatus struct {
+	CurrentReplicas int32 `json:"currentReplicas"`
+	// ExpectedReplicas, BindingTerm and RawValue are the result of the controller.
+	ExpectedReplicas int32   `json:"expectedReplicas"`
+	BindingTerm      string  `json:"bindingTerm"`
+	RawValue         float64 `json:"rawValue"`
+	// DesiredReplicas are the expected replicas after the schedule and
+	// per-zone floor, before stabilization and throttling.
+	DesiredReplicas int32      `json:"desiredReplicas"`
+	Replicas        int32      `json:"replicas"`
+	LastScaleTime   *time.Time `json:"lastScaleTime,omitempty"`
+	Error           string     `json:"error,omitempty"`
+	ScaleUpsBlocked bool       `json:"scaleUpsBlocked,omitempty"`
+}
+
+// pollErrors is the last poll error and the number of consecutive failed
+// polls, served as JSON at /debug/errors.
+type pollErrors struct {
+	// LastError is the error of the last failed poll, which may have been
+	// followed by successful polls.
+	LastError           string     `json:"lastError,omitempty"`
+	LastErrorTime       *time.Time `json:"lastErrorTime,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	// MaxSyncFailures is --max-sync-failures, 0 meaning polls never give up.
+	MaxSyncFailures int `json:"maxSyncFailures"`
+}
+
 type healthInfo struct {
 	m           sync.Mutex
 	lastError   error
 	failedCount int
+	// lastFailure is the error of the last failed poll, kept across
+	// successful polls, unlike lastError.
+	lastFailure     error
+	lastFailureTime time.Time
+	// pollingSince is when the poll loop last started, zero while it is not
+	// running, e.g. while waiting to become the leader.
+	pollingSince       time.Time
+	lastSuccessfulPoll time.Time
+	ready              bool
+	lastPollStatus     *pollStatus
+	// scaleUpsBlocked holds the targets whose scale ups are blocked by
+	// --max-consecutive-scale-ups.
+	scaleUpsBlocked map[string]bool
 }
 
 func newHealthInfo() *healthInfo {
 	return &healthInfo{m: sync.Mutex{}, lastError: nil, failedCount: 0}
 }
 
-func (h *healthInfo) setLastPollError(err error) int {
+func (h *healthInfo) setLastPollError(err error, now time.Time) int {
 	h.m.Lock()
 	defer h.m.Unlock()
 	h.lastError = err
@@ -41,28 +105,205 @@ func (h *healthInfo) setLastPollError(err error) int {
 		h.failedCount = 0
 	} else {
 		h.failedCount++
+		h.lastFailure = err
+		h.lastFailureTime = now
 	}
 	return h.failedCount
 }
 
+// getPollErrors returns the last poll error, if any, and the number of
+// consecutive failed polls.
+func (h *healthInfo) getPollErrors() *pollErrors {
+	h.m.Lock()
+	defer h.m.Unlock()
+	errors := &pollErrors{ConsecutiveFailures: h.failedCount}
+	if h.lastFailure != nil {
+		lastFailureTime := h.lastFailureTime
+		errors.LastError = h.lastFailure.Error()
+		errors.LastErrorTime = &lastFailureTime
+	}
+	return errors
+}
+
 func (h *healthInfo) getLastPollError() error {
 	h.m.Lock()
 	defer h.m.Unlock()
 	return h.lastError
 }
 
+func (h *healthInfo) setPolling(now time.Time, polling bool) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if polling {
+		h.pollingSince = now
+	} else {
+		h.pollingSince = time.Time{}
+	}
+}
+
+func (h *healthInfo) setLastSuccessfulPoll(now time.Time) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.lastSuccessfulPoll = now
+}
+
+// sinceLastSuccessfulPoll returns the time since the last successful poll, or
+// since the poll loop started if no poll succeeded since. It is zero while the
+// poll loop is not running.
+func (h *healthInfo) sinceLastSuccessfulPoll(now time.Time) time.Duration {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if h.pollingSince.IsZero() {
+		return 0
+	}
+	last := h.pollingSince
+	if h.lastSuccessfulPoll.After(last) {
+		last = h.lastSuccessfulPoll
+	}
+	return now.Sub(last)
+}
+
+func (h *healthInfo) setReady() {
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.ready = true
+}
+
+func (h *healthInfo) isReady() bool {
+	h.m.Lock()
+	defer h.m.Unlock()
+	return h.ready
+}
+
+func (h *healthInfo) setLastPollStatus(status *pollStatus) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.lastPollStatus = status
+}
+
+func (h *healthInfo) getLastPollStatus() *pollStatus {
+	h.m.Lock()
+	defer h.m.Unlock()
+	return h.lastPollStatus
+}
+
+// setScaleUpsBlocked records whether the scale ups of the target are blocked.
+func (h *healthInfo) setScaleUpsBlocked(target string, blocked bool) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if !blocked {
+		delete(h.scaleUpsBlocked, target)
+		return
+	}
+	if h.scaleUpsBlocked == nil {
+		h.scaleUpsBlocked = make(map[string]bool)
+	}
+	h.scaleUpsBlocked[target] = true
+}
+
+// getScaleUpsBlocked returns the sorted targets whose scale ups are blocked.
+func (h *healthInfo) getScaleUpsBlocked() []string {
+	h.m.Lock()
+	defer h.m.Unlock()
+	targets := make([]string, 0, len(h.scaleUpsBlocked))
+	for target := range h.scaleUpsBlocked {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
 type HealthServer interface {
 	Start()
+	Shutdown()
 }
 
+// stalledPollPeriods is the number of poll periods without a successful poll
+// after which /healthz reports the poll loop as stalled.
+const stalledPollPeriods = 3
+
 type httpHealthServer struct {
 	lastPollCycleHealth *healthInfo
+	clock               clock.Clock
+	maxStall            time.Duration
+	maxSyncFailures     int
+	server              *http.Server
+	listener            net.Listener
+}
+
+func newHTTPHealthServer(addr string, lastPollCycleHealth *healthInfo, clock clock.Clock, pollPeriod time.Duration, maxSyncFailures int) *httpHealthServer {
+	hs := &httpHealthServer{
+		lastPollCycleHealth: lastPollCycleHealth,
+		clock:               clock,
+		maxStall:            stalledPollPeriods * pollPeriod,
+		maxSyncFailures:     maxSyncFailures,
+	}
+	if addr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/healthz", hs.healthzFn)
+		mux.HandleFunc("/readyz", hs.readyzFn)
+		mux.HandleFunc("/last-poll", hs.lastPollFn)
+		mux.HandleFunc("/debug/status", hs.debugStatusFn)
+		mux.HandleFunc("/debug/errors", hs.debugErrorsFn)
+		hs.server = &http.Server{Addr: addr, Handler: mux}
+	}
+	return hs
+}
+
+// listen binds the address of the server ahead of Start, see listen.
+func (hs *httpHealthServer) listen() (err error) {
+	if hs.server == nil {
+		return nil
+	}
+	hs.listener, err = listen("--health-bind-address", hs.server.Addr)
+	return err
 }
 
 func (hs *httpHealthServer) Start() {
-	http.HandleFunc("/healthz", func(w http.ResponseWriter, req *http.Request) {})
-	http.HandleFunc("/last-poll", hs.lastPollFn)
-	glog.Fatal(http.ListenAndServe(":8080", nil))
+	if hs.server == nil {
+		return
+	}
+	if hs.listener == nil {
+		if err := hs.listen(); err != nil {
+			logging.Fatal(err)
+		}
+	}
+	logging.V(0).Infof("Serving health checks on %s", hs.listener.Addr())
+	if err := hs.server.Serve(hs.listener); err != nil && err != http.ErrServerClosed {
+		logging.Fatal(err)
+	}
+}
+
+func (hs *httpHealthServer) Shutdown() {
+	if hs.server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := hs.server.Shutdown(ctx); err != nil {
+		logging.Errorf("Error shutting down health server: %v", err)
+	}
+}
+
+func (hs *httpHealthServer) healthzFn(w http.ResponseWriter, req *http.Request) {
+	if since := hs.lastPollCycleHealth.sinceLastSuccessfulPoll(hs.clock.Now()); since > hs.maxStall {
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("No successful poll for %v", since)))
+		return
+	}
+	// Blocked scale ups don't fail the liveness probe, restarting the
+	// autoscaler would lift the block.
+	if blocked := hs.lastPollCycleHealth.getScaleUpsBlocked(); len(blocked) > 0 {
+		w.Write([]byte(fmt.Sprintf("Scale ups blocked by --max-consecutive-scale-ups: %s", strings.Join(blocked, ", "))))
+	}
+}
+
+func (hs *httpHealthServer) readyzFn(w http.ResponseWriter, req *http.Request) {
+	if !hs.lastPollCycleHealth.isReady() {
+		w.WriteHeader(500)
+		w.Write([]byte("ConfigMap and cluster status not fetched yet"))
+		return
+	}
 }
 
 func (hs *httpHealthServer) lastPollFn(w http.ResponseWriter, req *http.Request) {
@@ -72,3 +313,33 @@ func (hs *httpHealthServer) lastPollFn(w http.ResponseWriter, req *http.Request)
 		return
 	}
 }
+
+func (hs *httpHealthServer) debugStatusFn(w http.ResponseWriter, req *http.Request) {
+	status := hs.lastPollCycleHealth.getLastPollStatus()
+	if status == nil {
+		w.WriteHeader(500)
+		w.Write([]byte("No completed poll yet"))
+		return
+	}
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Error encoding the status of the last poll: %v", err)))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func (hs *httpHealthServer) debugErrorsFn(w http.ResponseWriter, req *http.Request) {
+	errors := hs.lastPollCycleHealth.getPollErrors()
+	errors.MaxSyncFailures = hs.maxSyncFailures
+	data, err := json.MarshalIndent(errors, "", "  ")
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("Error encoding the poll errors: %v", err)))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
+)
+
+func TestHealthServer(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	pollPeriod := 10 * time.Second
+	health := newHealthInfo()
+	hs := newHTTPHealthServer(":0", health, fakeClock, pollPeriod, 0)
+
+	expectStatus := func(path string, expCode int) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		hs.server.Handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != expCode {
+			t.Errorf("Expected %s to return %d, got %d: %s", path, expCode, rec.Code, rec.Body.String())
+		}
+	}
+
+	// Not polling yet, e.g. waiting to become the leader.
+	fakeClock.Step(time.Hour)
+	expectStatus("/healthz", http.StatusOK)
+	expectStatus("/readyz", http.StatusInternalServerError)
+
+	health.setPolling(fakeClock.Now(), true)
+	fakeClock.Step(stalledPollPeriods * pollPeriod)
+	expectStatus("/healthz", http.StatusOK)
+	fakeClock.Step(time.Second)
+	expectStatus("/healthz", http.StatusInternalServerError)
+
+	health.setLastSuccessfulPoll(fakeClock.Now())
+	health.setReady()
+	expectStatus("/healthz", http.StatusOK)
+	expectStatus("/readyz", http.StatusOK)
+
+	fakeClock.Step(stalledPollPeriods*pollPeriod + time.Second)
+	expectStatus("/healthz", http.StatusInternalServerError)
+
+	health.setPolling(fakeClock.Now(), false)
+	expectStatus("/healthz", http.StatusOK)
+}
+
+func TestHealthServerScaleUpsBlocked(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	health := newHealthInfo()
+	hs := newHTTPHealthServer(":0", health, fakeClock, 10*time.Second, 0)
+
+	healthz := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		hs.server.Handler.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+		return rec
+	}
+
+	health.setScaleUpsBlocked("deployment/foo", true)
+	health.setScaleUpsBlocked("deployment/bar", true)
+	// Blocked scale ups are reported without failing the liveness probe.
+	rec := healthz()
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "deployment/bar, deployment/foo") {
+		t.Errorf("Expected /healthz to report the blocked targets, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	health.setScaleUpsBlocked("deployment/foo", false)
+	health.setScaleUpsBlocked("deployment/bar", false)
+	if rec := healthz(); rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Errorf("Expected /healthz to report nothing once unblocked, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHealthServerDisabled(t *testing.T) {
+	hs := newHTTPHealthServer("", newHealthInfo(), clock.RealClock{}, time.Second, 0)
+	if hs.server != nil {
+		t.Errorf("Expected no server with an empty bind address")
+	}
+	// Must not block nor panic.
+	hs.Start()
+	hs.Shutdown()
+}
+
+func TestHealthServerDebugStatus(t *testing.T) {
+	health := newHealthInfo()
+	hs := newHTTPHealthServer(":0", health, clock.RealClock{}, time.Second, 0)
+
+	rec := httptest.NewRecorder()
+	hs.server.Handler.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/status", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected /debug/status to return %d before the first poll, got %d", http.StatusInternalServerError, rec.Code)
+	}
+
+	health.setLastPollStatus(&pollStatus{
+		ClusterStatus:  &k8sclient.ClusterStatus{SchedulableNodes: 10, SchedulableCores: 40},
+		ControllerType: "linear",
+		ParamsVersion:  "1",
+		Params:         map[string]int{"nodesPerReplica": 2},
+		Targets: map[string]*targetStatus{
+			"deployment/dns": {CurrentReplicas: 3, ExpectedReplicas: 5, BindingTerm: "nodes", RawValue: 5, DesiredReplicas: 5, Replicas: 5},
+		},
+	})
+	rec = httptest.NewRecorder()
+	hs.server.Handler.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected /debug/status to return %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var got struct {
+		ClusterStatus struct {
+			SchedulableNodes int32 `json:"schedulableNodes"`
+		} `json:"clusterStatus"`
+		Params  map[string]int `json:"params"`
+		Targets map[string]struct {
+			BindingTerm string `json:"bindingTerm"`
+			Replicas    int32  `json:"replicas"`
+		} `json:"targets"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Unexpected error decoding %s: %v", rec.Body.String(), err)
+	}
+	if got.ClusterStatus.SchedulableNodes != 10 || got.Params["nodesPerReplica"] != 2 {
+		t.Errorf("Unexpected cluster status or params in %s", rec.Body.String())
+	}
+	if target := got.Targets["deployment/dns"]; target.BindingTerm != "nodes" || target.Replicas != 5 {
+		t.Errorf("Unexpected target status in %s", rec.Body.String())
+	}
+}
+
+func TestHealthServerDebugErrors(t *testing.T) {
+	health := newHealthInfo()
+	hs := newHTTPHealthServer(":0", health, clock.RealClock{}, time.Second, 5)
+	getErrors := func() pollErrors {
+		rec := httptest.NewRecorder()
+		hs.server.Handler.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/errors", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected /debug/errors to return %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+		var got pollErrors
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("Unexpected error decoding %s: %v", rec.Body.String(), err)
+		}
+		return got
+	}
+
+	if got := getErrors(); got.LastError != "" || got.LastErrorTime != nil || got.ConsecutiveFailures != 0 || got.MaxSyncFailures != 5 {
+		t.Errorf("Unexpected poll errors before any failure: %+v", got)
+	}
+
+	failedAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	health.setLastPollError(fmt.Errorf("first"), failedAt.Add(-time.Minute))
+	health.setLastPollError(fmt.Errorf("connection refused"), failedAt)
+	got := getErrors()
+	if got.LastError != "connection refused" || got.LastErrorTime == nil || !got.LastErrorTime.Equal(failedAt) || got.ConsecutiveFailures != 2 {
+		t.Errorf("Unexpected poll errors after 2 failures: %+v", got)
+	}
+
+	// The last error is kept once polls succeed again.
+	health.setLastPollError(nil, failedAt.Add(time.Minute))
+	if got := getErrors(); got.LastError != "connection refused" || got.ConsecutiveFailures != 0 {
+		t.Errorf("Unexpected poll errors after a successful poll: %+v", got)
+	}
+}
@@ -17,24 +17,43 @@ limitations under the License.
 package k8sclient
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"net"
 	"strings"
 	"time"
 
-	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	"k8s.io/api/core/v1"
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
-	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/scale"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 
-	"github.com/golang/glog"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
 )
 
 // K8sClient - Wraps all needed client functionalities for autoscaler
@@ -45,86 +64,476 @@ type K8sClient interface {
 	CreateConfigMap(namespace, configmap string, params map[string]string) (*v1.ConfigMap, error)
 	// UpdateConfigMap updates a configmap with given namespace, name and params
 	UpdateConfigMap(namespace, configmap string, params map[string]string) (*v1.ConfigMap, error)
-	// GetClusterStatus counts schedulable nodes and cores in the cluster
+	// FetchSecret fetches the requested secret from the Apiserver
+	FetchSecret(namespace, secret string) (*v1.Secret, error)
+	// CreateSecret creates a secret with given namespace, name and params
+	CreateSecret(namespace, secret string, params map[string]string) (*v1.Secret, error)
+	// GetClusterStatus counts schedulable nodes, cores, memory, pods capacity and GPUs in the cluster
 	GetClusterStatus() (clusterStatus *ClusterStatus, err error)
-	// GetNamespace returns the namespace of target resource.
+	// GetNamespace returns the namespace of target resources.
 	GetNamespace() (namespace string)
-	// UpdateReplicas updates the number of replicas for the resource and return the previous replicas count
-	UpdateReplicas(expReplicas int32) (prevReplicas int32, err error)
+	// GetTargets returns the target resources to scale, in the order they were given
+	GetTargets() (targets []string)
+	// GetReplicas returns the current number of replicas of the target resource
+	GetReplicas(target string) (replicas int32, err error)
+	// GetReferencedReplicas returns the current number of replicas of a
+	// resource in the format of a target, which needn't be one of the targets
+	GetReferencedReplicas(resource string) (replicas int32, err error)
+	// GetDaemonSetDesiredPods returns the number of nodes which should run a
+	// pod of the DaemonSet, given as [<namespace>/]<name>, and whether the
+	// DaemonSet exists
+	GetDaemonSetDesiredPods(daemonSet string) (desired int32, found bool, err error)
+	// UpdateReplicas updates the number of replicas for the target resource and return the previous replicas count
+	UpdateReplicas(target string, expReplicas int32) (prevReplicas int32, err error)
+	// GetDisruptionsAllowed returns how many pods of the target can be
+	// disrupted according to the PodDisruptionBudgets matching its pods, and
+	// whether any PodDisruptionBudget matches them.
+	GetDisruptionsAllowed(target string) (disruptionsAllowed int32, found bool, err error)
+	// GetAnnotations returns the annotations of the target resource itself
+	GetAnnotations(target string) (annotations map[string]string, err error)
+	// CountPods counts the pods matching the selector of the target which
+	// haven't terminated
+	CountPods(target string) (pods int32, err error)
+	// AnnotateTarget sets the annotations on the target resource itself,
+	// leaving its other annotations as they are
+	AnnotateTarget(target string, annotations map[string]string) error
+	// GetPodMemoryRequest returns the memory request, in bytes, of a pod of
+	// the target from its pod template, and whether any is set.
+	GetPodMemoryRequest(target string) (request int64, found bool, err error)
+	// RecordEvent records an event on the target resource, failures are only logged
+	RecordEvent(target string, eventType, reason, message string)
+	// SetNodeCountHandler calls handler with the number of nodes matching
+	// the node selectors whenever one is added or deleted
+	SetNodeCountHandler(handler func(nodes int))
 }
 
 // k8sClient - Wraps all Kubernetes API client functionalities
 type k8sClient struct {
-	target        *scaleTarget
-	clientset     *kubernetes.Clientset
-	clusterStatus *ClusterStatus
-	nodeStore     cache.Store
-	reflector     *cache.Reflector
-	stopCh        chan struct{}
+	namespace                string
+	targets                  []string
+	scaleTargets             map[string]*scaleTarget
+	targetDiscovery          *targetDiscovery
+	pinTargetUID             bool
+	targetWait               bool
+	clientset                *kubernetes.Clientset
+	scaleClient              scale.ScalesGetter
+	dynamicClient            dynamic.Interface
+	mapper                   *restmapper.DeferredDiscoveryRESTMapper
+	discoveryRefresh         time.Duration
+	lastDiscovery            time.Time
+	recorder                 record.EventRecorder
+	clusterStatus            *ClusterStatus
+	nodeInformer             cache.SharedIndexInformer
+	podInformer              cache.SharedIndexInformer
+	namespaceInformer        cache.SharedIndexInformer
+	nodeLabels               string
+	nodeFieldSelector        string
+	ignoreTaintedNodes       bool
+	readyNodesOnly           bool
+	excludeControlPlaneNodes bool
+	maxCoresPerNode          int64
+	nodeWeightLabel          string
+	nodeWeights              map[string]float64
+	unschedulableNodeWeight  float64
+	backendSelector          string
+	toleratedTaintKeys       sets.String
+	gpuResourceName          v1.ResourceName
+	resourceSource           string
+	scaleResources           []ScaleResource
+	metricNodeSelectors      map[string]labels.Selector
+	stopCh                   chan struct{}
 }
 
-// NewK8sClient gives a k8sClient with the given dependencies.
-func NewK8sClient(namespace, target string, nodelabels string) (K8sClient, error) {
+// Config configures the client returned by NewK8sClient.
+type Config struct {
+	// Namespace is the namespace of the targets.
+	Namespace string
+	// Targets are the resources to scale, in the <resource>[.<group>]/<name>
+	// format.
+	Targets []string
+	// TargetNamespaceSelector, if set, discovers the targets in the
+	// namespaces matching it instead of looking them up in Namespace.
+	TargetNamespaceSelector string
+	// PinTargetUID fails the updates of a target recreated since it was
+	// first resolved.
+	PinTargetUID bool
+	// TargetWait keeps polling targets without a scale subresource instead
+	// of failing at startup.
+	TargetWait bool
+	// NodeLabels and NodeFieldSelector select the nodes which are counted.
+	NodeLabels        string
+	NodeFieldSelector string
+	// GPUResourceName is the extended resource counted as GPUs.
+	GPUResourceName string
+	// IgnoreTaintedNodes skips the nodes with NoSchedule or NoExecute taints
+	// other than those of ToleratedTaintKeys.
+	IgnoreTaintedNodes bool
+	ToleratedTaintKeys []string
+	// ResourceSource is ResourceSourceAllocatable or ResourceSourceCapacity.
+	ResourceSource string
+	// ReadyNodesOnly skips the nodes whose Ready condition is not True.
+	ReadyNodesOnly bool
+	// ExcludeControlPlaneNodes skips the control plane nodes.
+	ExcludeControlPlaneNodes bool
+	// MaxCoresPerNode caps the cores counted for each node, 0 meaning
+	// unbounded.
+	MaxCoresPerNode int64
+	// NodeWeightLabel names the label of the nodes whose values are weighted
+	// by NodeWeights.
+	NodeWeightLabel string
+	NodeWeights     map[string]float64
+	// UnschedulableNodeWeight, between 0 and 1, counts that fraction of the
+	// cordoned nodes, and of their resources, as schedulable.
+	UnschedulableNodeWeight float64
+	// BackendSelector selects the pods which are counted as matching pods.
+	BackendSelector string
+	// CountPendingPods watches the unschedulable pending pods.
+	CountPendingPods bool
+	// CountNamespaces watches the namespaces matching NamespaceSelector.
+	CountNamespaces   bool
+	NamespaceSelector string
+	// ScaleResources are the extended resources given with --scale-resource.
+	ScaleResources []ScaleResource
+	// MetricNodeSelectors restrict the nodes counted for each metric.
+	MetricNodeSelectors map[string]string
+	// DiscoveryRefresh is how often the API discovery is refreshed.
+	DiscoveryRefresh time.Duration
+	// QPS and Burst are the client-side rate limits of the API requests.
+	QPS   float32
+	Burst int
+	// Timeout bounds the API requests, watches excepted.
+	Timeout time.Duration
+}
+
+// NewK8sClient gives a k8sClient configured by c.
+func NewK8sClient(c Config) (K8sClient, error) {
+	selectors := make(map[string]labels.Selector)
+	for metric, rawSelector := range c.MetricNodeSelectors {
+		if rawSelector == "" {
+			continue
+		}
+		selector, err := labels.Parse(rawSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node selector of %s: %v", metric, err)
+		}
+		selectors[metric] = selector
+	}
 	config, err := rest.InClusterConfig()
 	if err != nil {
 		return nil, err
 	}
+	// Client-side rate limits, also applied to the scale client copied below.
+	config.QPS = c.QPS
+	config.Burst = c.Burst
 	// Use protobufs for communication with apiserver.
 	config.ContentType = "application/vnd.kubernetes.protobuf"
+	config.UserAgent = UserAgent()
+	// Watches of the informers are long-running requests, they get a client
+	// without the request timeout.
+	watchClientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	// Bound the other requests, also those of the clients copied below, so
+	// that a hung request doesn't block a poll.
+	config.Timeout = c.Timeout
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
 
-	scaleTarget, err := getScaleTarget(target, namespace)
+	logging.V(0).Infof("Counting %s resources of nodes", c.ResourceSource)
+	if c.NodeWeightLabel != "" {
+		logging.V(0).Infof("Weighting cores of nodes by their %s label: %v", c.NodeWeightLabel, c.NodeWeights)
+	}
+
+	// Resolve targets through API discovery so that any resource exposing a
+	// scale subresource, including custom resources, can be scaled.
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery()))
+	scaleTargets := make(map[string]*scaleTarget, len(c.Targets))
+	for _, target := range c.Targets {
+		scaleTarget, err := getScaleTarget(target, c.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		if err := resolveScaleTarget(scaleTarget, mapper, clientset.Discovery()); err != nil {
+			// A target without a scale subresource fails startup, unless it
+			// is awaited, e.g. while its CRD gets upgraded to a scalable one.
+			if IsNotScalable(err) && !c.TargetWait {
+				return nil, fmt.Errorf("%v, set --target-wait to keep polling until it is", err)
+			}
+			if !IsTargetNotFound(err) && !IsNotScalable(err) {
+				return nil, err
+			}
+			// The resource may be installed later on, e.g. a CRD applied after
+			// the autoscaler. Keep resolving it while polling.
+			logging.Warningf("Target %s is not available yet, will retry: %v", target, err)
+		}
+		scaleTargets[target] = scaleTarget
+	}
+	// Custom resources don't support protobuf, talk to scale subresources in JSON.
+	scaleConfig := rest.CopyConfig(config)
+	scaleConfig.ContentType = "application/json"
+	scaleClient, err := scale.NewForConfig(scaleConfig, mapper, dynamic.LegacyAPIPathResolverFunc, scale.NewDiscoveryScaleKindResolver(clientset.Discovery()))
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(scaleConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	// Start propagating contents of the nodeStore.
+	// Events are best-effort: they are written asynchronously and failures are
+	// only logged, so they never block scaling.
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&warningEventSink{&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")}})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: eventComponent})
 
-	opts := metav1.ListOptions{LabelSelector: nodelabels}
-	nodeListWatch := &cache.ListWatch{
-		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-			return clientset.CoreV1().Nodes().List(opts)
-		},
-		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-			return clientset.CoreV1().Nodes().Watch(opts)
-		},
-	}
-	nodeStore := cache.NewStore(cache.MetaNamespaceKeyFunc)
-	reflector := cache.NewReflector(nodeListWatch, &v1.Node{}, nodeStore, 0)
+	// Start a node informer to keep a local cache of nodes warm through a watch.
+	factory := informers.NewSharedInformerFactoryWithOptions(watchClientset, 0, informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+		opts.LabelSelector = c.NodeLabels
+		opts.FieldSelector = c.NodeFieldSelector
+	}))
+	nodeInformer := factory.Core().V1().Nodes().Informer()
 	stopCh := make(chan struct{})
-	go reflector.Run(stopCh)
+	factory.Start(stopCh)
+	// Pending pods are watched cluster-wide, only when they are counted.
+	var podInformer cache.SharedIndexInformer
+	if c.CountPendingPods {
+		podFactory := informers.NewSharedInformerFactoryWithOptions(watchClientset, 0, informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("status.phase", string(v1.PodPending)).String()
+		}))
+		podInformer = podFactory.Core().V1().Pods().Informer()
+		podFactory.Start(stopCh)
+	}
+	var namespaceInformer cache.SharedIndexInformer
+	if c.CountNamespaces {
+		namespaceFactory := informers.NewSharedInformerFactoryWithOptions(watchClientset, 0, informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = c.NamespaceSelector
+		}))
+		namespaceInformer = namespaceFactory.Core().V1().Namespaces().Informer()
+		namespaceFactory.Start(stopCh)
+	}
+	// With a target namespace selector, the targets are discovered in the
+	// selected namespaces instead of being looked up in the namespace.
+	var discovery *targetDiscovery
+	if c.TargetNamespaceSelector != "" {
+		logging.V(0).Infof("Discovering the targets in the namespaces matching %s", c.TargetNamespaceSelector)
+		if discovery, err = newTargetDiscovery(watchClientset, c.Targets, c.TargetNamespaceSelector, stopCh); err != nil {
+			return nil, err
+		}
+	}
 
 	return &k8sClient{
-		target:    scaleTarget,
-		clientset: clientset,
-		nodeStore: nodeStore,
-		reflector: reflector,
-		stopCh:    stopCh,
+		namespace:                c.Namespace,
+		targets:                  c.Targets,
+		scaleTargets:             scaleTargets,
+		targetDiscovery:          discovery,
+		pinTargetUID:             c.PinTargetUID,
+		targetWait:               c.TargetWait,
+		clientset:                clientset,
+		scaleClient:              scaleClient,
+		dynamicClient:            dynamicClient,
+		mapper:                   mapper,
+		discoveryRefresh:         c.DiscoveryRefresh,
+		lastDiscovery:            time.Now(),
+		recorder:                 recorder,
+		nodeInformer:             nodeInformer,
+		podInformer:              podInformer,
+		namespaceInformer:        namespaceInformer,
+		nodeLabels:               c.NodeLabels,
+		nodeFieldSelector:        c.NodeFieldSelector,
+		ignoreTaintedNodes:       c.IgnoreTaintedNodes,
+		readyNodesOnly:           c.ReadyNodesOnly,
+		excludeControlPlaneNodes: c.ExcludeControlPlaneNodes,
+		maxCoresPerNode:          c.MaxCoresPerNode,
+		nodeWeightLabel:          c.NodeWeightLabel,
+		nodeWeights:              c.NodeWeights,
+		unschedulableNodeWeight:  c.UnschedulableNodeWeight,
+		backendSelector:          c.BackendSelector,
+		toleratedTaintKeys:       sets.NewString(c.ToleratedTaintKeys...),
+		gpuResourceName:          v1.ResourceName(c.GPUResourceName),
+		resourceSource:           c.ResourceSource,
+		scaleResources:           c.ScaleResources,
+		metricNodeSelectors:      selectors,
+		stopCh:                   stopCh,
 	}, nil
 }
 
 func getScaleTarget(target, namespace string) (*scaleTarget, error) {
 	splits := strings.Split(target, "/")
-	if len(splits) != 2 {
+	if len(splits) != 2 || splits[0] == "" || splits[1] == "" {
 		return &scaleTarget{}, fmt.Errorf("target format error: %v", target)
 	}
-	kind := splits[0]
+	resource := schema.ParseGroupResource(splits[0])
+	if group, ok := builtinTargetGroups[resource.Resource]; ok && resource.Group == "" {
+		resource.Group = group
+	}
 	name := splits[1]
-	return &scaleTarget{kind, name, namespace}, nil
+	return &scaleTarget{resource: resource, name: name, namespace: namespace}, nil
+}
+
+// ValidateTargetFormat returns an error if target is not in the
+// <resource>[.<group>]/<name> format.
+func ValidateTargetFormat(target string) error {
+	_, err := getScaleTarget(target, "")
+	return err
+}
+
+// ValidateDaemonSetFormat returns an error if daemonSet is not in the
+// [<namespace>/]<name> format.
+func ValidateDaemonSetFormat(daemonSet string) error {
+	splits := strings.Split(daemonSet, "/")
+	if len(splits) > 2 || splits[0] == "" || splits[len(splits)-1] == "" {
+		return fmt.Errorf("daemonset format error: %v, expected [<namespace>/]<name>", daemonSet)
+	}
+	return nil
+}
+
+const (
+	// ResourceSourceAllocatable counts the allocatable resources of nodes.
+	ResourceSourceAllocatable = "allocatable"
+	// ResourceSourceCapacity counts the total capacity of nodes, including
+	// resources reserved for the system.
+	ResourceSourceCapacity = "capacity"
+)
+
+// Metrics which may be counted on their own subset of the nodes.
+const (
+	MetricCores  = "cores"
+	MetricMemory = "memory"
+	MetricPods   = "pods"
+	MetricGPUs   = "gpus"
+)
+
+// zoneLabel is the well-known label holding the zone of a node.
+const zoneLabel = "topology.kubernetes.io/zone"
+
+// controlPlaneLabels are the well-known labels of control plane nodes, the
+// latter being deprecated in favor of the former.
+var controlPlaneLabels = []string{
+	"node-role.kubernetes.io/control-plane",
+	"node-role.kubernetes.io/master",
+}
+
+// builtinTargetGroups pins the API group of built-in kinds that are served by
+// more than one group, so that they keep being scaled through apps/v1.
+var builtinTargetGroups = map[string]string{
+	"deployment":   "apps",
+	"deployments":  "apps",
+	"replicaset":   "apps",
+	"replicasets":  "apps",
+	"statefulset":  "apps",
+	"statefulsets": "apps",
 }
 
 // scaleTarget stores the scalable target recourse
 type scaleTarget struct {
-	kind      string
-	name      string
-	namespace string
+	resource   schema.GroupResource
+	name       string
+	namespace  string
+	kind       string
+	apiVersion string
+	uid        types.UID
+	resolved   bool
 }
 
 func (k *k8sClient) GetNamespace() (namespace string) {
-	return k.target.namespace
+	return k.namespace
+}
+
+func (k *k8sClient) GetTargets() (targets []string) {
+	if k.targetDiscovery != nil {
+		return k.discoveredTargets()
+	}
+	return k.targets
+}
+
+func (k *k8sClient) lookupScaleTarget(target string) (*scaleTarget, error) {
+	scaleTarget, ok := k.scaleTargets[target]
+	if !ok {
+		return nil, fmt.Errorf("unknown target: %v", target)
+	}
+	k.refreshDiscovery(time.Now())
+	if !scaleTarget.resolved {
+		if err := resolveScaleTarget(scaleTarget, k.mapper, k.clientset.Discovery()); err != nil {
+			// Rediscover resources on the next attempt.
+			k.mapper.Reset()
+			return nil, err
+		}
+		logging.V(0).Infof("Target %s is now available", target)
+	}
+	return scaleTarget, nil
+}
+
+// refreshDiscovery resets the cached API discovery every discoveryRefresh, so
+// that resources and versions installed since, e.g. by new CRDs, are picked
+// up. The targets are resolved again on their next lookup.
+func (k *k8sClient) refreshDiscovery(now time.Time) {
+	if k.discoveryRefresh <= 0 || now.Sub(k.lastDiscovery) < k.discoveryRefresh {
+		return
+	}
+	logging.V(0).Infof("Refreshing API discovery, last refreshed %v ago", now.Sub(k.lastDiscovery).Round(time.Second))
+	k.mapper.Reset()
+	k.lastDiscovery = now
+	for _, scaleTarget := range k.scaleTargets {
+		scaleTarget.resolved = false
+	}
+}
+
+// rediscoverOnNoMatch resets the cached API discovery and has the target
+// resolved again on its next lookup when err reports that its resource, or
+// its version, is no longer served.
+func (k *k8sClient) rediscoverOnNoMatch(target *scaleTarget, err error) {
+	if !meta.IsNoMatchError(err) {
+		return
+	}
+	logging.V(0).Infof("Refreshing API discovery, resource %v is no longer served as discovered: %v", target.resource, err)
+	k.mapper.Reset()
+	k.lastDiscovery = time.Now()
+	target.resolved = false
+}
+
+// TargetNotFoundError is returned when a target, or its resource, doesn't exist.
+// It is retryable as the target may be created later on.
+type TargetNotFoundError struct {
+	Target string
+	Err    error
+}
+
+func (e *TargetNotFoundError) Error() string {
+	return fmt.Sprintf("target %s not found: %v", e.Target, e.Err)
+}
+
+// IsTargetNotFound returns whether err is a TargetNotFoundError.
+func IsTargetNotFound(err error) bool {
+	_, ok := err.(*TargetNotFoundError)
+	return ok
+}
+
+// NotScalableError is returned when the resource of a target doesn't have a
+// scale subresource.
+type NotScalableError struct {
+	Target string
+	GVR    schema.GroupVersionResource
+}
+
+func (e *NotScalableError) Error() string {
+	return fmt.Sprintf("target %s is not scalable: %v does not have a scale subresource", e.Target, e.GVR)
+}
+
+// IsNotScalable returns whether err is a NotScalableError.
+func IsNotScalable(err error) bool {
+	_, ok := err.(*NotScalableError)
+	return ok
+}
+
+// targetError converts not found errors of the apiserver into TargetNotFoundError.
+func targetError(target string, err error) error {
+	if apierrors.IsNotFound(err) {
+		return &TargetNotFoundError{Target: target, Err: err}
+	}
+	return err
 }
 
 func (k *k8sClient) FetchConfigMap(namespace, configmap string) (*v1.ConfigMap, error) {
@@ -135,19 +544,79 @@ func (k *k8sClient) FetchConfigMap(namespace, configmap string) (*v1.ConfigMap,
 	return cm, nil
 }
 
+// createConfigMapBackoff bounds the retries of the creation of the ConfigMap
+// of default params, e.g. on a cold start against a throttled apiserver.
+var createConfigMapBackoff = wait.Backoff{
+	Steps:    5,
+	Duration: 200 * time.Millisecond,
+	Factor:   2.0,
+	Jitter:   0.1,
+}
+
 func (k *k8sClient) CreateConfigMap(namespace, configmap string, params map[string]string) (*v1.ConfigMap, error) {
 	providedConfigMap := v1.ConfigMap{}
 	providedConfigMap.ObjectMeta.Name = configmap
 	providedConfigMap.ObjectMeta.Namespace = namespace
 	providedConfigMap.Data = params
-	cm, err := k.clientset.CoreV1().ConfigMaps(namespace).Create(&providedConfigMap)
+	configMaps := k.clientset.CoreV1().ConfigMaps(namespace)
+	return createConfigMap(namespace+"/"+configmap, func() (*v1.ConfigMap, error) {
+		return configMaps.Create(&providedConfigMap)
+	}, func() (*v1.ConfigMap, error) {
+		return configMaps.Get(configmap, metav1.GetOptions{})
+	})
+}
+
+// createConfigMap creates the ConfigMap through create, retrying with
+// createConfigMapBackoff on errors which may be transient. A ConfigMap which
+// already exists, e.g. created meanwhile by another replica, is read through
+// get instead.
+func createConfigMap(name string, create, get func() (*v1.ConfigMap, error)) (*v1.ConfigMap, error) {
+	var cm *v1.ConfigMap
+	var lastErr error
+	attempt := 0
+	err := wait.ExponentialBackoff(createConfigMapBackoff, func() (bool, error) {
+		attempt++
+		var err error
+		cm, err = create()
+		if err == nil {
+			logging.V(0).Infof("Created ConfigMap %v", name)
+			return true, nil
+		}
+		if apierrors.IsAlreadyExists(err) {
+			logging.V(0).Infof("ConfigMap %v already exists, using it", name)
+			cm, err = get()
+		}
+		switch {
+		case err == nil:
+			return true, nil
+		case !isRetryable(err):
+			return false, err
+		}
+		lastErr = err
+		if attempt < createConfigMapBackoff.Steps {
+			logging.Warningf("Error creating ConfigMap %v (attempt %d of %d), retrying: %v", name, attempt, createConfigMapBackoff.Steps, err)
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return nil, fmt.Errorf("giving up creating ConfigMap %v after %d attempts: %v", name, attempt, lastErr)
+	}
 	if err != nil {
 		return nil, err
 	}
-	glog.V(0).Infof("Created ConfigMap %v in namespace %v", configmap, namespace)
 	return cm, nil
 }
 
+// isRetryable returns whether err may be transient, e.g. the apiserver being
+// overloaded or unreachable for a moment.
+func isRetryable(err error) bool {
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	return apierrors.IsTooManyRequests(err) || apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) ||
+		apierrors.IsServiceUnavailable(err) || apierrors.IsInternalError(err)
+}
+
 func (k *k8sClient) UpdateConfigMap(namespace, configmap string, params map[string]string) (*v1.ConfigMap, error) {
 	providedConfigMap := v1.ConfigMap{}
 	providedConfigMap.ObjectMeta.Name = configmap
@@ -157,75 +626,593 @@ func (k *k8sClient) UpdateConfigMap(namespace, configmap string, params map[stri
 	if err != nil {
 		return nil, err
 	}
-	glog.V(0).Infof("Updated ConfigMap %v in namespace %v", configmap, namespace)
+	logging.V(0).Infof("Updated ConfigMap %v in namespace %v", configmap, namespace)
 	return cm, nil
 }
 
+func (k *k8sClient) FetchSecret(namespace, secret string) (*v1.Secret, error) {
+	s, err := k.clientset.CoreV1().Secrets(namespace).Get(secret, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (k *k8sClient) CreateSecret(namespace, secret string, params map[string]string) (*v1.Secret, error) {
+	providedSecret := v1.Secret{}
+	providedSecret.ObjectMeta.Name = secret
+	providedSecret.ObjectMeta.Namespace = namespace
+	providedSecret.Data = make(map[string][]byte, len(params))
+	for key, value := range params {
+		providedSecret.Data[key] = []byte(value)
+	}
+	s, err := k.clientset.CoreV1().Secrets(namespace).Create(&providedSecret)
+	if err != nil {
+		return nil, err
+	}
+	logging.V(0).Infof("Created Secret %v in namespace %v", secret, namespace)
+	return s, nil
+}
+
 // ClusterStatus defines the cluster status
 type ClusterStatus struct {
-	TotalNodes       int32
-	SchedulableNodes int32
-	TotalCores       int32
-	SchedulableCores int32
+	TotalNodes        int32 `json:"totalNodes"`
+	SchedulableNodes  int32 `json:"schedulableNodes"`
+	TotalCores        int32 `json:"totalCores"`
+	SchedulableCores  int32 `json:"schedulableCores"`
+	TotalMemory       int64 `json:"totalMemory"`
+	SchedulableMemory int64 `json:"schedulableMemory"`
+	TotalPods         int32 `json:"totalPods"`
+	SchedulablePods   int32 `json:"schedulablePods"`
+	TotalGPUs         int32 `json:"totalGPUs"`
+	SchedulableGPUs   int32 `json:"schedulableGPUs"`
+	TotalZones        int32 `json:"totalZones"`
+	SchedulableZones  int32 `json:"schedulableZones"`
+	// TotalEphemeralStorage and SchedulableEphemeralStorage sum up the
+	// allocatable ephemeral-storage of the nodes, in bytes. Nodes which don't
+	// report it count as none.
+	TotalEphemeralStorage       int64 `json:"totalEphemeralStorage"`
+	SchedulableEphemeralStorage int64 `json:"schedulableEphemeralStorage"`
+	// UnschedulableNodes counts the cordoned nodes, e.g. those being drained.
+	UnschedulableNodes int32 `json:"unschedulableNodes"`
+	// DrainingNodes counts the nodes being drained or deleted: cordoned,
+	// deleted or tainted for deletion by the cluster autoscaler.
+	DrainingNodes int32 `json:"drainingNodes"`
+	// ScaleResources sums up the resources given with --scale-resource, in
+	// the same order.
+	ScaleResources []ScaleResourceStatus `json:"scaleResources,omitempty"`
+	// MatchingPods counts the running and pending pods matching
+	// --backend-selector in the namespace.
+	MatchingPods int32 `json:"matchingPods,omitempty"`
+	// PendingPods counts the pending pods which are unschedulable, with
+	// --count-pending-pods.
+	PendingPods int32 `json:"pendingPods,omitempty"`
+	// MatchingNamespaces counts the namespaces matching --namespace-selector,
+	// with --count-namespaces.
+	MatchingNamespaces int32 `json:"matchingNamespaces,omitempty"`
+}
+
+// ScaleResource is a resource of nodes, e.g. an extended resource, which the
+// linear controller runs a replica per PerReplica of.
+type ScaleResource struct {
+	Name       v1.ResourceName `json:"name"`
+	PerReplica float64         `json:"perReplica"`
+}
+
+// ScaleResourceStatus is the sum of a ScaleResource over the nodes.
+type ScaleResourceStatus struct {
+	ScaleResource
+	Total       int64 `json:"total"`
+	Schedulable int64 `json:"schedulable"`
 }
 
 func (k *k8sClient) GetClusterStatus() (clusterStatus *ClusterStatus, err error) {
-	// TODO: Consider moving this to NewK8sClient method and failing fast when
-	// reflector can't initialize. That is a tradeoff between silently non-working
-	// component and explicit restarts of it. In majority of the cases the restart
-	// won't repair it - though it may give better visibility into problems.
-	err = wait.PollImmediate(250*time.Millisecond, 5*time.Second, func() (bool, error) {
-		if k.reflector.LastSyncResourceVersion() == "" {
-			return false, nil
-		}
-		return true, nil
+	nodes, err := k.listNodes()
+	if err != nil {
+		return nil, err
+	}
+	clusterStatus = k.computeClusterStatus(nodes)
+	if k.backendSelector != "" {
+		pods, err := k.clientset.CoreV1().Pods(k.namespace).List(metav1.ListOptions{LabelSelector: k.backendSelector})
+		if err != nil {
+			return nil, err
+		}
+		clusterStatus.MatchingPods = countActivePods(pods.Items)
+	}
+	if k.podInformer != nil {
+		if k.podInformer.HasSynced() {
+			clusterStatus.PendingPods = countUnschedulablePods(k.podInformer.GetStore().List())
+		} else {
+			logging.Warningf("Pod informer has not synced, not counting pending pods")
+		}
+	}
+	if k.namespaceInformer != nil {
+		if k.namespaceInformer.HasSynced() {
+			clusterStatus.MatchingNamespaces = int32(len(k.namespaceInformer.GetStore().ListKeys()))
+		} else {
+			logging.Warningf("Namespace informer has not synced, not counting namespaces")
+		}
+	}
+	k.clusterStatus = clusterStatus
+	return clusterStatus, nil
+}
+
+// countUnschedulablePods counts the pending pods which the scheduler found no
+// node for.
+func countUnschedulablePods(pods []interface{}) int32 {
+	var count int32
+	for _, obj := range pods {
+		pod, ok := obj.(*v1.Pod)
+		if !ok || pod.Status.Phase != v1.PodPending {
+			continue
+		}
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == v1.PodScheduled && condition.Status == v1.ConditionFalse && condition.Reason == v1.PodReasonUnschedulable {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+// countActivePods counts the pods which haven't terminated.
+func countActivePods(pods []v1.Pod) int32 {
+	var count int32
+	for _, pod := range pods {
+		if pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed {
+			count++
+		}
+	}
+	return count
+}
+
+func (k *k8sClient) SetNodeCountHandler(handler func(nodes int)) {
+	countNodes := func(obj interface{}) {
+		handler(len(k.nodeInformer.GetStore().ListKeys()))
+	}
+	k.nodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    countNodes,
+		DeleteFunc: countNodes,
+	})
+}
+
+// listNodes returns the nodes from the informer cache, or lists them from the
+// apiserver if the informer has not synced.
+func (k *k8sClient) listNodes() ([]interface{}, error) {
+	// Give the informer a chance to sync right after start up.
+	err := wait.PollImmediate(250*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return k.nodeInformer.HasSynced(), nil
 	})
+	if err == nil {
+		return k.nodeInformer.GetStore().List(), nil
+	}
+
+	logging.Warningf("Node informer has not synced (%v), falling back to listing nodes", err)
+	nodeList, err := k.clientset.CoreV1().Nodes().List(metav1.ListOptions{LabelSelector: k.nodeLabels, FieldSelector: k.nodeFieldSelector})
 	if err != nil {
 		return nil, err
 	}
-	nodes := k.nodeStore.List()
+	nodes := make([]interface{}, len(nodeList.Items))
+	for i := range nodeList.Items {
+		nodes[i] = &nodeList.Items[i]
+	}
+	return nodes, nil
+}
 
-	clusterStatus = &ClusterStatus{}
-	clusterStatus.TotalNodes = int32(len(nodes))
+// computeClusterStatus sums up nodes, cores, memory, ephemeral storage, pods
+// capacity and GPUs from the given nodes. Memory and ephemeral storage are
+// counted in bytes. The unschedulableNodeWeight of the cordoned nodes, and of
+// their resources, is added to the schedulable ones, rounded to the nearest
+// unit.
+func (k *k8sClient) computeClusterStatus(nodes []interface{}) *ClusterStatus {
+	clusterStatus := &ClusterStatus{}
 	var tc resource.Quantity
 	var sc resource.Quantity
+	var tm resource.Quantity
+	var sm resource.Quantity
+	var te resource.Quantity
+	var se resource.Quantity
+	var tp resource.Quantity
+	var sp resource.Quantity
+	var tg resource.Quantity
+	var sg resource.Quantity
+	totalScaleResources := make([]resource.Quantity, len(k.scaleResources))
+	schedulableScaleResources := make([]resource.Quantity, len(k.scaleResources))
+	// Resources of the cordoned nodes, weighted once summed up.
+	var cordonedNodes int
+	var cc, cm, ce, cp, cg resource.Quantity
+	cordonedScaleResources := make([]resource.Quantity, len(k.scaleResources))
+	totalZones := sets.NewString()
+	schedulableZones := sets.NewString()
+	var notReadyNodes int
+	var controlPlaneNodes int
+	var clampedNodes int
+	var noEphemeralStorageNodes int
 	for i := range nodes {
 		node, ok := nodes[i].(*v1.Node)
 		if !ok {
-			glog.Errorf("Unexpected object: %#v", nodes[i])
+			logging.Errorf("Unexpected object: %#v", nodes[i])
+			continue
+		}
+		if k.readyNodesOnly && !isNodeReady(node) {
+			notReadyNodes++
 			continue
 		}
-		tc.Add(node.Status.Allocatable[v1.ResourceCPU])
-		if !node.Spec.Unschedulable {
+		if k.excludeControlPlaneNodes && isControlPlaneNode(node) {
+			controlPlaneNodes++
+			continue
+		}
+		clusterStatus.TotalNodes++
+		resources := k.nodeResources(node)
+		pods := resources[v1.ResourcePods]
+		if pods.IsZero() {
+			logging.V(2).Infof("Node %s reports zero allocatable pods", node.Name)
+		}
+		cores := resources[v1.ResourceCPU]
+		if k.maxCoresPerNode > 0 && cores.Cmp(*resource.NewQuantity(k.maxCoresPerNode, resource.DecimalSI)) > 0 {
+			cores = *resource.NewQuantity(k.maxCoresPerNode, resource.DecimalSI)
+			clampedNodes++
+		}
+		cores = k.weightCores(node, cores)
+		memory := resources[v1.ResourceMemory]
+		ephemeralStorage, ok := resources[v1.ResourceEphemeralStorage]
+		if !ok {
+			noEphemeralStorageNodes++
+		}
+		gpus := resources[k.gpuResourceName]
+		// Metrics with their own node selector only count the nodes matching it.
+		if !k.selectsNode(MetricCores, node) {
+			cores = resource.Quantity{}
+		}
+		if !k.selectsNode(MetricMemory, node) {
+			memory = resource.Quantity{}
+		}
+		if !k.selectsNode(MetricPods, node) {
+			pods = resource.Quantity{}
+		}
+		if !k.selectsNode(MetricGPUs, node) {
+			gpus = resource.Quantity{}
+		}
+		tc.Add(cores)
+		tm.Add(memory)
+		te.Add(ephemeralStorage)
+		tp.Add(pods)
+		tg.Add(gpus)
+		for i, scaleResource := range k.scaleResources {
+			totalScaleResources[i].Add(resources[scaleResource.Name])
+		}
+		zone := nodeZone(node)
+		if zone != "" {
+			totalZones.Insert(zone)
+		}
+		if node.Spec.Unschedulable {
+			clusterStatus.UnschedulableNodes++
+		}
+		if isDraining(node) {
+			clusterStatus.DrainingNodes++
+		}
+		if !node.Spec.Unschedulable && !k.hasDisqualifyingTaint(node) {
 			clusterStatus.SchedulableNodes++
-			sc.Add(node.Status.Allocatable[v1.ResourceCPU])
+			sc.Add(cores)
+			sm.Add(memory)
+			se.Add(ephemeralStorage)
+			sp.Add(pods)
+			sg.Add(gpus)
+			for i, scaleResource := range k.scaleResources {
+				schedulableScaleResources[i].Add(resources[scaleResource.Name])
+			}
+			if zone != "" {
+				schedulableZones.Insert(zone)
+			}
+		} else if node.Spec.Unschedulable && !k.hasDisqualifyingTaint(node) && k.unschedulableNodeWeight > 0 {
+			cordonedNodes++
+			cc.Add(cores)
+			cm.Add(memory)
+			ce.Add(ephemeralStorage)
+			cp.Add(pods)
+			cg.Add(gpus)
+			for i, scaleResource := range k.scaleResources {
+				cordonedScaleResources[i].Add(resources[scaleResource.Name])
+			}
 		}
 	}
 
+	if notReadyNodes > 0 {
+		logging.V(2).Infof("Excluded %d nodes whose Ready condition is not True out of %d nodes", notReadyNodes, len(nodes))
+	}
+	if k.excludeControlPlaneNodes {
+		logging.V(3).Infof("Excluded %d control plane nodes out of %d nodes", controlPlaneNodes, len(nodes))
+	}
+	if noEphemeralStorageNodes > 0 {
+		logging.V(3).Infof("Counted no ephemeral storage for %d nodes not reporting it out of %d nodes", noEphemeralStorageNodes, len(nodes))
+	}
+	if clampedNodes > 0 {
+		logging.V(3).Infof("Counted %d cores for %d nodes with more cores out of %d nodes", k.maxCoresPerNode, clampedNodes, len(nodes))
+	}
+
+	if cordonedNodes > 0 {
+		logging.V(3).Infof("Counted %v of %d cordoned nodes as schedulable out of %d nodes", k.unschedulableNodeWeight, cordonedNodes, len(nodes))
+	}
+
+	clusterStatus.SchedulableNodes += int32(k.weighted(int64(cordonedNodes)))
 	clusterStatus.TotalCores = int32(tc.Value())
-	clusterStatus.SchedulableCores = int32(sc.Value())
-	k.clusterStatus = clusterStatus
-	return clusterStatus, nil
+	clusterStatus.SchedulableCores = int32(sc.Value() + k.weighted(cc.Value()))
+	clusterStatus.TotalMemory = tm.Value()
+	clusterStatus.SchedulableMemory = sm.Value() + k.weighted(cm.Value())
+	clusterStatus.TotalEphemeralStorage = te.Value()
+	clusterStatus.SchedulableEphemeralStorage = se.Value() + k.weighted(ce.Value())
+	clusterStatus.TotalPods = int32(tp.Value())
+	clusterStatus.SchedulablePods = int32(sp.Value() + k.weighted(cp.Value()))
+	clusterStatus.TotalGPUs = int32(tg.Value())
+	clusterStatus.SchedulableGPUs = int32(sg.Value() + k.weighted(cg.Value()))
+	clusterStatus.TotalZones = int32(totalZones.Len())
+	clusterStatus.SchedulableZones = int32(schedulableZones.Len())
+	for i, scaleResource := range k.scaleResources {
+		clusterStatus.ScaleResources = append(clusterStatus.ScaleResources, ScaleResourceStatus{
+			ScaleResource: scaleResource,
+			Total:         totalScaleResources[i].Value(),
+			Schedulable:   schedulableScaleResources[i].Value() + k.weighted(cordonedScaleResources[i].Value()),
+		})
+	}
+	return clusterStatus
+}
+
+// weighted returns the unschedulableNodeWeight of the given cordoned
+// resources, rounded to the nearest unit.
+func (k *k8sClient) weighted(cordoned int64) int64 {
+	return int64(math.Round(k.unschedulableNodeWeight * float64(cordoned)))
+}
+
+// weightCores multiplies the cores of the node by the weight of the value of
+// its --node-weight-label label. Unlisted values and nodes without the label
+// weigh 1.
+func (k *k8sClient) weightCores(node *v1.Node, cores resource.Quantity) resource.Quantity {
+	if k.nodeWeightLabel == "" {
+		return cores
+	}
+	weight, ok := k.nodeWeights[node.Labels[k.nodeWeightLabel]]
+	if !ok || weight == 1 {
+		return cores
+	}
+	return *resource.NewMilliQuantity(int64(float64(cores.MilliValue())*weight), resource.DecimalSI)
+}
+
+// nodeResources returns the resources of the node counted by the autoscaler.
+func (k *k8sClient) nodeResources(node *v1.Node) v1.ResourceList {
+	if k.resourceSource == ResourceSourceCapacity {
+		return node.Status.Capacity
+	}
+	return node.Status.Allocatable
+}
+
+// isNodeReady returns whether the Ready condition of the node is True.
+func isNodeReady(node *v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// isControlPlaneNode returns whether the node bears one of the control plane labels.
+func isControlPlaneNode(node *v1.Node) bool {
+	for _, label := range controlPlaneLabels {
+		if _, ok := node.Labels[label]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeZone returns the zone of the node from its topology label, falling back
+// to the deprecated failure-domain label.
+func nodeZone(node *v1.Node) string {
+	if zone, ok := node.Labels[zoneLabel]; ok {
+		return zone
+	}
+	return node.Labels[v1.LabelZoneFailureDomain]
 }
 
-func (k *k8sClient) UpdateReplicas(expReplicas int32) (prevRelicas int32, err error) {
-	prevRelicas, err = k.updateReplicasAppsV1(expReplicas)
-	if err == nil || !apierrors.IsForbidden(err) {
+// selectsNode returns whether the resources of the node count toward the
+// metric, i.e. whether the node matches the node selector of the metric, if
+// any.
+func (k *k8sClient) selectsNode(metric string, node *v1.Node) bool {
+	selector, ok := k.metricNodeSelectors[metric]
+	return !ok || selector.Matches(labels.Set(node.Labels))
+}
+
+// toBeDeletedTaint is the taint the cluster autoscaler sets on the nodes it
+// is about to delete.
+const toBeDeletedTaint = "ToBeDeletedByClusterAutoscaler"
+
+// isDraining returns whether the node is being drained or deleted.
+func isDraining(node *v1.Node) bool {
+	if node.Spec.Unschedulable || node.DeletionTimestamp != nil {
+		return true
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == toBeDeletedTaint {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDisqualifyingTaint returns whether the node carries a NoSchedule or
+// NoExecute taint that is not tolerated, when tainted nodes are ignored.
+func (k *k8sClient) hasDisqualifyingTaint(node *v1.Node) bool {
+	if !k.ignoreTaintedNodes {
+		return false
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect != v1.TaintEffectNoSchedule && taint.Effect != v1.TaintEffectNoExecute {
+			continue
+		}
+		if k.toleratedTaintKeys.Has(taint.Key) {
+			continue
+		}
+		logging.V(4).Infof("Node %s is not counted as schedulable because of taint %s", node.Name, taint.ToString())
+		return true
+	}
+	return false
+}
+
+func (k *k8sClient) GetReplicas(target string) (replicas int32, err error) {
+	replicas, err = k.getReplicas(target)
+	return replicas, targetError(target, err)
+}
+
+func (k *k8sClient) getReplicas(target string) (replicas int32, err error) {
+	scaleTarget, err := k.lookupScaleTarget(target)
+	if err != nil {
+		return 0, err
+	}
+	replicas, _, err = k.getScale(scaleTarget)
+	k.rediscoverOnNoMatch(scaleTarget, err)
+	return replicas, err
+}
+
+// getScale reads the current replicas and pod selector of the target from
+// its scale subresource, through the polymorphic scale client, so that any
+// scalable kind reports them the same way. Only the kinds served by
+// extensions/v1beta1 fall back to it if the scale subresource is forbidden.
+func (k *k8sClient) getScale(target *scaleTarget) (replicas int32, selector string, err error) {
+	scale, err := k.scaleClient.Scales(target.namespace).Get(target.resource, target.name)
+	if err == nil {
+		k.observeUID(target, scale.UID)
+		return scale.Spec.Replicas, scale.Status.Selector, nil
+	}
+	if !apierrors.IsForbidden(err) || !hasExtensionsV1beta1Scale(target) {
+		return 0, "", err
+	}
+	logging.V(1).Infof("Falling back to extensions/v1beta1, error using scale subresource: %v", err)
+
+	// Fall back to using the extensions API if we get a forbidden error
+	scaleExt, err := k.getScaleExtensionsV1beta1(target)
+	if err != nil {
+		return 0, "", err
+	}
+	k.observeUID(target, scaleExt.UID)
+	return scaleExt.Spec.Replicas, scaleExt.Status.TargetSelector, nil
+}
+
+// observeUID records the UID of the target and returns whether it changed.
+// When the targets are pinned by UID, a change means that the target was
+// deleted and recreated under the same name: it is logged and the target is
+// resolved again on its next lookup.
+func (k *k8sClient) observeUID(target *scaleTarget, uid types.UID) (recreated bool) {
+	recreated = k.pinTargetUID && target.uid != "" && target.uid != uid
+	if recreated {
+		logging.V(0).Infof("Target %v/%s was recreated, its UID changed from %s to %s, resolving it again", target.resource, target.name, target.uid, uid)
+		target.resolved = false
+	}
+	target.uid = uid
+	return recreated
+}
+
+// checkUID fails the update of the target if it was recreated since it was
+// last read, as the replicas would otherwise be computed from the object it
+// replaced. The recreated target is scaled at the next poll.
+func (k *k8sClient) checkUID(target *scaleTarget, uid types.UID) error {
+	if k.observeUID(target, uid) {
+		return fmt.Errorf("target %v/%s was recreated with UID %s, not scaling it until the next poll", target.resource, target.name, uid)
+	}
+	return nil
+}
+
+// hasExtensionsV1beta1Scale returns whether extensions/v1beta1 serves the
+// scale of the target.
+func hasExtensionsV1beta1Scale(target *scaleTarget) bool {
+	switch target.resource.Resource {
+	case "deployments", "replicasets":
+		return true
+	}
+	return false
+}
+
+func (k *k8sClient) GetReferencedReplicas(resource string) (replicas int32, err error) {
+	resource = strings.ToLower(resource)
+	if _, ok := k.scaleTargets[resource]; !ok {
+		scaleTarget, err := getScaleTarget(resource, k.namespace)
+		if err != nil {
+			return 0, err
+		}
+		// Keep it so that it is resolved once only, like the targets.
+		k.scaleTargets[resource] = scaleTarget
+	}
+	replicas, err = k.getReplicas(resource)
+	return replicas, targetError(resource, err)
+}
+
+func (k *k8sClient) GetDaemonSetDesiredPods(daemonSet string) (desired int32, found bool, err error) {
+	namespace, name := k.namespace, daemonSet
+	if splits := strings.SplitN(daemonSet, "/", 2); len(splits) == 2 {
+		namespace, name = splits[0], splits[1]
+	}
+	ds, err := k.clientset.AppsV1().DaemonSets(namespace).Get(name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return ds.Status.DesiredNumberScheduled, true, nil
+}
+
+// updateConflictBackoff bounds the retries of a scale update which conflicts
+// with a concurrent write, so that the poll loop is never blocked for long.
+var updateConflictBackoff = wait.Backoff{
+	Steps:    3,
+	Duration: 100 * time.Millisecond,
+	Factor:   2.0,
+	Jitter:   0.1,
+}
+
+func (k *k8sClient) UpdateReplicas(target string, expReplicas int32) (prevRelicas int32, err error) {
+	attempt := 0
+	err = retry.RetryOnConflict(updateConflictBackoff, func() error {
+		attempt++
+		// Each attempt fetches the scale again, so retries update the latest version.
+		prevRelicas, err = k.updateReplicas(target, expReplicas)
+		if apierrors.IsConflict(err) && attempt < updateConflictBackoff.Steps {
+			logging.Warningf("Conflict updating replicas for %s (attempt %d of %d), retrying: %v", target, attempt, updateConflictBackoff.Steps, err)
+		}
+		return err
+	})
+	if apierrors.IsConflict(err) {
+		logging.Errorf("Giving up updating replicas for %s after %d conflicting attempts", target, attempt)
+	}
+	return prevRelicas, targetError(target, err)
+}
+
+func (k *k8sClient) updateReplicas(target string, expReplicas int32) (prevRelicas int32, err error) {
+	scaleTarget, err := k.lookupScaleTarget(target)
+	if err != nil {
+		return 0, err
+	}
+	prevRelicas, err = k.updateReplicasScaleSubresource(scaleTarget, expReplicas)
+	k.rediscoverOnNoMatch(scaleTarget, err)
+	if err == nil || !apierrors.IsForbidden(err) || !hasExtensionsV1beta1Scale(scaleTarget) {
 		return prevRelicas, err
 	}
-	glog.V(1).Infof("Falling back to extensions/v1beta1, error using apps/v1: %v", err)
+	logging.V(1).Infof("Falling back to extensions/v1beta1, error using scale subresource: %v", err)
 
 	// Fall back to using the extensions API if we get a forbidden error
-	scale, err := k.getScaleExtensionsV1beta1(k.target)
+	scale, err := k.getScaleExtensionsV1beta1(scaleTarget)
 	if err != nil {
 		return 0, err
 	}
+	if err := k.checkUID(scaleTarget, scale.UID); err != nil {
+		return 0, err
+	}
 	prevRelicas = scale.Spec.Replicas
 	if expReplicas != prevRelicas {
-		glog.V(0).Infof("Cluster status: SchedulableNodes[%v], TotalNodes[%v], SchedulableCores[%v], TotalCores[%v]", k.clusterStatus.SchedulableNodes, k.clusterStatus.TotalNodes, k.clusterStatus.SchedulableCores, k.clusterStatus.TotalCores)
-		glog.V(0).Infof("Replicas are not as expected : updating replicas from %d to %d", prevRelicas, expReplicas)
+		logging.V(0).Infof("Cluster status: SchedulableNodes[%v], TotalNodes[%v], SchedulableCores[%v], TotalCores[%v]", k.clusterStatus.SchedulableNodes, k.clusterStatus.TotalNodes, k.clusterStatus.SchedulableCores, k.clusterStatus.TotalCores)
+		logging.V(0).Infof("Replicas are not as expected for %s: updating replicas from %d to %d", target, prevRelicas, expReplicas)
 		scale.Spec.Replicas = expReplicas
-		_, err = k.updateScaleExtensionsV1beta1(k.target, scale)
+		_, err = k.updateScaleExtensionsV1beta1(scaleTarget, scale)
 		if err != nil {
 			return 0, err
 		}
@@ -235,48 +1222,43 @@ func (k *k8sClient) UpdateReplicas(expReplicas int32) (prevRelicas int32, err er
 
 func (k *k8sClient) getScaleExtensionsV1beta1(target *scaleTarget) (*extensionsv1beta1.Scale, error) {
 	opt := metav1.GetOptions{}
-	switch strings.ToLower(target.kind) {
-	case "deployment", "deployments":
+	switch target.resource.Resource {
+	case "deployments":
 		return k.clientset.ExtensionsV1beta1().Deployments(target.namespace).GetScale(target.name, opt)
-	case "replicaset", "replicasets":
+	case "replicasets":
 		return k.clientset.ExtensionsV1beta1().ReplicaSets(target.namespace).GetScale(target.name, opt)
 	default:
-		return nil, fmt.Errorf("unsupported target kind: %v", target.kind)
+		return nil, fmt.Errorf("unsupported target resource for extensions/v1beta1: %v", target.resource)
 	}
 }
 
 func (k *k8sClient) updateScaleExtensionsV1beta1(target *scaleTarget, scale *extensionsv1beta1.Scale) (*extensionsv1beta1.Scale, error) {
-	switch strings.ToLower(target.kind) {
-	case "deployment", "deployments":
+	switch target.resource.Resource {
+	case "deployments":
 		return k.clientset.ExtensionsV1beta1().Deployments(target.namespace).UpdateScale(target.name, scale)
-	case "replicaset", "replicasets":
+	case "replicasets":
 		return k.clientset.ExtensionsV1beta1().ReplicaSets(target.namespace).UpdateScale(target.name, scale)
 	default:
-		return nil, fmt.Errorf("unsupported target kind: %v", target.kind)
+		return nil, fmt.Errorf("unsupported target resource for extensions/v1beta1: %v", target.resource)
 	}
 }
 
-func (k *k8sClient) updateReplicasAppsV1(expReplicas int32) (prevRelicas int32, err error) {
-	req, err := requestForTarget(k.clientset.AppsV1().RESTClient().Get(), k.target)
+func (k *k8sClient) updateReplicasScaleSubresource(target *scaleTarget, expReplicas int32) (prevRelicas int32, err error) {
+	scales := k.scaleClient.Scales(target.namespace)
+	scale, err := scales.Get(target.resource, target.name)
 	if err != nil {
 		return 0, err
 	}
-
-	scale := &autoscalingv1.Scale{}
-	if err = req.Do().Into(scale); err != nil {
+	if err := k.checkUID(target, scale.UID); err != nil {
 		return 0, err
 	}
 
 	prevRelicas = scale.Spec.Replicas
 	if expReplicas != prevRelicas {
-		glog.V(0).Infof("Cluster status: SchedulableNodes[%v], SchedulableCores[%v]", k.clusterStatus.SchedulableNodes, k.clusterStatus.SchedulableCores)
-		glog.V(0).Infof("Replicas are not as expected : updating replicas from %d to %d", prevRelicas, expReplicas)
+		logging.V(0).Infof("Cluster status: SchedulableNodes[%v], SchedulableCores[%v]", k.clusterStatus.SchedulableNodes, k.clusterStatus.SchedulableCores)
+		logging.V(0).Infof("Replicas are not as expected for %s/%s: updating replicas from %d to %d", target.resource, target.name, prevRelicas, expReplicas)
 		scale.Spec.Replicas = expReplicas
-		req, err = requestForTarget(k.clientset.AppsV1().RESTClient().Put(), k.target)
-		if err != nil {
-			return 0, err
-		}
-		if err = req.Body(scale).Do().Error(); err != nil {
+		if _, err = scales.Update(target.resource, scale); err != nil {
 			return 0, err
 		}
 	}
@@ -284,26 +1266,261 @@ func (k *k8sClient) updateReplicasAppsV1(expReplicas int32) (prevRelicas int32,
 	return prevRelicas, nil
 }
 
-func requestForTarget(req *rest.Request, target *scaleTarget) (*rest.Request, error) {
-	var absPath, resource string
-	// Support the kinds we allowed scaling via the extensions API group
-	// TODO: switch to use the polymorphic scale client once client-go versions are updated
-	switch strings.ToLower(target.kind) {
-	case "deployment", "deployments":
-		absPath = "/apis/apps/v1"
-		resource = "deployments"
-	case "replicaset", "replicasets":
-		absPath = "/apis/apps/v1"
-		resource = "replicasets"
-	case "statefulset", "statefulsets":
-		absPath = "/apis/apps/v1"
-		resource = "statefulsets"
-	case "replicationcontroller", "replicationcontrollers":
-		absPath = "/api/v1"
-		resource = "replicationcontrollers"
-	default:
-		return nil, fmt.Errorf("unsupported target kind: %v", target.kind)
+func (k *k8sClient) GetDisruptionsAllowed(target string) (disruptionsAllowed int32, found bool, err error) {
+	disruptionsAllowed, found, err = k.getDisruptionsAllowed(target)
+	return disruptionsAllowed, found, targetError(target, err)
+}
+
+func (k *k8sClient) getDisruptionsAllowed(target string) (disruptionsAllowed int32, found bool, err error) {
+	scaleTarget, err := k.lookupScaleTarget(target)
+	if err != nil {
+		return 0, false, err
+	}
+	selector, err := k.getSelector(scaleTarget)
+	if err != nil {
+		return 0, false, err
+	}
+	pods, err := k.clientset.CoreV1().Pods(scaleTarget.namespace).List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return 0, false, err
+	}
+	pdbs, err := k.clientset.PolicyV1beta1().PodDisruptionBudgets(scaleTarget.namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return 0, false, err
 	}
+	disruptionsAllowed, found = podDisruptionsAllowed(pods.Items, pdbs.Items)
+	return disruptionsAllowed, found, nil
+}
+
+func (k *k8sClient) CountPods(target string) (pods int32, err error) {
+	pods, err = k.countPods(target)
+	return pods, targetError(target, err)
+}
 
-	return req.AbsPath(absPath).Namespace(target.namespace).Resource(resource).Name(target.name).SubResource("scale"), nil
+func (k *k8sClient) countPods(target string) (int32, error) {
+	scaleTarget, err := k.lookupScaleTarget(target)
+	if err != nil {
+		return 0, err
+	}
+	selector, err := k.getSelector(scaleTarget)
+	if err != nil {
+		return 0, err
+	}
+	pods, err := k.clientset.CoreV1().Pods(scaleTarget.namespace).List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return 0, err
+	}
+	return countActivePods(pods.Items), nil
+}
+
+// getSelector returns the label selector of the pods of the target.
+func (k *k8sClient) getSelector(target *scaleTarget) (labels.Selector, error) {
+	_, selector, err := k.getScale(target)
+	if err != nil {
+		return nil, err
+	}
+	if selector == "" {
+		return nil, fmt.Errorf("no pod selector in the scale subresource of %s/%s", target.resource, target.name)
+	}
+	return labels.Parse(selector)
+}
+
+// podDisruptionsAllowed returns the lowest number of disruptions allowed,
+// computed as currentHealthy - desiredHealthy, among the PodDisruptionBudgets
+// selecting any of the pods, and whether any of them does.
+func podDisruptionsAllowed(pods []v1.Pod, pdbs []policyv1beta1.PodDisruptionBudget) (disruptionsAllowed int32, found bool) {
+	for _, pdb := range pdbs {
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil || selector.Empty() {
+			continue
+		}
+		for _, pod := range pods {
+			if !selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			allowed := pdb.Status.CurrentHealthy - pdb.Status.DesiredHealthy
+			if allowed < 0 {
+				allowed = 0
+			}
+			if !found || allowed < disruptionsAllowed {
+				disruptionsAllowed = allowed
+			}
+			found = true
+			break
+		}
+	}
+	return disruptionsAllowed, found
+}
+
+func (k *k8sClient) GetAnnotations(target string) (annotations map[string]string, err error) {
+	annotations, err = k.getAnnotations(target)
+	return annotations, targetError(target, err)
+}
+
+func (k *k8sClient) getAnnotations(target string) (map[string]string, error) {
+	obj, err := k.getTargetObject(target)
+	if err != nil {
+		return nil, err
+	}
+	return obj.GetAnnotations(), nil
+}
+
+func (k *k8sClient) AnnotateTarget(target string, annotations map[string]string) error {
+	return targetError(target, k.annotateTarget(target, annotations))
+}
+
+func (k *k8sClient) annotateTarget(target string, annotations map[string]string) error {
+	scaleTarget, err := k.lookupScaleTarget(target)
+	if err != nil {
+		return err
+	}
+	gv, err := schema.ParseGroupVersion(scaleTarget.apiVersion)
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = k.dynamicClient.Resource(gv.WithResource(scaleTarget.resource.Resource)).Namespace(scaleTarget.namespace).Patch(scaleTarget.name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// getTargetObject gets the target resource itself, whatever its kind.
+func (k *k8sClient) getTargetObject(target string) (*unstructured.Unstructured, error) {
+	scaleTarget, err := k.lookupScaleTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	gv, err := schema.ParseGroupVersion(scaleTarget.apiVersion)
+	if err != nil {
+		return nil, err
+	}
+	return k.dynamicClient.Resource(gv.WithResource(scaleTarget.resource.Resource)).Namespace(scaleTarget.namespace).Get(scaleTarget.name, metav1.GetOptions{})
+}
+
+func (k *k8sClient) GetPodMemoryRequest(target string) (request int64, found bool, err error) {
+	request, found, err = k.getPodMemoryRequest(target)
+	return request, found, targetError(target, err)
+}
+
+func (k *k8sClient) getPodMemoryRequest(target string) (int64, bool, error) {
+	obj, err := k.getTargetObject(target)
+	if err != nil {
+		return 0, false, err
+	}
+	// Custom resources may have no pod template at spec.template.
+	template, found, err := unstructured.NestedMap(obj.Object, "spec", "template")
+	if err != nil || !found {
+		return 0, false, err
+	}
+	var podTemplate v1.PodTemplateSpec
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(template, &podTemplate); err != nil {
+		return 0, false, fmt.Errorf("could not parse the pod template of %s: %v", target, err)
+	}
+	request := podMemoryRequest(&podTemplate.Spec)
+	return request, request > 0, nil
+}
+
+// podMemoryRequest returns the memory request of a pod, i.e. the highest of the
+// sum of the requests of its containers and of the request of any init
+// container, as the scheduler computes it.
+func podMemoryRequest(spec *v1.PodSpec) int64 {
+	var request resource.Quantity
+	for _, container := range spec.Containers {
+		request.Add(container.Resources.Requests[v1.ResourceMemory])
+	}
+	for _, container := range spec.InitContainers {
+		if initRequest := container.Resources.Requests[v1.ResourceMemory]; initRequest.Cmp(request) > 0 {
+			request = initRequest
+		}
+	}
+	return request.Value()
+}
+
+func (k *k8sClient) RecordEvent(target, eventType, reason, message string) {
+	scaleTarget, err := k.lookupScaleTarget(target)
+	if err != nil {
+		logging.Warningf("Failed to record event %s: %v", reason, err)
+		return
+	}
+	ref := &v1.ObjectReference{
+		Kind:       scaleTarget.kind,
+		APIVersion: scaleTarget.apiVersion,
+		Name:       scaleTarget.name,
+		Namespace:  scaleTarget.namespace,
+		UID:        scaleTarget.uid,
+	}
+	k.recorder.Event(ref, eventType, reason, message)
+}
+
+// eventComponent is the source component of the events recorded on targets.
+const eventComponent = "cluster-proportional-autoscaler"
+
+// warningEventSink logs a warning when writing an event fails.
+type warningEventSink struct {
+	record.EventSink
+}
+
+func (s *warningEventSink) Create(event *v1.Event) (*v1.Event, error) {
+	e, err := s.EventSink.Create(event)
+	if err != nil {
+		logging.Warningf("Failed to create event %s on %s/%s: %v", event.Reason, event.InvolvedObject.Kind, event.InvolvedObject.Name, err)
+	}
+	return e, err
+}
+
+func (s *warningEventSink) Update(event *v1.Event) (*v1.Event, error) {
+	e, err := s.EventSink.Update(event)
+	if err != nil {
+		logging.Warningf("Failed to update event %s on %s/%s: %v", event.Reason, event.InvolvedObject.Kind, event.InvolvedObject.Name, err)
+	}
+	return e, err
+}
+
+func (s *warningEventSink) Patch(event *v1.Event, data []byte) (*v1.Event, error) {
+	e, err := s.EventSink.Patch(event, data)
+	if err != nil {
+		logging.Warningf("Failed to patch event %s on %s/%s: %v", event.Reason, event.InvolvedObject.Kind, event.InvolvedObject.Name, err)
+	}
+	return e, err
+}
+
+// resolveScaleTarget resolves the resource of the target through API discovery
+// and ensures it exposes a scale subresource.
+func resolveScaleTarget(target *scaleTarget, mapper meta.RESTMapper, discoveryClient discovery.DiscoveryInterface) error {
+	gvr, err := mapper.ResourceFor(target.resource.WithVersion(""))
+	if meta.IsNoMatchError(err) {
+		return &TargetNotFoundError{Target: fmt.Sprintf("%v/%s", target.resource, target.name), Err: err}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to discover target resource %v: %v", target.resource, err)
+	}
+	resources, err := discoveryClient.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		return fmt.Errorf("failed to discover resources of %v: %v", gvr.GroupVersion(), err)
+	}
+	if !hasScaleSubresource(resources, gvr.Resource) {
+		return &NotScalableError{Target: fmt.Sprintf("%v/%s", target.resource, target.name), GVR: gvr}
+	}
+	gvk, err := mapper.KindFor(gvr)
+	if err != nil {
+		return fmt.Errorf("failed to discover kind of target resource %v: %v", gvr.GroupResource(), err)
+	}
+	target.resource = gvr.GroupResource()
+	target.kind = gvk.Kind
+	target.apiVersion = gvk.GroupVersion().String()
+	target.resolved = true
+	return nil
+}
+
+func hasScaleSubresource(resources *metav1.APIResourceList, resource string) bool {
+	for _, r := range resources.APIResources {
+		if r.Name == resource+"/scale" {
+			return true
+		}
+	}
+	return false
 }
@@ -17,37 +17,74 @@ limitations under the License.
 package k8sclient
 
 import (
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	v1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/scale"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
 )
 
 func TestGetScaleTarget(t *testing.T) {
 	testCases := []struct {
-		target   string
-		expKind  string
-		expName  string
-		expError bool
+		target      string
+		expResource schema.GroupResource
+		expName     string
+		expError    bool
 	}{
 		{
 			"deployment/anything",
-			"deployment",
+			schema.GroupResource{Group: "apps", Resource: "deployment"},
 			"anything",
 			false,
 		},
 		{
 			"replicationcontroller/anotherthing",
-			"replicationcontroller",
+			schema.GroupResource{Resource: "replicationcontroller"},
 			"anotherthing",
 			false,
 		},
+		{
+			"foosets.example.com/myfoo",
+			schema.GroupResource{Group: "example.com", Resource: "foosets"},
+			"myfoo",
+			false,
+		},
 		{
 			"replicationcontroller",
-			"",
+			schema.GroupResource{},
 			"",
 			true,
 		},
 		{
 			"replicaset/anything/what",
+			schema.GroupResource{},
 			"",
+			true,
+		},
+		{
+			"deployment/",
+			schema.GroupResource{},
 			"",
 			true,
 		},
@@ -62,8 +99,1034 @@ func TestGetScaleTarget(t *testing.T) {
 			t.Errorf("Expect error, got no error for target: %v", tc.target)
 			continue
 		}
-		if res.kind != tc.expKind || res.name != tc.expName {
-			t.Errorf("Expect kind: %v, name: %v\ngot kind: %v, name: %v", tc.expKind, tc.expName, res.kind, res.name)
+		if res.resource != tc.expResource || res.name != tc.expName {
+			t.Errorf("Expect resource: %v, name: %v\ngot resource: %v, name: %v", tc.expResource, tc.expName, res.resource, res.name)
+		}
+	}
+}
+
+func TestResolveScaleTarget(t *testing.T) {
+	mapper := restmapper.NewDiscoveryRESTMapper([]*restmapper.APIGroupResources{
+		{
+			Group: metav1.APIGroup{
+				Name:             "example.com",
+				Versions:         []metav1.GroupVersionForDiscovery{{GroupVersion: "example.com/v1", Version: "v1"}},
+				PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "example.com/v1", Version: "v1"},
+			},
+			VersionedResources: map[string][]metav1.APIResource{
+				"v1": {
+					{Name: "foosets", SingularName: "fooset", Namespaced: true, Kind: "FooSet"},
+					{Name: "bars", SingularName: "bar", Namespaced: true, Kind: "Bar"},
+				},
+			},
+		},
+	})
+	discoveryClient := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{
+		Resources: []*metav1.APIResourceList{
+			{
+				GroupVersion: "example.com/v1",
+				APIResources: []metav1.APIResource{
+					{Name: "foosets"},
+					{Name: "foosets/scale"},
+					{Name: "bars"},
+				},
+			},
+		},
+	}}
+
+	testCases := []struct {
+		target         string
+		expResource    schema.GroupResource
+		expError       bool
+		expNotFound    bool
+		expNotScalable bool
+	}{
+		{"foosets.example.com/myfoo", schema.GroupResource{Group: "example.com", Resource: "foosets"}, false, false, false},
+		{"fooset.example.com/myfoo", schema.GroupResource{Group: "example.com", Resource: "foosets"}, false, false, false},
+		{"bars.example.com/mybar", schema.GroupResource{}, true, false, true},
+		{"noexist.example.com/anything", schema.GroupResource{}, true, true, false},
+	}
+
+	for _, tc := range testCases {
+		target, err := getScaleTarget(tc.target, "default")
+		if err != nil {
+			t.Fatalf("Unexpected error parsing target %v: %v", tc.target, err)
+		}
+		err = resolveScaleTarget(target, mapper, discoveryClient)
+		if err != nil && !tc.expError {
+			t.Errorf("Expect no error, got error for target %v: %v", tc.target, err)
+			continue
+		} else if err == nil && tc.expError {
+			t.Errorf("Expect error, got no error for target: %v", tc.target)
+			continue
+		}
+		if IsTargetNotFound(err) != tc.expNotFound {
+			t.Errorf("Expect target not found %v for target %v, got error: %v", tc.expNotFound, tc.target, err)
+		}
+		if IsNotScalable(err) != tc.expNotScalable {
+			t.Errorf("Expect target not scalable %v for target %v, got error: %v", tc.expNotScalable, tc.target, err)
+		}
+		if tc.expNotScalable && !strings.Contains(err.Error(), "example.com/v1, Resource=bars") {
+			t.Errorf("Expect the error for target %v to name the discovered resource, got: %v", tc.target, err)
+		}
+		if !tc.expError && target.resource != tc.expResource {
+			t.Errorf("Expect resource %v for target %v, got %v", tc.expResource, tc.target, target.resource)
+		}
+	}
+}
+
+func TestRefreshDiscovery(t *testing.T) {
+	discoveryClient := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+	start := time.Now()
+	target := &scaleTarget{resolved: true}
+	k := &k8sClient{
+		scaleTargets:     map[string]*scaleTarget{"foosets.example.com/myfoo": target},
+		mapper:           restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)),
+		discoveryRefresh: time.Minute,
+		lastDiscovery:    start,
+	}
+
+	k.refreshDiscovery(start.Add(30 * time.Second))
+	if !target.resolved {
+		t.Errorf("Expected the target to stay resolved before the refresh period")
+	}
+	k.refreshDiscovery(start.Add(time.Minute))
+	if target.resolved {
+		t.Errorf("Expected the target to be resolved again after the refresh period")
+	}
+	if !k.lastDiscovery.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected the last discovery at %v, got %v", start.Add(time.Minute), k.lastDiscovery)
+	}
+
+	// No periodic refresh by default.
+	target.resolved = true
+	k.discoveryRefresh = 0
+	k.refreshDiscovery(start.Add(time.Hour))
+	if !target.resolved {
+		t.Errorf("Expected the target to stay resolved without a refresh period")
+	}
+}
+
+func TestRediscoverOnNoMatch(t *testing.T) {
+	discoveryClient := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+	target := &scaleTarget{resource: schema.GroupResource{Group: "example.com", Resource: "foosets"}, resolved: true}
+	k := &k8sClient{mapper: restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))}
+
+	k.rediscoverOnNoMatch(target, errors.New("connection refused"))
+	if !target.resolved {
+		t.Errorf("Expected the target to stay resolved on other errors")
+	}
+	k.rediscoverOnNoMatch(target, &meta.NoResourceMatchError{PartialResource: target.resource.WithVersion("v1")})
+	if target.resolved {
+		t.Errorf("Expected the target to be resolved again when its resource is no longer served")
+	}
+}
+
+func TestComputeClusterStatus(t *testing.T) {
+	const gpuResourceName = "nvidia.com/gpu"
+	newNode := func(cpu, memory, pods string, unschedulable bool) *v1.Node {
+		node := &v1.Node{}
+		node.Spec.Unschedulable = unschedulable
+		node.Status.Allocatable = v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse(cpu),
+			v1.ResourceMemory: resource.MustParse(memory),
+		}
+		if pods != "" {
+			node.Status.Allocatable[v1.ResourcePods] = resource.MustParse(pods)
+		}
+		return node
+	}
+	newGPUNode := func(gpus string, unschedulable bool) *v1.Node {
+		node := newNode("8", "32Gi", "110", unschedulable)
+		node.Status.Allocatable[gpuResourceName] = resource.MustParse(gpus)
+		node.Status.Allocatable["amd.com/gpu"] = resource.MustParse("1")
+		return node
+	}
+
+	testCases := []struct {
+		nodes     []interface{}
+		expStatus ClusterStatus
+	}{
+		{
+			[]interface{}{},
+			ClusterStatus{},
+		},
+		{
+			[]interface{}{
+				newNode("4", "1Gi", "110", false),
+				newNode("2", "512Mi", "110", false),
+				newNode("8", "1048576Ki", "50", true),
+			},
+			ClusterStatus{
+				TotalNodes:         3,
+				SchedulableNodes:   2,
+				TotalCores:         14,
+				SchedulableCores:   6,
+				TotalMemory:        2*1024*1024*1024 + 512*1024*1024,
+				SchedulableMemory:  1024*1024*1024 + 512*1024*1024,
+				TotalPods:          270,
+				SchedulablePods:    220,
+				UnschedulableNodes: 1,
+				DrainingNodes:      1,
+			},
+		},
+		{
+			[]interface{}{
+				newNode("3500m", "3G", "", false),
+				newNode("1", "1G", "0", false),
+			},
+			ClusterStatus{
+				TotalNodes:        2,
+				SchedulableNodes:  2,
+				TotalCores:        5,
+				SchedulableCores:  5,
+				TotalMemory:       4000000000,
+				SchedulableMemory: 4000000000,
+			},
+		},
+		{
+			[]interface{}{
+				newGPUNode("4", false),
+				newGPUNode("2", true),
+				newNode("4", "16Gi", "110", false),
+			},
+			ClusterStatus{
+				TotalNodes:         3,
+				SchedulableNodes:   2,
+				TotalCores:         20,
+				SchedulableCores:   12,
+				TotalMemory:        80 * 1024 * 1024 * 1024,
+				SchedulableMemory:  48 * 1024 * 1024 * 1024,
+				TotalPods:          330,
+				SchedulablePods:    220,
+				TotalGPUs:          6,
+				SchedulableGPUs:    4,
+				UnschedulableNodes: 1,
+				DrainingNodes:      1,
+			},
+		},
+	}
+
+	k := &k8sClient{gpuResourceName: gpuResourceName}
+	for _, tc := range testCases {
+		status := k.computeClusterStatus(tc.nodes)
+		if !reflect.DeepEqual(*status, tc.expStatus) {
+			t.Errorf("Expect cluster status %+v, got %+v", tc.expStatus, *status)
+		}
+	}
+}
+
+func TestComputeClusterStatusExcludeControlPlaneNodes(t *testing.T) {
+	newNode := func(labels map[string]string) *v1.Node {
+		node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Labels: labels}}
+		node.Status.Allocatable = v1.ResourceList{v1.ResourceCPU: resource.MustParse("4")}
+		return node
+	}
+	nodes := []interface{}{
+		newNode(nil),
+		newNode(map[string]string{"node-role.kubernetes.io/worker": ""}),
+		newNode(map[string]string{"node-role.kubernetes.io/control-plane": ""}),
+		newNode(map[string]string{"node-role.kubernetes.io/master": "true"}),
+	}
+
+	testCases := []struct {
+		excludeControlPlaneNodes bool
+		expTotalNodes            int32
+		expSchedulableCores      int32
+	}{
+		{false, 4, 16},
+		{true, 2, 8},
+	}
+
+	for _, tc := range testCases {
+		k := &k8sClient{excludeControlPlaneNodes: tc.excludeControlPlaneNodes}
+		status := k.computeClusterStatus(nodes)
+		if status.TotalNodes != tc.expTotalNodes || status.SchedulableNodes != tc.expTotalNodes || status.SchedulableCores != tc.expSchedulableCores {
+			t.Errorf("With excludeControlPlaneNodes=%v expect %d nodes and %d cores, got %d total nodes, %d schedulable nodes and %d cores",
+				tc.excludeControlPlaneNodes, tc.expTotalNodes, tc.expSchedulableCores, status.TotalNodes, status.SchedulableNodes, status.SchedulableCores)
+		}
+	}
+}
+
+func TestComputeClusterStatusDrainingNodes(t *testing.T) {
+	now := metav1.Now()
+	nodes := []interface{}{
+		&v1.Node{},
+		&v1.Node{Spec: v1.NodeSpec{Unschedulable: true}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &now}},
+		&v1.Node{Spec: v1.NodeSpec{Taints: []v1.Taint{{Key: "ToBeDeletedByClusterAutoscaler", Effect: v1.TaintEffectNoSchedule}}}},
+		&v1.Node{Spec: v1.NodeSpec{Taints: []v1.Taint{{Key: "dedicated", Effect: v1.TaintEffectNoSchedule}}}},
+	}
+
+	k := &k8sClient{}
+	status := k.computeClusterStatus(nodes)
+	if status.DrainingNodes != 3 {
+		t.Errorf("Expected 3 draining nodes, got %d", status.DrainingNodes)
+	}
+	if status.UnschedulableNodes != 1 {
+		t.Errorf("Expected 1 unschedulable node, got %d", status.UnschedulableNodes)
+	}
+}
+
+func TestComputeClusterStatusMetricNodeSelectors(t *testing.T) {
+	newNode := func(pool string) *v1.Node {
+		node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"pool": pool}}}
+		node.Status.Allocatable = v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse("4"),
+			v1.ResourceMemory: resource.MustParse("1Gi"),
+			v1.ResourcePods:   resource.MustParse("100"),
+		}
+		return node
+	}
+	nodes := []interface{}{
+		newNode("compute"),
+		newNode("compute"),
+		newNode("system"),
+	}
+
+	k := &k8sClient{metricNodeSelectors: map[string]labels.Selector{
+		MetricCores: labels.SelectorFromSet(labels.Set{"pool": "compute"}),
+		MetricPods:  labels.SelectorFromSet(labels.Set{"pool": "system"}),
+	}}
+	status := k.computeClusterStatus(nodes)
+	if status.TotalNodes != 3 || status.SchedulableNodes != 3 {
+		t.Errorf("Expected 3 total and schedulable nodes, got %d and %d", status.TotalNodes, status.SchedulableNodes)
+	}
+	if status.TotalCores != 8 || status.SchedulableCores != 8 {
+		t.Errorf("Expected 8 total and schedulable cores, got %d and %d", status.TotalCores, status.SchedulableCores)
+	}
+	if status.TotalPods != 100 || status.SchedulablePods != 100 {
+		t.Errorf("Expected 100 total and schedulable pods, got %d and %d", status.TotalPods, status.SchedulablePods)
+	}
+	if expMemory := int64(3 * 1024 * 1024 * 1024); status.TotalMemory != expMemory || status.SchedulableMemory != expMemory {
+		t.Errorf("Expected %d total and schedulable memory, got %d and %d", expMemory, status.TotalMemory, status.SchedulableMemory)
+	}
+}
+
+func TestComputeClusterStatusMaxCoresPerNode(t *testing.T) {
+	newNode := func(cores string, unschedulable bool) *v1.Node {
+		node := &v1.Node{Spec: v1.NodeSpec{Unschedulable: unschedulable}}
+		node.Status.Allocatable = v1.ResourceList{v1.ResourceCPU: resource.MustParse(cores)}
+		return node
+	}
+	nodes := []interface{}{
+		newNode("4", false),
+		newNode("16", false),
+		newNode("128", false),
+		newNode("3500m", false),
+		newNode("128", true),
+	}
+
+	testCases := []struct {
+		maxCoresPerNode     int64
+		expTotalCores       int32
+		expSchedulableCores int32
+	}{
+		{0, 280, 152},
+		{16, 56, 40},
+		{8, 32, 24},
+	}
+
+	for _, tc := range testCases {
+		k := &k8sClient{maxCoresPerNode: tc.maxCoresPerNode}
+		status := k.computeClusterStatus(nodes)
+		if status.TotalCores != tc.expTotalCores || status.SchedulableCores != tc.expSchedulableCores {
+			t.Errorf("With maxCoresPerNode=%d expect %d total and %d schedulable cores, got %d and %d",
+				tc.maxCoresPerNode, tc.expTotalCores, tc.expSchedulableCores, status.TotalCores, status.SchedulableCores)
+		}
+	}
+}
+
+func TestComputeClusterStatusEphemeralStorage(t *testing.T) {
+	newNode := func(ephemeralStorage string, unschedulable bool) *v1.Node {
+		node := &v1.Node{Spec: v1.NodeSpec{Unschedulable: unschedulable}}
+		node.Status.Allocatable = v1.ResourceList{v1.ResourceCPU: resource.MustParse("4")}
+		if ephemeralStorage != "" {
+			node.Status.Allocatable[v1.ResourceEphemeralStorage] = resource.MustParse(ephemeralStorage)
+		}
+		return node
+	}
+	nodes := []interface{}{
+		newNode("100Gi", false),
+		newNode("50Gi", false),
+		newNode("100Gi", true),
+		// Nodes not reporting ephemeral storage count as none.
+		newNode("", false),
+	}
+
+	k := &k8sClient{}
+	status := k.computeClusterStatus(nodes)
+	const gi = 1024 * 1024 * 1024
+	if status.TotalEphemeralStorage != 250*gi || status.SchedulableEphemeralStorage != 150*gi {
+		t.Errorf("Expected %d total and %d schedulable ephemeral storage, got %d and %d",
+			250*gi, 150*gi, status.TotalEphemeralStorage, status.SchedulableEphemeralStorage)
+	}
+	if status.SchedulableNodes != 3 {
+		t.Errorf("Expected 3 schedulable nodes, got %d", status.SchedulableNodes)
+	}
+}
+
+func TestComputeClusterStatusNodeWeights(t *testing.T) {
+	const instanceTypeLabel = "node.kubernetes.io/instance-type"
+	newNode := func(cores, instanceType string, unschedulable bool) *v1.Node {
+		node := &v1.Node{Spec: v1.NodeSpec{Unschedulable: unschedulable}}
+		if instanceType != "" {
+			node.Labels = map[string]string{instanceTypeLabel: instanceType}
+		}
+		node.Status.Allocatable = v1.ResourceList{v1.ResourceCPU: resource.MustParse(cores)}
+		return node
+	}
+	nodes := []interface{}{
+		newNode("16", "4xlarge", false),
+		newNode("2", "small", false),
+		newNode("2", "small", true),
+		newNode("3", "medium", false),
+		newNode("4", "", false),
+	}
+
+	testCases := []struct {
+		label               string
+		weights             map[string]float64
+		maxCoresPerNode     int64
+		expTotalCores       int32
+		expSchedulableCores int32
+	}{
+		{"", nil, 0, 27, 25},
+		{instanceTypeLabel, nil, 0, 27, 25},
+		{instanceTypeLabel, map[string]float64{"4xlarge": 2, "small": 0.5}, 0, 41, 40},
+		{instanceTypeLabel, map[string]float64{"4xlarge": 2}, 8, 27, 25},
+		{instanceTypeLabel, map[string]float64{"medium": 0.5}, 0, 26, 24},
+		{"", map[string]float64{"4xlarge": 2}, 0, 27, 25},
+	}
+
+	for _, tc := range testCases {
+		k := &k8sClient{nodeWeightLabel: tc.label, nodeWeights: tc.weights, maxCoresPerNode: tc.maxCoresPerNode}
+		status := k.computeClusterStatus(nodes)
+		if status.TotalCores != tc.expTotalCores || status.SchedulableCores != tc.expSchedulableCores {
+			t.Errorf("With label %q and weights %v expect %d total and %d schedulable cores, got %d and %d",
+				tc.label, tc.weights, tc.expTotalCores, tc.expSchedulableCores, status.TotalCores, status.SchedulableCores)
+		}
+	}
+}
+
+func TestComputeClusterStatusUnschedulableNodeWeight(t *testing.T) {
+	newNode := func(cores, memory string, unschedulable bool) *v1.Node {
+		node := &v1.Node{Spec: v1.NodeSpec{Unschedulable: unschedulable}}
+		node.Status.Allocatable = v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse(cores),
+			v1.ResourceMemory: resource.MustParse(memory),
+		}
+		return node
+	}
+	tainted := newNode("4", "1Gi", true)
+	tainted.Spec.Taints = []v1.Taint{{Key: "dedicated", Effect: v1.TaintEffectNoSchedule}}
+	nodes := []interface{}{
+		newNode("4", "1Gi", false),
+		newNode("4", "1Gi", false),
+		newNode("8", "2Gi", true),
+		newNode("8", "2Gi", true),
+		newNode("8", "2Gi", true),
+		tainted,
+	}
+
+	const gi = 1024 * 1024 * 1024
+	testCases := []struct {
+		weight              float64
+		expSchedulableNodes int32
+		expSchedulableCores int32
+		expSchedulableMem   int64
+	}{
+		// Like leaving the cordoned nodes out.
+		{0, 2, 8, 2 * gi},
+		// 2 + round(0.5*3) = 4 nodes, 8 + 0.5*24 = 20 cores.
+		{0.5, 4, 20, 5 * gi},
+		// 2 + round(0.25*3) = 3 nodes, 8 + 0.25*24 = 14 cores.
+		{0.25, 3, 14, 3*gi + gi/2},
+		// The tainted node isn't counted even at a weight of 1.
+		{1, 5, 32, 8 * gi},
+	}
+
+	for _, tc := range testCases {
+		k := &k8sClient{unschedulableNodeWeight: tc.weight, ignoreTaintedNodes: true}
+		status := k.computeClusterStatus(nodes)
+		if status.SchedulableNodes != tc.expSchedulableNodes || status.SchedulableCores != tc.expSchedulableCores || status.SchedulableMemory != tc.expSchedulableMem {
+			t.Errorf("With weight %v expect %d schedulable nodes, %d cores and %d memory, got %d, %d and %d",
+				tc.weight, tc.expSchedulableNodes, tc.expSchedulableCores, tc.expSchedulableMem,
+				status.SchedulableNodes, status.SchedulableCores, status.SchedulableMemory)
+		}
+		if status.TotalNodes != 6 || status.TotalCores != 36 || status.UnschedulableNodes != 4 {
+			t.Errorf("With weight %v expect the total and unschedulable nodes unweighted, got %+v", tc.weight, status)
+		}
+	}
+}
+
+func TestComputeClusterStatusScaleResources(t *testing.T) {
+	const fpgaResourceName = "example.com/fpga"
+	newNode := func(fpgas string, unschedulable bool) *v1.Node {
+		node := &v1.Node{}
+		node.Spec.Unschedulable = unschedulable
+		node.Status.Allocatable = v1.ResourceList{v1.ResourceCPU: resource.MustParse("4")}
+		if fpgas != "" {
+			node.Status.Allocatable[fpgaResourceName] = resource.MustParse(fpgas)
+		}
+		return node
+	}
+	nodes := []interface{}{
+		newNode("4", false),
+		newNode("2", true),
+		newNode("", false),
+	}
+
+	scaleResources := []ScaleResource{
+		{Name: fpgaResourceName, PerReplica: 4},
+		{Name: "example.com/missing", PerReplica: 1},
+	}
+	k := &k8sClient{scaleResources: scaleResources}
+	status := k.computeClusterStatus(nodes)
+	expScaleResources := []ScaleResourceStatus{
+		{ScaleResource: scaleResources[0], Total: 6, Schedulable: 4},
+		{ScaleResource: scaleResources[1], Total: 0, Schedulable: 0},
+	}
+	if !reflect.DeepEqual(status.ScaleResources, expScaleResources) {
+		t.Errorf("Expect scale resources %+v, got %+v", expScaleResources, status.ScaleResources)
+	}
+}
+
+func TestListNodesFromInformer(t *testing.T) {
+	nodeList := &v1.NodeList{
+		Items: []v1.Node{
+			{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}},
+		},
+	}
+	nodeListWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return nodeList, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+	}
+	nodeInformer := cache.NewSharedIndexInformer(nodeListWatch, &v1.Node{}, 0, cache.Indexers{})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go nodeInformer.Run(stopCh)
+
+	k := &k8sClient{nodeInformer: nodeInformer}
+	nodes, err := k.listNodes()
+	if err != nil {
+		t.Fatalf("Unexpected error listing nodes: %v", err)
+	}
+	if len(nodes) != len(nodeList.Items) {
+		t.Errorf("Expect %d nodes from informer cache, got %d", len(nodeList.Items), len(nodes))
+	}
+}
+
+func TestGetClusterStatusCountsNamespaces(t *testing.T) {
+	newInformer := func(list runtime.Object, objType runtime.Object) cache.SharedIndexInformer {
+		return cache.NewSharedIndexInformer(&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return list, nil
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return watch.NewFake(), nil
+			},
+		}, objType, 0, cache.Indexers{})
+	}
+	nodeInformer := newInformer(&v1.NodeList{Items: []v1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}}}, &v1.Node{})
+	namespaceInformer := newInformer(&v1.NamespaceList{
+		Items: []v1.Namespace{
+			{ObjectMeta: metav1.ObjectMeta{Name: "tenant-a"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "tenant-b"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "tenant-c"}},
+		},
+	}, &v1.Namespace{})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go nodeInformer.Run(stopCh)
+	go namespaceInformer.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, namespaceInformer.HasSynced) {
+		t.Fatalf("Namespace informer did not sync")
+	}
+
+	k := &k8sClient{nodeInformer: nodeInformer, namespaceInformer: namespaceInformer}
+	status, err := k.GetClusterStatus()
+	if err != nil {
+		t.Fatalf("Unexpected error getting the cluster status: %v", err)
+	}
+	if status.MatchingNamespaces != 3 {
+		t.Errorf("Expect 3 namespaces, got %d", status.MatchingNamespaces)
+	}
+}
+
+func TestComputeClusterStatusWithTaints(t *testing.T) {
+	newTaintedNode := func(taints ...v1.Taint) *v1.Node {
+		node := &v1.Node{}
+		node.Spec.Taints = taints
+		node.Status.Allocatable = v1.ResourceList{
+			v1.ResourceCPU: resource.MustParse("4"),
+		}
+		return node
+	}
+	nodes := []interface{}{
+		newTaintedNode(),
+		newTaintedNode(v1.Taint{Key: "dedicated", Effect: v1.TaintEffectNoSchedule}),
+		newTaintedNode(v1.Taint{Key: "dedicated", Effect: v1.TaintEffectNoExecute}),
+		newTaintedNode(v1.Taint{Key: "dedicated", Effect: v1.TaintEffectPreferNoSchedule}),
+		newTaintedNode(v1.Taint{Key: "tolerated", Effect: v1.TaintEffectNoSchedule}),
+	}
+
+	testCases := []struct {
+		ignoreTaintedNodes  bool
+		expSchedulableNodes int32
+		expSchedulableCores int32
+	}{
+		{false, 5, 20},
+		{true, 3, 12},
+	}
+
+	for _, tc := range testCases {
+		k := &k8sClient{
+			ignoreTaintedNodes: tc.ignoreTaintedNodes,
+			toleratedTaintKeys: sets.NewString("tolerated"),
+		}
+		status := k.computeClusterStatus(nodes)
+		if status.TotalNodes != 5 || status.TotalCores != 20 {
+			t.Errorf("Expect tainted nodes to be counted in totals, got %d nodes and %d cores", status.TotalNodes, status.TotalCores)
+		}
+		if status.UnschedulableNodes != 0 {
+			t.Errorf("Expect tainted nodes not to be counted as unschedulable, got %d", status.UnschedulableNodes)
+		}
+		if status.SchedulableNodes != tc.expSchedulableNodes || status.SchedulableCores != tc.expSchedulableCores {
+			t.Errorf("With ignoreTaintedNodes=%v expect %d schedulable nodes and %d cores, got %d and %d",
+				tc.ignoreTaintedNodes, tc.expSchedulableNodes, tc.expSchedulableCores, status.SchedulableNodes, status.SchedulableCores)
+		}
+	}
+}
+
+func TestComputeClusterStatusReadyNodesOnly(t *testing.T) {
+	newNode := func(ready *v1.ConditionStatus, unschedulable bool) *v1.Node {
+		node := &v1.Node{}
+		node.Spec.Unschedulable = unschedulable
+		node.Status.Allocatable = v1.ResourceList{v1.ResourceCPU: resource.MustParse("4")}
+		if ready != nil {
+			node.Status.Conditions = []v1.NodeCondition{
+				{Type: v1.NodeMemoryPressure, Status: v1.ConditionFalse},
+				{Type: v1.NodeReady, Status: *ready},
+			}
+		}
+		return node
+	}
+	readyTrue, readyFalse, readyUnknown := v1.ConditionTrue, v1.ConditionFalse, v1.ConditionUnknown
+	nodes := []interface{}{
+		newNode(&readyTrue, false),
+		newNode(&readyTrue, true),
+		newNode(&readyFalse, false),
+		newNode(&readyUnknown, false),
+		newNode(nil, false),
+	}
+
+	testCases := []struct {
+		readyNodesOnly      bool
+		expTotalNodes       int32
+		expSchedulableNodes int32
+		expSchedulableCores int32
+	}{
+		{false, 5, 4, 16},
+		// The cordoned Ready node is still counted in totals.
+		{true, 2, 1, 4},
+	}
+
+	for _, tc := range testCases {
+		k := &k8sClient{readyNodesOnly: tc.readyNodesOnly}
+		status := k.computeClusterStatus(nodes)
+		if status.TotalNodes != tc.expTotalNodes || status.SchedulableNodes != tc.expSchedulableNodes || status.SchedulableCores != tc.expSchedulableCores {
+			t.Errorf("With readyNodesOnly=%v expect %d total nodes, %d schedulable nodes and %d cores, got %d, %d and %d",
+				tc.readyNodesOnly, tc.expTotalNodes, tc.expSchedulableNodes, tc.expSchedulableCores, status.TotalNodes, status.SchedulableNodes, status.SchedulableCores)
+		}
+	}
+}
+
+func TestComputeClusterStatusZones(t *testing.T) {
+	newZonedNode := func(labels map[string]string, unschedulable bool) *v1.Node {
+		node := &v1.Node{}
+		node.Labels = labels
+		node.Spec.Unschedulable = unschedulable
+		return node
+	}
+	nodes := []interface{}{
+		newZonedNode(map[string]string{zoneLabel: "zone-a"}, false),
+		newZonedNode(map[string]string{zoneLabel: "zone-a"}, false),
+		newZonedNode(map[string]string{v1.LabelZoneFailureDomain: "zone-b"}, false),
+		newZonedNode(map[string]string{zoneLabel: "zone-c"}, true),
+		newZonedNode(nil, false),
+	}
+
+	k := &k8sClient{}
+	status := k.computeClusterStatus(nodes)
+	if status.TotalZones != 3 {
+		t.Errorf("Expect 3 total zones, got %d", status.TotalZones)
+	}
+	if status.SchedulableZones != 2 {
+		t.Errorf("Expect 2 schedulable zones, got %d", status.SchedulableZones)
+	}
+}
+
+func TestComputeClusterStatusResourceSource(t *testing.T) {
+	node := &v1.Node{}
+	node.Status.Capacity = v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("8"),
+		v1.ResourceMemory: resource.MustParse("32Gi"),
+		v1.ResourcePods:   resource.MustParse("110"),
+	}
+	node.Status.Allocatable = v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("7500m"),
+		v1.ResourceMemory: resource.MustParse("30Gi"),
+		v1.ResourcePods:   resource.MustParse("100"),
+	}
+
+	testCases := []struct {
+		resourceSource string
+		expCores       int32
+		expMemory      int64
+		expPods        int32
+	}{
+		{"", 8, 30 * 1024 * 1024 * 1024, 100},
+		{ResourceSourceAllocatable, 8, 30 * 1024 * 1024 * 1024, 100},
+		{ResourceSourceCapacity, 8, 32 * 1024 * 1024 * 1024, 110},
+	}
+
+	for _, tc := range testCases {
+		k := &k8sClient{resourceSource: tc.resourceSource}
+		status := k.computeClusterStatus([]interface{}{node})
+		if status.SchedulableCores != tc.expCores || status.SchedulableMemory != tc.expMemory || status.SchedulablePods != tc.expPods {
+			t.Errorf("With source %q expect %d cores, %d memory and %d pods, got %d, %d and %d",
+				tc.resourceSource, tc.expCores, tc.expMemory, tc.expPods, status.SchedulableCores, status.SchedulableMemory, status.SchedulablePods)
+		}
+	}
+}
+
+func TestCountUnschedulablePods(t *testing.T) {
+	newPod := func(phase v1.PodPhase, conditions ...v1.PodCondition) interface{} {
+		return &v1.Pod{Status: v1.PodStatus{Phase: phase, Conditions: conditions}}
+	}
+	unschedulable := v1.PodCondition{Type: v1.PodScheduled, Status: v1.ConditionFalse, Reason: v1.PodReasonUnschedulable}
+	scheduled := v1.PodCondition{Type: v1.PodScheduled, Status: v1.ConditionTrue}
+	testCases := []struct {
+		pods     []interface{}
+		expCount int32
+	}{
+		{nil, 0},
+		{[]interface{}{newPod(v1.PodPending, unschedulable), newPod(v1.PodPending, unschedulable)}, 2},
+		// Pending pods being pulled or not looked at by the scheduler yet
+		// don't count.
+		{[]interface{}{newPod(v1.PodPending, unschedulable), newPod(v1.PodPending, scheduled), newPod(v1.PodPending)}, 1},
+		{[]interface{}{newPod(v1.PodRunning, unschedulable)}, 0},
+	}
+
+	for _, tc := range testCases {
+		if count := countUnschedulablePods(tc.pods); count != tc.expCount {
+			t.Errorf("Expect %d unschedulable pods for %v, got %d", tc.expCount, tc.pods, count)
+		}
+	}
+}
+
+func TestCountActivePods(t *testing.T) {
+	newPod := func(phase v1.PodPhase) v1.Pod {
+		return v1.Pod{Status: v1.PodStatus{Phase: phase}}
+	}
+	testCases := []struct {
+		pods     []v1.Pod
+		expCount int32
+	}{
+		{nil, 0},
+		{[]v1.Pod{newPod(v1.PodRunning), newPod(v1.PodPending), newPod(v1.PodUnknown)}, 3},
+		// Terminated pods, e.g. evicted ones, don't count.
+		{[]v1.Pod{newPod(v1.PodRunning), newPod(v1.PodSucceeded), newPod(v1.PodFailed)}, 1},
+	}
+
+	for _, tc := range testCases {
+		if count := countActivePods(tc.pods); count != tc.expCount {
+			t.Errorf("Expect %d active pods for %v, got %d", tc.expCount, tc.pods, count)
+		}
+	}
+}
+
+func TestPodDisruptionsAllowed(t *testing.T) {
+	pods := []v1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "dns", "tier": "kube-system"}}},
+		{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "dns", "tier": "kube-system"}}},
+	}
+	newPDB := func(matchLabels map[string]string, currentHealthy, desiredHealthy int32) policyv1beta1.PodDisruptionBudget {
+		return policyv1beta1.PodDisruptionBudget{
+			Spec: policyv1beta1.PodDisruptionBudgetSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: matchLabels},
+			},
+			Status: policyv1beta1.PodDisruptionBudgetStatus{
+				CurrentHealthy: currentHealthy,
+				DesiredHealthy: desiredHealthy,
+			},
+		}
+	}
+
+	testCases := []struct {
+		pdbs     []policyv1beta1.PodDisruptionBudget
+		expFound bool
+		expValue int32
+	}{
+		{nil, false, 0},
+		{[]policyv1beta1.PodDisruptionBudget{newPDB(map[string]string{"app": "other"}, 5, 1)}, false, 0},
+		// An empty selector doesn't count as matching.
+		{[]policyv1beta1.PodDisruptionBudget{newPDB(nil, 5, 1)}, false, 0},
+		{[]policyv1beta1.PodDisruptionBudget{newPDB(map[string]string{"app": "dns"}, 5, 3)}, true, 2},
+		// The most restrictive PDB wins.
+		{[]policyv1beta1.PodDisruptionBudget{
+			newPDB(map[string]string{"app": "dns"}, 5, 3),
+			newPDB(map[string]string{"tier": "kube-system"}, 5, 4),
+		}, true, 1},
+		// Already violated.
+		{[]policyv1beta1.PodDisruptionBudget{newPDB(map[string]string{"app": "dns"}, 2, 3)}, true, 0},
+	}
+
+	for i, tc := range testCases {
+		value, found := podDisruptionsAllowed(pods, tc.pdbs)
+		if found != tc.expFound || value != tc.expValue {
+			t.Errorf("Case %d: expected (%d, %v), got (%d, %v)", i, tc.expValue, tc.expFound, value, found)
+		}
+	}
+}
+
+// fakeScales serves a single scale and fails its first updates.
+type fakeScales struct {
+	replicas  int32
+	uid       types.UID
+	getErr    error
+	updateErr []error
+	gets      int
+	updates   int
+}
+
+func (f *fakeScales) Scales(namespace string) scale.ScaleInterface {
+	return f
+}
+
+func (f *fakeScales) Get(resource schema.GroupResource, name string) (*autoscalingv1.Scale, error) {
+	f.gets++
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	return &autoscalingv1.Scale{ObjectMeta: metav1.ObjectMeta{UID: f.uid}, Spec: autoscalingv1.ScaleSpec{Replicas: f.replicas}}, nil
+}
+
+func (f *fakeScales) Update(resource schema.GroupResource, s *autoscalingv1.Scale) (*autoscalingv1.Scale, error) {
+	f.updates++
+	if len(f.updateErr) > 0 {
+		err := f.updateErr[0]
+		f.updateErr = f.updateErr[1:]
+		return nil, err
+	}
+	f.replicas = s.Spec.Replicas
+	return s, nil
+}
+
+func TestUpdateReplicasRetriesOnConflict(t *testing.T) {
+	defer func(backoff wait.Backoff) { updateConflictBackoff = backoff }(updateConflictBackoff)
+	updateConflictBackoff.Duration = time.Millisecond
+
+	gr := schema.GroupResource{Group: "apps", Resource: "deployments"}
+	conflict := apierrors.NewConflict(gr, "target", errors.New("the object has been modified"))
+	testCases := []struct {
+		updateErr   []error
+		expErr      bool
+		expUpdates  int
+		expReplicas int32
+	}{
+		{nil, false, 1, 5},
+		// The scale is fetched again and updated after each conflict.
+		{[]error{conflict, conflict}, false, 3, 5},
+		// Retries are bounded.
+		{[]error{conflict, conflict, conflict, conflict}, true, 3, 2},
+		// Other errors are not retried.
+		{[]error{apierrors.NewBadRequest("bad")}, true, 1, 2},
+	}
+
+	for _, tc := range testCases {
+		scales := &fakeScales{replicas: 2, updateErr: tc.updateErr}
+		k := &k8sClient{
+			scaleClient:   scales,
+			clusterStatus: &ClusterStatus{},
+			scaleTargets: map[string]*scaleTarget{
+				"deployment/target": {resource: gr, name: "target", namespace: "default", resolved: true},
+			},
+		}
+		prevReplicas, err := k.UpdateReplicas("deployment/target", 5)
+		if tc.expErr != (err != nil) {
+			t.Errorf("For update errors %v expected error %v, got %v", tc.updateErr, tc.expErr, err)
+		}
+		if err == nil && prevReplicas != 2 {
+			t.Errorf("Expected 2 previous replicas, got %d", prevReplicas)
+		}
+		if scales.updates != tc.expUpdates || scales.gets != tc.expUpdates {
+			t.Errorf("For update errors %v expected %d gets and updates, got %d gets and %d updates", tc.updateErr, tc.expUpdates, scales.gets, scales.updates)
+		}
+		if scales.replicas != tc.expReplicas {
+			t.Errorf("For update errors %v expected %d replicas, got %d", tc.updateErr, tc.expReplicas, scales.replicas)
+		}
+	}
+}
+
+func TestCreateConfigMapRetries(t *testing.T) {
+	defer func(backoff wait.Backoff) { createConfigMapBackoff = backoff }(createConfigMapBackoff)
+	createConfigMapBackoff.Duration = time.Millisecond
+
+	gr := schema.GroupResource{Resource: "configmaps"}
+	throttled := apierrors.NewTooManyRequests("slow down", 1)
+	existing := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "params", ResourceVersion: "7"}}
+	testCases := []struct {
+		createErr  []error
+		expErr     bool
+		expCreates int
+		expGets    int
+		expVersion string
+	}{
+		{nil, false, 1, 0, "1"},
+		// Transient errors are retried with backoff.
+		{[]error{throttled, apierrors.NewServerTimeout(gr, "create", 1)}, false, 3, 0, "1"},
+		// Retries are bounded.
+		{[]error{throttled, throttled, throttled, throttled, throttled}, true, 5, 0, ""},
+		// A ConfigMap created meanwhile is read instead.
+		{[]error{throttled, apierrors.NewAlreadyExists(gr, "params")}, false, 2, 1, "7"},
+		// Other errors are not retried.
+		{[]error{apierrors.NewForbidden(gr, "params", errors.New("forbidden"))}, true, 1, 0, ""},
+	}
+
+	for _, tc := range testCases {
+		createErr := tc.createErr
+		var creates, gets int
+		cm, err := createConfigMap("default/params", func() (*v1.ConfigMap, error) {
+			creates++
+			if len(createErr) > 0 {
+				err := createErr[0]
+				createErr = createErr[1:]
+				return nil, err
+			}
+			return &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "params", ResourceVersion: "1"}}, nil
+		}, func() (*v1.ConfigMap, error) {
+			gets++
+			return existing, nil
+		})
+		if tc.expErr != (err != nil) {
+			t.Errorf("For create errors %v expected error %v, got %v", tc.createErr, tc.expErr, err)
+		}
+		if err == nil && cm.ResourceVersion != tc.expVersion {
+			t.Errorf("For create errors %v expected the ConfigMap at version %s, got %s", tc.createErr, tc.expVersion, cm.ResourceVersion)
+		}
+		if creates != tc.expCreates || gets != tc.expGets {
+			t.Errorf("For create errors %v expected %d creates and %d gets, got %d and %d", tc.createErr, tc.expCreates, tc.expGets, creates, gets)
+		}
+	}
+}
+
+func TestGetReplicas(t *testing.T) {
+	testCases := []struct {
+		resource schema.GroupResource
+		getErr   error
+		expErr   bool
+	}{
+		{schema.GroupResource{Group: "apps", Resource: "deployments"}, nil, false},
+		{schema.GroupResource{Group: "apps", Resource: "replicasets"}, nil, false},
+		{schema.GroupResource{Group: "apps", Resource: "statefulsets"}, nil, false},
+		{schema.GroupResource{Resource: "replicationcontrollers"}, nil, false},
+		{schema.GroupResource{Group: "example.com", Resource: "foosets"}, nil, false},
+		// Kinds not served by extensions/v1beta1 don't fall back to it.
+		{schema.GroupResource{Group: "apps", Resource: "statefulsets"}, apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "statefulsets/scale"}, "target", errors.New("forbidden")), true},
+		{schema.GroupResource{Resource: "replicationcontrollers"}, apierrors.NewForbidden(schema.GroupResource{Resource: "replicationcontrollers/scale"}, "target", errors.New("forbidden")), true},
+	}
+
+	for _, tc := range testCases {
+		scales := &fakeScales{replicas: 3, getErr: tc.getErr}
+		k := &k8sClient{
+			scaleClient: scales,
+			scaleTargets: map[string]*scaleTarget{
+				"target": {resource: tc.resource, name: "target", namespace: "default", resolved: true},
+			},
+		}
+		replicas, err := k.GetReplicas("target")
+		if tc.expErr != (err != nil) {
+			t.Errorf("For %v expected error %v, got %v", tc.resource, tc.expErr, err)
+		}
+		if err == nil && replicas != 3 {
+			t.Errorf("For %v expected 3 replicas, got %d", tc.resource, replicas)
+		}
+		if scales.gets != 1 {
+			t.Errorf("For %v expected the replicas to be read from the scale subresource once, got %d reads", tc.resource, scales.gets)
+		}
+	}
+}
+
+func TestPinTargetUID(t *testing.T) {
+	for _, pin := range []bool{false, true} {
+		scales := &fakeScales{replicas: 3, uid: "old"}
+		k := &k8sClient{
+			scaleClient:   scales,
+			clusterStatus: &ClusterStatus{},
+			pinTargetUID:  pin,
+			scaleTargets: map[string]*scaleTarget{
+				"deployment/target": {resource: schema.GroupResource{Group: "apps", Resource: "deployments"}, name: "target", namespace: "default", resolved: true},
+			},
+		}
+		if _, err := k.GetReplicas("deployment/target"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		// The target is recreated between the read and the update of its replicas.
+		scales.uid = "new"
+		_, err := k.UpdateReplicas("deployment/target", 5)
+		if pin != (err != nil) {
+			t.Errorf("With pinning %v expected error %v, got %v", pin, pin, err)
+		}
+		expUpdates := 1
+		if pin {
+			expUpdates = 0
+		}
+		if scales.updates != expUpdates {
+			t.Errorf("With pinning %v expected %d updates, got %d", pin, expUpdates, scales.updates)
+		}
+		target := k.scaleTargets["deployment/target"]
+		if target.uid != "new" {
+			t.Errorf("With pinning %v expected the UID of the recreated target to be recorded, got %q", pin, target.uid)
+		}
+		if target.resolved == pin {
+			t.Errorf("With pinning %v expected the target resolved %v, got %v", pin, !pin, target.resolved)
+		}
+	}
+}
+
+func TestPodMemoryRequest(t *testing.T) {
+	newContainer := func(memory string) v1.Container {
+		container := v1.Container{}
+		if memory != "" {
+			container.Resources.Requests = v1.ResourceList{v1.ResourceMemory: resource.MustParse(memory)}
+		}
+		return container
+	}
+	testCases := []struct {
+		containers     []v1.Container
+		initContainers []v1.Container
+		expRequest     int64
+	}{
+		{nil, nil, 0},
+		{[]v1.Container{newContainer("")}, nil, 0},
+		{[]v1.Container{newContainer("64Mi")}, nil, 64 * 1024 * 1024},
+		{[]v1.Container{newContainer("64Mi"), newContainer(""), newContainer("1Gi")}, nil, 1088 * 1024 * 1024},
+		{[]v1.Container{newContainer("64Mi")}, []v1.Container{newContainer("32Mi")}, 64 * 1024 * 1024},
+		{[]v1.Container{newContainer("64Mi")}, []v1.Container{newContainer("256Mi")}, 256 * 1024 * 1024},
+	}
+
+	for _, tc := range testCases {
+		spec := &v1.PodSpec{Containers: tc.containers, InitContainers: tc.initContainers}
+		if request := podMemoryRequest(spec); request != tc.expRequest {
+			t.Errorf("Expected a memory request of %d for %+v, got %d", tc.expRequest, spec, request)
 		}
 	}
 }
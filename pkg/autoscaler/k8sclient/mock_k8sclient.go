@@ -26,12 +26,47 @@ var _ = K8sClient(&MockK8sClient{})
 
 // MockK8sClient implements K8sClientInterface
 type MockK8sClient struct {
-	NumOfNodes        int
-	NumOfCores        int
-	NumOfReplicas     int
+	NumOfNodes       int
+	NumOfCores       int
+	NumOfMemory      int64
+	NumOfPods        int
+	NumOfGPUs        int
+	NumOfZones       int
+	ScaleResources   []ScaleResourceStatus
+	MatchingPods     int
+	PendingPods      int
+	Namespaces       int
+	DrainingNodes    int
+	NumOfReplicas    int
+	Targets          []string
+	TargetReplicas   map[string]int
+	UpdateReplicasFn func(target string, expReplicas int32) (int32, error)
+	Events           []string
+	// DisruptionsAllowed holds the disruptions allowed by the
+	// PodDisruptionBudget of each target, missing if there is none.
+	DisruptionsAllowed map[string]int32
+	// ReferencedReplicas holds the replicas of the resources referenced by
+	// the params, missing if they don't exist.
+	ReferencedReplicas map[string]int32
+	// DaemonSetDesiredPods holds the desired pods of the DaemonSets
+	// referenced by the params, missing if they don't exist.
+	DaemonSetDesiredPods map[string]int32
+	// PodMemoryRequests holds the memory request of the pods of each target,
+	// missing if none is set.
+	PodMemoryRequests map[string]int64
+	// TargetPods holds the pods matching the selector of each target,
+	// missing if there are none.
+	TargetPods map[string]int32
+	// Annotations holds the annotations of each target.
+	Annotations       map[string]map[string]string
 	ConfigMap         *v1.ConfigMap
 	FetchConfigMapFn  func(namespace, configmap string) (*v1.ConfigMap, error)
 	CreateConfigMapFn func(namespace, configmap string, params map[string]string) (*v1.ConfigMap, error)
+	FetchSecretFn     func(namespace, secret string) (*v1.Secret, error)
+	CreateSecretFn    func(namespace, secret string, params map[string]string) (*v1.Secret, error)
+	GetAnnotationsFn  func(target string) (map[string]string, error)
+	AnnotateTargetFn  func(target string, annotations map[string]string) error
+	NodeCountHandler  func(nodes int)
 }
 
 // FetchConfigMap mocks fetching the requested configmap from the Apiserver
@@ -58,9 +93,43 @@ func (k *MockK8sClient) UpdateConfigMap(namespace, configmap string, params map[
 	return nil, nil
 }
 
+// FetchSecret mocks fetching the requested secret from the Apiserver
+func (k *MockK8sClient) FetchSecret(namespace, secret string) (*v1.Secret, error) {
+	if k.FetchSecretFn != nil {
+		return k.FetchSecretFn(namespace, secret)
+	}
+	return nil, fmt.Errorf("secret not exist")
+}
+
+// CreateSecret mocks creating a secret with given namespace, name and params
+func (k *MockK8sClient) CreateSecret(namespace, secret string, params map[string]string) (*v1.Secret, error) {
+	if k.CreateSecretFn != nil {
+		return k.CreateSecretFn(namespace, secret, params)
+	}
+	return nil, nil
+}
+
 // GetClusterStatus mocks counting schedulable nodes and cores in the cluster
 func (k *MockK8sClient) GetClusterStatus() (*ClusterStatus, error) {
-	return &ClusterStatus{int32(k.NumOfNodes), int32(k.NumOfNodes), int32(k.NumOfCores), int32(k.NumOfCores)}, nil
+	return &ClusterStatus{
+		TotalNodes:         int32(k.NumOfNodes),
+		SchedulableNodes:   int32(k.NumOfNodes),
+		TotalCores:         int32(k.NumOfCores),
+		SchedulableCores:   int32(k.NumOfCores),
+		TotalMemory:        k.NumOfMemory,
+		SchedulableMemory:  k.NumOfMemory,
+		TotalPods:          int32(k.NumOfPods),
+		SchedulablePods:    int32(k.NumOfPods),
+		TotalGPUs:          int32(k.NumOfGPUs),
+		SchedulableGPUs:    int32(k.NumOfGPUs),
+		TotalZones:         int32(k.NumOfZones),
+		SchedulableZones:   int32(k.NumOfZones),
+		ScaleResources:     k.ScaleResources,
+		MatchingPods:       int32(k.MatchingPods),
+		PendingPods:        int32(k.PendingPods),
+		MatchingNamespaces: int32(k.Namespaces),
+		DrainingNodes:      int32(k.DrainingNodes),
+	}, nil
 }
 
 // GetNamespace mocks returning the namespace of target resource.
@@ -68,9 +137,101 @@ func (k *MockK8sClient) GetNamespace() string {
 	return ""
 }
 
+// GetTargets mocks returning the target resources, defaulting to a single target
+func (k *MockK8sClient) GetTargets() []string {
+	if len(k.Targets) == 0 {
+		return []string{"deployment/mock"}
+	}
+	return k.Targets
+}
+
+// GetReplicas mocks returning the current number of replicas for the resource
+func (k *MockK8sClient) GetReplicas(target string) (int32, error) {
+	if k.TargetReplicas != nil {
+		return int32(k.TargetReplicas[target]), nil
+	}
+	return int32(k.NumOfReplicas), nil
+}
+
+// GetReferencedReplicas mocks returning the number of replicas of a resource which needn't be a target
+func (k *MockK8sClient) GetReferencedReplicas(resource string) (int32, error) {
+	replicas, ok := k.ReferencedReplicas[resource]
+	if !ok {
+		return 0, &TargetNotFoundError{Target: resource, Err: fmt.Errorf("not found")}
+	}
+	return replicas, nil
+}
+
+// GetDaemonSetDesiredPods mocks returning the desired pods of a DaemonSet
+func (k *MockK8sClient) GetDaemonSetDesiredPods(daemonSet string) (int32, bool, error) {
+	desired, found := k.DaemonSetDesiredPods[daemonSet]
+	return desired, found, nil
+}
+
 // UpdateReplicas mocks updating the number of replicas for the resource and return the previous replicas count
-func (k *MockK8sClient) UpdateReplicas(expReplicas int32) (int32, error) {
+func (k *MockK8sClient) UpdateReplicas(target string, expReplicas int32) (int32, error) {
+	if k.UpdateReplicasFn != nil {
+		return k.UpdateReplicasFn(target, expReplicas)
+	}
+	if k.TargetReplicas != nil {
+		prevReplicas := int32(k.TargetReplicas[target])
+		k.TargetReplicas[target] = int(expReplicas)
+		return prevReplicas, nil
+	}
 	prevReplicas := int32(k.NumOfReplicas)
 	k.NumOfReplicas = int(expReplicas)
 	return prevReplicas, nil
 }
+
+// GetDisruptionsAllowed mocks returning the disruptions allowed by the PodDisruptionBudget of the target
+func (k *MockK8sClient) GetDisruptionsAllowed(target string) (int32, bool, error) {
+	disruptionsAllowed, found := k.DisruptionsAllowed[target]
+	return disruptionsAllowed, found, nil
+}
+
+// GetAnnotations mocks returning the annotations of the target resource
+func (k *MockK8sClient) GetAnnotations(target string) (map[string]string, error) {
+	if k.GetAnnotationsFn != nil {
+		return k.GetAnnotationsFn(target)
+	}
+	return k.Annotations[target], nil
+}
+
+// CountPods mocks counting the pods matching the selector of the target
+func (k *MockK8sClient) CountPods(target string) (int32, error) {
+	return k.TargetPods[target], nil
+}
+
+// AnnotateTarget mocks setting annotations on the target resource
+func (k *MockK8sClient) AnnotateTarget(target string, annotations map[string]string) error {
+	if k.AnnotateTargetFn != nil {
+		return k.AnnotateTargetFn(target, annotations)
+	}
+	if k.Annotations == nil {
+		k.Annotations = make(map[string]map[string]string)
+	}
+	if k.Annotations[target] == nil {
+		k.Annotations[target] = make(map[string]string)
+	}
+	for name, value := range annotations {
+		k.Annotations[target][name] = value
+	}
+	return nil
+}
+
+// GetPodMemoryRequest mocks returning the memory request of the pods of the target
+func (k *MockK8sClient) GetPodMemoryRequest(target string) (int64, bool, error) {
+	request, found := k.PodMemoryRequests[target]
+	return request, found, nil
+}
+
+// RecordEvent mocks recording an event on the target resource
+func (k *MockK8sClient) RecordEvent(target, eventType, reason, message string) {
+	k.Events = append(k.Events, fmt.Sprintf("%s %s %s %s", target, eventType, reason, message))
+}
+
+// SetNodeCountHandler mocks registering a handler of node count changes,
+// which tests may call through NodeCountHandler.
+func (k *MockK8sClient) SetNodeCountHandler(handler func(nodes int)) {
+	k.NodeCountHandler = handler
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sclient
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
+)
+
+// discoverableResources are the resources of the targets which can be
+// discovered across namespaces.
+var discoverableResources = sets.NewString("deployment", "deployments", "replicaset", "replicasets", "statefulset", "statefulsets")
+
+// objectInformer returns the informer of the objects of one of the
+// discoverableResources.
+func objectInformer(factory informers.SharedInformerFactory, resource string) cache.SharedIndexInformer {
+	switch strings.TrimSuffix(resource, "s") {
+	case "deployment":
+		return factory.Apps().V1().Deployments().Informer()
+	case "replicaset":
+		return factory.Apps().V1().ReplicaSets().Informer()
+	default:
+		return factory.Apps().V1().StatefulSets().Informer()
+	}
+}
+
+// ValidateDiscoverableTarget returns an error if target can't be discovered
+// across namespaces, which is supported for deployments, replicasets and
+// statefulsets only.
+func ValidateDiscoverableTarget(target string) error {
+	_, err := getDiscoverableTarget(target)
+	return err
+}
+
+func getDiscoverableTarget(target string) (*scaleTarget, error) {
+	scaleTarget, err := getScaleTarget(target, "")
+	if err != nil {
+		return nil, err
+	}
+	if !discoverableResources.Has(scaleTarget.resource.Resource) || scaleTarget.resource.Group != "apps" {
+		return nil, fmt.Errorf("target %s can't be discovered across namespaces, only deployments, replicasets and statefulsets can", target)
+	}
+	return scaleTarget, nil
+}
+
+// targetDiscovery discovers the targets across the namespaces matching a
+// label selector. The namespaces and the objects named as the targets are
+// both kept in informer caches, so that discovering the targets at each poll
+// doesn't hit the apiserver.
+type targetDiscovery struct {
+	namespaces cache.Store
+	// objects holds the objects named as each target in all namespaces.
+	objects map[string]cache.Store
+}
+
+// newTargetDiscovery starts the informers of the namespaces matching
+// namespaceSelector and of the objects named as the targets.
+func newTargetDiscovery(clientset kubernetes.Interface, targets []string, namespaceSelector string, stopCh <-chan struct{}) (*targetDiscovery, error) {
+	namespaceFactory := informers.NewSharedInformerFactoryWithOptions(clientset, 0, informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+		opts.LabelSelector = namespaceSelector
+	}))
+	d := &targetDiscovery{
+		namespaces: namespaceFactory.Core().V1().Namespaces().Informer().GetStore(),
+		objects:    make(map[string]cache.Store, len(targets)),
+	}
+	namespaceFactory.Start(stopCh)
+	for _, target := range targets {
+		scaleTarget, err := getDiscoverableTarget(target)
+		if err != nil {
+			return nil, err
+		}
+		// Only watch the objects with the name of the target.
+		factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0, informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", scaleTarget.name).String()
+		}))
+		d.objects[target] = objectInformer(factory, scaleTarget.resource.Resource).GetStore()
+		factory.Start(stopCh)
+	}
+	return d, nil
+}
+
+// discover returns the targets in the selected namespaces, in the format
+// <namespace>/<target>, ordered by target as given and then by namespace.
+func (d *targetDiscovery) discover(targets []string) []string {
+	var discovered []string
+	for _, target := range targets {
+		var namespaces []string
+		for _, obj := range d.objects[target].List() {
+			accessor, err := meta.Accessor(obj)
+			if err != nil {
+				continue
+			}
+			if _, selected, _ := d.namespaces.GetByKey(accessor.GetNamespace()); selected {
+				namespaces = append(namespaces, accessor.GetNamespace())
+			}
+		}
+		sort.Strings(namespaces)
+		for _, namespace := range namespaces {
+			discovered = append(discovered, NamespacedTarget(namespace, target))
+		}
+	}
+	return discovered
+}
+
+// NamespacedTarget returns the target in the given namespace, as returned by
+// GetTargets when the targets are discovered across namespaces.
+func NamespacedTarget(namespace, target string) string {
+	return namespace + "/" + target
+}
+
+// SplitNamespacedTarget returns the namespace of a target returned by
+// GetTargets and the target as given by --target, the namespace being empty
+// unless the targets are discovered across namespaces.
+func SplitNamespacedTarget(target string) (namespace, baseTarget string) {
+	if splits := strings.SplitN(target, "/", 3); len(splits) == 3 {
+		return splits[0], splits[1] + "/" + splits[2]
+	}
+	return "", target
+}
+
+// discoveredTargets returns the targets in the namespaces matching the target
+// namespace selector, keeping track of the newly discovered ones.
+func (k *k8sClient) discoveredTargets() []string {
+	targets := k.targetDiscovery.discover(k.targets)
+	for _, target := range targets {
+		if _, ok := k.scaleTargets[target]; ok {
+			continue
+		}
+		namespace, baseTarget := SplitNamespacedTarget(target)
+		scaleTarget, err := getScaleTarget(baseTarget, namespace)
+		if err != nil {
+			continue
+		}
+		logging.V(0).Infof("Discovered target %s", target)
+		k.scaleTargets[target] = scaleTarget
+	}
+	return targets
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sclient
+
+import (
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestGetTargetsDiscovered(t *testing.T) {
+	namespaces := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, name := range []string{"tenant-b", "tenant-a"} {
+		namespaces.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	deployments := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, namespace := range []string{"tenant-b", "tenant-a", "unselected"} {
+		deployments.Add(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: namespace}})
+	}
+	statefulSets := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	statefulSets.Add(&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "etcd", Namespace: "tenant-b"}})
+
+	k := &k8sClient{
+		targets:      []string{"statefulset/etcd", "deployment/coredns"},
+		scaleTargets: make(map[string]*scaleTarget),
+		targetDiscovery: &targetDiscovery{
+			namespaces: namespaces,
+			objects:    map[string]cache.Store{"statefulset/etcd": statefulSets, "deployment/coredns": deployments},
+		},
+	}
+	expTargets := []string{"tenant-b/statefulset/etcd", "tenant-a/deployment/coredns", "tenant-b/deployment/coredns"}
+	if targets := k.GetTargets(); !reflect.DeepEqual(targets, expTargets) {
+		t.Errorf("Expected targets %v, got %v", expTargets, targets)
+	}
+	if scaleTarget := k.scaleTargets["tenant-a/deployment/coredns"]; scaleTarget == nil || scaleTarget.namespace != "tenant-a" || scaleTarget.name != "coredns" {
+		t.Errorf("Expected the discovered target to be scaled in its namespace, got %+v", scaleTarget)
+	}
+
+	namespaces.Delete(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant-b"}})
+	expTargets = []string{"tenant-a/deployment/coredns"}
+	if targets := k.GetTargets(); !reflect.DeepEqual(targets, expTargets) {
+		t.Errorf("Expected targets %v once the namespace is no longer selected, got %v", expTargets, targets)
+	}
+}
+
+func TestSplitNamespacedTarget(t *testing.T) {
+	testCases := []struct {
+		target       string
+		expNamespace string
+		expTarget    string
+	}{
+		{"deployment/coredns", "", "deployment/coredns"},
+		{"tenant-a/deployment/coredns", "tenant-a", "deployment/coredns"},
+	}
+	for _, tc := range testCases {
+		if namespace, target := SplitNamespacedTarget(tc.target); namespace != tc.expNamespace || target != tc.expTarget {
+			t.Errorf("For %s expected namespace %q and target %s, got %q and %s", tc.target, tc.expNamespace, tc.expTarget, namespace, target)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sclient
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/version"
+)
+
+// UserAgent identifies the requests of the autoscaler, e.g. in the audit logs
+// of the apiserver.
+func UserAgent() string {
+	return fmt.Sprintf("cluster-proportional-autoscaler/%s (%s/%s)", version.VERSION, runtime.GOOS, runtime.GOARCH)
+}
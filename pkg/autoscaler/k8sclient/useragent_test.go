@@ -0,0 +1,31 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sclient
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/version"
+)
+
+func TestUserAgent(t *testing.T) {
+	userAgent := UserAgent()
+	if !strings.HasPrefix(userAgent, "cluster-proportional-autoscaler/"+version.VERSION+" (") {
+		t.Errorf("Expect the User-Agent to name the autoscaler and its version, got %q", userAgent)
+	}
+}
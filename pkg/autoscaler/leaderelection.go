@@ -0,0 +1,96 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
+)
+
+const (
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// newLeaseLock returns a Lease based lock identified by the hostname of
+// this autoscaler.
+func newLeaseLock(namespace, name string) (resourcelock.Interface, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.UserAgent = k8sclient.UserAgent()
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	id, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	return resourcelock.New(
+		resourcelock.LeasesResourceLock,
+		namespace,
+		name,
+		clientset.CoreV1(),
+		clientset.CoordinationV1(),
+		resourcelock.ResourceLockConfig{Identity: id},
+	)
+}
+
+// runWithLeaderElection blocks until this autoscaler acquires the lease and
+// then runs the autoscaling loop for as long as it holds the lease, or until
+// stopCtx is done.
+func (s *AutoScaler) runWithLeaderElection(stopCtx context.Context) {
+	ctx, cancel := context.WithCancel(stopCtx)
+	defer cancel()
+
+	logging.V(0).Infof("Waiting to acquire lease %s", s.leaseLock.Describe())
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            s.leaseLock,
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				logging.V(0).Infof("Acquired lease %s, start autoscaling", s.leaseLock.Describe())
+				leaderGauge.Set(1)
+				s.runLoop(ctx.Done())
+			},
+			OnStoppedLeading: func() {
+				leaderGauge.Set(0)
+				if stopCtx.Err() != nil {
+					logging.V(0).Infof("Released lease %s", s.leaseLock.Describe())
+					return
+				}
+				logging.Errorf("Lost lease %s, stop autoscaling", s.leaseLock.Describe())
+				s.exitFn()
+			},
+		},
+	})
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller/linearcontroller"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
+)
+
+// fakeLock is an in-memory resourcelock.Interface.
+type fakeLock struct {
+	m      sync.Mutex
+	record *resourcelock.LeaderElectionRecord
+}
+
+func (l *fakeLock) Get() (*resourcelock.LeaderElectionRecord, error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	if l.record == nil {
+		return nil, errors.NewNotFound(schema.GroupResource{Resource: "leases"}, "fake")
+	}
+	record := *l.record
+	return &record, nil
+}
+
+func (l *fakeLock) Create(ler resourcelock.LeaderElectionRecord) error {
+	return l.Update(ler)
+}
+
+func (l *fakeLock) Update(ler resourcelock.LeaderElectionRecord) error {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.record = &ler
+	return nil
+}
+
+func (l *fakeLock) holder() string {
+	l.m.Lock()
+	defer l.m.Unlock()
+	if l.record == nil {
+		return ""
+	}
+	return l.record.HolderIdentity
+}
+
+func (l *fakeLock) RecordEvent(string) {}
+
+func (l *fakeLock) Identity() string { return "fake-identity" }
+
+func (l *fakeLock) Describe() string { return "fake/lease" }
+
+func TestRun_LeaderElection(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    3,
+		NumOfReplicas: 1,
+		ConfigMap:     &testConfigMap,
+	}
+
+	lock := &fakeLock{}
+	stopped := make(chan struct{})
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		pollPeriod:          5 * time.Second,
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		readyCh:             make(chan<- struct{}, 1),
+		lastPollCycleHealth: newHealthInfo(),
+		healthServer:        mockHealthServer{},
+		leaseLock:           lock,
+		exitFn: func() {
+			t.Errorf("Unexpected exit on leadership loss")
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		autoScaler.Run(ctx)
+		close(stopped)
+	}()
+
+	t.Logf("Wait for the leader to scale replicas to 3\n")
+	if err := waitForReplicasNumberSatisfy(t, &mockK8s, 3); err != nil {
+		t.Fatalf("Timeout waiting for condition: %v", err)
+	}
+	if holder := lock.holder(); holder != lock.Identity() {
+		t.Errorf("Expected lease to be held by %q, got %q", lock.Identity(), holder)
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Timeout waiting for autoscaler to stop")
+	}
+	if err := wait.Poll(50*time.Millisecond, 3*time.Second, func() (bool, error) {
+		return lock.holder() == "", nil
+	}); err != nil {
+		t.Errorf("Expected lease to be released on stop, held by %q", lock.holder())
+	}
+}
@@ -0,0 +1,34 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"fmt"
+	"net"
+)
+
+// listen binds the TCP address given with flag, e.g. ":8080", "[::]:8080" or
+// "localhost:8080", at start up so that an address which can't be bound fails
+// fast, instead of the server failing later on in the background. Unspecified
+// hosts bind both IPv4 and IPv6 on dual-stack nodes.
+func listen(flag, addr string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on %s=%s: %v", flag, addr, err)
+	}
+	return listener, nil
+}
@@ -0,0 +1,36 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestListen(t *testing.T) {
+	listener, err := listen("--metrics-bind-address", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error listening on a free port: %v", err)
+	}
+	defer listener.Close()
+
+	// The port is taken now.
+	addr := listener.Addr().String()
+	if _, err := listen("--metrics-bind-address", addr); err == nil || !strings.Contains(err.Error(), "--metrics-bind-address="+addr) {
+		t.Errorf("Expected an error naming --metrics-bind-address=%s, got %v", addr, err)
+	}
+}
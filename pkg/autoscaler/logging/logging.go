@@ -0,0 +1,185 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logging wraps glog so that log lines can be written either in
+// glog's text format or as JSON objects.
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// FormatText writes log lines through glog, the default.
+	FormatText = "text"
+	// FormatJSON writes each log line as a JSON object to stderr.
+	FormatJSON = "json"
+)
+
+var (
+	mu sync.Mutex
+	// jsonOut is where JSON log lines are written, nil in text format.
+	jsonOut io.Writer
+	now     = time.Now
+)
+
+// IsValidFormat returns whether format is a supported log format.
+func IsValidFormat(format string) bool {
+	return format == FormatText || format == FormatJSON
+}
+
+// SetFormat sets the format of all subsequent log lines.
+func SetFormat(format string) error {
+	if !IsValidFormat(format) {
+		return fmt.Errorf("unknown log format %q, should be %s or %s", format, FormatText, FormatJSON)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	jsonOut = nil
+	if format == FormatJSON {
+		jsonOut = os.Stderr
+	}
+	return nil
+}
+
+// Verbose is a boolean type that implements Infof and InfoS like glog.Verbose.
+// Verbosity is still driven by glog's -v flag. Note that -vmodule matches this
+// file rather than the caller.
+type Verbose bool
+
+// V reports whether verbosity at the call site is at least the requested level.
+func V(level glog.Level) Verbose {
+	return Verbose(glog.V(level))
+}
+
+// Infof logs at info level if v is enabled.
+func (v Verbose) Infof(format string, args ...interface{}) {
+	if v {
+		output("info", fmt.Sprintf(format, args...), nil)
+	}
+}
+
+// InfoS logs msg with the given key/value pairs at info level if v is enabled.
+func (v Verbose) InfoS(msg string, keysAndValues ...interface{}) {
+	if v {
+		output("info", msg, keysAndValues)
+	}
+}
+
+// InfoS logs msg with the given key/value pairs at info level.
+func InfoS(msg string, keysAndValues ...interface{}) {
+	output("info", msg, keysAndValues)
+}
+
+// Warningf logs at warning level.
+func Warningf(format string, args ...interface{}) {
+	output("warning", fmt.Sprintf(format, args...), nil)
+}
+
+// Errorf logs at error level.
+func Errorf(format string, args ...interface{}) {
+	output("error", fmt.Sprintf(format, args...), nil)
+}
+
+// ErrorS logs msg with err and the given key/value pairs at error level.
+func ErrorS(err error, msg string, keysAndValues ...interface{}) {
+	output("error", msg, append([]interface{}{"err", err}, keysAndValues...))
+}
+
+// Fatal logs at fatal level and exits, like glog.Fatal.
+func Fatal(args ...interface{}) {
+	msg := fmt.Sprint(args...)
+	if out := jsonWriter(); out != nil {
+		output("fatal", msg, nil)
+		os.Exit(255)
+	}
+	glog.FatalDepth(1, msg)
+}
+
+func jsonWriter() io.Writer {
+	mu.Lock()
+	defer mu.Unlock()
+	return jsonOut
+}
+
+// output writes a log line for the caller of the exported function.
+func output(level, msg string, keysAndValues []interface{}) {
+	out := jsonWriter()
+	if out == nil {
+		if len(keysAndValues) > 0 {
+			msg = msg + " " + formatKeysAndValues(keysAndValues)
+		}
+		switch level {
+		case "warning":
+			glog.WarningDepth(2, msg)
+		case "error":
+			glog.ErrorDepth(2, msg)
+		default:
+			glog.InfoDepth(2, msg)
+		}
+		return
+	}
+
+	entry := map[string]interface{}{
+		"level": level,
+		"ts":    now().UTC().Format(time.RFC3339Nano),
+		"msg":   msg,
+	}
+	if _, file, line, ok := runtime.Caller(2); ok {
+		entry["caller"] = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	}
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := fmt.Sprint(keysAndValues[i])
+		var value interface{} = "(MISSING)"
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		entry[key] = value
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		data, _ = json.Marshal(map[string]string{"level": level, "msg": msg, "logError": err.Error()})
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	out.Write(append(data, '\n'))
+}
+
+// formatKeysAndValues renders key/value pairs as key=value for text logs.
+func formatKeysAndValues(keysAndValues []interface{}) string {
+	pairs := make([]string, 0, (len(keysAndValues)+1)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		var value interface{} = "(MISSING)"
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+		pairs = append(pairs, fmt.Sprintf("%v=%v", keysAndValues[i], value))
+	}
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetFormat(t *testing.T) {
+	defer SetFormat(FormatText)
+	for _, format := range []string{FormatText, FormatJSON} {
+		if err := SetFormat(format); err != nil {
+			t.Errorf("Unexpected error for format %q: %v", format, err)
+		}
+	}
+	if err := SetFormat("xml"); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	var buf bytes.Buffer
+	jsonOut = &buf
+	now = func() time.Time { return time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() {
+		jsonOut = nil
+		now = time.Now
+	}()
+
+	InfoS("Scaled target", "target", "deployment/foo", "desiredReplicas", 3, "schedulableNodes", 5)
+	ErrorS(errors.New("boom"), "Failed to scale", "target")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 log lines, got %d: %s", len(lines), buf.String())
+	}
+	testCases := []map[string]interface{}{
+		{
+			"level":            "info",
+			"ts":               "2019-07-01T12:00:00Z",
+			"msg":              "Scaled target",
+			"target":           "deployment/foo",
+			"desiredReplicas":  float64(3),
+			"schedulableNodes": float64(5),
+		},
+		{
+			"level":  "error",
+			"ts":     "2019-07-01T12:00:00Z",
+			"msg":    "Failed to scale",
+			"err":    "boom",
+			"target": "(MISSING)",
+		},
+	}
+	for i, expEntry := range testCases {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(lines[i], &entry); err != nil {
+			t.Errorf("Unexpected error decoding %s: %v", lines[i], err)
+			continue
+		}
+		if caller, _ := entry["caller"].(string); !strings.HasPrefix(caller, "logging_test.go:") {
+			t.Errorf("Expected the caller to be in logging_test.go, got %q", caller)
+		}
+		delete(entry, "caller")
+		if !reflect.DeepEqual(entry, expEntry) {
+			t.Errorf("Expected log line %v, got %v", expEntry, entry)
+		}
+	}
+}
+
+func TestFormatKeysAndValues(t *testing.T) {
+	testCases := []struct {
+		keysAndValues []interface{}
+		expResult     string
+	}{
+		{[]interface{}{"target", "deployment/foo", "desiredReplicas", 3}, "target=deployment/foo desiredReplicas=3"},
+		{[]interface{}{"target"}, "target=(MISSING)"},
+	}
+	for _, tc := range testCases {
+		if result := formatKeysAndValues(tc.keysAndValues); result != tc.expResult {
+			t.Errorf("Expected %q, got %q", tc.expResult, result)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "cluster_proportional_autoscaler"
+
+var (
+	currentReplicasGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "current_replicas",
+		Help:      "Number of replicas of the target after the last poll.",
+	}, []string{"target"})
+	desiredReplicasGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "desired_replicas",
+		Help:      "Number of replicas of the target computed by the controller at the last poll.",
+	}, []string{"target"})
+	bindingTermGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "binding_term_raw_replicas",
+		Help:      "Replicas of the target computed from the term which was the binding constraint at the last poll, before rounding and bounding.",
+	}, []string{"target", "term"})
+	schedulableNodesGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "schedulable_nodes",
+		Help:      "Number of schedulable nodes observed at the last poll.",
+	})
+	schedulableCoresGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "schedulable_cores",
+		Help:      "Number of schedulable cores observed at the last poll.",
+	})
+	matchingPodsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "matching_pods",
+		Help:      "Number of pods matching --backend-selector observed at the last poll.",
+	})
+	pendingPodsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "pending_pods",
+		Help:      "Number of unschedulable pending pods observed at the last poll, with --count-pending-pods set.",
+	})
+	namespacesGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "namespaces",
+		Help:      "Number of namespaces matching --namespace-selector observed at the last poll, with --count-namespaces set.",
+	})
+	scalingFrozenGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "scaling_frozen",
+		Help:      "Whether scaling was frozen at the last poll (1) because the node count dropped by more than --max-node-drop-fraction, or not (0).",
+	})
+	scaleOperationsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "scale_operations_total",
+		Help:      "Number of times the replicas of the target were changed.",
+	})
+	targetUnavailableCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "target_unavailable_total",
+		Help:      "Number of times a target could not be scaled because it was not found.",
+	}, []string{"target"})
+	syncFailuresGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "consecutive_sync_failures",
+		Help:      "Number of consecutive failed poll cycles.",
+	})
+	consecutiveScaleUpsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "consecutive_scale_ups",
+		Help:      "Number of scale ups of the target in a row, without any scale down.",
+	}, []string{"target"})
+	scaleUpsBlockedGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "scale_ups_blocked",
+		Help:      "Whether the scale ups of the target are blocked by --max-consecutive-scale-ups (1) or not (0).",
+	}, []string{"target"})
+	leaderGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "leader",
+		Help:      "Whether this autoscaler is running the autoscaling loop (1) or standing by for leadership (0).",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		currentReplicasGauge,
+		desiredReplicasGauge,
+		bindingTermGauge,
+		schedulableNodesGauge,
+		schedulableCoresGauge,
+		matchingPodsGauge,
+		pendingPodsGauge,
+		namespacesGauge,
+		scalingFrozenGauge,
+		scaleOperationsCounter,
+		targetUnavailableCounter,
+		syncFailuresGauge,
+		consecutiveScaleUpsGauge,
+		scaleUpsBlockedGauge,
+		leaderGauge,
+	)
+}
+
+func newMetricsServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return &http.Server{Addr: addr, Handler: mux}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller/linearcontroller"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPollUpdatesMetrics(t *testing.T) {
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"coresPerReplica": 4, "nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    3,
+		NumOfCores:    24,
+		NumOfReplicas: 1,
+		ConfigMap:     &testConfigMap,
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+	}
+
+	scaleOperations := testutil.ToFloat64(scaleOperationsCounter)
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+
+	expMetrics := []struct {
+		name     string
+		value    float64
+		expValue float64
+	}{
+		{"current_replicas", testutil.ToFloat64(currentReplicasGauge.WithLabelValues("deployment/mock")), 6},
+		{"desired_replicas", testutil.ToFloat64(desiredReplicasGauge.WithLabelValues("deployment/mock")), 6},
+		{"binding_term_raw_replicas", testutil.ToFloat64(bindingTermGauge.WithLabelValues("deployment/mock", "cores")), 6},
+		{"schedulable_nodes", testutil.ToFloat64(schedulableNodesGauge), 3},
+		{"schedulable_cores", testutil.ToFloat64(schedulableCoresGauge), 24},
+		{"scale_operations_total", testutil.ToFloat64(scaleOperationsCounter) - scaleOperations, 1},
+	}
+	for _, m := range expMetrics {
+		if m.value != m.expValue {
+			t.Errorf("Metric %s: expected %v, got %v", m.name, m.expValue, m.value)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
+)
+
+// minReactivePollInterval rate limits the polls triggered by node events, so
+// that a flood of them doesn't turn into a storm of scale operations.
+const minReactivePollInterval = 5 * time.Second
+
+// onNodeCountChange is called by the node informer with the number of nodes
+// whenever one is added or deleted. It triggers a poll once the number of
+// nodes moved by at least reactiveNodeDelta since the last poll. Triggers are
+// coalesced while a poll is pending.
+func (s *AutoScaler) onNodeCountChange(nodes int) {
+	atomic.StoreInt32(&s.observedNodes, int32(nodes))
+	delta := int32(nodes) - atomic.LoadInt32(&s.polledNodes)
+	if delta < 0 {
+		delta = -delta
+	}
+	if int(delta) < s.reactiveNodeDelta {
+		return
+	}
+	select {
+	case s.reactiveCh <- struct{}{}:
+		logging.V(2).Infof("Number of nodes changed by %d since the last poll, polling right away", delta)
+	default:
+	}
+}
+
+// markPolled records the number of nodes the node events are compared to.
+func (s *AutoScaler) markPolled() {
+	atomic.StoreInt32(&s.polledNodes, atomic.LoadInt32(&s.observedNodes))
+}
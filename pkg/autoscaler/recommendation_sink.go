@@ -0,0 +1,59 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
+)
+
+// recommendationSink pushes the status of each poll to an external endpoint,
+// e.g. for an advisory mode along with --dry-run. Like the scale webhook,
+// delivery is best-effort and never blocks polling: a report is dropped if the
+// previous one is still being sent.
+type recommendationSink struct {
+	url     string
+	client  *http.Client
+	sending int32
+}
+
+func newRecommendationSink(url string) *recommendationSink {
+	return &recommendationSink{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// publish sends the status in the background.
+func (rs *recommendationSink) publish(status *pollStatus) {
+	if !atomic.CompareAndSwapInt32(&rs.sending, 0, 1) {
+		logging.Warningf("Dropping the recommendations of the poll at %v: the previous ones are still being sent", status.Time)
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&rs.sending, 0)
+		if err := rs.send(status); err != nil {
+			logging.Warningf("Error sending the recommendations to the sink: %v", err)
+		}
+	}()
+}
+
+func (rs *recommendationSink) send(status *pollStatus) error {
+	return postJSON(rs.client, rs.url, nil, status)
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller/linearcontroller"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
+)
+
+func TestPollAPIServer_RecommendationSink(t *testing.T) {
+	reports := make(chan pollStatus, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var status pollStatus
+		if err := json.NewDecoder(req.Body).Decode(&status); err != nil {
+			t.Errorf("Unexpected error decoding the recommendations: %v", err)
+		}
+		reports <- status
+	}))
+	defer server.Close()
+
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    10,
+		NumOfReplicas: 2,
+		ConfigMap:     &testConfigMap,
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+		dryRun:              true,
+		recommendationSink:  newRecommendationSink(server.URL),
+	}
+
+	// The recommendations are sent at every poll, not only on changes.
+	for i := 0; i < 2; i++ {
+		if err := autoScaler.pollAPIServer(); err != nil {
+			t.Fatalf("Poll %d: unexpected failure: %v", i, err)
+		}
+		select {
+		case status := <-reports:
+			ts := status.Targets["deployment/mock"]
+			if ts == nil || ts.CurrentReplicas != 2 || ts.DesiredReplicas != 10 || status.ClusterStatus.SchedulableNodes != 10 || status.ControllerType != linearcontroller.ControllerType {
+				t.Errorf("Poll %d: unexpected recommendations %+v", i, status)
+			}
+		case <-time.After(wait.ForeverTestTimeout):
+			t.Fatalf("Poll %d: timeout waiting for the recommendations", i)
+		}
+		if err := wait.Poll(10*time.Millisecond, wait.ForeverTestTimeout, func() (bool, error) {
+			return atomic.LoadInt32(&autoScaler.recommendationSink.sending) == 0, nil
+		}); err != nil {
+			t.Fatalf("Poll %d: timeout waiting for the recommendations to be sent", i)
+		}
+	}
+	if mockK8s.NumOfReplicas != 2 {
+		t.Errorf("Expected the replicas to be left alone in dry run, got %d", mockK8s.NumOfReplicas)
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"sort"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
+)
+
+// Reload requests a poll right away, re-reading the params, e.g. on SIGHUP. It
+// never blocks, requests are coalesced while a reload is pending.
+func (s *AutoScaler) Reload() {
+	select {
+	case s.reloadCh <- struct{}{}:
+	default:
+		logging.V(2).Infof("A reload is already pending")
+	}
+}
+
+// reload polls and logs the keys of the params which changed since the last
+// poll.
+func (s *AutoScaler) reload() {
+	var before map[string]string
+	if s.lastConfigMap != nil {
+		before = s.lastConfigMap.Data
+	}
+	version := s.paramsVersion
+	logging.V(0).Infof("Reloading the params")
+	// Reloads don't wait for the backoff while targets are unavailable.
+	s.skippedPolls = s.unavailableBackoff
+	s.tryPollAPIServer()
+	if s.paramsVersion == version {
+		logging.V(0).Infof("Params unchanged at version %s", version)
+		return
+	}
+	var after map[string]string
+	if s.lastConfigMap != nil {
+		after = s.lastConfigMap.Data
+	}
+	logging.V(0).Infof("Params changed from version %s to %s, changed keys: %v", version, s.paramsVersion, changedKeys(before, after))
+}
+
+// changedKeys returns the sorted keys added, removed or updated from before to
+// after.
+func changedKeys(before, after map[string]string) []string {
+	var keys []string
+	for key, value := range after {
+		if oldValue, ok := before[key]; !ok || oldValue != value {
+			keys = append(keys, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
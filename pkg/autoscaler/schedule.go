@@ -0,0 +1,137 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller"
+
+	"github.com/robfig/cron/v3"
+)
+
+// scheduleLookbacks bounds how far back the last activation of a cron
+// expression is searched for, trying short periods first to keep frequent
+// expressions cheap.
+var scheduleLookbacks = []time.Duration{
+	time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+	31 * 24 * time.Hour,
+	366 * 24 * time.Hour,
+}
+
+// scheduleWindow overrides the min and/or max replicas from each activation of
+// its start cron expression until the following activation of its end one.
+type scheduleWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	Min   *int32 `json:"min,omitempty"`
+	Max   *int32 `json:"max,omitempty"`
+
+	start cron.Schedule
+	end   cron.Schedule
+}
+
+// replicaSchedule applies the min and max replicas of its active window on
+// top of the replicas computed by the controller.
+type replicaSchedule struct {
+	windows  []*scheduleWindow
+	location *time.Location
+}
+
+func parseSchedule(data []byte, location *time.Location) (*replicaSchedule, error) {
+	var windows []*scheduleWindow
+	if err := controller.DecodeParams(data, &windows); err != nil {
+		return nil, fmt.Errorf("could not parse schedule (%s)", err)
+	}
+	for i, w := range windows {
+		var err error
+		if w.start, err = cron.ParseStandard(w.Start); err != nil {
+			return nil, fmt.Errorf("invalid start %q of schedule window %d: %v", w.Start, i, err)
+		}
+		if w.end, err = cron.ParseStandard(w.End); err != nil {
+			return nil, fmt.Errorf("invalid end %q of schedule window %d: %v", w.End, i, err)
+		}
+		if w.Min == nil && w.Max == nil {
+			return nil, fmt.Errorf("schedule window %d must set at least one of min or max", i)
+		}
+		if (w.Min != nil && *w.Min < 0) || (w.Max != nil && *w.Max < 0) {
+			return nil, fmt.Errorf("min and max of schedule window %d cannot be negative", i)
+		}
+		if w.Min != nil && w.Max != nil && *w.Min > *w.Max {
+			return nil, fmt.Errorf("min of schedule window %d cannot be greater than max", i)
+		}
+	}
+	if location == nil {
+		location = time.UTC
+	}
+	return &replicaSchedule{windows: windows, location: location}, nil
+}
+
+// activeWindow returns the window active at now, the most recently started
+// one if windows overlap, or nil if no window is active.
+func (r *replicaSchedule) activeWindow(now time.Time) *scheduleWindow {
+	now = now.In(r.location)
+	var active *scheduleWindow
+	var activeStart time.Time
+	for _, w := range r.windows {
+		start, ok := lastActivation(w.start, now)
+		if !ok || !w.end.Next(start).After(now) {
+			continue
+		}
+		if active == nil || start.After(activeStart) {
+			active = w
+			activeStart = start
+		}
+	}
+	return active
+}
+
+// apply returns replicas bounded by the min and max of the window active at now.
+func (r *replicaSchedule) apply(replicas int32, now time.Time) int32 {
+	w := r.activeWindow(now)
+	if w == nil {
+		return replicas
+	}
+	if w.Min != nil && replicas < *w.Min {
+		replicas = *w.Min
+	}
+	if w.Max != nil && replicas > *w.Max {
+		replicas = *w.Max
+	}
+	return replicas
+}
+
+// lastActivation returns the last activation of schedule at or before now.
+func lastActivation(schedule cron.Schedule, now time.Time) (time.Time, bool) {
+	for _, lookback := range scheduleLookbacks {
+		last := schedule.Next(now.Add(-lookback))
+		if last.IsZero() || last.After(now) {
+			continue
+		}
+		for {
+			next := schedule.Next(last)
+			if next.IsZero() || next.After(now) {
+				return last, true
+			}
+			last = next
+		}
+	}
+	return time.Time{}, false
+}
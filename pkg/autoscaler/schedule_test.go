@@ -0,0 +1,95 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	testCases := []struct {
+		jsonData string
+		expError bool
+	}{
+		{`[{"start": "0 8 * * 1-5", "end": "0 18 * * 1-5", "min": 10}]`, false},
+		{`[{"start": "0 8 * * *", "end": "0 18 * * *", "min": 2, "max": 5}]`, false},
+		{`[]`, false},
+		{`[{"start": "0 8 * * *", "end": "0 18 * * *"}]`, true},
+		{`[{"start": "not a cron", "end": "0 18 * * *", "min": 2}]`, true},
+		{`[{"start": "0 8 * * *", "end": "", "min": 2}]`, true},
+		{`[{"start": "0 8 * * *", "end": "0 18 * * *", "min": -1}]`, true},
+		{`[{"start": "0 8 * * *", "end": "0 18 * * *", "min": 5, "max": 2}]`, true},
+		{`[{"start": "0 8 * * *", "end": "0 18 * * *", "minimum": 2}]`, true},
+	}
+
+	for _, tc := range testCases {
+		_, err := parseSchedule([]byte(tc.jsonData), time.UTC)
+		if err != nil && !tc.expError {
+			t.Errorf("Unexpected parse failure for %s: %v", tc.jsonData, err)
+		} else if err == nil && tc.expError {
+			t.Errorf("Unexpected parse success for %s, expected failure", tc.jsonData)
+		}
+	}
+}
+
+func TestScheduleApply(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Timezone database not available: %v", err)
+	}
+	schedule, err := parseSchedule([]byte(`[
+		{"start": "0 8 * * 1-5", "end": "0 18 * * 1-5", "min": 10},
+		{"start": "0 12 * * 1-5", "end": "0 13 * * 1-5", "min": 20},
+		{"start": "0 22 * * *", "end": "0 6 * * *", "max": 3}
+	]`), location)
+	if err != nil {
+		t.Fatalf("Unexpected parse failure: %v", err)
+	}
+
+	testCases := []struct {
+		now         time.Time
+		replicas    int32
+		expReplicas int32
+	}{
+		// Monday before business hours.
+		{time.Date(2019, 7, 22, 7, 0, 0, 0, location), 5, 5},
+		// Monday during business hours, raised to min.
+		{time.Date(2019, 7, 22, 9, 0, 0, 0, location), 5, 10},
+		// Already above min.
+		{time.Date(2019, 7, 22, 9, 0, 0, 0, location), 15, 15},
+		// The lunch window started most recently and overrides business hours.
+		{time.Date(2019, 7, 22, 12, 30, 0, 0, location), 5, 20},
+		// Back to the business hours window after lunch.
+		{time.Date(2019, 7, 22, 13, 30, 0, 0, location), 5, 10},
+		// Monday at the end of business hours.
+		{time.Date(2019, 7, 22, 18, 0, 0, 0, location), 5, 5},
+		// Saturday, no business hours.
+		{time.Date(2019, 7, 27, 9, 0, 0, 0, location), 5, 5},
+		// Overnight window crossing midnight caps max.
+		{time.Date(2019, 7, 27, 23, 0, 0, 0, location), 5, 3},
+		{time.Date(2019, 7, 28, 2, 0, 0, 0, location), 5, 3},
+		// Schedule is evaluated in its timezone: 9:00 UTC is 5:00 in New York.
+		{time.Date(2019, 7, 22, 9, 0, 0, 0, time.UTC), 5, 3},
+	}
+
+	for _, tc := range testCases {
+		if replicas := schedule.apply(tc.replicas, tc.now); replicas != tc.expReplicas {
+			t.Errorf("At %v expected %d replicas, got %d", tc.now, tc.expReplicas, replicas)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller/plugin"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
+)
+
+// SimulatedReplicas is the recommendation of the controller for a synthetic
+// cluster size.
+type SimulatedReplicas struct {
+	Nodes    int
+	Cores    int
+	Expected controller.ExpectedReplicas
+}
+
+// Simulate computes the replicas the controller of the params recommends for
+// each combination of nodes and cores, all schedulable, as at runtime before
+// the schedule and the flags bounding the replicas apply.
+func Simulate(params map[string]string, nodes, cores []int) ([]SimulatedReplicas, error) {
+	cont, err := plugin.EnsureController(nil, &v1.ConfigMap{Data: params})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]SimulatedReplicas, 0, len(nodes)*len(cores))
+	for _, n := range nodes {
+		for _, c := range cores {
+			status := &k8sclient.ClusterStatus{
+				TotalNodes:       int32(n),
+				SchedulableNodes: int32(n),
+				TotalCores:       int32(c),
+				SchedulableCores: int32(c),
+			}
+			expected, err := cont.GetExpectedReplicas(status, 0)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, SimulatedReplicas{Nodes: n, Cores: c, Expected: expected})
+		}
+	}
+	return results, nil
+}
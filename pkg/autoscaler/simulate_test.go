@@ -0,0 +1,57 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	params := map[string]string{
+		"linear": `{"nodesPerReplica": 16, "coresPerReplica": 256, "min": 2}`,
+	}
+	results, err := Simulate(params, []int{0, 50, 100}, []int{0, 1600})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []struct {
+		nodes, cores int
+		replicas     int32
+	}{
+		{0, 0, 2},
+		{0, 1600, 7},
+		{50, 0, 4},
+		{50, 1600, 7},
+		{100, 0, 7},
+		{100, 1600, 7},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+	}
+	for i, exp := range expected {
+		r := results[i]
+		if r.Nodes != exp.nodes || r.Cores != exp.cores || r.Expected.Replicas != exp.replicas {
+			t.Errorf("Expected %d replicas for %d nodes and %d cores, got %d for %d nodes and %d cores",
+				exp.replicas, exp.nodes, exp.cores, r.Expected.Replicas, r.Nodes, r.Cores)
+		}
+	}
+
+	if _, err := Simulate(map[string]string{"linear": `{"nodesPerReplica": -1}`}, []int{0}, []int{0}); err == nil {
+		t.Errorf("Expected an error for invalid params")
+	}
+}
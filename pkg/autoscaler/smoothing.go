@@ -0,0 +1,55 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"math"
+)
+
+type nodeCountSample struct {
+	nodes int32
+	cores int32
+}
+
+// nodeCountSmoother averages the schedulable nodes and cores over the last
+// samples, one per poll, to dampen transient node churn. It is kept in memory
+// only, so a restart starts from an empty window.
+type nodeCountSmoother struct {
+	window  int
+	samples []nodeCountSample
+}
+
+func newNodeCountSmoother(window int) *nodeCountSmoother {
+	return &nodeCountSmoother{window: window}
+}
+
+// smooth records the observed nodes and cores and returns their averages,
+// rounded to the nearest integer, over the samples within the window.
+func (sm *nodeCountSmoother) smooth(nodes, cores int32) (int32, int32) {
+	sm.samples = append(sm.samples, nodeCountSample{nodes, cores})
+	if len(sm.samples) > sm.window {
+		sm.samples = sm.samples[len(sm.samples)-sm.window:]
+	}
+
+	var sumNodes, sumCores float64
+	for _, s := range sm.samples {
+		sumNodes += float64(s.nodes)
+		sumCores += float64(s.cores)
+	}
+	n := float64(len(sm.samples))
+	return int32(math.Round(sumNodes / n)), int32(math.Round(sumCores / n))
+}
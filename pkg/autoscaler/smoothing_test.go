@@ -0,0 +1,48 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"testing"
+)
+
+func TestNodeCountSmoother(t *testing.T) {
+	sm := newNodeCountSmoother(3)
+
+	testCases := []struct {
+		nodes    int32
+		cores    int32
+		expNodes int32
+		expCores int32
+	}{
+		// The window fills up from the first sample.
+		{10, 40, 10, 40},
+		{20, 80, 15, 60},
+		{10, 40, 13, 53},
+		// The first sample left the window.
+		{30, 120, 20, 80},
+		{30, 120, 23, 93},
+		{30, 120, 30, 120},
+	}
+
+	for i, tc := range testCases {
+		nodes, cores := sm.smooth(tc.nodes, tc.cores)
+		if nodes != tc.expNodes || cores != tc.expCores {
+			t.Errorf("Sample %d: observed %d nodes and %d cores, expected %d and %d, got %d and %d", i, tc.nodes, tc.cores, tc.expNodes, tc.expCores, nodes, cores)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+type timestampedRecommendation struct {
+	replicas  int32
+	timestamp time.Time
+}
+
+// scaleDownStabilizer delays scale downs until the lower recommendation
+// has been stable for the whole window. Scale ups are never delayed.
+type scaleDownStabilizer struct {
+	window          time.Duration
+	clock           clock.Clock
+	recommendations []timestampedRecommendation
+	seeded          bool
+}
+
+func newScaleDownStabilizer(window time.Duration, clock clock.Clock) *scaleDownStabilizer {
+	return &scaleDownStabilizer{window: window, clock: clock}
+}
+
+// seed records the replicas the target had when the autoscaler started, so
+// that a restart opens a fresh window instead of scaling down immediately.
+func (st *scaleDownStabilizer) seed(currentReplicas int32) {
+	st.recommendations = append(st.recommendations, timestampedRecommendation{currentReplicas, st.clock.Now()})
+	st.seeded = true
+}
+
+// stabilize records the recommendation and returns the highest one seen
+// within the window.
+func (st *scaleDownStabilizer) stabilize(recommended int32) int32 {
+	now := st.clock.Now()
+	cutoff := now.Add(-st.window)
+	kept := st.recommendations[:0]
+	for _, r := range st.recommendations {
+		if r.timestamp.After(cutoff) {
+			kept = append(kept, r)
+		}
+	}
+	st.recommendations = append(kept, timestampedRecommendation{recommended, now})
+
+	stabilized := recommended
+	for _, r := range st.recommendations {
+		if r.replicas > stabilized {
+			stabilized = r.replicas
+		}
+	}
+	return stabilized
+}
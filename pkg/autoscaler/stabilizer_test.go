@@ -0,0 +1,60 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestScaleDownStabilizer(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	pollPeriod := 10 * time.Second
+	st := newScaleDownStabilizer(30*time.Second, fakeClock)
+	st.seed(10)
+
+	testCases := []struct {
+		recommended int32
+		expReplicas int32
+	}{
+		// A restart must not scale down within the first window.
+		{5, 10},
+		{5, 10},
+		{5, 10},
+		// Window since the start has passed.
+		{5, 5},
+		// Scale ups are immediate.
+		{8, 8},
+		{6, 8},
+		{7, 8},
+		// The 8 recommendation left the window, 7 is still in it.
+		{6, 7},
+		{6, 7},
+		{6, 6},
+		{6, 6},
+		{12, 12},
+	}
+
+	for i, tc := range testCases {
+		if replicas := st.stabilize(tc.recommended); replicas != tc.expReplicas {
+			t.Errorf("Poll %d: recommended %d, expected %d, got %d", i, tc.recommended, tc.expReplicas, replicas)
+		}
+		fakeClock.Step(pollPeriod)
+	}
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/version"
+)
+
+const (
+	tracerName = "cluster-proportional-autoscaler"
+	// Span kinds and status codes of OTLP.
+	spanKindInternal = 1
+	spanKindClient   = 3
+	statusCodeError  = 2
+)
+
+// otlpTracer exports a trace of each poll to an OTLP/HTTP endpoint, encoded
+// in JSON. Like the recommendation sink, export is best-effort and never
+// blocks polling: a trace is dropped if the previous one is still being sent.
+type otlpTracer struct {
+	url     string
+	client  *http.Client
+	sending int32
+}
+
+// newOTLPTracer returns a tracer exporting to endpoint. An endpoint without a
+// path gets the default /v1/traces path of OTLP/HTTP.
+func newOTLPTracer(endpoint string) *otlpTracer {
+	if u, err := url.Parse(endpoint); err == nil && (u.Path == "" || u.Path == "/") {
+		u.Path = "/v1/traces"
+		endpoint = u.String()
+	}
+	return &otlpTracer{
+		url:    endpoint,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// traceSpan is a timed operation of a poll. All the methods are no-ops on a
+// nil span, which is what a nil tracer starts, so that polls are not slowed
+// down while tracing is disabled.
+type traceSpan struct {
+	tracer     *otlpTracer
+	root       *traceSpan
+	traceID    string
+	spanID     string
+	parentID   string
+	name       string
+	kind       int
+	start      time.Time
+	end        time.Time
+	attributes []otlpKeyValue
+	err        error
+	// spans holds the finished spans of the trace, on the root span only.
+	spans []*traceSpan
+}
+
+// startSpan starts the root span of a trace.
+func (t *otlpTracer) startSpan(name string, now time.Time) *traceSpan {
+	if t == nil {
+		return nil
+	}
+	s := &traceSpan{tracer: t, traceID: randomID(16), spanID: randomID(8), name: name, kind: spanKindInternal, start: now}
+	s.root = s
+	return s
+}
+
+// child starts a span of an API call within s.
+func (s *traceSpan) child(name string, now time.Time) *traceSpan {
+	if s == nil {
+		return nil
+	}
+	return &traceSpan{tracer: s.tracer, root: s.root, traceID: s.traceID, spanID: randomID(8), parentID: s.spanID, name: name, kind: spanKindClient, start: now}
+}
+
+// setAttribute sets an attribute of the span, value being a string, an int,
+// an int32 or a bool.
+func (s *traceSpan) setAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	var v otlpAnyValue
+	switch value := value.(type) {
+	case string:
+		v.StringValue = &value
+	case int:
+		i := strconv.Itoa(value)
+		v.IntValue = &i
+	case int32:
+		i := strconv.Itoa(int(value))
+		v.IntValue = &i
+	case bool:
+		v.BoolValue = &value
+	default:
+		return
+	}
+	s.attributes = append(s.attributes, otlpKeyValue{Key: key, Value: v})
+}
+
+// finish ends the span, failed if err is not nil. Finishing the root span
+// exports the trace.
+func (s *traceSpan) finish(now time.Time, err error) {
+	if s == nil {
+		return
+	}
+	s.end = now
+	s.err = err
+	s.root.spans = append(s.root.spans, s)
+	if s == s.root {
+		s.tracer.export(s.spans)
+	}
+}
+
+// export sends the spans in the background.
+func (t *otlpTracer) export(spans []*traceSpan) {
+	if !atomic.CompareAndSwapInt32(&t.sending, 0, 1) {
+		logging.Warningf("Dropping the trace of the poll at %v: the previous one is still being sent", spans[len(spans)-1].start)
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&t.sending, 0)
+		if err := t.send(spans); err != nil {
+			logging.Warningf("Error exporting the trace to the OTLP endpoint: %v", err)
+		}
+	}()
+}
+
+func (t *otlpTracer) send(spans []*traceSpan) error {
+	return postJSON(t.client, t.url, nil, newOTLPTraces(spans))
+}
+
+// randomID returns n random bytes, hex-encoded as the trace and span IDs of
+// OTLP/JSON.
+func randomID(n int) string {
+	id := make([]byte, n)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// otlpTraces is the ExportTraceServiceRequest of OTLP, in its JSON encoding.
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+// otlpAnyValue holds one of its values. 64-bit integers are encoded as
+// strings in OTLP/JSON.
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+func newOTLPTraces(spans []*traceSpan) *otlpTraces {
+	serviceName := tracerName
+	serviceVersion := version.VERSION
+	scopeSpans := otlpScopeSpans{Scope: otlpScope{Name: tracerName, Version: version.VERSION}}
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           s.traceID,
+			SpanID:            s.spanID,
+			ParentSpanID:      s.parentID,
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        s.attributes,
+		}
+		if s.err != nil {
+			span.Status = &otlpStatus{Code: statusCodeError, Message: s.err.Error()}
+		}
+		scopeSpans.Spans = append(scopeSpans.Spans, span)
+	}
+	return &otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{
+			{Key: "service.name", Value: otlpAnyValue{StringValue: &serviceName}},
+			{Key: "service.version", Value: otlpAnyValue{StringValue: &serviceVersion}},
+		}},
+		ScopeSpans: []otlpScopeSpans{scopeSpans},
+	}}}
+}
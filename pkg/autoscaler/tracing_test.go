@@ -0,0 +1,183 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller/linearcontroller"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
+)
+
+func TestNewOTLPTracer(t *testing.T) {
+	testCases := []struct {
+		endpoint string
+		expURL   string
+	}{
+		{"http://otel-collector:4318", "http://otel-collector:4318/v1/traces"},
+		{"http://otel-collector:4318/", "http://otel-collector:4318/v1/traces"},
+		{"https://traces.example.com/otlp/v1/traces", "https://traces.example.com/otlp/v1/traces"},
+	}
+
+	for _, tc := range testCases {
+		if tracer := newOTLPTracer(tc.endpoint); tracer.url != tc.expURL {
+			t.Errorf("For endpoint %s expected to export to %s, got %s", tc.endpoint, tc.expURL, tracer.url)
+		}
+	}
+}
+
+func TestPollAPIServer_Tracing(t *testing.T) {
+	traces := make(chan otlpTraces, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v1/traces" {
+			t.Errorf("Unexpected path %s", req.URL.Path)
+		}
+		var trace otlpTraces
+		if err := json.NewDecoder(req.Body).Decode(&trace); err != nil {
+			t.Errorf("Unexpected error decoding the trace: %v", err)
+		}
+		traces <- trace
+	}))
+	defer server.Close()
+
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    10,
+		NumOfCores:    40,
+		NumOfReplicas: 2,
+		ConfigMap:     &testConfigMap,
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+		tracer:              newOTLPTracer(server.URL),
+	}
+
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected failure: %v", err)
+	}
+	if autoScaler.pollSpan != nil {
+		t.Errorf("Expected the span of the poll to be cleared once finished")
+	}
+	var trace otlpTraces
+	select {
+	case trace = <-traces:
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Fatalf("Timeout waiting for the trace")
+	}
+
+	spans := make(map[string]otlpSpan)
+	for _, span := range trace.ResourceSpans[0].ScopeSpans[0].Spans {
+		spans[span.Name] = span
+	}
+	root, ok := spans["poll"]
+	if !ok || root.ParentSpanID != "" || len(root.TraceID) != 32 || len(root.SpanID) != 16 {
+		t.Fatalf("Unexpected root span %+v", root)
+	}
+	expAttributes := map[string]string{
+		"cpa.nodes":             "10",
+		"cpa.cores":             "40",
+		"cpa.replicas.expected": "10",
+		"cpa.replicas":          "10",
+		"cpa.scaled":            "true",
+	}
+	for key, expValue := range expAttributes {
+		if value := attributeValue(root.Attributes, key); value != expValue {
+			t.Errorf("Expected attribute %s of the poll span to be %s, got %q", key, expValue, value)
+		}
+	}
+	for _, name := range []string{"GetClusterStatus", "GetReplicas", "UpdateReplicas"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("Expected a %s span, got spans %v", name, spans)
+			continue
+		}
+		if span.TraceID != root.TraceID || span.ParentSpanID != root.SpanID || span.Kind != spanKindClient {
+			t.Errorf("Expected the %s span to be a client span within the poll span, got %+v", name, span)
+		}
+	}
+	if target := attributeValue(spans["UpdateReplicas"].Attributes, "cpa.target"); target != "deployment/mock" {
+		t.Errorf("Expected the UpdateReplicas span to be for deployment/mock, got %q", target)
+	}
+}
+
+func TestTraceSpanError(t *testing.T) {
+	tracer := &otlpTracer{}
+	now := time.Now()
+	root := tracer.startSpan("poll", now)
+	// Keep the trace from being exported.
+	tracer.sending = 1
+	root.child("GetClusterStatus", now).finish(now.Add(time.Second), errors.New("timeout"))
+	root.finish(now.Add(time.Second), errors.New("timeout"))
+
+	spans := newOTLPTraces(root.spans).ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %+v", spans)
+	}
+	for _, span := range spans {
+		if span.Status == nil || span.Status.Code != statusCodeError || span.Status.Message != "timeout" {
+			t.Errorf("Expected the %s span to have an error status, got %+v", span.Name, span.Status)
+		}
+	}
+
+	// Spans of a disabled tracer are nil and record nothing.
+	var disabled *otlpTracer
+	span := disabled.startSpan("poll", now)
+	span.child("GetClusterStatus", now).finish(now, nil)
+	span.setAttribute("cpa.nodes", 1)
+	span.finish(now, nil)
+	if span != nil {
+		t.Errorf("Expected no span without a tracer, got %+v", span)
+	}
+}
+
+// attributeValue returns the value of the attribute key, formatted as in JSON.
+func attributeValue(attributes []otlpKeyValue, key string) string {
+	for _, a := range attributes {
+		if a.Key != key {
+			continue
+		}
+		switch {
+		case a.Value.StringValue != nil:
+			return *a.Value.StringValue
+		case a.Value.IntValue != nil:
+			return *a.Value.IntValue
+		case a.Value.BoolValue != nil:
+			if *a.Value.BoolValue {
+				return "true"
+			}
+			return "false"
+		}
+	}
+	return ""
+}
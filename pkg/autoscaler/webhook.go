@@ -0,0 +1,111 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/logging"
+)
+
+// webhookTimeout bounds the delivery of a single scale notification.
+const webhookTimeout = 5 * time.Second
+
+// scaleNotification is the JSON payload POSTed to the scale webhook.
+type scaleNotification struct {
+	Target      string    `json:"target"`
+	OldReplicas int32     `json:"oldReplicas"`
+	NewReplicas int32     `json:"newReplicas"`
+	Nodes       int32     `json:"nodes"`
+	Cores       int32     `json:"cores"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// scaleWebhook notifies an external endpoint of scale changes. Delivery is
+// best-effort: notifications are sent asynchronously and failures are only
+// logged, so they never block scaling.
+type scaleWebhook struct {
+	url         string
+	headerName  string
+	headerValue string
+	client      *http.Client
+}
+
+// newScaleWebhook returns a webhook POSTing to url. authHeader, if not empty,
+// is a "Name: value" header sent along with each notification.
+func newScaleWebhook(url, authHeader string) *scaleWebhook {
+	w := &scaleWebhook{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+	if authHeader != "" {
+		parts := strings.SplitN(authHeader, ":", 2)
+		w.headerName = strings.TrimSpace(parts[0])
+		if len(parts) == 2 {
+			w.headerValue = strings.TrimSpace(parts[1])
+		}
+	}
+	return w
+}
+
+// notify sends the notification in the background.
+func (w *scaleWebhook) notify(n scaleNotification) {
+	go func() {
+		if err := w.send(n); err != nil {
+			logging.Warningf("Error notifying the scale webhook of the scaling of %s: %v", n.Target, err)
+		}
+	}()
+}
+
+func (w *scaleWebhook) send(n scaleNotification) error {
+	header := http.Header{}
+	if w.headerName != "" {
+		header.Set(w.headerName, w.headerValue)
+	}
+	return postJSON(w.client, w.url, header, n)
+}
+
+// postJSON POSTs the payload encoded in JSON to url, along with header, and
+// fails on any status other than 2xx.
+func postJSON(client *http.Client, url string, header http.Header, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/controller/linearcontroller"
+	"github.com/kubernetes-incubator/cluster-proportional-autoscaler/pkg/autoscaler/k8sclient"
+)
+
+func TestScaleWebhookSend(t *testing.T) {
+	var gotHeader string
+	var got scaleNotification
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotHeader = req.Header.Get("X-Auth-Token")
+		if err := json.NewDecoder(req.Body).Decode(&got); err != nil {
+			t.Errorf("Unexpected error decoding the notification: %v", err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	now := time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)
+	n := scaleNotification{Target: "deployment/dns", OldReplicas: 3, NewReplicas: 4, Nodes: 40, Cores: 160, Timestamp: now}
+	webhook := newScaleWebhook(server.URL, "X-Auth-Token: secret")
+	if err := webhook.send(n); err != nil {
+		t.Fatalf("Unexpected error sending the notification: %v", err)
+	}
+	if got != n {
+		t.Errorf("Expected notification %+v, got %+v", n, got)
+	}
+	if gotHeader != "secret" {
+		t.Errorf("Expected the auth header to be secret, got %q", gotHeader)
+	}
+
+	status = http.StatusInternalServerError
+	if err := webhook.send(n); err == nil {
+		t.Errorf("Expected an error on an unexpected status")
+	}
+}
+
+func TestPollAPIServer_ScaleWebhook(t *testing.T) {
+	notifications := make(chan scaleNotification, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var n scaleNotification
+		json.NewDecoder(req.Body).Decode(&n)
+		notifications <- n
+	}))
+	defer server.Close()
+
+	testConfigMap := v1.ConfigMap{
+		Data: map[string]string{
+			linearcontroller.ControllerType: `{"nodesPerReplica": 1}`,
+		},
+	}
+	testConfigMap.ObjectMeta.ResourceVersion = `1`
+	mockK8s := k8sclient.MockK8sClient{
+		NumOfNodes:    10,
+		NumOfCores:    40,
+		NumOfReplicas: 2,
+		ConfigMap:     &testConfigMap,
+	}
+	autoScaler := &AutoScaler{
+		k8sClient:           &mockK8s,
+		clock:               clock.NewFakeClock(time.Now()),
+		configMapName:       "fake-cluster-proportional-autoscaler-params",
+		lastPollCycleHealth: newHealthInfo(),
+		webhook:             newScaleWebhook(server.URL, ""),
+	}
+
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	select {
+	case n := <-notifications:
+		if n.Target != "deployment/mock" || n.OldReplicas != 2 || n.NewReplicas != 10 || n.Nodes != 10 || n.Cores != 40 {
+			t.Errorf("Unexpected notification %+v", n)
+		}
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Fatalf("Timeout waiting for the scale notification")
+	}
+
+	// No notification when the replicas are unchanged.
+	if err := autoScaler.pollAPIServer(); err != nil {
+		t.Fatalf("Unexpected poll failure: %v", err)
+	}
+	select {
+	case n := <-notifications:
+		t.Errorf("Unexpected notification %+v", n)
+	case <-time.After(100 * time.Millisecond):
+	}
+}